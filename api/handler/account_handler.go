@@ -2,11 +2,10 @@ package handler
 
 import (
     "encoding/json"
+    "io"
     "net/http"
-    "strconv"
     "transfer-service/model"
     "transfer-service/service"
-    "github.com/gorilla/mux"
 )
 
 type AccountHandler struct {
@@ -18,70 +17,49 @@ func NewAccountHandler(s *service.AccountService) *AccountHandler {
 }
 
 func (h *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
-    var acc model.Account
-    if err := json.NewDecoder(r.Body).Decode(&acc); err != nil {
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(model.APIResponse{
-            Success: false,
-            Message: "Invalid request body",
-            Error:   err.Error(),
-        })
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
         return
     }
 
-    // Get result from service
-    result := h.svc.CreateAccount(r.Context(), acc)
-    
-    // Pass through the service response
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(result.Status)
-    if result.Success {
-        json.NewEncoder(w).Encode(model.APIResponse{
-            Success: result.Success,
-            Message: result.Message,
-            Data:    result.Data,
-        })
-    } else {
-        json.NewEncoder(w).Encode(model.APIResponse{
-            Success: result.Success,
-            Message: result.Message,
-            Error:   result.Error,
-        })
+    key, err := idempotencyKey(r, body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid Idempotency-Key header", err)
+        return
     }
+
+    var acc model.Account
+    if err := json.Unmarshal(body, &acc); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    // Get result from service and pass it through
+    writeResult(w, h.svc.CreateAccountIdempotent(r.Context(), acc, key))
 }
 
 func (h *AccountHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
-    idStr := mux.Vars(r)["id"]
-    id, err := strconv.Atoi(idStr)
-    if err != nil {
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(model.APIResponse{
-            Success: false,
-            Message: "Invalid account ID",
-            Error:   err.Error(),
-        })
+    id, ok := pathAccountID(w, r, h.svc)
+    if !ok {
+        return
+    }
+
+    // Get result from service and pass it through
+    writeResult(w, h.svc.GetAccount(r.Context(), id))
+}
+
+func (h *AccountHandler) UpdateAccount(w http.ResponseWriter, r *http.Request) {
+    id, ok := pathAccountID(w, r, h.svc)
+    if !ok {
         return
     }
 
-    // Get result from service
-    result := h.svc.GetAccount(r.Context(), id)
-    
-    // Pass through the service response
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(result.Status)
-    if result.Success {
-        json.NewEncoder(w).Encode(model.APIResponse{
-            Success: result.Success,
-            Message: result.Message,
-            Data:    result.Data,
-        })
-    } else {
-        json.NewEncoder(w).Encode(model.APIResponse{
-            Success: result.Success,
-            Message: result.Message,
-            Error:   result.Error,
-        })
+    var upd service.AccountUpdate
+    if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
     }
+
+    writeResult(w, h.svc.UpdateAccount(r.Context(), id, upd))
 }
@@ -0,0 +1,18 @@
+package handler
+
+import (
+    "net/http"
+    "transfer-service/service"
+    "github.com/gorilla/mux"
+)
+
+// pathAccountID resolves the {id} path variable, either a public account ID or
+// a legacy integer ID. It writes the error response when no account matches.
+func pathAccountID(w http.ResponseWriter, r *http.Request, accounts *service.AccountService) (int, bool) {
+    id, result := accounts.ResolveAccountID(r.Context(), mux.Vars(r)["id"])
+    if result != nil {
+        writeResult(w, result)
+        return 0, false
+    }
+    return id, true
+}
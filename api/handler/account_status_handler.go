@@ -0,0 +1,45 @@
+package handler
+
+import (
+    "encoding/json"
+    "io"
+    "net/http"
+    "transfer-service/service"
+)
+
+// AccountStatusHandler serves the admin endpoints that freeze, reactivate and close accounts
+type AccountStatusHandler struct {
+    svc      *service.TransactionService
+    accounts *service.AccountService
+}
+
+func NewAccountStatusHandler(s *service.TransactionService, accounts *service.AccountService) *AccountStatusHandler {
+    return &AccountStatusHandler{svc: s, accounts: accounts}
+}
+
+func (h *AccountStatusHandler) ChangeStatus(w http.ResponseWriter, r *http.Request) {
+    id, ok := pathAccountID(w, r, h.accounts)
+    if !ok {
+        return
+    }
+
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    key, err := idempotencyKey(r, body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid Idempotency-Key header", err)
+        return
+    }
+
+    var req service.AccountStatusRequest
+    if err := json.Unmarshal(body, &req); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    writeResult(w, h.svc.ChangeAccountStatusIdempotent(r.Context(), id, req, key))
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+    "encoding/json"
+    "net/http"
+    "strconv"
+    "transfer-service/service"
+    "github.com/gorilla/mux"
+)
+
+type APIKeyHandler struct {
+    svc *service.APIKeyService
+}
+
+func NewAPIKeyHandler(s *service.APIKeyService) *APIKeyHandler {
+    return &APIKeyHandler{svc: s}
+}
+
+func (h *APIKeyHandler) Issue(w http.ResponseWriter, r *http.Request) {
+    var req service.APIKeyRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    writeResult(w, h.svc.IssueKey(r.Context(), req))
+}
+
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+    writeResult(w, h.svc.ListKeys(r.Context()))
+}
+
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid API key ID", err)
+        return
+    }
+
+    writeResult(w, h.svc.RevokeKey(r.Context(), id))
+}
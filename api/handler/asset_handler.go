@@ -0,0 +1,19 @@
+package handler
+
+import (
+    "net/http"
+    "transfer-service/service"
+)
+
+type AssetHandler struct {
+    svc *service.AssetService
+}
+
+func NewAssetHandler(s *service.AssetService) *AssetHandler {
+    return &AssetHandler{svc: s}
+}
+
+func (h *AssetHandler) ListAssets(w http.ResponseWriter, r *http.Request) {
+    // Get result from service and pass it through
+    writeResult(w, h.svc.ListAssets(r.Context()))
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+    "net/http"
+    "transfer-service/middleware"
+    "transfer-service/service"
+)
+
+// RejectUnauthenticated writes the response to a request the authenticator
+// turned away. It runs before the router, so it negotiates the format itself.
+func RejectUnauthenticated(w http.ResponseWriter, r *http.Request, err error) {
+    w = requestWriter(w, r)
+    if !middleware.IsAuthenticationError(err) {
+        // The credentials could not be checked, e.g. the database is down
+        writeFailure(w, http.StatusInternalServerError, "Failed to authenticate request", err.Error(), service.CodeInternal, nil)
+        return
+    }
+    writeError(w, http.StatusUnauthorized, "Authentication required", err)
+}
+
+// RejectRateLimited writes the response to a request over its rate limit.
+// It may run before the router, so it negotiates the format itself.
+func RejectRateLimited(w http.ResponseWriter, r *http.Request, err error) {
+    writeError(requestWriter(w, r), http.StatusTooManyRequests, "Too many requests; retry after the Retry-After delay", err)
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+    "encoding/json"
+    "io"
+    "net/http"
+    "strconv"
+    "transfer-service/model"
+    "transfer-service/service"
+    "github.com/gorilla/mux"
+)
+
+type CustomerHandler struct {
+    svc *service.CustomerService
+}
+
+func NewCustomerHandler(s *service.CustomerService) *CustomerHandler {
+    return &CustomerHandler{svc: s}
+}
+
+func (h *CustomerHandler) CreateCustomer(w http.ResponseWriter, r *http.Request) {
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    key, err := idempotencyKey(r, body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid Idempotency-Key header", err)
+        return
+    }
+
+    var c model.Customer
+    if err := json.Unmarshal(body, &c); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    // Get result from service and pass it through
+    writeResult(w, h.svc.CreateCustomerIdempotent(r.Context(), c, key))
+}
+
+func (h *CustomerHandler) ListCustomers(w http.ResponseWriter, r *http.Request) {
+    // Get result from service and pass it through
+    writeResult(w, h.svc.ListCustomers(r.Context()))
+}
+
+func (h *CustomerHandler) GetCustomer(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid customer ID", err)
+        return
+    }
+
+    writeResult(w, h.svc.GetCustomer(r.Context(), id))
+}
+
+func (h *CustomerHandler) UpdateCustomer(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid customer ID", err)
+        return
+    }
+
+    var upd service.CustomerUpdate
+    if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    writeResult(w, h.svc.UpdateCustomer(r.Context(), id, upd))
+}
+
+func (h *CustomerHandler) DeleteCustomer(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid customer ID", err)
+        return
+    }
+
+    writeResult(w, h.svc.DeleteCustomer(r.Context(), id))
+}
+
+func (h *CustomerHandler) GetCustomerAccounts(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid customer ID", err)
+        return
+    }
+
+    writeResult(w, h.svc.GetCustomerAccounts(r.Context(), id))
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+    "encoding/json"
+    "net/http"
+    "transfer-service/model"
+    "transfer-service/service"
+    "github.com/gorilla/mux"
+)
+
+// FeeHandler serves fee schedules and fee previews
+type FeeHandler struct {
+    svc *service.FeeService
+}
+
+func NewFeeHandler(s *service.FeeService) *FeeHandler {
+    return &FeeHandler{svc: s}
+}
+
+func (h *FeeHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+    // Get result from service and pass it through
+    writeResult(w, h.svc.ListSchedules(r.Context()))
+}
+
+func (h *FeeHandler) SetSchedule(w http.ResponseWriter, r *http.Request) {
+    var schedule model.FeeSchedule
+    if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+    schedule.AssetCode = mux.Vars(r)["asset"]
+
+    // Get result from service and pass it through
+    writeResult(w, h.svc.SetSchedule(r.Context(), schedule))
+}
+
+func (h *FeeHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+    // Get result from service and pass it through
+    writeResult(w, h.svc.DeleteSchedule(r.Context(), mux.Vars(r)["asset"]))
+}
+
+func (h *FeeHandler) Preview(w http.ResponseWriter, r *http.Request) {
+    var req model.Transaction
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    // Get result from service and pass it through
+    writeResult(w, h.svc.Preview(r.Context(), req))
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+    "encoding/json"
+    "net/http"
+    "transfer-service/model"
+    "transfer-service/service"
+)
+
+type FXHandler struct {
+    svc *service.FXService
+}
+
+func NewFXHandler(s *service.FXService) *FXHandler {
+    return &FXHandler{svc: s}
+}
+
+func (h *FXHandler) ListRates(w http.ResponseWriter, r *http.Request) {
+    // Get result from service and pass it through
+    writeResult(w, h.svc.ListRates(r.Context()))
+}
+
+func (h *FXHandler) SetRate(w http.ResponseWriter, r *http.Request) {
+    var rate model.FXRate
+    if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    // Get result from service and pass it through
+    writeResult(w, h.svc.SetRate(r.Context(), rate))
+}
+
+func (h *FXHandler) ListPositions(w http.ResponseWriter, r *http.Request) {
+    // Get result from service and pass it through
+    writeResult(w, h.svc.ListPositions(r.Context()))
+}
+
+func (h *FXHandler) SetPosition(w http.ResponseWriter, r *http.Request) {
+    var position model.FXPosition
+    if err := json.NewDecoder(r.Body).Decode(&position); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    // Get result from service and pass it through
+    writeResult(w, h.svc.SetPosition(r.Context(), position))
+}
+
+func (h *FXHandler) CreateQuote(w http.ResponseWriter, r *http.Request) {
+    var req model.FXQuote
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    // Get result from service and pass it through
+    writeResult(w, h.svc.CreateQuote(r.Context(), req))
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+    "bytes"
+    "encoding/json"
+    "io"
+    "net/http"
+    "strconv"
+    "transfer-service/model"
+    "transfer-service/service"
+    "github.com/gorilla/mux"
+)
+
+type HoldHandler struct {
+    svc *service.TransactionService
+}
+
+func NewHoldHandler(s *service.TransactionService) *HoldHandler {
+    return &HoldHandler{svc: s}
+}
+
+func (h *HoldHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    key, err := idempotencyKey(r, body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid Idempotency-Key header", err)
+        return
+    }
+
+    var hold model.Hold
+    if err := json.Unmarshal(body, &hold); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    writeResult(w, h.svc.AuthorizeIdempotent(r.Context(), hold, key))
+}
+
+func (h *HoldHandler) GetHold(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid hold ID", err)
+        return
+    }
+
+    writeResult(w, h.svc.GetHold(r.Context(), id))
+}
+
+// Capture settles a hold. An empty body captures the full held amount.
+func (h *HoldHandler) Capture(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid hold ID", err)
+        return
+    }
+
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    key, err := idempotencyKey(r, body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid Idempotency-Key header", err)
+        return
+    }
+
+    var req service.CaptureRequest
+    if len(bytes.TrimSpace(body)) > 0 {
+        if err := json.Unmarshal(body, &req); err != nil {
+            writeError(w, http.StatusBadRequest, "Invalid request body", err)
+            return
+        }
+    }
+
+    writeResult(w, h.svc.CaptureIdempotent(r.Context(), id, req, key))
+}
+
+func (h *HoldHandler) Void(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid hold ID", err)
+        return
+    }
+
+    writeResult(w, h.svc.Void(r.Context(), id))
+}
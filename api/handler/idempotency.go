@@ -0,0 +1,58 @@
+package handler
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "net/http"
+    "strings"
+    "transfer-service/model"
+)
+
+const (
+    idempotencyKeyHeader  = "Idempotency-Key"
+    maxIdempotencyKeySize = 255
+)
+
+var errInvalidIdempotencyKey = requestError("idempotency key must be at most 255 characters")
+
+// idempotencyKey reads the Idempotency-Key header and fingerprints the request
+// it was sent with. It returns nil when the header is absent.
+func idempotencyKey(r *http.Request, body []byte) (*model.IdempotencyKey, error) {
+    key := r.Header.Get(idempotencyKeyHeader)
+    if key == "" {
+        return nil, nil
+    }
+    if len(key) > maxIdempotencyKeySize {
+        return nil, errInvalidIdempotencyKey
+    }
+
+    // Ignore insignificant whitespace so a re-encoded retry still matches
+    var compact bytes.Buffer
+    if err := json.Compact(&compact, body); err != nil {
+        compact.Reset()
+        compact.Write(body)
+    }
+
+    h := sha256.New()
+    h.Write([]byte(r.Method + " " + routePath(r.URL.Path) + "\n"))
+    h.Write(compact.Bytes())
+
+    return &model.IdempotencyKey{
+        Key:         key,
+        Fingerprint: hex.EncodeToString(h.Sum(nil)),
+    }, nil
+}
+
+// routePath returns the path of a request without its /v1 or /v2 prefix, so
+// that a retry through another prefix of the same route matches. Stored
+// responses replay in either version.
+func routePath(path string) string {
+    for _, prefix := range []string{"/v1/", "/v2/"} {
+        if strings.HasPrefix(path, prefix) {
+            return path[len(prefix)-1:]
+        }
+    }
+    return path
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+    "encoding/json"
+    "net/http"
+    "transfer-service/model"
+    "transfer-service/service"
+    "github.com/gorilla/mux"
+)
+
+// LimitHandler serves limit tiers and per-account transfer limits
+type LimitHandler struct {
+    svc      *service.LimitService
+    accounts *service.AccountService
+}
+
+func NewLimitHandler(s *service.LimitService, accounts *service.AccountService) *LimitHandler {
+    return &LimitHandler{svc: s, accounts: accounts}
+}
+
+func (h *LimitHandler) ListTiers(w http.ResponseWriter, r *http.Request) {
+    // Get result from service and pass it through
+    writeResult(w, h.svc.ListTiers(r.Context()))
+}
+
+func (h *LimitHandler) SetTier(w http.ResponseWriter, r *http.Request) {
+    var tier model.LimitTier
+    if err := json.NewDecoder(r.Body).Decode(&tier); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+    tier.Name = mux.Vars(r)["name"]
+
+    // Get result from service and pass it through
+    writeResult(w, h.svc.SetTier(r.Context(), tier))
+}
+
+func (h *LimitHandler) SetAccountLimits(w http.ResponseWriter, r *http.Request) {
+    id, ok := pathAccountID(w, r, h.accounts)
+    if !ok {
+        return
+    }
+
+    var limits model.AccountLimits
+    if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+    limits.AccountID = id
+
+    // Get result from service and pass it through
+    writeResult(w, h.svc.SetAccountLimits(r.Context(), limits))
+}
+
+func (h *LimitHandler) GetAccountLimits(w http.ResponseWriter, r *http.Request) {
+    id, ok := pathAccountID(w, r, h.accounts)
+    if !ok {
+        return
+    }
+
+    // Get result from service and pass it through
+    writeResult(w, h.svc.GetAccountLimits(r.Context(), id))
+}
@@ -0,0 +1,20 @@
+package handler
+
+import (
+    "net/http"
+    "strconv"
+    "transfer-service/service"
+)
+
+// pageRequest reads the cursor and limit query parameters of a history endpoint
+func pageRequest(r *http.Request) (service.PageRequest, error) {
+    page := service.PageRequest{Cursor: r.URL.Query().Get("cursor")}
+    if v := r.URL.Query().Get("limit"); v != "" {
+        limit, err := strconv.Atoi(v)
+        if err != nil {
+            return page, err
+        }
+        page.Limit = limit
+    }
+    return page, nil
+}
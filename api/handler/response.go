@@ -0,0 +1,132 @@
+package handler
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "transfer-service/service"
+    "go.uber.org/zap"
+)
+
+// writeResult passes a service result through as the JSON API response
+func writeResult(w http.ResponseWriter, result *service.Result) {
+    if result.Replayed {
+        w.Header().Set("Idempotent-Replayed", "true")
+    }
+    if result.Status == http.StatusServiceUnavailable {
+        // Conflicts with concurrent transfers are safe to retry shortly
+        w.Header().Set("Retry-After", "1")
+    }
+    data := result.Data
+    if isAPIv2(w) {
+        data = model.ExactJSON(data)
+    }
+    if !result.Success {
+        // Data on a failure carries details of the rejection, such as a limit
+        writeFailure(w, result.Status, result.Message, result.Error, result.ErrorCode(), data)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(result.Status)
+    json.NewEncoder(w).Encode(model.APIResponse{
+        Success: result.Success,
+        Message: result.Message,
+        Data:    data,
+    })
+}
+
+// writeError writes a failed API response for a request rejected by the handler
+func writeError(w http.ResponseWriter, status int, message string, err error) {
+    writeFailure(w, status, message, clientDetail(err), service.ErrorCodeOf(err, status), nil)
+}
+
+// requestError is a reason to reject a request that the handler wrote for the client
+type requestError string
+
+func (e requestError) Error() string {
+    return string(e)
+}
+
+// invalidRequest formats a requestError
+func invalidRequest(format string, args ...interface{}) error {
+    return requestError(fmt.Sprintf(format, args...))
+}
+
+// clientDetail describes why a request was rejected. Errors from decoding and
+// parsing are described in the API's terms, as their own text names Go types
+// and library internals. Errors with a code and requestErrors were written for
+// the client; any other error is not described.
+func clientDetail(err error) string {
+    var reqErr requestError
+    var syntaxErr *json.SyntaxError
+    var typeErr *json.UnmarshalTypeError
+    var numErr *strconv.NumError
+    var timeErr *time.ParseError
+    switch {
+    case errors.As(err, &reqErr), service.HasErrorCode(err):
+        return err.Error()
+    case errors.As(err, &syntaxErr):
+        return fmt.Sprintf("malformed JSON at byte %d", syntaxErr.Offset)
+    case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+        return "body is empty or incomplete"
+    case errors.As(err, &typeErr) && typeErr.Field != "":
+        return fmt.Sprintf("%s cannot be a JSON %s", typeErr.Field, typeErr.Value)
+    case errors.As(err, &typeErr):
+        return "body cannot be a JSON " + typeErr.Value
+    case errors.As(err, &numErr):
+        return fmt.Sprintf("%q is not an integer", numErr.Num)
+    case errors.As(err, &timeErr):
+        return fmt.Sprintf("%q is not an RFC 3339 time", timeErr.Value)
+    }
+    return "request is malformed"
+}
+
+// writeFailure writes a failure as problem details or in the API envelope.
+// The detail of an internal error may name database internals, so it is
+// logged with the correlation ID and not sent.
+func writeFailure(w http.ResponseWriter, status int, message, detail string, code service.ErrorCode, data interface{}) {
+    correlationID := w.Header().Get(middleware.CorrelationIDHeader)
+    if status == http.StatusInternalServerError {
+        middleware.GetLogger().Error("Request failed",
+            zap.String("correlation_id", correlationID),
+            zap.String("code", string(code)),
+            zap.String("message", message),
+            zap.String("error", detail),
+        )
+        detail = "internal error"
+        if correlationID != "" {
+            detail += "; quote correlation ID " + correlationID + " when reporting it"
+        }
+    }
+
+    if wantsProblem(w) {
+        w.Header().Set("Content-Type", problemContentType)
+        w.WriteHeader(status)
+        json.NewEncoder(w).Encode(model.Problem{
+            Type:          "urn:transfer-service:error:" + strings.ToLower(strings.ReplaceAll(string(code), "_", "-")),
+            Title:         message,
+            Status:        status,
+            Detail:        detail,
+            Code:          string(code),
+            CorrelationID: correlationID,
+            Data:          data,
+        })
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(model.APIResponse{
+        Success: false,
+        Message: message,
+        Data:    data,
+        Error:   detail,
+        Code:    string(code),
+    })
+}
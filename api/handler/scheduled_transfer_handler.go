@@ -0,0 +1,61 @@
+package handler
+
+import (
+    "encoding/json"
+    "io"
+    "net/http"
+    "strconv"
+    "transfer-service/model"
+    "transfer-service/service"
+    "github.com/gorilla/mux"
+)
+
+type ScheduledTransferHandler struct {
+    svc *service.ScheduledTransferService
+}
+
+func NewScheduledTransferHandler(s *service.ScheduledTransferService) *ScheduledTransferHandler {
+    return &ScheduledTransferHandler{svc: s}
+}
+
+func (h *ScheduledTransferHandler) Schedule(w http.ResponseWriter, r *http.Request) {
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    key, err := idempotencyKey(r, body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid Idempotency-Key header", err)
+        return
+    }
+
+    var st model.ScheduledTransfer
+    if err := json.Unmarshal(body, &st); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    writeResult(w, h.svc.ScheduleIdempotent(r.Context(), st, key))
+}
+
+func (h *ScheduledTransferHandler) GetScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid scheduled transfer ID", err)
+        return
+    }
+
+    writeResult(w, h.svc.GetScheduledTransfer(r.Context(), id))
+}
+
+func (h *ScheduledTransferHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid scheduled transfer ID", err)
+        return
+    }
+
+    writeResult(w, h.svc.Cancel(r.Context(), id))
+}
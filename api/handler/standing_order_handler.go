@@ -0,0 +1,91 @@
+package handler
+
+import (
+    "encoding/json"
+    "io"
+    "net/http"
+    "strconv"
+    "transfer-service/model"
+    "transfer-service/service"
+    "github.com/gorilla/mux"
+)
+
+type StandingOrderHandler struct {
+    svc *service.StandingOrderService
+}
+
+func NewStandingOrderHandler(s *service.StandingOrderService) *StandingOrderHandler {
+    return &StandingOrderHandler{svc: s}
+}
+
+func (h *StandingOrderHandler) Create(w http.ResponseWriter, r *http.Request) {
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    key, err := idempotencyKey(r, body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid Idempotency-Key header", err)
+        return
+    }
+
+    var o model.StandingOrder
+    if err := json.Unmarshal(body, &o); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    writeResult(w, h.svc.CreateIdempotent(r.Context(), o, key))
+}
+
+func (h *StandingOrderHandler) GetStandingOrder(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid standing order ID", err)
+        return
+    }
+
+    writeResult(w, h.svc.GetStandingOrder(r.Context(), id))
+}
+
+func (h *StandingOrderHandler) GetRuns(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid standing order ID", err)
+        return
+    }
+
+    writeResult(w, h.svc.GetRuns(r.Context(), id))
+}
+
+func (h *StandingOrderHandler) Pause(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid standing order ID", err)
+        return
+    }
+
+    writeResult(w, h.svc.Pause(r.Context(), id))
+}
+
+func (h *StandingOrderHandler) Resume(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid standing order ID", err)
+        return
+    }
+
+    writeResult(w, h.svc.Resume(r.Context(), id))
+}
+
+func (h *StandingOrderHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid standing order ID", err)
+        return
+    }
+
+    writeResult(w, h.svc.Cancel(r.Context(), id))
+}
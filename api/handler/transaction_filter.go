@@ -0,0 +1,113 @@
+package handler
+
+import (
+    "net/http"
+    "time"
+    "transfer-service/model"
+    "transfer-service/service"
+    "github.com/shopspring/decimal"
+)
+
+// dateLayout is the layout of a date-only from or to query parameter
+const dateLayout = "2006-01-02"
+
+// transactionFilter reads and validates the filter query parameters of a
+// history endpoint. accountID is the account of /accounts/{id}/transactions,
+// nil on /transactions, where an account query parameter may set it instead.
+// It writes the error response when a parameter is invalid.
+func transactionFilter(w http.ResponseWriter, r *http.Request, accounts *service.AccountService, accountID *int) (model.TransactionFilter, bool) {
+    q := r.URL.Query()
+    filter := model.TransactionFilter{AccountID: accountID}
+
+    if ref := q.Get("account"); ref != "" && accountID == nil {
+        id, result := accounts.ResolveAccountID(r.Context(), ref)
+        if result != nil {
+            writeResult(w, result)
+            return filter, false
+        }
+        filter.AccountID = &id
+    }
+    if ref := q.Get("counterparty"); ref != "" {
+        id, result := accounts.ResolveAccountID(r.Context(), ref)
+        if result != nil {
+            writeResult(w, result)
+            return filter, false
+        }
+        filter.CounterpartyID = &id
+    }
+
+    filter.Direction = q.Get("direction")
+    switch filter.Direction {
+    case "", model.DirectionIncoming, model.DirectionOutgoing:
+    default:
+        writeError(w, http.StatusBadRequest, "Invalid direction", invalidRequest("direction must be %s or %s", model.DirectionIncoming, model.DirectionOutgoing))
+        return filter, false
+    }
+    if filter.AccountID == nil && (filter.Direction != "" || filter.CounterpartyID != nil) {
+        writeError(w, http.StatusBadRequest, "Invalid filter", requestError("direction and counterparty require an account"))
+        return filter, false
+    }
+
+    var err error
+    if filter.From, err = queryTime(q.Get("from"), false); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid from", err)
+        return filter, false
+    }
+    if filter.To, err = queryTime(q.Get("to"), true); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid to", err)
+        return filter, false
+    }
+    if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+        writeError(w, http.StatusBadRequest, "Invalid filter", requestError("from must be before to"))
+        return filter, false
+    }
+
+    if filter.MinAmount, err = queryAmount(q.Get("min_amount")); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid min_amount", err)
+        return filter, false
+    }
+    if filter.MaxAmount, err = queryAmount(q.Get("max_amount")); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid max_amount", err)
+        return filter, false
+    }
+    if filter.MinAmount != nil && filter.MaxAmount != nil && filter.MinAmount.GreaterThan(*filter.MaxAmount) {
+        writeError(w, http.StatusBadRequest, "Invalid filter", requestError("min_amount must not exceed max_amount"))
+        return filter, false
+    }
+
+    return filter, true
+}
+
+// queryTime parses an RFC 3339 time or a date. A date as the end of a range
+// includes the whole day.
+func queryTime(v string, end bool) (*time.Time, error) {
+    if v == "" {
+        return nil, nil
+    }
+    if t, err := time.Parse(time.RFC3339, v); err == nil {
+        return &t, nil
+    }
+    t, err := time.Parse(dateLayout, v)
+    if err != nil {
+        return nil, invalidRequest("%q is not an RFC 3339 time or a YYYY-MM-DD date", v)
+    }
+    if end {
+        t = t.AddDate(0, 0, 1)
+    }
+    return &t, nil
+}
+
+// queryAmount parses a non-negative decimal amount
+func queryAmount(v string) (*decimal.Decimal, error) {
+    if v == "" {
+        return nil, nil
+    }
+    d, err := decimal.NewFromString(v)
+    if err != nil {
+        return nil, invalidRequest("%q is not a decimal amount", v)
+    }
+    if d.IsNegative() {
+        return nil, requestError("amount cannot be negative")
+    }
+    return &d, nil
+}
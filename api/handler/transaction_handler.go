@@ -1,7 +1,9 @@
 package handler
 
 import (
+    "bytes"
     "encoding/json"
+    "io"
     "net/http"
     "strconv"
     "transfer-service/model"
@@ -10,100 +12,150 @@ import (
 )
 
 type TransactionHandler struct {
-    svc *service.TransactionService
+    svc      *service.TransactionService
+    accounts *service.AccountService
 }
 
-func NewTransactionHandler(s *service.TransactionService) *TransactionHandler {
-    return &TransactionHandler{svc: s}
+func NewTransactionHandler(s *service.TransactionService, accounts *service.AccountService) *TransactionHandler {
+    return &TransactionHandler{svc: s, accounts: accounts}
 }
 
 func (h *TransactionHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    key, err := idempotencyKey(r, body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid Idempotency-Key header", err)
+        return
+    }
+
     var tx model.Transaction
-    if err := json.NewDecoder(r.Body).Decode(&tx); err != nil {
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(model.APIResponse{
-            Success: false,
-            Message: "Invalid request body",
-            Error:   err.Error(),
-        })
-        return
-    }
-
-    // Get result from service
-    result := h.svc.Transfer(r.Context(), tx)
-    
-    // Pass through the service response
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(result.Status)
-    if result.Success {
-        json.NewEncoder(w).Encode(model.APIResponse{
-            Success: result.Success,
-            Message: result.Message,
-            Data:    result.Data,
-        })
-    } else {
-        json.NewEncoder(w).Encode(model.APIResponse{
-            Success: result.Success,
-            Message: result.Message,
-            Error:   result.Error,
-        })
+    if err := json.Unmarshal(body, &tx); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
     }
+
+    // Get result from service and pass it through
+    writeResult(w, h.svc.TransferIdempotent(r.Context(), tx, key))
+}
+
+// TransferBatch executes a list of transfer legs all-or-nothing
+func (h *TransactionHandler) TransferBatch(w http.ResponseWriter, r *http.Request) {
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    key, err := idempotencyKey(r, body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid Idempotency-Key header", err)
+        return
+    }
+
+    var req service.BatchRequest
+    if err := json.Unmarshal(body, &req); err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    writeResult(w, h.svc.TransferBatchIdempotent(r.Context(), req, key))
+}
+
+// Reverse refunds all or part of a transaction. An empty body reverses the
+// remaining amount.
+func (h *TransactionHandler) Reverse(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid transaction ID", err)
+        return
+    }
+
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid request body", err)
+        return
+    }
+
+    key, err := idempotencyKey(r, body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid Idempotency-Key header", err)
+        return
+    }
+
+    var req service.ReversalRequest
+    if len(bytes.TrimSpace(body)) > 0 {
+        if err := json.Unmarshal(body, &req); err != nil {
+            writeError(w, http.StatusBadRequest, "Invalid request body", err)
+            return
+        }
+    }
+
+    writeResult(w, h.svc.ReverseIdempotent(r.Context(), id, req, key))
 }
 
 func (h *TransactionHandler) GetTransactionHistory(w http.ResponseWriter, r *http.Request) {
-    // Get result from service
-    result := h.svc.GetTransactionHistory(r.Context())
-    
-    // Pass through the service response
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(result.Status)
-    if result.Success {
-        json.NewEncoder(w).Encode(model.APIResponse{
-            Success: result.Success,
-            Message: result.Message,
-            Data:    result.Data,
-        })
-    } else {
-        json.NewEncoder(w).Encode(model.APIResponse{
-            Success: result.Success,
-            Message: result.Message,
-            Error:   result.Error,
-        })
+    page, err := pageRequest(r)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid limit", err)
+        return
     }
+    filter, ok := transactionFilter(w, r, h.accounts, nil)
+    if !ok {
+        return
+    }
+
+    // Get result from service and pass it through
+    writeResult(w, h.svc.GetTransactionHistory(r.Context(), filter, page))
 }
 
 func (h *TransactionHandler) GetAccountTransactionHistory(w http.ResponseWriter, r *http.Request) {
-    idStr := mux.Vars(r)["id"]
-    id, err := strconv.Atoi(idStr)
+    id, ok := pathAccountID(w, r, h.accounts)
+    if !ok {
+        return
+    }
+
+    page, err := pageRequest(r)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid limit", err)
+        return
+    }
+    filter, ok := transactionFilter(w, r, h.accounts, &id)
+    if !ok {
+        return
+    }
+
+    // Get result from service and pass it through
+    writeResult(w, h.svc.GetAccountTransactionHistory(r.Context(), id, filter, page))
+}
+
+func (h *TransactionHandler) GetAccountStatement(w http.ResponseWriter, r *http.Request) {
+    id, ok := pathAccountID(w, r, h.accounts)
+    if !ok {
+        return
+    }
+
+    from, err := queryTime(r.URL.Query().Get("from"), false)
+    if err == nil && from == nil {
+        err = requestError("from is required")
+    }
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "Invalid from", err)
+        return
+    }
+    to, err := queryTime(r.URL.Query().Get("to"), true)
+    if err == nil && to == nil {
+        err = requestError("to is required")
+    }
     if err != nil {
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(model.APIResponse{
-            Success: false,
-            Message: "Invalid account ID",
-            Error:   err.Error(),
-        })
-        return
-    }
-
-    // Get result from service
-    result := h.svc.GetAccountTransactionHistory(r.Context(), id)
-    
-    // Pass through the service response
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(result.Status)
-    if result.Success {
-        json.NewEncoder(w).Encode(model.APIResponse{
-            Success: result.Success,
-            Message: result.Message,
-            Data:    result.Data,
-        })
-    } else {
-        json.NewEncoder(w).Encode(model.APIResponse{
-            Success: result.Success,
-            Message: result.Message,
-            Error:   result.Error,
-        })
+        writeError(w, http.StatusBadRequest, "Invalid to", err)
+        return
     }
+
+    // Get result from service and pass it through
+    writeResult(w, h.svc.GetAccountStatement(r.Context(), id, *from, *to))
 }
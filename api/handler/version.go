@@ -0,0 +1,56 @@
+package handler
+
+import (
+    "net/http"
+    "strings"
+)
+
+// problemContentType is the media type of RFC 9457 problem details
+const problemContentType = "application/problem+json"
+
+// apiWriter records how the response of a request is written
+type apiWriter struct {
+    http.ResponseWriter
+    exact    bool // amounts as exact decimal strings instead of floats (API v2)
+    problems bool // failures as problem details instead of the API envelope
+}
+
+// Negotiate is the middleware of every route. Clients that accept
+// application/problem+json get their failures as problem details.
+func Negotiate(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        next.ServeHTTP(&apiWriter{
+            ResponseWriter: w,
+            problems:       acceptsProblem(r),
+        }, r)
+    })
+}
+
+// requestWriter negotiates the format of a response written outside the
+// router, whose version middleware has not run
+func requestWriter(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+    v2 := strings.HasPrefix(r.URL.Path, "/v2/")
+    return &apiWriter{ResponseWriter: w, exact: v2, problems: v2 || acceptsProblem(r)}
+}
+
+func acceptsProblem(r *http.Request) bool {
+    return strings.Contains(r.Header.Get("Accept"), problemContentType)
+}
+
+// APIv2 is the middleware of the /v2 routes. The handlers are the same as
+// v1's; only writeResult changes how the data and failures are serialized.
+func APIv2(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        next.ServeHTTP(&apiWriter{ResponseWriter: w, exact: true, problems: true}, r)
+    })
+}
+
+func isAPIv2(w http.ResponseWriter) bool {
+    aw, ok := w.(*apiWriter)
+    return ok && aw.exact
+}
+
+func wantsProblem(w http.ResponseWriter) bool {
+    aw, ok := w.(*apiWriter)
+    return ok && aw.problems
+}
@@ -1,8 +1,15 @@
 package main
 
 import (
+    "context"
+    "crypto/ed25519"
+    "encoding/base64"
     "net/http"
+    "os"
+    "strings"
+    "time"
     "transfer-service/api/handler"
+    "transfer-service/model"
     "transfer-service/repository"
     "transfer-service/service"
     "transfer-service/middleware"
@@ -26,28 +33,256 @@ func main() {
 
     accountRepo := repository.NewAccountRepository(dbMiddleware.GetDB())
     transactionRepo := repository.NewTransactionRepository(dbMiddleware.GetDB())
+    idempotencyRepo := repository.NewIdempotencyRepository(dbMiddleware.GetDB())
+    assetRepo := repository.NewAssetRepository(dbMiddleware.GetDB())
+    fxRepo := repository.NewFXRepository(dbMiddleware.GetDB())
+    holdRepo := repository.NewHoldRepository(dbMiddleware.GetDB())
+    limitRepo := repository.NewLimitRepository(dbMiddleware.GetDB())
+    feeRepo := repository.NewFeeRepository(dbMiddleware.GetDB())
+    customerRepo := repository.NewCustomerRepository(dbMiddleware.GetDB())
+    scheduledRepo := repository.NewScheduledTransferRepository(dbMiddleware.GetDB())
+    standingOrderRepo := repository.NewStandingOrderRepository(dbMiddleware.GetDB())
+    apiKeyRepo := repository.NewAPIKeyRepository(dbMiddleware.GetDB())
+    uow := repository.NewUnitOfWork(dbMiddleware.GetDB())
     
-    accountSvc := service.NewAccountService(accountRepo)
-    transactionSvc := service.NewTransactionService(accountRepo, transactionRepo)
+    assetSvc := service.NewAssetService(assetRepo)
+    if err := assetSvc.LoadRegistry(context.Background()); err != nil {
+        log.Fatal("Failed to load asset registry", zap.Error(err))
+    }
+
+    // Quotes lock a rate for FX_QUOTE_TTL (e.g. "30s")
+    quoteTTL := service.DefaultQuoteTTL
+    if v := os.Getenv("FX_QUOTE_TTL"); v != "" {
+        ttl, err := time.ParseDuration(v)
+        if err != nil {
+            log.Fatal("Invalid FX_QUOTE_TTL", zap.String("value", v), zap.Error(err))
+        }
+        quoteTTL = ttl
+    }
+    fxSvc := service.NewFXService(fxRepo, quoteTTL)
+
+    // Optionally seed FX rates and position accounts from a local file
+    if path := os.Getenv("FX_RATES_FILE"); path != "" {
+        if err := fxSvc.LoadRatesFile(context.Background(), path); err != nil {
+            log.Fatal("Failed to load FX rates file", zap.Error(err))
+        }
+    }
+
+    accountSvc := service.NewAccountService(accountRepo, idempotencyRepo, uow)
+    limitSvc := service.NewLimitService(limitRepo, accountRepo)
+    feeSvc := service.NewFeeService(feeRepo, accountRepo)
+    customerSvc := service.NewCustomerService(customerRepo, accountRepo, idempotencyRepo, uow)
+    transactionSvc := service.NewTransactionService(accountRepo, transactionRepo, idempotencyRepo, fxRepo, holdRepo, limitRepo, feeRepo, uow)
+
+    // Holds reserve funds for HOLD_TTL (e.g. "168h") unless captured or voided first
+    if v := os.Getenv("HOLD_TTL"); v != "" {
+        ttl, err := time.ParseDuration(v)
+        if err != nil {
+            log.Fatal("Invalid HOLD_TTL", zap.String("value", v), zap.Error(err))
+        }
+        transactionSvc.SetHoldTTL(ttl)
+    }
+
+    scheduledSvc := service.NewScheduledTransferService(scheduledRepo, accountRepo, idempotencyRepo, transactionSvc, uow)
+    standingOrderSvc := service.NewStandingOrderService(standingOrderRepo, accountRepo, idempotencyRepo, transactionSvc, uow)
+
+    // Execute due scheduled transfers and standing orders every SCHEDULER_INTERVAL (e.g. "5s")
+    schedulerInterval := service.DefaultSchedulerInterval
+    if v := os.Getenv("SCHEDULER_INTERVAL"); v != "" {
+        interval, err := time.ParseDuration(v)
+        if err != nil || interval <= 0 {
+            log.Fatal("Invalid SCHEDULER_INTERVAL", zap.String("value", v), zap.Error(err))
+        }
+        schedulerInterval = interval
+    }
+    // The workers act as the system principal; the policy denies a context without one
+    workerCtx, stopWorkers := context.WithCancel(context.Background())
+    defer stopWorkers()
+    go scheduledSvc.RunWorker(middleware.WithPrincipal(workerCtx, model.SystemPrincipal("scheduled-transfers")), schedulerInterval)
+    go standingOrderSvc.RunWorker(middleware.WithPrincipal(workerCtx, model.SystemPrincipal("standing-orders")), schedulerInterval)
+
+    apiKeySvc := service.NewAPIKeyService(apiKeyRepo)
+    // Requests are limited per client and route class; failed authentications
+    // are limited by IP address
+    limiter := rateLimiter(workerCtx, repository.NewRateLimitRepository(dbMiddleware.GetDB()))
+    rejectUnauthenticated := handler.RejectUnauthenticated
+    if limiter != nil {
+        rejectUnauthenticated = limiter.LimitRejected(rejectUnauthenticated)
+    }
+    authenticator := middleware.NewAuthenticator(apiKeySvc, jwtVerifier(), rejectUnauthenticated)
 
     accountHandler := handler.NewAccountHandler(accountSvc)
-    txHandler := handler.NewTransactionHandler(transactionSvc)
+    customerHandler := handler.NewCustomerHandler(customerSvc)
+    txHandler := handler.NewTransactionHandler(transactionSvc, accountSvc)
+    assetHandler := handler.NewAssetHandler(assetSvc)
+    fxHandler := handler.NewFXHandler(fxSvc)
+    limitHandler := handler.NewLimitHandler(limitSvc, accountSvc)
+    feeHandler := handler.NewFeeHandler(feeSvc)
+    holdHandler := handler.NewHoldHandler(transactionSvc)
+    accountStatusHandler := handler.NewAccountStatusHandler(transactionSvc, accountSvc)
+    scheduledHandler := handler.NewScheduledTransferHandler(scheduledSvc)
+    standingOrderHandler := handler.NewStandingOrderHandler(standingOrderSvc)
+    apiKeyHandler := handler.NewAPIKeyHandler(apiKeySvc)
 
     r := mux.NewRouter()
     
-    // Apply logging middleware to all routes
+    // Apply correlation IDs, authentication, logging, rate limits and error
+    // format negotiation to all routes. Every route requires an API key or JWT.
+    r.Use(middleware.CorrelationMiddleware)
+    r.Use(authenticator.Middleware)
     r.Use(middleware.LoggingMiddleware)
+    if limiter != nil {
+        r.Use(limiter.Middleware)
+    }
+    r.Use(handler.Negotiate)
     
-    r.HandleFunc("/accounts", accountHandler.CreateAccount).Methods("POST")
-    r.HandleFunc("/accounts/{id}", accountHandler.GetAccount).Methods("GET")
-    r.HandleFunc("/transactions", txHandler.Transfer).Methods("POST")
+    // v1 is served both unprefixed, for existing clients, and under /v1. v2
+    // runs the same handlers but writes amounts as exact decimal strings.
+    routes := func(r *mux.Router) {
+        r.HandleFunc("/accounts", accountHandler.CreateAccount).Methods("POST")
+        r.HandleFunc("/accounts/{id}", accountHandler.GetAccount).Methods("GET")
+        r.HandleFunc("/accounts/{id}", accountHandler.UpdateAccount).Methods("PATCH")
+        r.HandleFunc("/customers", customerHandler.CreateCustomer).Methods("POST")
+        r.HandleFunc("/customers", customerHandler.ListCustomers).Methods("GET")
+        r.HandleFunc("/customers/{id}", customerHandler.GetCustomer).Methods("GET")
+        r.HandleFunc("/customers/{id}", customerHandler.UpdateCustomer).Methods("PATCH")
+        r.HandleFunc("/customers/{id}", customerHandler.DeleteCustomer).Methods("DELETE")
+        r.HandleFunc("/customers/{id}/accounts", customerHandler.GetCustomerAccounts).Methods("GET")
+        r.HandleFunc("/admin/accounts/{id}/status", accountStatusHandler.ChangeStatus).Methods("POST")
+        r.HandleFunc("/accounts/{id}/limits", limitHandler.GetAccountLimits).Methods("GET")
+        r.HandleFunc("/admin/accounts/{id}/limits", limitHandler.SetAccountLimits).Methods("PUT")
+        r.HandleFunc("/admin/limit-tiers", limitHandler.ListTiers).Methods("GET")
+        r.HandleFunc("/admin/limit-tiers/{name}", limitHandler.SetTier).Methods("PUT")
+        r.HandleFunc("/admin/api-keys", apiKeyHandler.Issue).Methods("POST")
+        r.HandleFunc("/admin/api-keys", apiKeyHandler.List).Methods("GET")
+        r.HandleFunc("/admin/api-keys/{id}/revoke", apiKeyHandler.Revoke).Methods("POST")
+        r.HandleFunc("/transactions", txHandler.Transfer).Methods("POST")
+        r.HandleFunc("/transactions/batch", txHandler.TransferBatch).Methods("POST")
+        r.HandleFunc("/transactions/{id}/reversal", txHandler.Reverse).Methods("POST")
+        r.HandleFunc("/scheduled-transfers", scheduledHandler.Schedule).Methods("POST")
+        r.HandleFunc("/scheduled-transfers/{id}", scheduledHandler.GetScheduledTransfer).Methods("GET")
+        r.HandleFunc("/scheduled-transfers/{id}/cancel", scheduledHandler.Cancel).Methods("POST")
+        r.HandleFunc("/standing-orders", standingOrderHandler.Create).Methods("POST")
+        r.HandleFunc("/standing-orders/{id}", standingOrderHandler.GetStandingOrder).Methods("GET")
+        r.HandleFunc("/standing-orders/{id}/runs", standingOrderHandler.GetRuns).Methods("GET")
+        r.HandleFunc("/standing-orders/{id}/pause", standingOrderHandler.Pause).Methods("POST")
+        r.HandleFunc("/standing-orders/{id}/resume", standingOrderHandler.Resume).Methods("POST")
+        r.HandleFunc("/standing-orders/{id}/cancel", standingOrderHandler.Cancel).Methods("POST")
+        r.HandleFunc("/holds", holdHandler.Authorize).Methods("POST")
+        r.HandleFunc("/holds/{id}", holdHandler.GetHold).Methods("GET")
+        r.HandleFunc("/holds/{id}/capture", holdHandler.Capture).Methods("POST")
+        r.HandleFunc("/holds/{id}/void", holdHandler.Void).Methods("POST")
+        r.HandleFunc("/assets", assetHandler.ListAssets).Methods("GET")
+        r.HandleFunc("/fx/rates", fxHandler.ListRates).Methods("GET")
+        r.HandleFunc("/fx/rates", fxHandler.SetRate).Methods("PUT")
+        r.HandleFunc("/fx/positions", fxHandler.ListPositions).Methods("GET")
+        r.HandleFunc("/fx/positions", fxHandler.SetPosition).Methods("PUT")
+        r.HandleFunc("/quotes", fxHandler.CreateQuote).Methods("POST")
+        r.HandleFunc("/fees", feeHandler.ListSchedules).Methods("GET")
+        r.HandleFunc("/fees/preview", feeHandler.Preview).Methods("POST")
+        r.HandleFunc("/fees/{asset}", feeHandler.SetSchedule).Methods("PUT")
+        r.HandleFunc("/fees/{asset}", feeHandler.DeleteSchedule).Methods("DELETE")
 
-    // Not in the scope of the project...
-    r.HandleFunc("/transactions", txHandler.GetTransactionHistory).Methods("GET")
-    r.HandleFunc("/accounts/{id}/transactions", txHandler.GetAccountTransactionHistory).Methods("GET")
+        // Not in the scope of the project...
+        r.HandleFunc("/transactions", txHandler.GetTransactionHistory).Methods("GET")
+        r.HandleFunc("/accounts/{id}/transactions", txHandler.GetAccountTransactionHistory).Methods("GET")
+        r.HandleFunc("/accounts/{id}/statement", txHandler.GetAccountStatement).Methods("GET")
+    }
+    routes(r)
+    routes(r.PathPrefix("/v1").Subrouter())
+    v2 := r.PathPrefix("/v2").Subrouter()
+    v2.Use(handler.APIv2)
+    routes(v2)
 
     log.Info("Server listening on :8080")
     if err := http.ListenAndServe(":8080", r); err != nil {
         log.Fatal("Server failed to start", zap.Error(err))
     }
 }
+
+// jwtVerifier builds the JWT verifier from AUTH_JWT_HS256_SECRET and
+// AUTH_JWT_ED25519_PUBLIC_KEY (base64), checking AUTH_JWT_ISSUER and
+// AUTH_JWT_AUDIENCE when set. Without a key only API keys are accepted.
+func jwtVerifier() *middleware.JWTVerifier {
+    log := middleware.GetLogger()
+
+    secret := []byte(os.Getenv("AUTH_JWT_HS256_SECRET"))
+    if len(secret) > 0 && len(secret) < 32 {
+        log.Fatal("AUTH_JWT_HS256_SECRET must be at least 32 bytes")
+    }
+    var edKey ed25519.PublicKey
+    if v := os.Getenv("AUTH_JWT_ED25519_PUBLIC_KEY"); v != "" {
+        key, err := base64.StdEncoding.DecodeString(v)
+        if err != nil || len(key) != ed25519.PublicKeySize {
+            log.Fatal("Invalid AUTH_JWT_ED25519_PUBLIC_KEY", zap.Error(err))
+        }
+        edKey = key
+    }
+    if len(secret) == 0 && edKey == nil {
+        log.Warn("No JWT key configured; only API keys are accepted")
+        return nil
+    }
+    return middleware.NewJWTVerifier(secret, edKey, os.Getenv("AUTH_JWT_ISSUER"), os.Getenv("AUTH_JWT_AUDIENCE"))
+}
+
+// Default rate limits per client, as "<requests>/<duration>"
+var defaultRateLimits = map[middleware.RouteClass]string{
+    middleware.RouteClassRead:  "300/1m",
+    middleware.RouteClassWrite: "60/1m",
+    middleware.RouteClassMoney: "30/1m",
+}
+
+// rateLimiter builds the rate limiter from RATE_LIMIT_STORE ("memory", the
+// default, "postgres" to share limits between instances, or "off") and the
+// limits RATE_LIMIT_READ, RATE_LIMIT_WRITE and RATE_LIMIT_MONEY (e.g. "30/1m").
+// It returns nil when rate limiting is off.
+func rateLimiter(ctx context.Context, buckets repository.RateLimitRepository) *middleware.RateLimiter {
+    log := middleware.GetLogger()
+
+    limits := make(map[middleware.RouteClass]model.RateLimit)
+    var longest time.Duration
+    for class, def := range defaultRateLimits {
+        name := "RATE_LIMIT_" + strings.ToUpper(string(class))
+        v := os.Getenv(name)
+        if v == "" {
+            v = def
+        }
+        limit, err := model.ParseRateLimit(v)
+        if err != nil {
+            log.Fatal("Invalid "+name, zap.String("value", v), zap.Error(err))
+        }
+        limits[class] = limit
+        if limit.Per > longest {
+            longest = limit.Per
+        }
+    }
+
+    switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+    case "", "memory":
+        return middleware.NewRateLimiter(middleware.NewMemoryRateLimitStore(), limits, handler.RejectRateLimited)
+    case "postgres":
+        // Buckets idle for longer than the longest period have refilled
+        // and are deleted
+        go func() {
+            ticker := time.NewTicker(longest)
+            defer ticker.Stop()
+            for {
+                select {
+                case <-ctx.Done():
+                    return
+                case <-ticker.C:
+                    if _, err := buckets.DeleteIdle(ctx, longest); err != nil {
+                        log.Warn("Failed to delete idle rate limit buckets", zap.Error(err))
+                    }
+                }
+            }
+        }()
+        return middleware.NewRateLimiter(buckets, limits, handler.RejectRateLimited)
+    case "off":
+        log.Warn("Rate limiting is off")
+        return nil
+    default:
+        log.Fatal("Invalid RATE_LIMIT_STORE", zap.String("value", store))
+    }
+    return nil
+}
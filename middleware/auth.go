@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"transfer-service/model"
+	"go.uber.org/zap"
+)
+
+var ErrMissingCredentials = errors.New("missing credentials")
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// APIKeyPrefix starts every API key, which tells keys apart from JWTs
+const APIKeyPrefix = "tsk_"
+
+// APIKeyAuthenticator resolves an API key to its principal. It returns
+// ErrInvalidAPIKey for unknown, revoked and expired keys.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*model.Principal, error)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated principal
+func WithPrincipal(ctx context.Context, principal *model.Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal of the request ctx belongs to,
+// or nil when it was not authenticated
+func PrincipalFromContext(ctx context.Context) *model.Principal {
+	principal, _ := ctx.Value(principalKey{}).(*model.Principal)
+	return principal
+}
+
+// Authenticator authenticates requests by API key or JWT, sent as
+// "Authorization: Bearer <credential>" or, for API keys, "X-API-Key".
+type Authenticator struct {
+	keys   APIKeyAuthenticator
+	tokens *JWTVerifier
+	reject func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// NewAuthenticator creates an authenticator; tokens may be nil to accept API
+// keys only. reject writes the response to a request that failed.
+func NewAuthenticator(keys APIKeyAuthenticator, tokens *JWTVerifier, reject func(w http.ResponseWriter, r *http.Request, err error)) *Authenticator {
+	return &Authenticator{keys: keys, tokens: tokens, reject: reject}
+}
+
+// Middleware rejects requests without valid credentials and puts the
+// principal of the others on the request context
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.Authenticate(r)
+		if err != nil {
+			GetLogger().Warn("Authentication failed",
+				zap.String("correlation_id", CorrelationID(r.Context())),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("remote_addr", r.RemoteAddr),
+				zap.Error(err),
+			)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="transfer-service"`)
+			a.reject(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// Authenticate returns the principal of the request's credentials
+func (a *Authenticator) Authenticate(r *http.Request) (*model.Principal, error) {
+	credential := r.Header.Get("X-API-Key")
+	if credential == "" {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			credential = strings.TrimSpace(token)
+		}
+	}
+
+	switch {
+	case credential == "":
+		return nil, ErrMissingCredentials
+	case strings.HasPrefix(credential, APIKeyPrefix):
+		return a.keys.AuthenticateAPIKey(r.Context(), credential)
+	case a.tokens != nil:
+		return a.tokens.Verify(credential)
+	}
+	return nil, ErrInvalidToken
+}
+
+// IsAuthenticationError reports whether err means the credentials were
+// missing or invalid, rather than that they could not be checked
+func IsAuthenticationError(err error) bool {
+	return errors.Is(err, ErrMissingCredentials) || errors.Is(err, ErrInvalidAPIKey) || errors.Is(err, ErrInvalidToken)
+}
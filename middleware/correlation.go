@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// CorrelationIDHeader carries the ID that ties a request to its log lines
+const CorrelationIDHeader = "X-Correlation-ID"
+
+const maxCorrelationIDLength = 128
+
+type correlationIDKey struct{}
+
+// CorrelationMiddleware gives every request a correlation ID: the client's
+// X-Correlation-ID when it is usable, otherwise a new one. The ID is echoed
+// in the response and stored in the request context.
+func CorrelationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(CorrelationIDHeader)
+		if !validCorrelationID(id) {
+			id = newCorrelationID()
+		}
+		w.Header().Set(CorrelationIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), correlationIDKey{}, id)))
+	})
+}
+
+// CorrelationID returns the correlation ID of the request ctx belongs to, or
+// "" outside a request
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+func newCorrelationID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validCorrelationID accepts IDs short enough to log and made of characters
+// that cannot forge log fields or headers
+func validCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -1,13 +1,40 @@
 package middleware
 
 import (
+	"errors"
 	"github.com/lib/pq"
 )
 
+const (
+	pqUniqueViolation      = "23505"
+	pqForeignKeyViolation  = "23503"
+	pqSerializationFailure = "40001"
+	pqDeadlockDetected     = "40P01"
+)
 
 func IsUniqueViolation(err error) bool {
-	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation {
+		return true
+	}
+	return false
+}
+
+// IsForeignKeyViolation reports whether err is a reference to a missing row
+func IsForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pqForeignKeyViolation {
 		return true
 	}
 	return false
-} 
\ No newline at end of file
+}
+
+// IsRetryable reports whether err is a serialization failure or deadlock,
+// i.e. a conflict that may succeed if the whole transaction is run again
+func IsRetryable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == pqSerializationFailure || pqErr.Code == pqDeadlockDetected
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"transfer-service/model"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// jwtLeeway tolerates clock skew between the issuer and this service
+const jwtLeeway = 30 * time.Second
+
+// JWTVerifier verifies JWTs signed with HS256 or EdDSA (Ed25519) without
+// calling the issuer. Only the algorithms with a configured key are accepted.
+type JWTVerifier struct {
+	hmacSecret []byte
+	edKey      ed25519.PublicKey
+	issuer     string
+	audience   string
+	now        func() time.Time
+}
+
+// NewJWTVerifier creates a verifier for HS256 tokens signed with hmacSecret
+// and EdDSA tokens signed by the private half of edKey; either may be empty.
+// Empty issuer and audience are not checked.
+func NewJWTVerifier(hmacSecret []byte, edKey ed25519.PublicKey, issuer, audience string) *JWTVerifier {
+	return &JWTVerifier{
+		hmacSecret: hmacSecret,
+		edKey:      edKey,
+		issuer:     issuer,
+		audience:   audience,
+		now:        time.Now,
+	}
+}
+
+// SetClock replaces the clock used to check expiry, for tests
+func (v *JWTVerifier) SetClock(now func() time.Time) {
+	v.now = now
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// jwtClaims are the claims the service reads. role and customer_id are
+// private claims naming the principal's role and customer.
+type jwtClaims struct {
+	Subject    string          `json:"sub"`
+	Issuer     string          `json:"iss,omitempty"`
+	Audience   json.RawMessage `json:"aud,omitempty"` // a string or an array of strings
+	ExpiresAt  *int64          `json:"exp"`
+	NotBefore  *int64          `json:"nbf,omitempty"`
+	Role       string          `json:"role"`
+	CustomerID *int            `json:"customer_id,omitempty"`
+}
+
+// Verify checks the token's signature and claims and returns its principal.
+// Tokens must expire.
+func (v *JWTVerifier) Verify(token string) (*model.Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header cannot be decoded", ErrInvalidToken)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature is not base64url-encoded", ErrInvalidToken)
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	switch {
+	case header.Alg == "HS256" && len(v.hmacSecret) > 0:
+		mac := hmac.New(sha256.New, v.hmacSecret)
+		mac.Write(signed)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	case header.Alg == "EdDSA" && len(v.edKey) == ed25519.PublicKeySize:
+		if !ed25519.Verify(v.edKey, signed, signature) {
+			return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	default:
+		return nil, fmt.Errorf("%w: algorithm %q not accepted", ErrInvalidToken, header.Alg)
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: claims cannot be decoded", ErrInvalidToken)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return &model.Principal{
+		Subject:    claims.Subject,
+		Method:     model.AuthMethodJWT,
+		Role:       claims.Role,
+		CustomerID: claims.CustomerID,
+	}, nil
+}
+
+func (v *JWTVerifier) checkClaims(claims jwtClaims) error {
+	now := v.now()
+	switch {
+	case claims.Subject == "":
+		return fmt.Errorf("%w: sub is required", ErrInvalidToken)
+	case claims.ExpiresAt == nil:
+		return fmt.Errorf("%w: exp is required", ErrInvalidToken)
+	case now.After(time.Unix(*claims.ExpiresAt, 0).Add(jwtLeeway)):
+		return fmt.Errorf("%w: expired", ErrInvalidToken)
+	case claims.NotBefore != nil && now.Add(jwtLeeway).Before(time.Unix(*claims.NotBefore, 0)):
+		return fmt.Errorf("%w: not yet valid", ErrInvalidToken)
+	case v.issuer != "" && claims.Issuer != v.issuer:
+		return fmt.Errorf("%w: issuer %q not accepted", ErrInvalidToken, claims.Issuer)
+	case v.audience != "" && !hasAudience(claims.Audience, v.audience):
+		return fmt.Errorf("%w: audience not accepted", ErrInvalidToken)
+	case !model.IsRole(claims.Role):
+		return fmt.Errorf("%w: unknown role %q", ErrInvalidToken, claims.Role)
+	case claims.Role == model.RoleCustomer && claims.CustomerID == nil:
+		return fmt.Errorf("%w: customer_id is required for the customer role", ErrInvalidToken)
+	}
+	return nil
+}
+
+// hasAudience reports whether the aud claim, a string or an array, names audience
+func hasAudience(raw json.RawMessage, audience string) bool {
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		return single == audience
+	}
+	var many []string
+	if json.Unmarshal(raw, &many) == nil {
+		for _, aud := range many {
+			if aud == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
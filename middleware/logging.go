@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"time"
 	"go.uber.org/zap"
@@ -14,6 +15,8 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		// Log the incoming request
 		log := GetLogger()
 		log.Info("HTTP Request",
+			zap.String("correlation_id", CorrelationID(r.Context())),
+			zap.String("principal", principalSubject(r.Context())),
 			zap.String("method", r.Method),
 			zap.String("path", r.URL.Path),
 			zap.String("remote_addr", r.RemoteAddr),
@@ -29,6 +32,8 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		
 		// Log the response
 		log.Info("HTTP Response",
+			zap.String("correlation_id", CorrelationID(r.Context())),
+			zap.String("principal", principalSubject(r.Context())),
 			zap.String("method", r.Method),
 			zap.String("path", r.URL.Path),
 			zap.Int("status_code", wrapped.statusCode),
@@ -37,6 +42,14 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// principalSubject names the principal of a request in logs
+func principalSubject(ctx context.Context) string {
+	if principal := PrincipalFromContext(ctx); principal != nil {
+		return principal.Subject
+	}
+	return ""
+}
+
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
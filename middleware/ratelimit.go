@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"transfer-service/model"
+	"go.uber.org/zap"
+)
+
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RouteClass groups routes that share a rate limit
+type RouteClass string
+
+// Route classes
+const (
+	RouteClassRead  RouteClass = "read"  // GET and HEAD requests
+	RouteClassWrite RouteClass = "write" // changes that move no money
+	RouteClassMoney RouteClass = "money" // transfers, holds, scheduled transfers and standing orders
+)
+
+// moneyRoutes are the first path segments under which writes move money
+var moneyRoutes = map[string]bool{
+	"transactions":        true,
+	"holds":               true,
+	"scheduled-transfers": true,
+	"standing-orders":     true,
+}
+
+// ClassifyRoute returns the class of a request from its method and path.
+// The /v1 and /v2 prefixes are ignored.
+func ClassifyRoute(r *http.Request) RouteClass {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+		return RouteClassRead
+	}
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(segments) > 1 && (segments[0] == "v1" || segments[0] == "v2") {
+		segments = segments[1:]
+	}
+	if moneyRoutes[segments[0]] {
+		return RouteClassMoney
+	}
+	return RouteClassWrite
+}
+
+// RateLimitStore keeps token buckets. Take takes a token from the bucket of
+// key, creating a full one if there is none.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit model.RateLimit) (model.RateLimitDecision, error)
+}
+
+// RateLimiter limits requests per client and route class: by principal once
+// authenticated, otherwise by IP address
+type RateLimiter struct {
+	store  RateLimitStore
+	limits map[RouteClass]model.RateLimit
+	reject func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// NewRateLimiter creates a rate limiter; a class without a limit is not
+// limited. reject writes the response to a limited request.
+func NewRateLimiter(store RateLimitStore, limits map[RouteClass]model.RateLimit, reject func(w http.ResponseWriter, r *http.Request, err error)) *RateLimiter {
+	return &RateLimiter{store: store, limits: limits, reject: reject}
+}
+
+// Middleware turns away requests over their client's limit with 429 and
+// sets the RateLimit headers on the others
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.allow(w, r) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// LimitRejected wraps the authenticator's reject function, so that clients
+// failing authentication are limited by IP address as well
+func (l *RateLimiter) LimitRejected(reject func(w http.ResponseWriter, r *http.Request, err error)) func(w http.ResponseWriter, r *http.Request, err error) {
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		if l.allow(w, r) {
+			reject(w, r, err)
+		}
+	}
+}
+
+// allow takes a token for the request and reports whether it may go on. A
+// store failure lets the request through, as refusing all traffic would be
+// worse than not limiting it for a while.
+func (l *RateLimiter) allow(w http.ResponseWriter, r *http.Request) bool {
+	class := ClassifyRoute(r)
+	limit, ok := l.limits[class]
+	if !ok {
+		return true
+	}
+	client := RateLimitClient(r)
+	decision, err := l.store.Take(r.Context(), string(class)+":"+client, limit)
+	if err != nil {
+		GetLogger().Warn("Rate limit store failed; request not limited",
+			zap.String("correlation_id", CorrelationID(r.Context())),
+			zap.String("client", client),
+			zap.Error(err),
+		)
+		return true
+	}
+
+	header := w.Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+	header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, ceilSeconds(limit.Per)))
+	if decision.Allowed {
+		return true
+	}
+
+	header.Set("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+	GetLogger().Warn("Rate limit exceeded",
+		zap.String("correlation_id", CorrelationID(r.Context())),
+		zap.String("client", client),
+		zap.String("route_class", string(class)),
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+	l.reject(w, r, fmt.Errorf("%w: %s requests are limited to %s", ErrRateLimited, class, limit))
+	return false
+}
+
+// RateLimitClient names the client a request is counted against: its
+// principal, or its IP address when it has none
+func RateLimitClient(r *http.Request) string {
+	if principal := PrincipalFromContext(r.Context()); principal != nil {
+		return "client:" + principal.Method + ":" + principal.Subject
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// ceilSeconds rounds a duration up to whole seconds, at least one
+func ceilSeconds(d time.Duration) int {
+	return int(math.Max(1, math.Ceil(d.Seconds())))
+}
+
+// rateLimitSweepInterval is how often the memory store drops full buckets
+const rateLimitSweepInterval = time.Minute
+
+type memoryBucket struct {
+	bucket model.TokenBucket
+	limit  model.RateLimit
+}
+
+// MemoryRateLimitStore keeps token buckets in memory, which limits each
+// instance separately
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*memoryBucket), now: time.Now}
+}
+
+// SetClock replaces the clock used to refill buckets, for tests
+func (s *MemoryRateLimitStore) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit model.RateLimit) (model.RateLimitDecision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{bucket: limit.NewTokenBucket(now)}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	var decision model.RateLimitDecision
+	b.bucket, decision = limit.Take(b.bucket, now)
+	return decision, nil
+}
+
+// sweep drops the buckets that have refilled, which a new full bucket
+// replaces exactly
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if now.Sub(b.bucket.UpdatedAt) >= b.limit.Per {
+			delete(s.buckets, key)
+		}
+	}
+}
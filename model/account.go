@@ -5,19 +5,99 @@ import (
     "github.com/shopspring/decimal"
 )
 
+// Account statuses
+const (
+    AccountStatusActive  = "active"
+    AccountStatusFrozen  = "frozen"  // may receive but not send funds
+    AccountStatusDormant = "dormant" // inactive; may receive but not send funds until reactivated
+    AccountStatusClosed  = "closed"  // takes no part in any movement
+)
+
+// accountStatusTransitions lists the statuses each status may change to
+var accountStatusTransitions = map[string][]string{
+    AccountStatusActive:  {AccountStatusFrozen, AccountStatusDormant, AccountStatusClosed},
+    AccountStatusFrozen:  {AccountStatusActive},
+    AccountStatusDormant: {AccountStatusActive, AccountStatusClosed},
+}
+
+// IsAccountStatus reports whether status is a known account status
+func IsAccountStatus(status string) bool {
+    switch status {
+    case AccountStatusActive, AccountStatusFrozen, AccountStatusDormant, AccountStatusClosed:
+        return true
+    }
+    return false
+}
+
+// CanChangeAccountStatus reports whether an account may move from one status to another
+func CanChangeAccountStatus(from, to string) bool {
+    for _, allowed := range accountStatusTransitions[from] {
+        if allowed == to {
+            return true
+        }
+    }
+    return false
+}
+
 type Account struct {
-    ID      int             `json:"account_id"`
-    Balance decimal.Decimal `json:"balance"`
+    ID        int             `json:"account_id"`          // legacy integer ID, accepted wherever a public ID is
+    PublicID  string          `json:"public_id,omitempty"` // opaque ID allocated by the service
+    AssetCode string          `json:"asset_code"`
+    Balance   decimal.Decimal `json:"balance"`
+    Status    string          `json:"status,omitempty"`
+
+    // CustomerID is the customer that owns the account, if any
+    CustomerID *int `json:"customer_id,omitempty"`
+
+    // OverdraftLimit is how far below zero the balance may go
+    OverdraftLimit decimal.Decimal `json:"overdraft_limit"`
+
+    // HeldBalance is the sum of the account's pending, unexpired holds
+    HeldBalance decimal.Decimal `json:"-"`
+}
+
+// CanSend reports whether the account may be debited
+func (a Account) CanSend() bool {
+    return a.Status != AccountStatusFrozen && a.Status != AccountStatusDormant && a.Status != AccountStatusClosed
+}
+
+// AvailableBalance is the ledger balance less the funds reserved by holds
+func (a Account) AvailableBalance() decimal.Decimal {
+    return a.Balance.Sub(a.HeldBalance)
 }
 
-// MarshalJSON customizes JSON marshaling to format balance with 5 decimal places
+// SpendableBalance is the available balance plus the overdraft limit; debits
+// may not exceed it
+func (a Account) SpendableBalance() decimal.Decimal {
+    return a.AvailableBalance().Add(a.OverdraftLimit)
+}
+
+// MarshalJSON customizes JSON marshaling to format balances with the scale of the account's asset.
+// balance is kept for existing clients and equals ledger_balance.
 func (a Account) MarshalJSON() ([]byte, error) {
     type Alias Account
+    scale := AssetScale(a.AssetCode)
     return json.Marshal(&struct {
         *Alias
-        Balance float64 `json:"balance"`
+        Balance          float64 `json:"balance"`
+        LedgerBalance    float64 `json:"ledger_balance"`
+        AvailableBalance float64 `json:"available_balance"`
+        OverdraftLimit   float64 `json:"overdraft_limit"`
     }{
-        Alias:   (*Alias)(&a),
-        Balance: a.Balance.Round(5).InexactFloat64(),
+        Alias:            (*Alias)(&a),
+        Balance:          a.Balance.Round(scale).InexactFloat64(),
+        LedgerBalance:    a.Balance.Round(scale).InexactFloat64(),
+        AvailableBalance: a.AvailableBalance().Round(scale).InexactFloat64(),
+        OverdraftLimit:   a.OverdraftLimit.Round(scale).InexactFloat64(),
     })
 }
+
+// exactAmounts returns the account's balances for API v2
+func (a Account) exactAmounts() map[string]string {
+    return map[string]string{
+        "balance":           exactAmount(a.Balance, a.AssetCode),
+        "ledger_balance":    exactAmount(a.Balance, a.AssetCode),
+        "available_balance": exactAmount(a.AvailableBalance(), a.AssetCode),
+        "overdraft_limit":   exactAmount(a.OverdraftLimit, a.AssetCode),
+    }
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+    "sort"
+    "sync"
+)
+
+// DefaultAssetCode is used for accounts created without an asset code. It keeps
+// the original 5-decimal precision of the service.
+const DefaultAssetCode = "USD"
+
+const DefaultAssetScale int32 = 5
+
+// Asset is a currency or other asset held in accounts. Scale is the number of
+// decimal places amounts of the asset may carry.
+type Asset struct {
+    Code  string `json:"code"`
+    Scale int32  `json:"scale"`
+}
+
+// The asset registry is loaded from the assets table at startup and read on
+// every validation and JSON encoding of an amount
+var (
+    assetsMu sync.RWMutex
+    assets   = map[string]Asset{
+        DefaultAssetCode: {Code: DefaultAssetCode, Scale: DefaultAssetScale},
+    }
+)
+
+// RegisterAsset adds or replaces an asset in the registry
+func RegisterAsset(a Asset) {
+    assetsMu.Lock()
+    defer assetsMu.Unlock()
+    assets[a.Code] = a
+}
+
+// LookupAsset returns the registered asset for code
+func LookupAsset(code string) (Asset, bool) {
+    assetsMu.RLock()
+    defer assetsMu.RUnlock()
+    a, ok := assets[code]
+    return a, ok
+}
+
+// AssetScale returns the scale of the asset, or DefaultAssetScale if it is not registered
+func AssetScale(code string) int32 {
+    if a, ok := LookupAsset(code); ok {
+        return a.Scale
+    }
+    return DefaultAssetScale
+}
+
+// RegisteredAssets returns all registered assets ordered by code
+func RegisteredAssets() []Asset {
+    assetsMu.RLock()
+    defer assetsMu.RUnlock()
+    list := make([]Asset, 0, len(assets))
+    for _, a := range assets {
+        list = append(list, a)
+    }
+    sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+    return list
+}
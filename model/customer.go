@@ -0,0 +1,65 @@
+package model
+
+import (
+    "encoding/json"
+    "time"
+    "github.com/shopspring/decimal"
+)
+
+// KYC tiers, from least to most verified
+const (
+    KYCTierNone     = "none"
+    KYCTierBasic    = "basic"
+    KYCTierVerified = "verified"
+)
+
+// IsKYCTier reports whether tier is a known KYC tier
+func IsKYCTier(tier string) bool {
+    switch tier {
+    case KYCTierNone, KYCTierBasic, KYCTierVerified:
+        return true
+    }
+    return false
+}
+
+// Customer owns accounts. ExternalRef is the customer's ID in the client's own
+// systems and is unique when set.
+type Customer struct {
+    ID          int       `json:"id"`
+    Name        string    `json:"name"`
+    ExternalRef string    `json:"external_ref,omitempty"`
+    KYCTier     string    `json:"kyc_tier"`
+    CreatedAt   time.Time `json:"created_at,omitempty"`
+    UpdatedAt   time.Time `json:"updated_at,omitempty"`
+}
+
+// CustomerBalance is the total of a customer's accounts in one asset
+type CustomerBalance struct {
+    AssetCode        string          `json:"asset_code"`
+    Accounts         int             `json:"accounts"`
+    LedgerBalance    decimal.Decimal `json:"ledger_balance"`
+    AvailableBalance decimal.Decimal `json:"available_balance"`
+}
+
+// MarshalJSON customizes JSON marshaling to format balances with the scale of their asset
+func (b CustomerBalance) MarshalJSON() ([]byte, error) {
+    type Alias CustomerBalance
+    scale := AssetScale(b.AssetCode)
+    return json.Marshal(&struct {
+        *Alias
+        LedgerBalance    float64 `json:"ledger_balance"`
+        AvailableBalance float64 `json:"available_balance"`
+    }{
+        Alias:            (*Alias)(&b),
+        LedgerBalance:    b.LedgerBalance.Round(scale).InexactFloat64(),
+        AvailableBalance: b.AvailableBalance.Round(scale).InexactFloat64(),
+    })
+}
+
+// exactAmounts returns the balances for API v2
+func (b CustomerBalance) exactAmounts() map[string]string {
+    return map[string]string{
+        "ledger_balance":    exactAmount(b.LedgerBalance, b.AssetCode),
+        "available_balance": exactAmount(b.AvailableBalance, b.AssetCode),
+    }
+}
@@ -0,0 +1,144 @@
+package model
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "github.com/shopspring/decimal"
+)
+
+// exactAmounter is implemented by models whose MarshalJSON writes amounts as
+// floats. exactAmounts returns those amounts as exact decimal strings fixed
+// to the scale of their asset, keyed by JSON name; an unset amount is omitted.
+type exactAmounter interface {
+    exactAmounts() map[string]string
+}
+
+var (
+    marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+    modelPkgPath  = reflect.TypeOf(Account{}).PkgPath()
+)
+
+// ExactJSON converts v into a value that marshals like v, except that every
+// amount is an exact decimal string (API v2). Models are written from their
+// fields instead of their MarshalJSON, with exactAmounts in place of the
+// floats; other decimals keep their full precision.
+func ExactJSON(v interface{}) interface{} {
+    if v == nil {
+        return nil
+    }
+    return exactValue(reflect.ValueOf(v))
+}
+
+func exactValue(v reflect.Value) interface{} {
+    switch v.Kind() {
+    case reflect.Interface, reflect.Ptr:
+        if v.IsNil() {
+            return nil
+        }
+        return exactValue(v.Elem())
+    case reflect.Slice:
+        if v.IsNil() {
+            return nil
+        }
+        if v.Type().Elem().Kind() == reflect.Uint8 {
+            return v.Interface()
+        }
+        fallthrough
+    case reflect.Array:
+        out := make([]interface{}, v.Len())
+        for i := range out {
+            out[i] = exactValue(v.Index(i))
+        }
+        return out
+    case reflect.Map:
+        if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+            return v.Interface()
+        }
+        out := make(map[string]interface{}, v.Len())
+        for _, key := range v.MapKeys() {
+            out[key.String()] = exactValue(v.MapIndex(key))
+        }
+        return out
+    case reflect.Struct:
+        // Types outside this package that marshal themselves, such as
+        // time.Time and decimal.Decimal, are written as usual
+        if v.Type().PkgPath() != modelPkgPath && v.Type().Implements(marshalerType) {
+            return v.Interface()
+        }
+        out := make(map[string]interface{})
+        exactFields(v, out)
+        if amounter, ok := v.Interface().(exactAmounter); ok {
+            for name, amount := range amounter.exactAmounts() {
+                out[name] = amount
+            }
+        }
+        return out
+    }
+    return v.Interface()
+}
+
+// exactFields adds the exported fields of a struct to out under their JSON
+// names, following the json tags as encoding/json does
+func exactFields(v reflect.Value, out map[string]interface{}) {
+    t := v.Type()
+    for i := 0; i < t.NumField(); i++ {
+        field := t.Field(i)
+        if !field.IsExported() && !field.Anonymous {
+            continue
+        }
+        tag := field.Tag.Get("json")
+        if tag == "-" {
+            continue
+        }
+        name, options, _ := strings.Cut(tag, ",")
+        value := v.Field(i)
+
+        if field.Anonymous && name == "" {
+            if value.Kind() == reflect.Ptr {
+                if value.IsNil() {
+                    continue
+                }
+                value = value.Elem()
+            }
+            if value.Kind() == reflect.Struct {
+                exactFields(value, out)
+                continue
+            }
+        }
+        if !field.IsExported() {
+            continue
+        }
+        if name == "" {
+            name = field.Name
+        }
+        if strings.Contains(options, "omitempty") && isEmptyValue(value) {
+            continue
+        }
+        out[name] = exactValue(value)
+    }
+}
+
+// isEmptyValue reports whether omitempty drops v
+func isEmptyValue(v reflect.Value) bool {
+    switch v.Kind() {
+    case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+        return v.Len() == 0
+    case reflect.Bool:
+        return !v.Bool()
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return v.Int() == 0
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return v.Uint() == 0
+    case reflect.Float32, reflect.Float64:
+        return v.Float() == 0
+    case reflect.Interface, reflect.Ptr:
+        return v.IsNil()
+    }
+    return false
+}
+
+// exactAmount formats an amount with the scale of its asset
+func exactAmount(d decimal.Decimal, assetCode string) string {
+    return d.StringFixed(AssetScale(assetCode))
+}
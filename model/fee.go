@@ -0,0 +1,54 @@
+package model
+
+import (
+    "time"
+    "github.com/shopspring/decimal"
+)
+
+// Fee types
+const (
+    FeeTypeFlat    = "flat"
+    FeeTypePercent = "percent"
+    FeeTypeTiered  = "tiered"
+)
+
+// FeeSchedule is the fee charged on transfers of an asset. The source account
+// pays the fee on top of the amount and it is credited to FeeAccountID.
+type FeeSchedule struct {
+    AssetCode    string           `json:"asset_code"`
+    Type         string           `json:"type"`
+    FlatAmount   *decimal.Decimal `json:"flat_amount,omitempty"` // flat fees
+    Percent      *decimal.Decimal `json:"percent,omitempty"`     // percent fees, e.g. 1.5 for 1.5%
+    Tiers        []FeeTier        `json:"tiers,omitempty"`       // tiered fees, in ascending up_to order
+    MinFee       *decimal.Decimal `json:"min_fee,omitempty"`
+    MaxFee       *decimal.Decimal `json:"max_fee,omitempty"`
+    FeeAccountID int              `json:"fee_account_id"`
+    UpdatedAt    time.Time        `json:"updated_at,omitempty"`
+}
+
+// FeeTier charges a flat amount plus a percentage on transfers up to UpTo.
+// A nil UpTo has no upper bound and must be the last tier.
+type FeeTier struct {
+    UpTo       *decimal.Decimal `json:"up_to,omitempty"`
+    FlatAmount *decimal.Decimal `json:"flat_amount,omitempty"`
+    Percent    *decimal.Decimal `json:"percent,omitempty"`
+}
+
+// FeePreview is the cost of a transfer before it is submitted
+type FeePreview struct {
+    SourceAccountID int             `json:"source_account_id"`
+    SourceAccount   string          `json:"source_account,omitempty"` // its public ID
+    AssetCode       string          `json:"asset_code"`
+    Amount          decimal.Decimal `json:"amount"`
+    Fee             decimal.Decimal `json:"fee"`
+    Total           decimal.Decimal `json:"total"`
+}
+
+// exactAmounts returns the preview's amounts for API v2
+func (p FeePreview) exactAmounts() map[string]string {
+    return map[string]string{
+        "amount": exactAmount(p.Amount, p.AssetCode),
+        "fee":    exactAmount(p.Fee, p.AssetCode),
+        "total":  exactAmount(p.Total, p.AssetCode),
+    }
+}
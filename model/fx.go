@@ -0,0 +1,33 @@
+package model
+
+import (
+    "time"
+    "github.com/shopspring/decimal"
+)
+
+// FXRate converts BaseAsset into QuoteAsset: 1 unit of BaseAsset buys Rate units of QuoteAsset
+type FXRate struct {
+    BaseAsset  string          `json:"base_asset"`
+    QuoteAsset string          `json:"quote_asset"`
+    Rate       decimal.Decimal `json:"rate"`
+    UpdatedAt  time.Time       `json:"updated_at,omitempty"`
+}
+
+// FXQuote locks an FXRate until ExpiresAt. A quote can be used by one transfer.
+type FXQuote struct {
+    ID            string          `json:"id"`
+    BaseAsset     string          `json:"base_asset"`
+    QuoteAsset    string          `json:"quote_asset"`
+    Rate          decimal.Decimal `json:"rate"`
+    ExpiresAt     time.Time       `json:"expires_at"`
+    TransactionID *int            `json:"transaction_id,omitempty"` // set once the quote has been used
+    CreatedAt     time.Time       `json:"created_at,omitempty"`
+}
+
+// FXPosition is the account that holds the service's position in an asset.
+// Conversions credit the position of the source asset and debit the position
+// of the destination asset, keeping each asset's postings balanced.
+type FXPosition struct {
+    AssetCode string `json:"asset_code"`
+    AccountID int    `json:"account_id"`
+}
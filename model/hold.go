@@ -0,0 +1,62 @@
+package model
+
+import (
+    "encoding/json"
+    "time"
+    "github.com/shopspring/decimal"
+)
+
+// Hold statuses. A pending hold past its expiry is reported as expired and no
+// longer reduces the available balance.
+const (
+    HoldStatusPending  = "pending"
+    HoldStatusCaptured = "captured"
+    HoldStatusVoided   = "voided"
+    HoldStatusExpired  = "expired"
+)
+
+// Hold reserves Amount of the source account's available balance until it is
+// captured into a transfer to the destination, voided or expires
+type Hold struct {
+    ID                   int              `json:"id,omitempty"`
+    SourceAccountID      int              `json:"source_account_id"`
+    DestinationAccountID int              `json:"destination_account_id"`
+    SourceAccount        string           `json:"source_account,omitempty"`      // public IDs, as on Transaction
+    DestinationAccount   string           `json:"destination_account,omitempty"`
+    AssetCode            string           `json:"asset_code,omitempty"`
+    Amount               decimal.Decimal  `json:"amount"`
+    Status               string           `json:"status,omitempty"`
+    CapturedAmount       *decimal.Decimal `json:"captured_amount,omitempty"`
+    TransactionID        *int             `json:"transaction_id,omitempty"` // the capture transfer
+    ExpiresAt            time.Time        `json:"expires_at"`
+    CreatedAt            time.Time        `json:"created_at,omitempty"`
+}
+
+// MarshalJSON customizes JSON marshaling to format amounts with the scale of the hold's asset
+func (h Hold) MarshalJSON() ([]byte, error) {
+    type Alias Hold
+    out := &struct {
+        *Alias
+        Amount         float64  `json:"amount"`
+        CapturedAmount *float64 `json:"captured_amount,omitempty"`
+    }{
+        Alias:  (*Alias)(&h),
+        Amount: h.Amount.Round(AssetScale(h.AssetCode)).InexactFloat64(),
+    }
+    if h.CapturedAmount != nil {
+        amount := h.CapturedAmount.Round(AssetScale(h.AssetCode)).InexactFloat64()
+        out.CapturedAmount = &amount
+    }
+    return json.Marshal(out)
+}
+
+// exactAmounts returns the hold's amounts for API v2
+func (h Hold) exactAmounts() map[string]string {
+    amounts := map[string]string{
+        "amount": exactAmount(h.Amount, h.AssetCode),
+    }
+    if h.CapturedAmount != nil {
+        amounts["captured_amount"] = exactAmount(*h.CapturedAmount, h.AssetCode)
+    }
+    return amounts
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+    "encoding/json"
+    "time"
+)
+
+// IdempotencyKey is the client-supplied Idempotency-Key of a request together
+// with a fingerprint of the request it was sent with
+type IdempotencyKey struct {
+    Key         string
+    Fingerprint string
+}
+
+// IdempotencyRecord is the stored response of a request made with an idempotency key
+type IdempotencyRecord struct {
+    Principal      string // the caller the key belongs to
+    Scope          string
+    Key            string
+    Fingerprint    string
+    ResponseStatus int
+    ResponseBody   json.RawMessage
+    CreatedAt      time.Time
+}
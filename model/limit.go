@@ -0,0 +1,37 @@
+package model
+
+import (
+    "github.com/shopspring/decimal"
+)
+
+// Limits caps the outgoing transfers of an account. Amounts are in the
+// account's asset; windows are calendar days and months in the database time
+// zone (UTC by default). A nil field is unlimited.
+type Limits struct {
+    MaxSingleAmount  *decimal.Decimal `json:"max_single_amount,omitempty"`
+    MaxDailyAmount   *decimal.Decimal `json:"max_daily_amount,omitempty"`
+    MaxMonthlyAmount *decimal.Decimal `json:"max_monthly_amount,omitempty"`
+    MaxDailyCount    *int             `json:"max_daily_count,omitempty"`
+    MaxMonthlyCount  *int             `json:"max_monthly_count,omitempty"`
+}
+
+// LimitTier is a named set of limits shared by the accounts assigned to it
+type LimitTier struct {
+    Name string `json:"name"`
+    Limits
+}
+
+// AccountLimits assigns an account to a tier and overrides any of its limits
+type AccountLimits struct {
+    AccountID int    `json:"account_id"`
+    Tier      string `json:"tier,omitempty"`
+    Limits
+}
+
+// LimitUsage is an account's outgoing transfer volume in the current windows
+type LimitUsage struct {
+    DailyAmount   decimal.Decimal `json:"daily_amount"`
+    DailyCount    int             `json:"daily_count"`
+    MonthlyAmount decimal.Decimal `json:"monthly_amount"`
+    MonthlyCount  int             `json:"monthly_count"`
+}
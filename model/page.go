@@ -0,0 +1,39 @@
+package model
+
+import (
+    "time"
+    "github.com/shopspring/decimal"
+)
+
+// TransactionCursor is the position of a transaction in history order,
+// newest first by created_at and then id
+type TransactionCursor struct {
+    CreatedAt time.Time
+    ID        int
+}
+
+// TransactionPage is one page of transaction history. NextCursor fetches the
+// following page and is empty on the last one.
+type TransactionPage struct {
+    Transactions []*Transaction `json:"transactions"`
+    NextCursor   string         `json:"next_cursor,omitempty"`
+}
+
+// Directions of a transaction relative to the account whose history is read
+const (
+    DirectionIncoming = "incoming"
+    DirectionOutgoing = "outgoing"
+)
+
+// TransactionFilter narrows transaction history. Nil and empty fields do not
+// filter. Direction and CounterpartyID are relative to AccountID, which the
+// account history endpoint sets to its own account.
+type TransactionFilter struct {
+    AccountID      *int
+    CounterpartyID *int
+    Direction      string
+    From           *time.Time // created at or after
+    To             *time.Time // created before
+    MinAmount      *decimal.Decimal
+    MaxAmount      *decimal.Decimal
+}
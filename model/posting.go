@@ -0,0 +1,56 @@
+package model
+
+import (
+    "encoding/json"
+    "time"
+    "github.com/shopspring/decimal"
+)
+
+// Posting is one leg of a double-entry transaction. Debits are negative,
+// credits are positive, and the postings of a transaction always sum to zero
+// per asset.
+type Posting struct {
+    ID            int             `json:"id,omitempty"`
+    TransactionID int             `json:"transaction_id"`
+    AccountID     int             `json:"account_id"`
+    AssetCode     string          `json:"asset_code"`
+    Amount        decimal.Decimal `json:"amount"`
+    BalanceAfter  decimal.Decimal `json:"balance_after"`
+    CreatedAt     time.Time       `json:"created_at,omitempty"`
+}
+
+// MarshalJSON customizes JSON marshaling to format amounts with the scale of the posting's asset
+func (p Posting) MarshalJSON() ([]byte, error) {
+    type Alias Posting
+    return json.Marshal(&struct {
+        *Alias
+        Amount       float64 `json:"amount"`
+        BalanceAfter float64 `json:"balance_after"`
+    }{
+        Alias:        (*Alias)(&p),
+        Amount:       p.Amount.Round(AssetScale(p.AssetCode)).InexactFloat64(),
+        BalanceAfter: p.BalanceAfter.Round(AssetScale(p.AssetCode)).InexactFloat64(),
+    })
+}
+
+// exactAmounts returns the posting's amounts for API v2
+func (p Posting) exactAmounts() map[string]string {
+    return map[string]string{
+        "amount":        exactAmount(p.Amount, p.AssetCode),
+        "balance_after": exactAmount(p.BalanceAfter, p.AssetCode),
+    }
+}
+
+// PostingsBalanced reports whether the given postings sum to zero for every asset
+func PostingsBalanced(postings []Posting) bool {
+    sums := make(map[string]decimal.Decimal)
+    for _, p := range postings {
+        sums[p.AssetCode] = sums[p.AssetCode].Add(p.Amount)
+    }
+    for _, sum := range sums {
+        if !sum.IsZero() {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+    "time"
+)
+
+// Roles of authenticated callers
+const (
+    RoleCustomer = "customer" // acts on the accounts of one customer
+    RoleOperator = "operator" // back office staff
+    RoleAdmin    = "admin"    // manages accounts, keys and configuration
+    RoleSystem   = "system"   // the service's own background work; no credential carries it
+)
+
+// IsRole reports whether role is a known role that keys and tokens may carry
+func IsRole(role string) bool {
+    switch role {
+    case RoleCustomer, RoleOperator, RoleAdmin:
+        return true
+    }
+    return false
+}
+
+// Ways a principal can authenticate
+const (
+    AuthMethodAPIKey = "api_key"
+    AuthMethodJWT    = "jwt"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+    Subject    string // "api_key:<id>" for API keys, the sub claim for JWTs
+    Method     string
+    Role       string
+    CustomerID *int // the customer a customer principal acts for
+}
+
+// SystemPrincipal is the principal of the service's own background work, such
+// as the scheduler workers
+func SystemPrincipal(name string) *Principal {
+    return &Principal{Subject: "system:" + name, Role: RoleSystem}
+}
+
+// APIKey is a key issued to an API client. Only a hash of the key is stored;
+// the key itself is returned once, when it is issued.
+type APIKey struct {
+    ID         int        `json:"id"`
+    Name       string     `json:"name"`
+    Prefix     string     `json:"prefix"` // first characters of the key, to tell keys apart
+    Role       string     `json:"role"`
+    CustomerID *int       `json:"customer_id,omitempty"`
+    ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+    RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+    CreatedAt  time.Time  `json:"created_at"`
+    Hash       string     `json:"-"`
+}
+
+// Active reports whether the key may authenticate at the given time
+func (k APIKey) Active(at time.Time) bool {
+    if k.RevokedAt != nil {
+        return false
+    }
+    return k.ExpiresAt == nil || at.Before(*k.ExpiresAt)
+}
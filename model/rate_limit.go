@@ -0,0 +1,87 @@
+package model
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "strconv"
+    "strings"
+    "time"
+)
+
+var ErrInvalidRateLimit = errors.New("invalid rate limit")
+
+// RateLimit is a token bucket: it holds up to Burst requests and refills
+// completely over Per
+type RateLimit struct {
+    Burst int
+    Per   time.Duration
+}
+
+// ParseRateLimit reads a limit written as "<requests>/<duration>", such as
+// "60/1m"
+func ParseRateLimit(s string) (RateLimit, error) {
+    n, per, ok := strings.Cut(strings.TrimSpace(s), "/")
+    if !ok {
+        return RateLimit{}, fmt.Errorf("%w: %q is not <requests>/<duration>", ErrInvalidRateLimit, s)
+    }
+    burst, err := strconv.Atoi(n)
+    if err != nil || burst < 1 {
+        return RateLimit{}, fmt.Errorf("%w: %q needs a positive number of requests", ErrInvalidRateLimit, s)
+    }
+    d, err := time.ParseDuration(per)
+    if err != nil || d <= 0 {
+        return RateLimit{}, fmt.Errorf("%w: %q needs a positive duration", ErrInvalidRateLimit, s)
+    }
+    return RateLimit{Burst: burst, Per: d}, nil
+}
+
+// String writes the limit as ParseRateLimit reads it
+func (l RateLimit) String() string {
+    return strconv.Itoa(l.Burst) + "/" + l.Per.String()
+}
+
+// TokenBucket is the state of one client's bucket
+type TokenBucket struct {
+    Tokens    float64
+    UpdatedAt time.Time
+}
+
+// RateLimitDecision is the outcome of taking a token
+type RateLimitDecision struct {
+    Allowed    bool
+    Limit      int           // the bucket size
+    Remaining  int           // whole tokens left
+    RetryAfter time.Duration // until the next token, when refused
+    Reset      time.Duration // until the bucket is full again
+}
+
+// NewTokenBucket returns a full bucket
+func (l RateLimit) NewTokenBucket(now time.Time) TokenBucket {
+    return TokenBucket{Tokens: float64(l.Burst), UpdatedAt: now}
+}
+
+// Take refills the bucket for the time since it was last updated and takes
+// one token from it if there is one
+func (l RateLimit) Take(b TokenBucket, now time.Time) (TokenBucket, RateLimitDecision) {
+    rate := float64(l.Burst) / l.Per.Seconds() // tokens per second
+    if elapsed := now.Sub(b.UpdatedAt); elapsed > 0 {
+        b.Tokens = math.Min(float64(l.Burst), b.Tokens+elapsed.Seconds()*rate)
+        b.UpdatedAt = now
+    }
+
+    decision := RateLimitDecision{Limit: l.Burst}
+    if b.Tokens >= 1 {
+        b.Tokens--
+        decision.Allowed = true
+    } else {
+        decision.RetryAfter = secondsDuration((1 - b.Tokens) / rate)
+    }
+    decision.Remaining = int(math.Floor(b.Tokens))
+    decision.Reset = secondsDuration((float64(l.Burst) - b.Tokens) / rate)
+    return b, decision
+}
+
+func secondsDuration(s float64) time.Duration {
+    return time.Duration(math.Ceil(s * float64(time.Second)))
+}
@@ -6,4 +6,17 @@ type APIResponse struct {
     Message string      `json:"message,omitempty"`
     Data    interface{} `json:"data,omitempty"`
     Error   interface{} `json:"error,omitempty"`
+    Code    string      `json:"code,omitempty"` // stable error code of a failure
+}
+
+// Problem is an RFC 9457 problem details body. It replaces APIResponse for
+// failures on API v2 and for clients that accept application/problem+json.
+type Problem struct {
+    Type          string      `json:"type"`
+    Title         string      `json:"title"`
+    Status        int         `json:"status"`
+    Detail        string      `json:"detail,omitempty"`
+    Code          string      `json:"code"`
+    CorrelationID string      `json:"correlation_id,omitempty"`
+    Data          interface{} `json:"data,omitempty"` // details of the rejection, such as a limit
 } 
\ No newline at end of file
@@ -0,0 +1,64 @@
+package model
+
+import (
+    "encoding/json"
+    "time"
+    "github.com/shopspring/decimal"
+)
+
+// Scheduled transfer statuses
+const (
+    ScheduledStatusScheduled = "scheduled"
+    ScheduledStatusExecuted  = "executed"
+    ScheduledStatusFailed    = "failed"
+    ScheduledStatusCancelled = "cancelled"
+)
+
+// ScheduledTransfer is a transfer executed by the background worker once
+// ExecuteAt has passed
+type ScheduledTransfer struct {
+    ID                   int             `json:"id,omitempty"`
+    SourceAccountID      int             `json:"source_account_id"`
+    DestinationAccountID int             `json:"destination_account_id"`
+    SourceAccount        string          `json:"source_account,omitempty"`      // public IDs, as on Transaction
+    DestinationAccount   string          `json:"destination_account,omitempty"`
+    AssetCode            string          `json:"asset_code,omitempty"`
+    Amount               decimal.Decimal `json:"amount"`
+    Convert              bool            `json:"convert,omitempty"`
+    ExecuteAt            time.Time       `json:"execute_at"`
+    Status               string          `json:"status,omitempty"`
+    TransactionID        *int            `json:"transaction_id,omitempty"` // set once executed
+    FailureReason        string          `json:"failure_reason,omitempty"`
+    Attempts             int             `json:"attempts,omitempty"`        // failed attempts that will be retried
+    NextAttemptAt        *time.Time      `json:"next_attempt_at,omitempty"` // set while waiting to retry
+    CreatedAt            time.Time       `json:"created_at,omitempty"`
+    UpdatedAt            time.Time       `json:"updated_at,omitempty"`
+}
+
+// Transfer returns the transfer to execute for s
+func (s ScheduledTransfer) Transfer() Transaction {
+    return Transaction{
+        SourceAccountID:      s.SourceAccountID,
+        DestinationAccountID: s.DestinationAccountID,
+        AssetCode:            s.AssetCode,
+        Amount:               s.Amount,
+        Convert:              s.Convert,
+    }
+}
+
+// MarshalJSON customizes JSON marshaling to format the amount with the scale of its asset
+func (s ScheduledTransfer) MarshalJSON() ([]byte, error) {
+    type Alias ScheduledTransfer
+    return json.Marshal(&struct {
+        *Alias
+        Amount float64 `json:"amount"`
+    }{
+        Alias:  (*Alias)(&s),
+        Amount: s.Amount.Round(AssetScale(s.AssetCode)).InexactFloat64(),
+    })
+}
+
+// exactAmounts returns the amount for API v2
+func (s ScheduledTransfer) exactAmounts() map[string]string {
+    return map[string]string{"amount": exactAmount(s.Amount, s.AssetCode)}
+}
@@ -0,0 +1,104 @@
+package model
+
+import (
+    "encoding/json"
+    "time"
+    "github.com/shopspring/decimal"
+)
+
+// Standing order frequencies
+const (
+    FrequencyDaily   = "daily"
+    FrequencyWeekly  = "weekly"
+    FrequencyMonthly = "monthly" // on DayOfMonth, or the month's last day if shorter
+    FrequencyCron    = "cron"    // five-field cron expression in Cron
+)
+
+// Standing order statuses
+const (
+    StandingOrderActive    = "active"
+    StandingOrderPaused    = "paused"
+    StandingOrderCompleted = "completed"
+    StandingOrderCancelled = "cancelled"
+)
+
+// Standing order run statuses
+const (
+    RunStatusSucceeded = "succeeded"
+    RunStatusFailed    = "failed"
+)
+
+// StandingOrder is a recurring transfer. Occurrences are counted once they
+// succeed or run out of retries; the order completes at EndAt or after
+// MaxOccurrences, whichever comes first.
+type StandingOrder struct {
+    ID                   int             `json:"id,omitempty"`
+    SourceAccountID      int             `json:"source_account_id"`
+    DestinationAccountID int             `json:"destination_account_id"`
+    SourceAccount        string          `json:"source_account,omitempty"`      // public IDs, as on Transaction
+    DestinationAccount   string          `json:"destination_account,omitempty"`
+    AssetCode            string          `json:"asset_code,omitempty"`
+    Amount               decimal.Decimal `json:"amount"`
+    Convert              bool            `json:"convert,omitempty"`
+    Frequency            string          `json:"frequency"`
+    DayOfMonth           int             `json:"day_of_month,omitempty"`
+    Cron                 string          `json:"cron,omitempty"`
+    StartAt              time.Time       `json:"start_at"`
+    EndAt                *time.Time      `json:"end_at,omitempty"`
+    MaxOccurrences       *int            `json:"max_occurrences,omitempty"`
+
+    // Retry policy: a failed run is retried up to MaxRetries times,
+    // RetryIntervalSeconds apart, before the occurrence is skipped
+    MaxRetries           int             `json:"max_retries"`
+    RetryIntervalSeconds int             `json:"retry_interval_seconds"`
+
+    Status               string          `json:"status,omitempty"`
+    Occurrences          int             `json:"occurrences"`
+    FailedAttempts       int             `json:"failed_attempts"` // failures of the pending occurrence
+    CurrentRunAt         *time.Time      `json:"current_run_at,omitempty"` // the pending occurrence
+    NextRunAt            *time.Time      `json:"next_run_at,omitempty"`    // when the worker runs it, later on retries
+    CreatedAt            time.Time       `json:"created_at,omitempty"`
+    UpdatedAt            time.Time       `json:"updated_at,omitempty"`
+}
+
+// Transfer returns the transfer executed for each occurrence of o
+func (o StandingOrder) Transfer() Transaction {
+    id := o.ID
+    return Transaction{
+        StandingOrderID:      &id,
+        SourceAccountID:      o.SourceAccountID,
+        DestinationAccountID: o.DestinationAccountID,
+        AssetCode:            o.AssetCode,
+        Amount:               o.Amount,
+        Convert:              o.Convert,
+    }
+}
+
+// MarshalJSON customizes JSON marshaling to format the amount with the scale of its asset
+func (o StandingOrder) MarshalJSON() ([]byte, error) {
+    type Alias StandingOrder
+    return json.Marshal(&struct {
+        *Alias
+        Amount float64 `json:"amount"`
+    }{
+        Alias:  (*Alias)(&o),
+        Amount: o.Amount.Round(AssetScale(o.AssetCode)).InexactFloat64(),
+    })
+}
+
+// exactAmounts returns the amount for API v2
+func (o StandingOrder) exactAmounts() map[string]string {
+    return map[string]string{"amount": exactAmount(o.Amount, o.AssetCode)}
+}
+
+// StandingOrderRun is one execution attempt of a standing order occurrence
+type StandingOrderRun struct {
+    ID              int       `json:"id,omitempty"`
+    StandingOrderID int       `json:"standing_order_id"`
+    ScheduledFor    time.Time `json:"scheduled_for"`
+    Attempt         int       `json:"attempt"`
+    Status          string    `json:"status"`
+    TransactionID   *int      `json:"transaction_id,omitempty"`
+    FailureReason   string    `json:"failure_reason,omitempty"`
+    CreatedAt       time.Time `json:"created_at,omitempty"`
+}
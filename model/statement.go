@@ -0,0 +1,75 @@
+package model
+
+import (
+    "encoding/json"
+    "time"
+    "github.com/shopspring/decimal"
+)
+
+// StatementEntry is how a transaction changed one account: the net of the
+// account's postings in it, negative for money out, and the balance after them
+type StatementEntry struct {
+    AccountID             int             `json:"account_id"`
+    AssetCode             string          `json:"asset_code"`
+    Amount                decimal.Decimal `json:"amount"`
+    BalanceAfter          decimal.Decimal `json:"balance_after"`
+    CounterpartyAccountID int             `json:"counterparty_account_id"`
+}
+
+// MarshalJSON customizes JSON marshaling to format amounts with the scale of the entry's asset
+func (e StatementEntry) MarshalJSON() ([]byte, error) {
+    type Alias StatementEntry
+    scale := AssetScale(e.AssetCode)
+    return json.Marshal(&struct {
+        *Alias
+        Amount       float64 `json:"amount"`
+        BalanceAfter float64 `json:"balance_after"`
+    }{
+        Alias:        (*Alias)(&e),
+        Amount:       e.Amount.Round(scale).InexactFloat64(),
+        BalanceAfter: e.BalanceAfter.Round(scale).InexactFloat64(),
+    })
+}
+
+// exactAmounts returns the entry's amounts for API v2
+func (e StatementEntry) exactAmounts() map[string]string {
+    return map[string]string{
+        "amount":        exactAmount(e.Amount, e.AssetCode),
+        "balance_after": exactAmount(e.BalanceAfter, e.AssetCode),
+    }
+}
+
+// Statement lists the transactions that changed an account from From up to
+// To, oldest first, between its balances at those times
+type Statement struct {
+    AccountID      int             `json:"account_id"`
+    AssetCode      string          `json:"asset_code"`
+    From           time.Time       `json:"from"`
+    To             time.Time       `json:"to"`
+    OpeningBalance decimal.Decimal `json:"opening_balance"`
+    ClosingBalance decimal.Decimal `json:"closing_balance"`
+    Entries        []*Transaction  `json:"entries"`
+}
+
+// MarshalJSON customizes JSON marshaling to format balances with the scale of the account's asset
+func (s Statement) MarshalJSON() ([]byte, error) {
+    type Alias Statement
+    scale := AssetScale(s.AssetCode)
+    return json.Marshal(&struct {
+        *Alias
+        OpeningBalance float64 `json:"opening_balance"`
+        ClosingBalance float64 `json:"closing_balance"`
+    }{
+        Alias:          (*Alias)(&s),
+        OpeningBalance: s.OpeningBalance.Round(scale).InexactFloat64(),
+        ClosingBalance: s.ClosingBalance.Round(scale).InexactFloat64(),
+    })
+}
+
+// exactAmounts returns the balances for API v2
+func (s Statement) exactAmounts() map[string]string {
+    return map[string]string{
+        "opening_balance": exactAmount(s.OpeningBalance, s.AssetCode),
+        "closing_balance": exactAmount(s.ClosingBalance, s.AssetCode),
+    }
+}
@@ -6,22 +6,91 @@ import (
     "github.com/shopspring/decimal"
 )
 
+// Transaction kinds
+const (
+    TransactionKindTransfer = "transfer"
+    TransactionKindReversal = "reversal"
+)
+
 type Transaction struct {
     ID                   int             `json:"id,omitempty"`
+    Kind                 string          `json:"kind,omitempty"`
+    ReversalOf           *int            `json:"reversal_of,omitempty"` // the transaction a reversal refunds
+    StandingOrderID      *int            `json:"standing_order_id,omitempty"` // the order that produced the transfer
     SourceAccountID      int             `json:"source_account_id"`
     DestinationAccountID int             `json:"destination_account_id"`
+
+    // Requests may name the accounts by public ID instead; the service
+    // resolves them to the account IDs above. Responses carry both.
+    SourceAccount        string          `json:"source_account,omitempty"`
+    DestinationAccount   string          `json:"destination_account,omitempty"`
+
+    AssetCode            string          `json:"asset_code,omitempty"`
     Amount               decimal.Decimal `json:"amount"`
+    Convert              bool            `json:"convert,omitempty"` // request a cross-asset conversion
+    QuoteID              string          `json:"quote_id,omitempty"`
+
+    // Set on cross-asset transfers: the credited asset and amount, the rate
+    // used and the fraction of the converted amount removed by rounding down
+    DestinationAssetCode string           `json:"destination_asset_code,omitempty"`
+    DestinationAmount    *decimal.Decimal `json:"destination_amount,omitempty"`
+    FXRate               *decimal.Decimal `json:"fx_rate,omitempty"`
+    FXRounding           *decimal.Decimal `json:"fx_rounding,omitempty"`
+
+    // Set on transfers that were charged a fee: the fee, in the source asset,
+    // paid by the source on top of the amount and the account it went to
+    Fee                  *decimal.Decimal `json:"fee,omitempty"`
+    FeeAccountID         *int             `json:"fee_account_id,omitempty"`
+
+    // Set on account history and statement entries: how the transaction
+    // changed that account
+    Entry                *StatementEntry `json:"entry,omitempty"`
+
     CreatedAt            time.Time       `json:"created_at,omitempty"`
 }
 
-// MarshalJSON customizes JSON marshaling to format amount with 5 decimal places
+// MarshalJSON customizes JSON marshaling to format amounts with the scale of their asset
 func (t Transaction) MarshalJSON() ([]byte, error) {
     type Alias Transaction
-    return json.Marshal(&struct {
+    out := &struct {
         *Alias
-        Amount float64 `json:"amount"`
+        Amount            float64  `json:"amount"`
+        DestinationAmount *float64 `json:"destination_amount,omitempty"`
+        FXRounding        *float64 `json:"fx_rounding,omitempty"`
+        Fee               *float64 `json:"fee,omitempty"`
     }{
         Alias:  (*Alias)(&t),
-        Amount: t.Amount.Round(5).InexactFloat64(),
-    })
-}
\ No newline at end of file
+        Amount: t.Amount.Round(AssetScale(t.AssetCode)).InexactFloat64(),
+    }
+    if t.DestinationAmount != nil {
+        amount := t.DestinationAmount.Round(AssetScale(t.DestinationAssetCode)).InexactFloat64()
+        out.DestinationAmount = &amount
+    }
+    if t.FXRounding != nil {
+        rounding := t.FXRounding.InexactFloat64()
+        out.FXRounding = &rounding
+    }
+    if t.Fee != nil {
+        fee := t.Fee.Round(AssetScale(t.AssetCode)).InexactFloat64()
+        out.Fee = &fee
+    }
+    return json.Marshal(out)
+}
+
+// exactAmounts returns the transaction's amounts for API v2. fx_rounding is
+// the unrounded remainder of a conversion and keeps its full precision.
+func (t Transaction) exactAmounts() map[string]string {
+    amounts := map[string]string{
+        "amount": exactAmount(t.Amount, t.AssetCode),
+    }
+    if t.DestinationAmount != nil {
+        amounts["destination_amount"] = exactAmount(*t.DestinationAmount, t.DestinationAssetCode)
+    }
+    if t.FXRounding != nil {
+        amounts["fx_rounding"] = t.FXRounding.String()
+    }
+    if t.Fee != nil {
+        amounts["fee"] = exactAmount(*t.Fee, t.AssetCode)
+    }
+    return amounts
+}
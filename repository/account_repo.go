@@ -3,20 +3,40 @@ package repository
 import (
     "context"
     "database/sql"
+    "errors"
     "transfer-service/model"
+    "github.com/lib/pq"
     "github.com/shopspring/decimal"
 )
 
 type AccountRepository interface {
-    Create(ctx context.Context, account model.Account) error
+    Create(ctx context.Context, account model.Account) (int, error)
+    CreateWithTx(ctx context.Context, tx *sql.Tx, account model.Account) (int, error)
     GetByID(ctx context.Context, id int) (*model.Account, error)
     GetByIDWithLock(ctx context.Context, tx *sql.Tx, id int) (*model.Account, error)
+    GetByPublicID(ctx context.Context, publicID string) (*model.Account, error)
+    GetByCustomerID(ctx context.Context, customerID int) ([]*model.Account, error)
     UpdateBalance(ctx context.Context, id int, newBalance decimal.Decimal) error
     UpdateBalanceWithTx(ctx context.Context, tx *sql.Tx, id int, newBalance decimal.Decimal) error
-    DeleteByID(ctx context.Context, id int) error
+    ApplyPostingWithTx(ctx context.Context, tx *sql.Tx, id int, amount decimal.Decimal) (decimal.Decimal, error)
+    GetPostingsByAccountID(ctx context.Context, accountID int) ([]*model.Posting, error)
+    GetLedgerBalance(ctx context.Context, id int) (decimal.Decimal, error)
+    UpdateStatusWithTx(ctx context.Context, tx *sql.Tx, id int, status string) error
+    UpdateOverdraftLimitWithTx(ctx context.Context, tx *sql.Tx, id int, limit decimal.Decimal) error
     GetDB() *sql.DB
 }
 
+// accountColumns is the column list read by scanAccount. The held balance only
+// counts pending holds that have not expired, so expiry needs no background job.
+const accountColumns = `id, public_id, asset_code, balance, status, overdraft_limit, customer_id,
+    (SELECT COALESCE(SUM(h.amount), 0) FROM holds h
+     WHERE h.source_account_id = accounts.id AND h.status = 'pending' AND h.expires_at > NOW())`
+
+// accountRefColumns selects the public IDs of the source and destination
+// accounts of a transaction, hold, scheduled transfer or standing order
+const accountRefColumns = `(SELECT a.public_id FROM accounts a WHERE a.id = source_account_id),
+    (SELECT a.public_id FROM accounts a WHERE a.id = destination_account_id)`
+
 type accountRepo struct {
     db *sql.DB
 }
@@ -25,27 +45,95 @@ func NewAccountRepository(db *sql.DB) AccountRepository {
     return &accountRepo{db: db}
 }
 
-func (r *accountRepo) Create(ctx context.Context, a model.Account) error {
-    _, err := r.db.ExecContext(ctx, "INSERT INTO accounts (id, balance) VALUES ($1, $2)", a.ID, a.Balance)
-    return err
+func (r *accountRepo) Create(ctx context.Context, a model.Account) (int, error) {
+    return r.CreateWithTx(ctx, nil, a)
+}
+
+// CreateWithTx inserts the account within a caller-supplied database transaction
+// and returns its ID. An account without an ID takes the next value of
+// accounts_id_seq; values already taken by legacy accounts created with a
+// client-chosen ID are skipped.
+func (r *accountRepo) CreateWithTx(ctx context.Context, tx *sql.Tx, a model.Account) (int, error) {
+    if a.ID != 0 {
+        _, err := executor(r.db, tx).ExecContext(ctx, "INSERT INTO accounts (id, public_id, asset_code, balance, opening_balance, overdraft_limit, customer_id) VALUES ($1, $2, $3, $4, $4, $5, $6)", a.ID, a.PublicID, a.AssetCode, a.Balance, a.OverdraftLimit, a.CustomerID)
+        return a.ID, err
+    }
+
+    // A failed insert aborts tx, so each attempt runs under a savepoint
+    savepoint := func(stmt string) error {
+        if tx == nil {
+            return nil
+        }
+        _, err := tx.ExecContext(ctx, stmt+" allocate_account_id")
+        return err
+    }
+    for {
+        if err := savepoint("SAVEPOINT"); err != nil {
+            return 0, err
+        }
+        var id int
+        err := executor(r.db, tx).QueryRowContext(ctx, "INSERT INTO accounts (public_id, asset_code, balance, opening_balance, overdraft_limit, customer_id) VALUES ($1, $2, $3, $3, $4, $5) RETURNING id", a.PublicID, a.AssetCode, a.Balance, a.OverdraftLimit, a.CustomerID).Scan(&id)
+        if err == nil {
+            return id, savepoint("RELEASE SAVEPOINT")
+        }
+        if !isPrimaryKeyViolation(err, "accounts_pkey") {
+            return 0, err
+        }
+        if err := savepoint("ROLLBACK TO SAVEPOINT"); err != nil {
+            return 0, err
+        }
+    }
+}
+
+// isPrimaryKeyViolation reports whether err is a duplicate key in the named
+// primary key constraint
+func isPrimaryKeyViolation(err error, constraint string) bool {
+    var pqErr *pq.Error
+    return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
 }
 
 func (r *accountRepo) GetByID(ctx context.Context, id int) (*model.Account, error) {
-    var a model.Account
-    err := r.db.QueryRowContext(ctx, "SELECT id, balance FROM accounts WHERE id = $1", id).Scan(&a.ID, &a.Balance)
+    return scanAccount(r.db.QueryRowContext(ctx, "SELECT "+accountColumns+" FROM accounts WHERE id = $1", id))
+}
+
+func (r *accountRepo) GetByPublicID(ctx context.Context, publicID string) (*model.Account, error) {
+    return scanAccount(r.db.QueryRowContext(ctx, "SELECT "+accountColumns+" FROM accounts WHERE public_id = $1", publicID))
+}
+
+// GetByCustomerID returns the accounts owned by a customer in ID order
+func (r *accountRepo) GetByCustomerID(ctx context.Context, customerID int) ([]*model.Account, error) {
+    rows, err := r.db.QueryContext(ctx, "SELECT "+accountColumns+" FROM accounts WHERE customer_id = $1 ORDER BY id", customerID)
     if err != nil {
         return nil, err
     }
-    return &a, nil
+    defer rows.Close()
+
+    accounts := []*model.Account{}
+    for rows.Next() {
+        account, err := scanAccount(rows)
+        if err != nil {
+            return nil, err
+        }
+        accounts = append(accounts, account)
+    }
+
+    return accounts, rows.Err()
 }
 
 // GetByIDWithLock uses SELECT FOR UPDATE to lock the row for update
 func (r *accountRepo) GetByIDWithLock(ctx context.Context, tx *sql.Tx, id int) (*model.Account, error) {
+    return scanAccount(tx.QueryRowContext(ctx, "SELECT "+accountColumns+" FROM accounts WHERE id = $1 FOR UPDATE", id))
+}
+
+// scanAccount reads one row selected with accountColumns
+func scanAccount(row rowScanner) (*model.Account, error) {
     var a model.Account
-    err := tx.QueryRowContext(ctx, "SELECT id, balance FROM accounts WHERE id = $1 FOR UPDATE", id).Scan(&a.ID, &a.Balance)
+    var customerID sql.NullInt64
+    err := row.Scan(&a.ID, &a.PublicID, &a.AssetCode, &a.Balance, &a.Status, &a.OverdraftLimit, &customerID, &a.HeldBalance)
     if err != nil {
         return nil, err
     }
+    a.CustomerID = intPtr(customerID)
     return &a, nil
 }
 
@@ -60,8 +148,51 @@ func (r *accountRepo) UpdateBalanceWithTx(ctx context.Context, tx *sql.Tx, id in
     return err
 }
 
-func (r *accountRepo) DeleteByID(ctx context.Context, id int) error {
-    _, err := r.db.ExecContext(ctx, "DELETE FROM accounts WHERE id = $1", id)
+// ApplyPostingWithTx adds a signed posting amount to the account balance within a
+// transaction and returns the resulting balance
+func (r *accountRepo) ApplyPostingWithTx(ctx context.Context, tx *sql.Tx, id int, amount decimal.Decimal) (decimal.Decimal, error) {
+    var balance decimal.Decimal
+    err := tx.QueryRowContext(ctx, "UPDATE accounts SET balance = balance + $1 WHERE id = $2 RETURNING balance", amount, id).Scan(&balance)
+    if err != nil {
+        return decimal.Zero, err
+    }
+    return balance, nil
+}
+
+func (r *accountRepo) GetPostingsByAccountID(ctx context.Context, accountID int) ([]*model.Posting, error) {
+    rows, err := r.db.QueryContext(ctx,
+        "SELECT "+postingColumns+" FROM postings WHERE account_id = $1 ORDER BY id DESC",
+        accountID,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    return scanPostings(rows)
+}
+
+// GetLedgerBalance derives the account balance from its opening balance and postings
+func (r *accountRepo) GetLedgerBalance(ctx context.Context, id int) (decimal.Decimal, error) {
+    var balance decimal.Decimal
+    err := r.db.QueryRowContext(ctx, "SELECT ledger_balance FROM account_ledger_balances WHERE account_id = $1", id).Scan(&balance)
+    if err != nil {
+        return decimal.Zero, err
+    }
+    return balance, nil
+}
+
+// UpdateStatusWithTx changes the account status within a transaction. Accounts
+// are closed rather than deleted, so their transactions keep their references.
+func (r *accountRepo) UpdateStatusWithTx(ctx context.Context, tx *sql.Tx, id int, status string) error {
+    _, err := executor(r.db, tx).ExecContext(ctx, "UPDATE accounts SET status = $1, status_changed_at = CURRENT_TIMESTAMP WHERE id = $2", status, id)
+    return err
+}
+
+// UpdateOverdraftLimitWithTx changes how far below zero the account may go.
+// The database rejects a limit the current balance already exceeds.
+func (r *accountRepo) UpdateOverdraftLimitWithTx(ctx context.Context, tx *sql.Tx, id int, limit decimal.Decimal) error {
+    _, err := executor(r.db, tx).ExecContext(ctx, "UPDATE accounts SET overdraft_limit = $1 WHERE id = $2", limit, id)
     return err
 }
 
@@ -0,0 +1,81 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "time"
+    "transfer-service/model"
+)
+
+type APIKeyRepository interface {
+    Create(ctx context.Context, key model.APIKey) (*model.APIKey, error)
+    GetByHash(ctx context.Context, hash string) (*model.APIKey, error)
+    GetAll(ctx context.Context) ([]*model.APIKey, error)
+    Revoke(ctx context.Context, id int, at time.Time) (*model.APIKey, error)
+}
+
+// apiKeyColumns is the column list read by scanAPIKey
+const apiKeyColumns = "id, name, key_prefix, key_hash, role, customer_id, expires_at, revoked_at, created_at"
+
+type apiKeyRepo struct {
+    db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) APIKeyRepository {
+    return &apiKeyRepo{db: db}
+}
+
+func (r *apiKeyRepo) Create(ctx context.Context, k model.APIKey) (*model.APIKey, error) {
+    return scanAPIKey(r.db.QueryRowContext(ctx,
+        `INSERT INTO api_keys (name, key_prefix, key_hash, role, customer_id, expires_at)
+         VALUES ($1, $2, $3, $4, $5, $6) RETURNING `+apiKeyColumns,
+        k.Name, k.Prefix, k.Hash, k.Role, k.CustomerID, k.ExpiresAt,
+    ))
+}
+
+func (r *apiKeyRepo) GetByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+    return scanAPIKey(r.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1", hash))
+}
+
+func (r *apiKeyRepo) GetAll(ctx context.Context) ([]*model.APIKey, error) {
+    rows, err := r.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY id")
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    keys := []*model.APIKey{}
+    for rows.Next() {
+        key, err := scanAPIKey(rows)
+        if err != nil {
+            return nil, err
+        }
+        keys = append(keys, key)
+    }
+
+    return keys, rows.Err()
+}
+
+// Revoke marks a key revoked; revoking it again keeps the first time. It
+// returns sql.ErrNoRows if the key does not exist.
+func (r *apiKeyRepo) Revoke(ctx context.Context, id int, at time.Time) (*model.APIKey, error) {
+    return scanAPIKey(r.db.QueryRowContext(ctx,
+        "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1 RETURNING "+apiKeyColumns,
+        id, at.UTC(),
+    ))
+}
+
+// scanAPIKey reads one row selected with apiKeyColumns
+func scanAPIKey(row rowScanner) (*model.APIKey, error) {
+    var k model.APIKey
+    var customerID sql.NullInt64
+    var expiresAt, revokedAt sql.NullTime
+    err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Hash, &k.Role, &customerID, &expiresAt, &revokedAt, &k.CreatedAt)
+    if err != nil {
+        return nil, err
+    }
+    k.CustomerID = intPtr(customerID)
+    k.ExpiresAt = timePtr(expiresAt)
+    k.RevokedAt = timePtr(revokedAt)
+    return &k, nil
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "transfer-service/model"
+)
+
+type AssetRepository interface {
+    GetAll(ctx context.Context) ([]model.Asset, error)
+}
+
+type assetRepo struct {
+    db *sql.DB
+}
+
+func NewAssetRepository(db *sql.DB) AssetRepository {
+    return &assetRepo{db: db}
+}
+
+func (r *assetRepo) GetAll(ctx context.Context) ([]model.Asset, error) {
+    rows, err := r.db.QueryContext(ctx, "SELECT code, scale FROM assets ORDER BY code")
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var assets []model.Asset
+    for rows.Next() {
+        var a model.Asset
+        if err := rows.Scan(&a.Code, &a.Scale); err != nil {
+            return nil, err
+        }
+        assets = append(assets, a)
+    }
+
+    return assets, rows.Err()
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "transfer-service/model"
+)
+
+type CustomerRepository interface {
+    CreateWithTx(ctx context.Context, tx *sql.Tx, customer model.Customer) (*model.Customer, error)
+    GetByID(ctx context.Context, id int) (*model.Customer, error)
+    GetAll(ctx context.Context) ([]*model.Customer, error)
+    Update(ctx context.Context, customer model.Customer) (*model.Customer, error)
+    Delete(ctx context.Context, id int) error
+}
+
+// customerColumns is the column list read by scanCustomer
+const customerColumns = "id, name, external_ref, kyc_tier, created_at, updated_at"
+
+type customerRepo struct {
+    db *sql.DB
+}
+
+func NewCustomerRepository(db *sql.DB) CustomerRepository {
+    return &customerRepo{db: db}
+}
+
+// CreateWithTx inserts the customer within a caller-supplied database transaction
+func (r *customerRepo) CreateWithTx(ctx context.Context, tx *sql.Tx, c model.Customer) (*model.Customer, error) {
+    return scanCustomer(executor(r.db, tx).QueryRowContext(ctx,
+        "INSERT INTO customers (name, external_ref, kyc_tier) VALUES ($1, $2, $3) RETURNING "+customerColumns,
+        c.Name, nullString(c.ExternalRef), c.KYCTier,
+    ))
+}
+
+func (r *customerRepo) GetByID(ctx context.Context, id int) (*model.Customer, error) {
+    return scanCustomer(r.db.QueryRowContext(ctx, "SELECT "+customerColumns+" FROM customers WHERE id = $1", id))
+}
+
+func (r *customerRepo) GetAll(ctx context.Context) ([]*model.Customer, error) {
+    rows, err := r.db.QueryContext(ctx, "SELECT "+customerColumns+" FROM customers ORDER BY id")
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    customers := []*model.Customer{}
+    for rows.Next() {
+        customer, err := scanCustomer(rows)
+        if err != nil {
+            return nil, err
+        }
+        customers = append(customers, customer)
+    }
+
+    return customers, rows.Err()
+}
+
+// Update replaces the customer's name, external reference and KYC tier; it
+// returns sql.ErrNoRows if the customer does not exist
+func (r *customerRepo) Update(ctx context.Context, c model.Customer) (*model.Customer, error) {
+    return scanCustomer(r.db.QueryRowContext(ctx,
+        `UPDATE customers SET name = $2, external_ref = $3, kyc_tier = $4, updated_at = CURRENT_TIMESTAMP
+         WHERE id = $1 RETURNING `+customerColumns,
+        c.ID, c.Name, nullString(c.ExternalRef), c.KYCTier,
+    ))
+}
+
+// Delete removes a customer; it returns sql.ErrNoRows if the customer does not
+// exist and a foreign key violation if it still owns accounts
+func (r *customerRepo) Delete(ctx context.Context, id int) error {
+    res, err := r.db.ExecContext(ctx, "DELETE FROM customers WHERE id = $1", id)
+    if err != nil {
+        return err
+    }
+    n, err := res.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if n == 0 {
+        return sql.ErrNoRows
+    }
+    return nil
+}
+
+// scanCustomer reads one row selected with customerColumns
+func scanCustomer(row rowScanner) (*model.Customer, error) {
+    var c model.Customer
+    var externalRef sql.NullString
+    err := row.Scan(&c.ID, &c.Name, &externalRef, &c.KYCTier, &c.CreatedAt, &c.UpdatedAt)
+    if err != nil {
+        return nil, err
+    }
+    c.ExternalRef = externalRef.String
+    return &c, nil
+}
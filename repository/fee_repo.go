@@ -0,0 +1,118 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "transfer-service/model"
+    "github.com/shopspring/decimal"
+)
+
+type FeeRepository interface {
+    GetSchedules(ctx context.Context) ([]*model.FeeSchedule, error)
+    GetScheduleWithTx(ctx context.Context, tx *sql.Tx, assetCode string) (*model.FeeSchedule, error)
+    UpsertSchedule(ctx context.Context, schedule model.FeeSchedule) (*model.FeeSchedule, error)
+    DeleteSchedule(ctx context.Context, assetCode string) error
+}
+
+// feeScheduleColumns is the column list read by scanFeeSchedule
+const feeScheduleColumns = "asset_code, fee_type, flat_amount, percent, tiers, min_fee, max_fee, fee_account_id, updated_at"
+
+type feeRepo struct {
+    db *sql.DB
+}
+
+func NewFeeRepository(db *sql.DB) FeeRepository {
+    return &feeRepo{db: db}
+}
+
+func (r *feeRepo) GetSchedules(ctx context.Context) ([]*model.FeeSchedule, error) {
+    rows, err := r.db.QueryContext(ctx, "SELECT "+feeScheduleColumns+" FROM fee_schedules ORDER BY asset_code")
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    schedules := []*model.FeeSchedule{}
+    for rows.Next() {
+        schedule, err := scanFeeSchedule(rows)
+        if err != nil {
+            return nil, err
+        }
+        schedules = append(schedules, schedule)
+    }
+
+    return schedules, rows.Err()
+}
+
+// GetScheduleWithTx returns the fee schedule of an asset, or sql.ErrNoRows if
+// its transfers are free
+func (r *feeRepo) GetScheduleWithTx(ctx context.Context, tx *sql.Tx, assetCode string) (*model.FeeSchedule, error) {
+    return scanFeeSchedule(executor(r.db, tx).QueryRowContext(ctx,
+        "SELECT "+feeScheduleColumns+" FROM fee_schedules WHERE asset_code = $1",
+        assetCode,
+    ))
+}
+
+func (r *feeRepo) UpsertSchedule(ctx context.Context, s model.FeeSchedule) (*model.FeeSchedule, error) {
+    var tiers []byte
+    if len(s.Tiers) > 0 {
+        var err error
+        if tiers, err = json.Marshal(s.Tiers); err != nil {
+            return nil, err
+        }
+    }
+
+    err := r.db.QueryRowContext(ctx,
+        `INSERT INTO fee_schedules (asset_code, fee_type, flat_amount, percent, tiers, min_fee, max_fee, fee_account_id)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+         ON CONFLICT (asset_code) DO UPDATE SET
+             fee_type = EXCLUDED.fee_type, flat_amount = EXCLUDED.flat_amount, percent = EXCLUDED.percent,
+             tiers = EXCLUDED.tiers, min_fee = EXCLUDED.min_fee, max_fee = EXCLUDED.max_fee,
+             fee_account_id = EXCLUDED.fee_account_id, updated_at = CURRENT_TIMESTAMP
+         RETURNING updated_at`,
+        s.AssetCode, s.Type, s.FlatAmount, s.Percent, tiers, s.MinFee, s.MaxFee, s.FeeAccountID,
+    ).Scan(&s.UpdatedAt)
+    if err != nil {
+        return nil, err
+    }
+    return &s, nil
+}
+
+// DeleteSchedule makes transfers of an asset free; it returns sql.ErrNoRows
+// if the asset had no schedule
+func (r *feeRepo) DeleteSchedule(ctx context.Context, assetCode string) error {
+    res, err := r.db.ExecContext(ctx, "DELETE FROM fee_schedules WHERE asset_code = $1", assetCode)
+    if err != nil {
+        return err
+    }
+    n, err := res.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if n == 0 {
+        return sql.ErrNoRows
+    }
+    return nil
+}
+
+// scanFeeSchedule reads one row selected with feeScheduleColumns
+func scanFeeSchedule(row rowScanner) (*model.FeeSchedule, error) {
+    var s model.FeeSchedule
+    var flatAmount, percent, minFee, maxFee decimal.NullDecimal
+    var tiers []byte
+    err := row.Scan(&s.AssetCode, &s.Type, &flatAmount, &percent, &tiers, &minFee, &maxFee, &s.FeeAccountID, &s.UpdatedAt)
+    if err != nil {
+        return nil, err
+    }
+    s.FlatAmount = decimalPtr(flatAmount)
+    s.Percent = decimalPtr(percent)
+    s.MinFee = decimalPtr(minFee)
+    s.MaxFee = decimalPtr(maxFee)
+    if tiers != nil {
+        if err := json.Unmarshal(tiers, &s.Tiers); err != nil {
+            return nil, err
+        }
+    }
+    return &s, nil
+}
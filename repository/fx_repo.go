@@ -0,0 +1,144 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "transfer-service/model"
+)
+
+type FXRepository interface {
+    GetRates(ctx context.Context) ([]*model.FXRate, error)
+    GetRateWithTx(ctx context.Context, tx *sql.Tx, baseAsset, quoteAsset string) (*model.FXRate, error)
+    UpsertRate(ctx context.Context, rate model.FXRate) (*model.FXRate, error)
+    CreateQuote(ctx context.Context, quote model.FXQuote) (*model.FXQuote, error)
+    GetQuoteWithLock(ctx context.Context, tx *sql.Tx, id string) (*model.FXQuote, error)
+    MarkQuoteUsedWithTx(ctx context.Context, tx *sql.Tx, id string, transactionID int) error
+    GetPositions(ctx context.Context) ([]*model.FXPosition, error)
+    GetPositionWithTx(ctx context.Context, tx *sql.Tx, assetCode string) (*model.FXPosition, error)
+    UpsertPosition(ctx context.Context, position model.FXPosition) error
+}
+
+type fxRepo struct {
+    db *sql.DB
+}
+
+func NewFXRepository(db *sql.DB) FXRepository {
+    return &fxRepo{db: db}
+}
+
+func (r *fxRepo) GetRates(ctx context.Context) ([]*model.FXRate, error) {
+    rows, err := r.db.QueryContext(ctx, "SELECT base_asset, quote_asset, rate, updated_at FROM fx_rates ORDER BY base_asset, quote_asset")
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var rates []*model.FXRate
+    for rows.Next() {
+        var rate model.FXRate
+        if err := rows.Scan(&rate.BaseAsset, &rate.QuoteAsset, &rate.Rate, &rate.UpdatedAt); err != nil {
+            return nil, err
+        }
+        rates = append(rates, &rate)
+    }
+
+    return rates, rows.Err()
+}
+
+func (r *fxRepo) GetRateWithTx(ctx context.Context, tx *sql.Tx, baseAsset, quoteAsset string) (*model.FXRate, error) {
+    var rate model.FXRate
+    err := executor(r.db, tx).QueryRowContext(ctx,
+        "SELECT base_asset, quote_asset, rate, updated_at FROM fx_rates WHERE base_asset = $1 AND quote_asset = $2",
+        baseAsset, quoteAsset,
+    ).Scan(&rate.BaseAsset, &rate.QuoteAsset, &rate.Rate, &rate.UpdatedAt)
+    if err != nil {
+        return nil, err
+    }
+    return &rate, nil
+}
+
+func (r *fxRepo) UpsertRate(ctx context.Context, rate model.FXRate) (*model.FXRate, error) {
+    err := r.db.QueryRowContext(ctx,
+        `INSERT INTO fx_rates (base_asset, quote_asset, rate) VALUES ($1, $2, $3)
+         ON CONFLICT (base_asset, quote_asset) DO UPDATE SET rate = EXCLUDED.rate, updated_at = CURRENT_TIMESTAMP
+         RETURNING updated_at`,
+        rate.BaseAsset, rate.QuoteAsset, rate.Rate,
+    ).Scan(&rate.UpdatedAt)
+    if err != nil {
+        return nil, err
+    }
+    return &rate, nil
+}
+
+func (r *fxRepo) CreateQuote(ctx context.Context, q model.FXQuote) (*model.FXQuote, error) {
+    err := r.db.QueryRowContext(ctx,
+        "INSERT INTO fx_quotes (id, base_asset, quote_asset, rate, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING created_at",
+        q.ID, q.BaseAsset, q.QuoteAsset, q.Rate, q.ExpiresAt,
+    ).Scan(&q.CreatedAt)
+    if err != nil {
+        return nil, err
+    }
+    return &q, nil
+}
+
+// GetQuoteWithLock locks the quote so two transfers cannot use it concurrently
+func (r *fxRepo) GetQuoteWithLock(ctx context.Context, tx *sql.Tx, id string) (*model.FXQuote, error) {
+    var q model.FXQuote
+    var transactionID sql.NullInt64
+    err := tx.QueryRowContext(ctx,
+        "SELECT id, base_asset, quote_asset, rate, expires_at, transaction_id, created_at FROM fx_quotes WHERE id = $1 FOR UPDATE",
+        id,
+    ).Scan(&q.ID, &q.BaseAsset, &q.QuoteAsset, &q.Rate, &q.ExpiresAt, &transactionID, &q.CreatedAt)
+    if err != nil {
+        return nil, err
+    }
+    if transactionID.Valid {
+        used := int(transactionID.Int64)
+        q.TransactionID = &used
+    }
+    return &q, nil
+}
+
+func (r *fxRepo) MarkQuoteUsedWithTx(ctx context.Context, tx *sql.Tx, id string, transactionID int) error {
+    _, err := executor(r.db, tx).ExecContext(ctx, "UPDATE fx_quotes SET transaction_id = $1 WHERE id = $2", transactionID, id)
+    return err
+}
+
+func (r *fxRepo) GetPositions(ctx context.Context) ([]*model.FXPosition, error) {
+    rows, err := r.db.QueryContext(ctx, "SELECT asset_code, account_id FROM fx_positions ORDER BY asset_code")
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var positions []*model.FXPosition
+    for rows.Next() {
+        var p model.FXPosition
+        if err := rows.Scan(&p.AssetCode, &p.AccountID); err != nil {
+            return nil, err
+        }
+        positions = append(positions, &p)
+    }
+
+    return positions, rows.Err()
+}
+
+func (r *fxRepo) GetPositionWithTx(ctx context.Context, tx *sql.Tx, assetCode string) (*model.FXPosition, error) {
+    var p model.FXPosition
+    err := executor(r.db, tx).QueryRowContext(ctx,
+        "SELECT asset_code, account_id FROM fx_positions WHERE asset_code = $1",
+        assetCode,
+    ).Scan(&p.AssetCode, &p.AccountID)
+    if err != nil {
+        return nil, err
+    }
+    return &p, nil
+}
+
+func (r *fxRepo) UpsertPosition(ctx context.Context, p model.FXPosition) error {
+    _, err := r.db.ExecContext(ctx,
+        "INSERT INTO fx_positions (asset_code, account_id) VALUES ($1, $2) ON CONFLICT (asset_code) DO UPDATE SET account_id = EXCLUDED.account_id",
+        p.AssetCode, p.AccountID,
+    )
+    return err
+}
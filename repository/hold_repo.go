@@ -0,0 +1,85 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "transfer-service/model"
+    "github.com/shopspring/decimal"
+)
+
+type HoldRepository interface {
+    CreateWithTx(ctx context.Context, tx *sql.Tx, hold model.Hold) (*model.Hold, error)
+    GetByID(ctx context.Context, id int) (*model.Hold, error)
+    GetByIDWithLock(ctx context.Context, tx *sql.Tx, id int) (*model.Hold, error)
+    CaptureWithTx(ctx context.Context, tx *sql.Tx, id int, amount decimal.Decimal, transactionID int) error
+    VoidWithTx(ctx context.Context, tx *sql.Tx, id int) error
+}
+
+// holdColumns is the column list read by scanHold. Pending holds past their
+// expiry are reported as expired.
+const holdColumns = `id, source_account_id, destination_account_id, `+accountRefColumns+`, asset_code, amount,
+    CASE WHEN status = 'pending' AND expires_at <= NOW() THEN 'expired' ELSE status END,
+    captured_amount, transaction_id, expires_at, created_at`
+
+type holdRepo struct {
+    db *sql.DB
+}
+
+func NewHoldRepository(db *sql.DB) HoldRepository {
+    return &holdRepo{db: db}
+}
+
+// CreateWithTx inserts a pending hold within a caller-supplied database transaction
+func (r *holdRepo) CreateWithTx(ctx context.Context, tx *sql.Tx, h model.Hold) (*model.Hold, error) {
+    err := executor(r.db, tx).QueryRowContext(ctx,
+        `INSERT INTO holds (source_account_id, destination_account_id, asset_code, amount, expires_at)
+         VALUES ($1, $2, $3, $4, $5) RETURNING id, `+accountRefColumns+`, status, created_at`,
+        h.SourceAccountID, h.DestinationAccountID, h.AssetCode, h.Amount, h.ExpiresAt,
+    ).Scan(&h.ID, &h.SourceAccount, &h.DestinationAccount, &h.Status, &h.CreatedAt)
+    if err != nil {
+        return nil, err
+    }
+    return &h, nil
+}
+
+func (r *holdRepo) GetByID(ctx context.Context, id int) (*model.Hold, error) {
+    return scanHold(r.db.QueryRowContext(ctx, "SELECT "+holdColumns+" FROM holds WHERE id = $1", id))
+}
+
+// GetByIDWithLock locks the hold with FOR UPDATE so it is captured or voided once
+func (r *holdRepo) GetByIDWithLock(ctx context.Context, tx *sql.Tx, id int) (*model.Hold, error) {
+    return scanHold(executor(r.db, tx).QueryRowContext(ctx, "SELECT "+holdColumns+" FROM holds WHERE id = $1 FOR UPDATE", id))
+}
+
+// CaptureWithTx finalizes a hold with the captured amount and its transfer
+func (r *holdRepo) CaptureWithTx(ctx context.Context, tx *sql.Tx, id int, amount decimal.Decimal, transactionID int) error {
+    _, err := executor(r.db, tx).ExecContext(ctx,
+        "UPDATE holds SET status = 'captured', captured_amount = $1, transaction_id = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3",
+        amount, transactionID, id,
+    )
+    return err
+}
+
+// VoidWithTx releases a hold without moving money
+func (r *holdRepo) VoidWithTx(ctx context.Context, tx *sql.Tx, id int) error {
+    _, err := executor(r.db, tx).ExecContext(ctx,
+        "UPDATE holds SET status = 'voided', updated_at = CURRENT_TIMESTAMP WHERE id = $1",
+        id,
+    )
+    return err
+}
+
+// scanHold reads one row selected with holdColumns
+func scanHold(row rowScanner) (*model.Hold, error) {
+    var h model.Hold
+    var capturedAmount decimal.NullDecimal
+    var transactionID sql.NullInt64
+    err := row.Scan(&h.ID, &h.SourceAccountID, &h.DestinationAccountID, &h.SourceAccount, &h.DestinationAccount, &h.AssetCode, &h.Amount,
+        &h.Status, &capturedAmount, &transactionID, &h.ExpiresAt, &h.CreatedAt)
+    if err != nil {
+        return nil, err
+    }
+    h.CapturedAmount = decimalPtr(capturedAmount)
+    h.TransactionID = intPtr(transactionID)
+    return &h, nil
+}
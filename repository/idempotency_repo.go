@@ -0,0 +1,42 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "transfer-service/model"
+)
+
+type IdempotencyRepository interface {
+    GetWithTx(ctx context.Context, tx *sql.Tx, principal, scope, key string) (*model.IdempotencyRecord, error)
+    CreateWithTx(ctx context.Context, tx *sql.Tx, record model.IdempotencyRecord) error
+}
+
+type idempotencyRepo struct {
+    db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) IdempotencyRepository {
+    return &idempotencyRepo{db: db}
+}
+
+func (r *idempotencyRepo) GetWithTx(ctx context.Context, tx *sql.Tx, principal, scope, key string) (*model.IdempotencyRecord, error) {
+    var rec model.IdempotencyRecord
+    err := executor(r.db, tx).QueryRowContext(ctx,
+        "SELECT principal, scope, key, request_fingerprint, response_status, response_body, created_at FROM idempotency_keys WHERE principal = $1 AND scope = $2 AND key = $3",
+        principal, scope, key,
+    ).Scan(&rec.Principal, &rec.Scope, &rec.Key, &rec.Fingerprint, &rec.ResponseStatus, &rec.ResponseBody, &rec.CreatedAt)
+    if err != nil {
+        return nil, err
+    }
+    return &rec, nil
+}
+
+// CreateWithTx stores the response for a key in the same transaction as the
+// operation that produced it, so the key and its effects commit together
+func (r *idempotencyRepo) CreateWithTx(ctx context.Context, tx *sql.Tx, rec model.IdempotencyRecord) error {
+    _, err := executor(r.db, tx).ExecContext(ctx,
+        "INSERT INTO idempotency_keys (principal, scope, key, request_fingerprint, response_status, response_body) VALUES ($1, $2, $3, $4, $5, $6)",
+        rec.Principal, rec.Scope, rec.Key, rec.Fingerprint, rec.ResponseStatus, []byte(rec.ResponseBody),
+    )
+    return err
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "transfer-service/model"
+    "github.com/shopspring/decimal"
+)
+
+type LimitRepository interface {
+    GetTiers(ctx context.Context) ([]*model.LimitTier, error)
+    UpsertTier(ctx context.Context, tier model.LimitTier) error
+    GetAccountLimits(ctx context.Context, accountID int) (*model.AccountLimits, error)
+    UpsertAccountLimits(ctx context.Context, limits model.AccountLimits) error
+    GetEffectiveWithTx(ctx context.Context, tx *sql.Tx, accountID int) (*model.Limits, error)
+    GetUsageWithTx(ctx context.Context, tx *sql.Tx, accountID int) (*model.LimitUsage, error)
+}
+
+// limitColumns is the column list read by scanLimits
+const limitColumns = `max_single_amount, max_daily_amount, max_monthly_amount, max_daily_count, max_monthly_count`
+
+type limitRepo struct {
+    db *sql.DB
+}
+
+func NewLimitRepository(db *sql.DB) LimitRepository {
+    return &limitRepo{db: db}
+}
+
+func (r *limitRepo) GetTiers(ctx context.Context) ([]*model.LimitTier, error) {
+    rows, err := r.db.QueryContext(ctx, "SELECT name, "+limitColumns+" FROM limit_tiers ORDER BY name")
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    tiers := []*model.LimitTier{}
+    for rows.Next() {
+        var tier model.LimitTier
+        if err := scanLimits(rows, &tier.Limits, &tier.Name); err != nil {
+            return nil, err
+        }
+        tiers = append(tiers, &tier)
+    }
+    return tiers, rows.Err()
+}
+
+func (r *limitRepo) UpsertTier(ctx context.Context, tier model.LimitTier) error {
+    _, err := r.db.ExecContext(ctx,
+        `INSERT INTO limit_tiers (name, `+limitColumns+`) VALUES ($1, $2, $3, $4, $5, $6)
+         ON CONFLICT (name) DO UPDATE SET max_single_amount = EXCLUDED.max_single_amount,
+             max_daily_amount = EXCLUDED.max_daily_amount, max_monthly_amount = EXCLUDED.max_monthly_amount,
+             max_daily_count = EXCLUDED.max_daily_count, max_monthly_count = EXCLUDED.max_monthly_count,
+             updated_at = CURRENT_TIMESTAMP`,
+        tier.Name, tier.MaxSingleAmount, tier.MaxDailyAmount, tier.MaxMonthlyAmount, tier.MaxDailyCount, tier.MaxMonthlyCount,
+    )
+    return err
+}
+
+// GetAccountLimits returns the tier and overrides set on an account, or
+// sql.ErrNoRows if none are
+func (r *limitRepo) GetAccountLimits(ctx context.Context, accountID int) (*model.AccountLimits, error) {
+    limits := model.AccountLimits{AccountID: accountID}
+    var tier sql.NullString
+    err := scanLimits(r.db.QueryRowContext(ctx,
+        "SELECT tier, "+limitColumns+" FROM account_limits WHERE account_id = $1",
+        accountID,
+    ), &limits.Limits, &tier)
+    if err != nil {
+        return nil, err
+    }
+    limits.Tier = tier.String
+    return &limits, nil
+}
+
+func (r *limitRepo) UpsertAccountLimits(ctx context.Context, limits model.AccountLimits) error {
+    _, err := r.db.ExecContext(ctx,
+        `INSERT INTO account_limits (account_id, tier, `+limitColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)
+         ON CONFLICT (account_id) DO UPDATE SET tier = EXCLUDED.tier, max_single_amount = EXCLUDED.max_single_amount,
+             max_daily_amount = EXCLUDED.max_daily_amount, max_monthly_amount = EXCLUDED.max_monthly_amount,
+             max_daily_count = EXCLUDED.max_daily_count, max_monthly_count = EXCLUDED.max_monthly_count,
+             updated_at = CURRENT_TIMESTAMP`,
+        limits.AccountID, nullString(limits.Tier), limits.MaxSingleAmount, limits.MaxDailyAmount, limits.MaxMonthlyAmount,
+        limits.MaxDailyCount, limits.MaxMonthlyCount,
+    )
+    return err
+}
+
+// GetEffectiveWithTx returns an account's limits, taking each one from the
+// account's overrides or else its tier. Accounts without limits get none.
+func (r *limitRepo) GetEffectiveWithTx(ctx context.Context, tx *sql.Tx, accountID int) (*model.Limits, error) {
+    var limits model.Limits
+    err := scanLimits(executor(r.db, tx).QueryRowContext(ctx,
+        `SELECT COALESCE(l.max_single_amount, t.max_single_amount), COALESCE(l.max_daily_amount, t.max_daily_amount),
+             COALESCE(l.max_monthly_amount, t.max_monthly_amount), COALESCE(l.max_daily_count, t.max_daily_count),
+             COALESCE(l.max_monthly_count, t.max_monthly_count)
+         FROM account_limits l LEFT JOIN limit_tiers t ON t.name = l.tier
+         WHERE l.account_id = $1`,
+        accountID,
+    ), &limits)
+    if err == sql.ErrNoRows {
+        return &model.Limits{}, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &limits, nil
+}
+
+// GetUsageWithTx sums the account's outgoing transfers since the start of the
+// current UTC day and month, the zone transactions.created_at is stored in.
+// Reversals are not counted.
+func (r *limitRepo) GetUsageWithTx(ctx context.Context, tx *sql.Tx, accountID int) (*model.LimitUsage, error) {
+    var usage model.LimitUsage
+    err := executor(r.db, tx).QueryRowContext(ctx,
+        `SELECT COALESCE(SUM(amount) FILTER (WHERE created_at >= date_trunc('day', (NOW() AT TIME ZONE 'UTC'))), 0),
+             COUNT(*) FILTER (WHERE created_at >= date_trunc('day', (NOW() AT TIME ZONE 'UTC'))),
+             COALESCE(SUM(amount), 0), COUNT(*)
+         FROM transactions
+         WHERE source_account_id = $1 AND kind = 'transfer' AND created_at >= date_trunc('month', (NOW() AT TIME ZONE 'UTC'))`,
+        accountID,
+    ).Scan(&usage.DailyAmount, &usage.DailyCount, &usage.MonthlyAmount, &usage.MonthlyCount)
+    if err != nil {
+        return nil, err
+    }
+    return &usage, nil
+}
+
+// scanLimits reads limitColumns into limits after any leading columns in dest
+func scanLimits(row rowScanner, limits *model.Limits, dest ...interface{}) error {
+    var single, daily, monthly decimal.NullDecimal
+    var dailyCount, monthlyCount sql.NullInt64
+    dest = append(dest, &single, &daily, &monthly, &dailyCount, &monthlyCount)
+    if err := row.Scan(dest...); err != nil {
+        return err
+    }
+    limits.MaxSingleAmount = decimalPtr(single)
+    limits.MaxDailyAmount = decimalPtr(daily)
+    limits.MaxMonthlyAmount = decimalPtr(monthly)
+    limits.MaxDailyCount = intPtr(dailyCount)
+    limits.MaxMonthlyCount = intPtr(monthlyCount)
+    return nil
+}
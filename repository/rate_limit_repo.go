@@ -0,0 +1,65 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "time"
+    "transfer-service/model"
+)
+
+// RateLimitRepository keeps token buckets in Postgres, which shares the
+// limits between every instance of the service
+type RateLimitRepository interface {
+    Take(ctx context.Context, key string, limit model.RateLimit) (model.RateLimitDecision, error)
+    DeleteIdle(ctx context.Context, idle time.Duration) (int64, error)
+}
+
+type rateLimitRepo struct {
+    db  *sql.DB
+    uow UnitOfWork
+}
+
+func NewRateLimitRepository(db *sql.DB) RateLimitRepository {
+    return &rateLimitRepo{db: db, uow: NewUnitOfWork(db)}
+}
+
+// Take locks the bucket of key, creating a full one if there is none, and
+// takes a token from it. Buckets are refilled by the database clock, so that
+// instances agree on it.
+func (r *rateLimitRepo) Take(ctx context.Context, key string, limit model.RateLimit) (model.RateLimitDecision, error) {
+    var decision model.RateLimitDecision
+    err := r.uow.Do(ctx, nil, func(tx *sql.Tx) error {
+        var bucket model.TokenBucket
+        var now time.Time
+        err := tx.QueryRowContext(ctx,
+            `INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES ($1, $2, clock_timestamp())
+             ON CONFLICT (key) DO UPDATE SET key = EXCLUDED.key
+             RETURNING tokens, updated_at, clock_timestamp()`,
+            key, float64(limit.Burst),
+        ).Scan(&bucket.Tokens, &bucket.UpdatedAt, &now)
+        if err != nil {
+            return err
+        }
+
+        bucket, decision = limit.Take(bucket, now)
+        _, err = tx.ExecContext(ctx,
+            "UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3 WHERE key = $1",
+            key, bucket.Tokens, bucket.UpdatedAt,
+        )
+        return err
+    })
+    return decision, err
+}
+
+// DeleteIdle deletes the buckets not used for idle, which have refilled if
+// idle is at least the longest limit period
+func (r *rateLimitRepo) DeleteIdle(ctx context.Context, idle time.Duration) (int64, error) {
+    res, err := r.db.ExecContext(ctx,
+        "DELETE FROM rate_limit_buckets WHERE updated_at < clock_timestamp() - $1 * interval '1 second'",
+        idle.Seconds(),
+    )
+    if err != nil {
+        return 0, err
+    }
+    return res.RowsAffected()
+}
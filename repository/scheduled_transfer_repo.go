@@ -0,0 +1,134 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "time"
+    "transfer-service/model"
+)
+
+type ScheduledTransferRepository interface {
+    CreateWithTx(ctx context.Context, tx *sql.Tx, s model.ScheduledTransfer) (*model.ScheduledTransfer, error)
+    GetByID(ctx context.Context, id int) (*model.ScheduledTransfer, error)
+    ClaimDueWithTx(ctx context.Context, tx *sql.Tx, now time.Time, limit int) ([]*model.ScheduledTransfer, error)
+    MarkExecutedWithTx(ctx context.Context, tx *sql.Tx, id int, transactionID int) error
+    MarkFailedWithTx(ctx context.Context, tx *sql.Tx, id int, reason string) error
+    MarkRetryWithTx(ctx context.Context, tx *sql.Tx, id int, reason string, nextAttemptAt time.Time) error
+    Cancel(ctx context.Context, id int) (*model.ScheduledTransfer, error)
+}
+
+// scheduledTransferColumns is the column list read by scanScheduledTransfer
+const scheduledTransferColumns = `id, source_account_id, destination_account_id, `+accountRefColumns+`, asset_code, amount, convert,
+    execute_at, status, transaction_id, failure_reason, attempts, next_attempt_at, created_at, updated_at`
+
+type scheduledTransferRepo struct {
+    db *sql.DB
+}
+
+func NewScheduledTransferRepository(db *sql.DB) ScheduledTransferRepository {
+    return &scheduledTransferRepo{db: db}
+}
+
+// CreateWithTx stores a scheduled transfer within a caller-supplied database transaction
+func (r *scheduledTransferRepo) CreateWithTx(ctx context.Context, tx *sql.Tx, s model.ScheduledTransfer) (*model.ScheduledTransfer, error) {
+    return scanScheduledTransfer(executor(r.db, tx).QueryRowContext(ctx,
+        `INSERT INTO scheduled_transfers (source_account_id, destination_account_id, asset_code, amount, convert, execute_at)
+         VALUES ($1, $2, $3, $4, $5, $6) RETURNING `+scheduledTransferColumns,
+        s.SourceAccountID, s.DestinationAccountID, s.AssetCode, s.Amount, s.Convert, s.ExecuteAt,
+    ))
+}
+
+func (r *scheduledTransferRepo) GetByID(ctx context.Context, id int) (*model.ScheduledTransfer, error) {
+    return scanScheduledTransfer(r.db.QueryRowContext(ctx,
+        "SELECT "+scheduledTransferColumns+" FROM scheduled_transfers WHERE id = $1",
+        id,
+    ))
+}
+
+// ClaimDueWithTx locks up to limit due transfers, oldest first. Transfers
+// waiting to retry are skipped until next_attempt_at, and so are rows locked by
+// another instance, so concurrent workers never claim the same transfer; the
+// claim lasts until tx ends.
+func (r *scheduledTransferRepo) ClaimDueWithTx(ctx context.Context, tx *sql.Tx, now time.Time, limit int) ([]*model.ScheduledTransfer, error) {
+    rows, err := executor(r.db, tx).QueryContext(ctx,
+        `SELECT `+scheduledTransferColumns+` FROM scheduled_transfers
+         WHERE status = 'scheduled' AND execute_at <= $1
+           AND (next_attempt_at IS NULL OR next_attempt_at <= $1)
+         ORDER BY execute_at, id
+         LIMIT $2
+         FOR UPDATE SKIP LOCKED`,
+        now, limit,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var due []*model.ScheduledTransfer
+    for rows.Next() {
+        s, err := scanScheduledTransfer(rows)
+        if err != nil {
+            return nil, err
+        }
+        due = append(due, s)
+    }
+    return due, rows.Err()
+}
+
+// MarkExecutedWithTx links an executed transfer to its transaction
+func (r *scheduledTransferRepo) MarkExecutedWithTx(ctx context.Context, tx *sql.Tx, id int, transactionID int) error {
+    _, err := executor(r.db, tx).ExecContext(ctx,
+        "UPDATE scheduled_transfers SET status = 'executed', transaction_id = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+        transactionID, id,
+    )
+    return err
+}
+
+// MarkFailedWithTx records why a transfer could not be executed. Transfers that
+// another worker already executed are left alone.
+func (r *scheduledTransferRepo) MarkFailedWithTx(ctx context.Context, tx *sql.Tx, id int, reason string) error {
+    _, err := executor(r.db, tx).ExecContext(ctx,
+        "UPDATE scheduled_transfers SET status = 'failed', failure_reason = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND status = 'scheduled'",
+        reason, id,
+    )
+    return err
+}
+
+// MarkRetryWithTx counts a failed attempt and keeps the transfer scheduled
+// until nextAttemptAt
+func (r *scheduledTransferRepo) MarkRetryWithTx(ctx context.Context, tx *sql.Tx, id int, reason string, nextAttemptAt time.Time) error {
+    _, err := executor(r.db, tx).ExecContext(ctx,
+        `UPDATE scheduled_transfers SET attempts = attempts + 1, failure_reason = $1, next_attempt_at = $2, updated_at = CURRENT_TIMESTAMP
+         WHERE id = $3 AND status = 'scheduled'`,
+        reason, nextAttemptAt, id,
+    )
+    return err
+}
+
+// Cancel cancels a transfer that has not run yet. It returns sql.ErrNoRows if
+// the transfer does not exist or is no longer scheduled; a transfer being
+// executed stays locked until the worker commits.
+func (r *scheduledTransferRepo) Cancel(ctx context.Context, id int) (*model.ScheduledTransfer, error) {
+    return scanScheduledTransfer(r.db.QueryRowContext(ctx,
+        `UPDATE scheduled_transfers SET status = 'cancelled', updated_at = CURRENT_TIMESTAMP
+         WHERE id = $1 AND status = 'scheduled' RETURNING `+scheduledTransferColumns,
+        id,
+    ))
+}
+
+// scanScheduledTransfer reads one row selected with scheduledTransferColumns
+func scanScheduledTransfer(row rowScanner) (*model.ScheduledTransfer, error) {
+    var s model.ScheduledTransfer
+    var transactionID sql.NullInt64
+    var failureReason sql.NullString
+    var nextAttemptAt sql.NullTime
+    err := row.Scan(&s.ID, &s.SourceAccountID, &s.DestinationAccountID, &s.SourceAccount, &s.DestinationAccount, &s.AssetCode, &s.Amount, &s.Convert,
+        &s.ExecuteAt, &s.Status, &transactionID, &failureReason, &s.Attempts, &nextAttemptAt, &s.CreatedAt, &s.UpdatedAt)
+    if err != nil {
+        return nil, err
+    }
+    s.TransactionID = intPtr(transactionID)
+    s.FailureReason = failureReason.String
+    s.NextAttemptAt = timePtr(nextAttemptAt)
+    return &s, nil
+}
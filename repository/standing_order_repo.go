@@ -0,0 +1,173 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "time"
+    "transfer-service/model"
+)
+
+type StandingOrderRepository interface {
+    CreateWithTx(ctx context.Context, tx *sql.Tx, o model.StandingOrder) (*model.StandingOrder, error)
+    GetByID(ctx context.Context, id int) (*model.StandingOrder, error)
+    GetByIDWithLock(ctx context.Context, tx *sql.Tx, id int) (*model.StandingOrder, error)
+    ClaimDueWithTx(ctx context.Context, tx *sql.Tx, now time.Time, limit int) ([]*model.StandingOrder, error)
+    UpdateWithTx(ctx context.Context, tx *sql.Tx, o *model.StandingOrder) error
+    CreateRunWithTx(ctx context.Context, tx *sql.Tx, run model.StandingOrderRun) error
+    GetRuns(ctx context.Context, orderID int) ([]*model.StandingOrderRun, error)
+}
+
+// standingOrderColumns is the column list read by scanStandingOrder
+const standingOrderColumns = `id, source_account_id, destination_account_id, `+accountRefColumns+`, asset_code, amount, convert,
+    frequency, day_of_month, cron, start_at, end_at, max_occurrences, max_retries, retry_interval_seconds,
+    status, occurrences, failed_attempts, current_run_at, next_run_at, created_at, updated_at`
+
+const standingOrderRunColumns = `id, standing_order_id, scheduled_for, attempt, status, transaction_id, failure_reason, created_at`
+
+type standingOrderRepo struct {
+    db *sql.DB
+}
+
+func NewStandingOrderRepository(db *sql.DB) StandingOrderRepository {
+    return &standingOrderRepo{db: db}
+}
+
+// CreateWithTx stores a standing order within a caller-supplied database transaction
+func (r *standingOrderRepo) CreateWithTx(ctx context.Context, tx *sql.Tx, o model.StandingOrder) (*model.StandingOrder, error) {
+    var dayOfMonth sql.NullInt64
+    if o.DayOfMonth != 0 {
+        dayOfMonth = sql.NullInt64{Int64: int64(o.DayOfMonth), Valid: true}
+    }
+    return scanStandingOrder(executor(r.db, tx).QueryRowContext(ctx,
+        `INSERT INTO standing_orders (source_account_id, destination_account_id, asset_code, amount, convert,
+             frequency, day_of_month, cron, start_at, end_at, max_occurrences, max_retries, retry_interval_seconds,
+             current_run_at, next_run_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING `+standingOrderColumns,
+        o.SourceAccountID, o.DestinationAccountID, o.AssetCode, o.Amount, o.Convert,
+        o.Frequency, dayOfMonth, nullString(o.Cron), o.StartAt, o.EndAt, o.MaxOccurrences, o.MaxRetries, o.RetryIntervalSeconds,
+        o.CurrentRunAt, o.NextRunAt,
+    ))
+}
+
+func (r *standingOrderRepo) GetByID(ctx context.Context, id int) (*model.StandingOrder, error) {
+    return scanStandingOrder(r.db.QueryRowContext(ctx,
+        "SELECT "+standingOrderColumns+" FROM standing_orders WHERE id = $1",
+        id,
+    ))
+}
+
+// GetByIDWithLock locks a standing order until tx ends
+func (r *standingOrderRepo) GetByIDWithLock(ctx context.Context, tx *sql.Tx, id int) (*model.StandingOrder, error) {
+    return scanStandingOrder(executor(r.db, tx).QueryRowContext(ctx,
+        "SELECT "+standingOrderColumns+" FROM standing_orders WHERE id = $1 FOR UPDATE",
+        id,
+    ))
+}
+
+// ClaimDueWithTx locks up to limit active orders whose next run is due, oldest
+// first. Rows locked by another instance are skipped, as for scheduled transfers.
+func (r *standingOrderRepo) ClaimDueWithTx(ctx context.Context, tx *sql.Tx, now time.Time, limit int) ([]*model.StandingOrder, error) {
+    rows, err := executor(r.db, tx).QueryContext(ctx,
+        `SELECT `+standingOrderColumns+` FROM standing_orders
+         WHERE status = 'active' AND next_run_at <= $1
+         ORDER BY next_run_at, id
+         LIMIT $2
+         FOR UPDATE SKIP LOCKED`,
+        now, limit,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var due []*model.StandingOrder
+    for rows.Next() {
+        o, err := scanStandingOrder(rows)
+        if err != nil {
+            return nil, err
+        }
+        due = append(due, o)
+    }
+    return due, rows.Err()
+}
+
+// UpdateWithTx saves the status and progress of a standing order
+func (r *standingOrderRepo) UpdateWithTx(ctx context.Context, tx *sql.Tx, o *model.StandingOrder) error {
+    _, err := executor(r.db, tx).ExecContext(ctx,
+        `UPDATE standing_orders SET status = $1, occurrences = $2, failed_attempts = $3, current_run_at = $4,
+             next_run_at = $5, updated_at = CURRENT_TIMESTAMP
+         WHERE id = $6`,
+        o.Status, o.Occurrences, o.FailedAttempts, o.CurrentRunAt, o.NextRunAt, o.ID,
+    )
+    return err
+}
+
+// CreateRunWithTx records one execution attempt of a standing order
+func (r *standingOrderRepo) CreateRunWithTx(ctx context.Context, tx *sql.Tx, run model.StandingOrderRun) error {
+    var transactionID sql.NullInt64
+    if run.TransactionID != nil {
+        transactionID = sql.NullInt64{Int64: int64(*run.TransactionID), Valid: true}
+    }
+    _, err := executor(r.db, tx).ExecContext(ctx,
+        `INSERT INTO standing_order_runs (standing_order_id, scheduled_for, attempt, status, transaction_id, failure_reason)
+         VALUES ($1, $2, $3, $4, $5, $6)`,
+        run.StandingOrderID, run.ScheduledFor, run.Attempt, run.Status, transactionID, nullString(run.FailureReason),
+    )
+    return err
+}
+
+// GetRuns returns the execution attempts of a standing order, oldest first
+func (r *standingOrderRepo) GetRuns(ctx context.Context, orderID int) ([]*model.StandingOrderRun, error) {
+    rows, err := r.db.QueryContext(ctx,
+        "SELECT "+standingOrderRunColumns+" FROM standing_order_runs WHERE standing_order_id = $1 ORDER BY id",
+        orderID,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    runs := []*model.StandingOrderRun{}
+    for rows.Next() {
+        var run model.StandingOrderRun
+        var transactionID sql.NullInt64
+        var failureReason sql.NullString
+        if err := rows.Scan(&run.ID, &run.StandingOrderID, &run.ScheduledFor, &run.Attempt, &run.Status,
+            &transactionID, &failureReason, &run.CreatedAt); err != nil {
+            return nil, err
+        }
+        run.TransactionID = intPtr(transactionID)
+        run.FailureReason = failureReason.String
+        runs = append(runs, &run)
+    }
+    return runs, rows.Err()
+}
+
+// scanStandingOrder reads one row selected with standingOrderColumns
+func scanStandingOrder(row rowScanner) (*model.StandingOrder, error) {
+    var o model.StandingOrder
+    var dayOfMonth, maxOccurrences sql.NullInt64
+    var cron sql.NullString
+    var endAt, currentRunAt, nextRunAt sql.NullTime
+    err := row.Scan(&o.ID, &o.SourceAccountID, &o.DestinationAccountID, &o.SourceAccount, &o.DestinationAccount, &o.AssetCode, &o.Amount, &o.Convert,
+        &o.Frequency, &dayOfMonth, &cron, &o.StartAt, &endAt, &maxOccurrences, &o.MaxRetries, &o.RetryIntervalSeconds,
+        &o.Status, &o.Occurrences, &o.FailedAttempts, &currentRunAt, &nextRunAt, &o.CreatedAt, &o.UpdatedAt)
+    if err != nil {
+        return nil, err
+    }
+    o.DayOfMonth = int(dayOfMonth.Int64)
+    o.Cron = cron.String
+    o.EndAt = timePtr(endAt)
+    o.MaxOccurrences = intPtr(maxOccurrences)
+    o.CurrentRunAt = timePtr(currentRunAt)
+    o.NextRunAt = timePtr(nextRunAt)
+    return &o, nil
+}
+
+// timePtr returns nil for a NULL column
+func timePtr(t sql.NullTime) *time.Time {
+    if !t.Valid {
+        return nil
+    }
+    return &t.Time
+}
@@ -3,14 +3,41 @@ package repository
 import (
     "context"
     "database/sql"
+    "fmt"
+    "strings"
+    "time"
     "transfer-service/model"
+    "github.com/lib/pq"
+    "github.com/shopspring/decimal"
 )
 
 type TransactionRepository interface {
     Create(ctx context.Context, tx model.Transaction) (*model.Transaction, error)
+    CreateWithTx(ctx context.Context, tx *sql.Tx, t model.Transaction) (*model.Transaction, error)
     GetByID(ctx context.Context, id int) (*model.Transaction, error)
+    GetByIDWithLock(ctx context.Context, tx *sql.Tx, id int) (*model.Transaction, error)
+    GetReversalsWithTx(ctx context.Context, tx *sql.Tx, originalID int) ([]*model.Transaction, error)
     GetByAccountID(ctx context.Context, accountID int) ([]*model.Transaction, error)
     GetAll(ctx context.Context) ([]*model.Transaction, error)
+    GetAllPage(ctx context.Context, filter model.TransactionFilter, after *model.TransactionCursor, limit int) ([]*model.Transaction, error)
+    GetByAccountIDPage(ctx context.Context, accountID int, filter model.TransactionFilter, after *model.TransactionCursor, limit int) ([]*model.Transaction, error)
+    CreatePostingsWithTx(ctx context.Context, tx *sql.Tx, postings []model.Posting) error
+    GetPostingsByTransactionID(ctx context.Context, transactionID int) ([]*model.Posting, error)
+    GetByIDs(ctx context.Context, ids []int) ([]*model.Transaction, error)
+    GetAccountPostings(ctx context.Context, accountID int, transactionIDs []int) ([]*model.Posting, error)
+    GetAccountPostingsBetween(ctx context.Context, accountID int, from, to time.Time) ([]*model.Posting, error)
+    GetBalanceBefore(ctx context.Context, accountID int, before time.Time) (decimal.Decimal, error)
+}
+
+// transactionColumns is the column list read by scanTransaction
+const transactionColumns = "id, kind, reversal_of, standing_order_id, source_account_id, destination_account_id, " + accountRefColumns + ", asset_code, amount, quote_id, destination_asset_code, destination_amount, fx_rate, fx_rounding, fee, fee_account_id, created_at"
+
+// postingColumns is the column list read by scanPostings
+const postingColumns = "id, transaction_id, account_id, asset_code, amount, balance_after, created_at"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+    Scan(dest ...interface{}) error
 }
 
 type transactionRepo struct {
@@ -22,39 +49,146 @@ func NewTransactionRepository(db *sql.DB) TransactionRepository {
 }
 
 func (r *transactionRepo) Create(ctx context.Context, t model.Transaction) (*model.Transaction, error) {
-    var id int
-    err := r.db.QueryRowContext(ctx, 
-        "INSERT INTO transactions (source_account_id, destination_account_id, amount) VALUES ($1, $2, $3) RETURNING id",
-        t.SourceAccountID, t.DestinationAccountID, t.Amount,
-    ).Scan(&id)
+    return r.CreateWithTx(ctx, nil, t)
+}
+
+// CreateWithTx logs a transaction within a caller-supplied database transaction
+func (r *transactionRepo) CreateWithTx(ctx context.Context, tx *sql.Tx, t model.Transaction) (*model.Transaction, error) {
+    if t.Kind == "" {
+        t.Kind = model.TransactionKindTransfer
+    }
+
+    // Return the generated columns directly; the row is not visible outside tx until commit
+    err := executor(r.db, tx).QueryRowContext(ctx, 
+        `INSERT INTO transactions (kind, reversal_of, standing_order_id, source_account_id, destination_account_id, asset_code, amount, quote_id, destination_asset_code, destination_amount, fx_rate, fx_rounding, fee, fee_account_id)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id, `+accountRefColumns+`, created_at`,
+        t.Kind, t.ReversalOf, t.StandingOrderID, t.SourceAccountID, t.DestinationAccountID, t.AssetCode, t.Amount,
+        nullString(t.QuoteID), nullString(t.DestinationAssetCode), t.DestinationAmount, t.FXRate, t.FXRounding, t.Fee, t.FeeAccountID,
+    ).Scan(&t.ID, &t.SourceAccount, &t.DestinationAccount, &t.CreatedAt)
     
     if err != nil {
         return nil, err
     }
     
-    // Get the created transaction with timestamp
-    return r.GetByID(ctx, id)
+    return &t, nil
 }
 
 func (r *transactionRepo) GetByID(ctx context.Context, id int) (*model.Transaction, error) {
-    var t model.Transaction
-    err := r.db.QueryRowContext(ctx, 
-        "SELECT id, source_account_id, destination_account_id, amount, created_at FROM transactions WHERE id = $1",
+    return scanTransaction(r.db.QueryRowContext(ctx, 
+        "SELECT "+transactionColumns+" FROM transactions WHERE id = $1",
         id,
-    ).Scan(&t.ID, &t.SourceAccountID, &t.DestinationAccountID, &t.Amount, &t.CreatedAt)
-    
-    if err != nil {
-        return nil, err
-    }
-    
-    return &t, nil
+    ))
+}
+
+// GetByIDWithLock locks a transaction row with FOR UPDATE, serializing
+// concurrent reversals of the same transaction
+func (r *transactionRepo) GetByIDWithLock(ctx context.Context, tx *sql.Tx, id int) (*model.Transaction, error) {
+    return scanTransaction(executor(r.db, tx).QueryRowContext(ctx, 
+        "SELECT "+transactionColumns+" FROM transactions WHERE id = $1 FOR UPDATE",
+        id,
+    ))
+}
+
+// GetReversalsWithTx returns the reversals of a transaction, oldest first
+func (r *transactionRepo) GetReversalsWithTx(ctx context.Context, tx *sql.Tx, originalID int) ([]*model.Transaction, error) {
+    return r.queryWithTx(ctx, tx, 
+        "SELECT "+transactionColumns+" FROM transactions WHERE reversal_of = $1 ORDER BY id",
+        originalID,
+    )
 }
 
 func (r *transactionRepo) GetByAccountID(ctx context.Context, accountID int) ([]*model.Transaction, error) {
-    rows, err := r.db.QueryContext(ctx, 
-        "SELECT id, source_account_id, destination_account_id, amount, created_at FROM transactions WHERE source_account_id = $1 OR destination_account_id = $1 ORDER BY created_at DESC",
+    return r.query(ctx, 
+        "SELECT "+transactionColumns+" FROM transactions WHERE source_account_id = $1 OR destination_account_id = $1 ORDER BY created_at DESC",
         accountID,
     )
+}
+
+func (r *transactionRepo) GetAll(ctx context.Context) ([]*model.Transaction, error) {
+    return r.query(ctx, 
+        "SELECT "+transactionColumns+" FROM transactions ORDER BY created_at DESC",
+    )
+}
+
+// GetAllPage returns up to limit transactions matching the filter, newest
+// first, that come after the cursor; a nil cursor starts from the newest
+func (r *transactionRepo) GetAllPage(ctx context.Context, filter model.TransactionFilter, after *model.TransactionCursor, limit int) ([]*model.Transaction, error) {
+    where, args := historyConditions(filter, after)
+    args = append(args, limit)
+    return r.query(ctx, 
+        "SELECT "+transactionColumns+" FROM transactions"+where+fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args)),
+        args...,
+    )
+}
+
+// GetByAccountIDPage is GetAllPage for the transactions an account sent or received
+func (r *transactionRepo) GetByAccountIDPage(ctx context.Context, accountID int, filter model.TransactionFilter, after *model.TransactionCursor, limit int) ([]*model.Transaction, error) {
+    filter.AccountID = &accountID
+    return r.GetAllPage(ctx, filter, after, limit)
+}
+
+// historyConditions builds the WHERE clause of a history query and its
+// arguments, numbered from $1. Times are compared in UTC, the zone the
+// timestamps are written in, like every query on a TIMESTAMP column.
+func historyConditions(f model.TransactionFilter, after *model.TransactionCursor) (string, []interface{}) {
+    var conditions []string
+    var args []interface{}
+    arg := func(v interface{}) string {
+        args = append(args, v)
+        return fmt.Sprintf("$%d", len(args))
+    }
+
+    if f.AccountID != nil {
+        account := arg(*f.AccountID)
+        var counterparty string
+        if f.CounterpartyID != nil {
+            counterparty = arg(*f.CounterpartyID)
+        }
+        outgoing := "source_account_id = " + account
+        incoming := "destination_account_id = " + account
+        if counterparty != "" {
+            outgoing += " AND destination_account_id = " + counterparty
+            incoming += " AND source_account_id = " + counterparty
+        }
+        switch f.Direction {
+        case model.DirectionOutgoing:
+            conditions = append(conditions, outgoing)
+        case model.DirectionIncoming:
+            conditions = append(conditions, incoming)
+        default:
+            conditions = append(conditions, "(("+outgoing+") OR ("+incoming+"))")
+        }
+    }
+    if f.From != nil {
+        conditions = append(conditions, "created_at >= "+arg(f.From.UTC())+"::timestamp")
+    }
+    if f.To != nil {
+        conditions = append(conditions, "created_at < "+arg(f.To.UTC())+"::timestamp")
+    }
+    if f.MinAmount != nil {
+        conditions = append(conditions, "amount >= "+arg(*f.MinAmount))
+    }
+    if f.MaxAmount != nil {
+        conditions = append(conditions, "amount <= "+arg(*f.MaxAmount))
+    }
+    if after != nil {
+        conditions = append(conditions, "(created_at, id) < ("+arg(after.CreatedAt.UTC())+"::timestamp, "+arg(after.ID)+")")
+    }
+
+    if len(conditions) == 0 {
+        return "", args
+    }
+    return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// query runs a select of transactionColumns and scans every row
+func (r *transactionRepo) query(ctx context.Context, query string, args ...interface{}) ([]*model.Transaction, error) {
+    return r.queryWithTx(ctx, nil, query, args...)
+}
+
+// queryWithTx is query inside a caller-supplied database transaction
+func (r *transactionRepo) queryWithTx(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]*model.Transaction, error) {
+    rows, err := executor(r.db, tx).QueryContext(ctx, query, args...)
     if err != nil {
         return nil, err
     }
@@ -62,35 +196,152 @@ func (r *transactionRepo) GetByAccountID(ctx context.Context, accountID int) ([]
     
     var transactions []*model.Transaction
     for rows.Next() {
-        var t model.Transaction
-        err := rows.Scan(&t.ID, &t.SourceAccountID, &t.DestinationAccountID, &t.Amount, &t.CreatedAt)
+        t, err := scanTransaction(rows)
         if err != nil {
             return nil, err
         }
-        transactions = append(transactions, &t)
+        transactions = append(transactions, t)
     }
     
-    return transactions, nil
+    return transactions, rows.Err()
 }
 
-func (r *transactionRepo) GetAll(ctx context.Context) ([]*model.Transaction, error) {
-    rows, err := r.db.QueryContext(ctx, 
-        "SELECT id, source_account_id, destination_account_id, amount, created_at FROM transactions ORDER BY created_at DESC",
+// scanTransaction reads one row selected with transactionColumns
+func scanTransaction(row rowScanner) (*model.Transaction, error) {
+    var t model.Transaction
+    var reversalOf, standingOrderID, feeAccountID sql.NullInt64
+    var quoteID, destinationAsset sql.NullString
+    var destinationAmount, fxRate, fxRounding, fee decimal.NullDecimal
+    err := row.Scan(&t.ID, &t.Kind, &reversalOf, &standingOrderID, &t.SourceAccountID, &t.DestinationAccountID,
+        &t.SourceAccount, &t.DestinationAccount, &t.AssetCode, &t.Amount, &quoteID, &destinationAsset, &destinationAmount, &fxRate, &fxRounding, &fee, &feeAccountID, &t.CreatedAt)
+    if err != nil {
+        return nil, err
+    }
+    t.ReversalOf = intPtr(reversalOf)
+    t.StandingOrderID = intPtr(standingOrderID)
+    t.QuoteID = quoteID.String
+    t.DestinationAssetCode = destinationAsset.String
+    t.DestinationAmount = decimalPtr(destinationAmount)
+    t.FXRate = decimalPtr(fxRate)
+    t.FXRounding = decimalPtr(fxRounding)
+    t.Fee = decimalPtr(fee)
+    t.FeeAccountID = intPtr(feeAccountID)
+    return &t, nil
+}
+
+// nullString stores empty strings as NULL
+func nullString(s string) sql.NullString {
+    return sql.NullString{String: s, Valid: s != ""}
+}
+
+// decimalPtr returns nil for a NULL column
+func decimalPtr(d decimal.NullDecimal) *decimal.Decimal {
+    if !d.Valid {
+        return nil
+    }
+    return &d.Decimal
+}
+
+// intPtr returns nil for a NULL column
+func intPtr(n sql.NullInt64) *int {
+    if !n.Valid {
+        return nil
+    }
+    i := int(n.Int64)
+    return &i
+}
+
+// CreatePostingsWithTx inserts the ledger legs of a transaction within a database transaction
+func (r *transactionRepo) CreatePostingsWithTx(ctx context.Context, tx *sql.Tx, postings []model.Posting) error {
+    for _, p := range postings {
+        _, err := executor(r.db, tx).ExecContext(ctx,
+            "INSERT INTO postings (transaction_id, account_id, asset_code, amount, balance_after) VALUES ($1, $2, $3, $4, $5)",
+            p.TransactionID, p.AccountID, p.AssetCode, p.Amount, p.BalanceAfter,
+        )
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (r *transactionRepo) GetPostingsByTransactionID(ctx context.Context, transactionID int) ([]*model.Posting, error) {
+    rows, err := r.db.QueryContext(ctx,
+        "SELECT "+postingColumns+" FROM postings WHERE transaction_id = $1 ORDER BY id",
+        transactionID,
     )
     if err != nil {
         return nil, err
     }
     defer rows.Close()
-    
-    var transactions []*model.Transaction
+
+    return scanPostings(rows)
+}
+
+// GetByIDs returns the transactions with the given IDs in ID order
+func (r *transactionRepo) GetByIDs(ctx context.Context, ids []int) ([]*model.Transaction, error) {
+    return r.query(ctx, 
+        "SELECT "+transactionColumns+" FROM transactions WHERE id = ANY($1) ORDER BY id",
+        pq.Array(ids),
+    )
+}
+
+// GetAccountPostings returns an account's postings in the given transactions in ID order
+func (r *transactionRepo) GetAccountPostings(ctx context.Context, accountID int, transactionIDs []int) ([]*model.Posting, error) {
+    rows, err := r.db.QueryContext(ctx,
+        "SELECT "+postingColumns+" FROM postings WHERE account_id = $1 AND transaction_id = ANY($2) ORDER BY id",
+        accountID, pq.Array(transactionIDs),
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    return scanPostings(rows)
+}
+
+// GetAccountPostingsBetween returns an account's postings created at or after
+// from and before to, in ID order. Times are compared in UTC.
+func (r *transactionRepo) GetAccountPostingsBetween(ctx context.Context, accountID int, from, to time.Time) ([]*model.Posting, error) {
+    rows, err := r.db.QueryContext(ctx,
+        "SELECT "+postingColumns+" FROM postings WHERE account_id = $1 AND created_at >= $2::timestamp AND created_at < $3::timestamp ORDER BY id",
+        accountID, from.UTC(), to.UTC(),
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    return scanPostings(rows)
+}
+
+// GetBalanceBefore returns an account's balance after its last posting created
+// before the given time, or its opening balance if there is none. Postings of
+// one account are ordered by ID, as each is written with the account locked.
+// Times are compared in UTC.
+func (r *transactionRepo) GetBalanceBefore(ctx context.Context, accountID int, before time.Time) (decimal.Decimal, error) {
+    var balance decimal.Decimal
+    err := r.db.QueryRowContext(ctx,
+        `SELECT COALESCE(
+            (SELECT balance_after FROM postings WHERE account_id = $1 AND created_at < $2::timestamp ORDER BY id DESC LIMIT 1),
+            opening_balance
+         ) FROM accounts WHERE id = $1`,
+        accountID, before.UTC(),
+    ).Scan(&balance)
+    return balance, err
+}
+
+// scanPostings reads posting rows selected with postingColumns
+func scanPostings(rows *sql.Rows) ([]*model.Posting, error) {
+    var postings []*model.Posting
     for rows.Next() {
-        var t model.Transaction
-        err := rows.Scan(&t.ID, &t.SourceAccountID, &t.DestinationAccountID, &t.Amount, &t.CreatedAt)
+        var p model.Posting
+        err := rows.Scan(&p.ID, &p.TransactionID, &p.AccountID, &p.AssetCode, &p.Amount, &p.BalanceAfter, &p.CreatedAt)
         if err != nil {
             return nil, err
         }
-        transactions = append(transactions, &t)
+        postings = append(postings, &p)
     }
-    
-    return transactions, nil
-} 
\ No newline at end of file
+
+    return postings, rows.Err()
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+)
+
+// DBTX is the query interface shared by *sql.DB and *sql.Tx, so repository
+// methods can run either standalone or inside a caller-supplied transaction
+type DBTX interface {
+    ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+    QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+    QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// executor returns tx when the caller supplied one, otherwise the plain connection pool
+func executor(db *sql.DB, tx *sql.Tx) DBTX {
+    if tx != nil {
+        return tx
+    }
+    return db
+}
+
+// UnitOfWork runs a group of repository calls as one database transaction.
+// Repositories join the transaction through their *WithTx methods; everything
+// done inside fn commits together or rolls back together.
+type UnitOfWork interface {
+    Do(ctx context.Context, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error
+}
+
+type unitOfWork struct {
+    db *sql.DB
+}
+
+func NewUnitOfWork(db *sql.DB) UnitOfWork {
+    return &unitOfWork{db: db}
+}
+
+// Do begins a transaction, runs fn and commits if fn succeeds. Any error or
+// panic from fn rolls the transaction back.
+func (u *unitOfWork) Do(ctx context.Context, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+    tx, err := u.db.BeginTx(ctx, opts)
+    if err != nil {
+        return fmt.Errorf("begin transaction: %w", err)
+    }
+
+    defer func() {
+        if p := recover(); p != nil {
+            tx.Rollback()
+            panic(p)
+        }
+    }()
+
+    if err := fn(tx); err != nil {
+        tx.Rollback()
+        return err
+    }
+
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("commit transaction: %w", err)
+    }
+    return nil
+}
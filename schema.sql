@@ -1,15 +1,372 @@
 -- schema.sql
+-- Asset registry (Currencies and other assets with the decimal places they allow)
+CREATE TABLE IF NOT EXISTS assets (
+    code TEXT PRIMARY KEY,
+    scale INT NOT NULL CHECK (scale BETWEEN 0 AND 18),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO assets (code, scale) VALUES
+    ('USD', 5),
+    ('EUR', 2),
+    ('BTC', 8)
+ON CONFLICT (code) DO NOTHING;
+
 CREATE TABLE IF NOT EXISTS accounts (
     id INT PRIMARY KEY,
-    balance NUMERIC(15,5) NOT NULL CHECK (balance >= 0),
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    asset_code TEXT NOT NULL DEFAULT 'USD' REFERENCES assets(code),
+    balance NUMERIC(38,18) NOT NULL,
+    opening_balance NUMERIC(38,18) NOT NULL DEFAULT 0,
+    overdraft_limit NUMERIC(38,18) NOT NULL DEFAULT 0 CHECK (overdraft_limit >= 0),
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'frozen', 'dormant', 'closed')),
+    status_changed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- Accounts may only go negative up to their overdraft limit
+    CONSTRAINT accounts_balance_within_overdraft CHECK (balance >= -overdraft_limit)
 );
 
+-- Columns added to accounts after their table was first created. CREATE TABLE
+-- IF NOT EXISTS leaves an existing table as it is, so they are added here.
+-- The ledger view depends on the balance columns and is recreated below.
+DROP VIEW IF EXISTS account_ledger_balances;
+-- Accounts that predate opening_balance opened with whatever part of their
+-- balance their postings do not explain, so they are backfilled once, as the
+-- column is added
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                    WHERE table_schema = current_schema() AND table_name = 'accounts' AND column_name = 'opening_balance') THEN
+        ALTER TABLE accounts ADD COLUMN opening_balance NUMERIC(38,18) NOT NULL DEFAULT 0;
+        IF to_regclass('postings') IS NULL THEN
+            UPDATE accounts SET opening_balance = balance;
+        ELSE
+            EXECUTE 'UPDATE accounts a SET opening_balance = a.balance - COALESCE((SELECT SUM(p.amount) FROM postings p WHERE p.account_id = a.id), 0)';
+        END IF;
+    END IF;
+END $$;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS asset_code TEXT NOT NULL DEFAULT 'USD' REFERENCES assets(code);
+ALTER TABLE accounts ALTER COLUMN balance TYPE NUMERIC(38,18);
+ALTER TABLE accounts ALTER COLUMN opening_balance TYPE NUMERIC(38,18);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'frozen', 'dormant', 'closed'));
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdraft_limit NUMERIC(38,18) NOT NULL DEFAULT 0 CHECK (overdraft_limit >= 0);
+
+-- Overdrafts replace the original non-negative balance check. Earlier schemas
+-- left the overdraft check unnamed, as accounts_check.
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_balance_check;
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_check;
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_balance_within_overdraft;
+ALTER TABLE accounts ADD CONSTRAINT accounts_balance_within_overdraft CHECK (balance >= -overdraft_limit);
+
 -- Transaction table (To log transactions)
 CREATE TABLE IF NOT EXISTS transactions (
     id SERIAL PRIMARY KEY,
+    kind TEXT NOT NULL DEFAULT 'transfer' CHECK (kind IN ('transfer', 'reversal')),
+    reversal_of INT REFERENCES transactions(id),
     source_account_id INT NOT NULL,
     destination_account_id INT NOT NULL,
-    amount NUMERIC(15,5) NOT NULL CHECK (amount > 0),
+    asset_code TEXT NOT NULL DEFAULT 'USD' REFERENCES assets(code),
+    amount NUMERIC(38,18) NOT NULL CHECK (amount > 0),
+    quote_id TEXT,
+    destination_asset_code TEXT REFERENCES assets(code),
+    destination_amount NUMERIC(38,18) CHECK (destination_amount > 0),
+    fx_rate NUMERIC(38,18),
+    fx_rounding NUMERIC(38,18),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT transactions_reversal_of_check CHECK ((kind = 'reversal') = (reversal_of IS NOT NULL))
+);
+
+-- Columns added to transactions after their table was first created
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS asset_code TEXT NOT NULL DEFAULT 'USD' REFERENCES assets(code);
+ALTER TABLE transactions ALTER COLUMN amount TYPE NUMERIC(38,18);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS quote_id TEXT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS destination_asset_code TEXT REFERENCES assets(code);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS destination_amount NUMERIC(38,18) CHECK (destination_amount > 0);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_rate NUMERIC(38,18);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_rounding NUMERIC(38,18);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'transfer' CHECK (kind IN ('transfer', 'reversal'));
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS reversal_of INT REFERENCES transactions(id);
+-- Earlier schemas left the reversal check unnamed, as transactions_check
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_check;
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_reversal_of_check;
+ALTER TABLE transactions ADD CONSTRAINT transactions_reversal_of_check CHECK ((kind = 'reversal') = (reversal_of IS NOT NULL));
+
+CREATE INDEX IF NOT EXISTS idx_transactions_reversal_of ON transactions (reversal_of) WHERE reversal_of IS NOT NULL;
+
+-- Postings table (Double-entry ledger legs: debits are negative, credits positive)
+CREATE TABLE IF NOT EXISTS postings (
+    id SERIAL PRIMARY KEY,
+    transaction_id INT NOT NULL REFERENCES transactions(id),
+    account_id INT NOT NULL REFERENCES accounts(id),
+    asset_code TEXT NOT NULL REFERENCES assets(code),
+    amount NUMERIC(38,18) NOT NULL CHECK (amount <> 0),
+    balance_after NUMERIC(38,18) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Postings recorded before assets existed take the asset of their account
+ALTER TABLE postings ADD COLUMN IF NOT EXISTS asset_code TEXT REFERENCES assets(code);
+UPDATE postings p SET asset_code = a.asset_code FROM accounts a WHERE a.id = p.account_id AND p.asset_code IS NULL;
+ALTER TABLE postings ALTER COLUMN asset_code SET NOT NULL;
+ALTER TABLE postings ALTER COLUMN amount TYPE NUMERIC(38,18);
+ALTER TABLE postings ALTER COLUMN balance_after TYPE NUMERIC(38,18);
+
+CREATE INDEX IF NOT EXISTS idx_postings_account_id ON postings (account_id, id);
+CREATE INDEX IF NOT EXISTS idx_postings_transaction_id ON postings (transaction_id);
+
+-- The postings of every transaction must sum to zero per asset (checked at commit)
+CREATE OR REPLACE FUNCTION check_postings_balanced() RETURNS TRIGGER AS $$
+BEGIN
+    IF (SELECT SUM(amount) FROM postings
+        WHERE transaction_id = NEW.transaction_id AND asset_code = NEW.asset_code) <> 0 THEN
+        RAISE EXCEPTION 'postings of transaction % do not sum to zero for asset %', NEW.transaction_id, NEW.asset_code;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS postings_balanced ON postings;
+CREATE CONSTRAINT TRIGGER postings_balanced
+    AFTER INSERT ON postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_postings_balanced();
+
+-- Balances derived from postings, used to check the stored account balance
+CREATE OR REPLACE VIEW account_ledger_balances AS
+SELECT a.id AS account_id,
+       a.opening_balance + COALESCE(SUM(p.amount), 0) AS ledger_balance
+FROM accounts a
+LEFT JOIN postings p ON p.account_id = a.id
+GROUP BY a.id, a.opening_balance;
+
+-- Idempotency keys (Stored responses of POST requests sent with an Idempotency-Key header)
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    principal TEXT NOT NULL DEFAULT '',
+    scope TEXT NOT NULL,
+    key TEXT NOT NULL,
+    request_fingerprint TEXT NOT NULL,
+    response_status INT NOT NULL,
+    response_body JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (principal, scope, key)
+);
+
+-- Keys belong to the caller that sent them. Keys stored before they did have
+-- no principal, so they are never replayed to anyone.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS principal TEXT NOT NULL DEFAULT '';
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD CONSTRAINT idempotency_keys_pkey PRIMARY KEY (principal, scope, key);
+
+-- FX rates (1 unit of base_asset buys rate units of quote_asset)
+CREATE TABLE IF NOT EXISTS fx_rates (
+    base_asset TEXT NOT NULL REFERENCES assets(code),
+    quote_asset TEXT NOT NULL REFERENCES assets(code),
+    rate NUMERIC(38,18) NOT NULL CHECK (rate > 0),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (base_asset, quote_asset)
+);
+
+-- FX quotes (A rate locked for a short time, usable by one transfer)
+CREATE TABLE IF NOT EXISTS fx_quotes (
+    id TEXT PRIMARY KEY,
+    base_asset TEXT NOT NULL REFERENCES assets(code),
+    quote_asset TEXT NOT NULL REFERENCES assets(code),
+    rate NUMERIC(38,18) NOT NULL CHECK (rate > 0),
+    expires_at TIMESTAMPTZ NOT NULL,
+    transaction_id INT REFERENCES transactions(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- FX position accounts (The service's holding account per asset, used as the
+-- counterparty of both conversion legs so postings balance per asset)
+CREATE TABLE IF NOT EXISTS fx_positions (
+    asset_code TEXT PRIMARY KEY REFERENCES assets(code),
+    account_id INT NOT NULL REFERENCES accounts(id)
+);
+
+-- Holds (Funds reserved on the source account until captured, voided or expired)
+CREATE TABLE IF NOT EXISTS holds (
+    id SERIAL PRIMARY KEY,
+    source_account_id INT NOT NULL REFERENCES accounts(id),
+    destination_account_id INT NOT NULL REFERENCES accounts(id),
+    asset_code TEXT NOT NULL REFERENCES assets(code),
+    amount NUMERIC(38,18) NOT NULL CHECK (amount > 0),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'captured', 'voided')),
+    captured_amount NUMERIC(38,18) CHECK (captured_amount > 0 AND captured_amount <= amount),
+    transaction_id INT REFERENCES transactions(id),
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_pending ON holds (source_account_id, expires_at) WHERE status = 'pending';
+
+-- Scheduled transfers (Executed by the background worker once execute_at has passed)
+CREATE TABLE IF NOT EXISTS scheduled_transfers (
+    id SERIAL PRIMARY KEY,
+    source_account_id INT NOT NULL REFERENCES accounts(id),
+    destination_account_id INT NOT NULL REFERENCES accounts(id),
+    asset_code TEXT NOT NULL REFERENCES assets(code),
+    amount NUMERIC(38,18) NOT NULL CHECK (amount > 0),
+    convert BOOLEAN NOT NULL DEFAULT FALSE,
+    execute_at TIMESTAMPTZ NOT NULL,
+    status TEXT NOT NULL DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'executed', 'failed', 'cancelled')),
+    transaction_id INT REFERENCES transactions(id),
+    failure_reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_due ON scheduled_transfers (execute_at, id) WHERE status = 'scheduled';
+
+-- Transfers that hit a database error are retried with a backoff, then failed
+ALTER TABLE scheduled_transfers ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE scheduled_transfers ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ;
+
+-- Standing orders (Recurring transfers run by the background worker at next_run_at)
+CREATE TABLE IF NOT EXISTS standing_orders (
+    id SERIAL PRIMARY KEY,
+    source_account_id INT NOT NULL REFERENCES accounts(id),
+    destination_account_id INT NOT NULL REFERENCES accounts(id),
+    asset_code TEXT NOT NULL REFERENCES assets(code),
+    amount NUMERIC(38,18) NOT NULL CHECK (amount > 0),
+    convert BOOLEAN NOT NULL DEFAULT FALSE,
+    frequency TEXT NOT NULL CHECK (frequency IN ('daily', 'weekly', 'monthly', 'cron')),
+    day_of_month INT CHECK (day_of_month BETWEEN 1 AND 31),
+    cron TEXT,
+    start_at TIMESTAMPTZ NOT NULL,
+    end_at TIMESTAMPTZ,
+    max_occurrences INT CHECK (max_occurrences > 0),
+    max_retries INT NOT NULL DEFAULT 0 CHECK (max_retries >= 0),
+    retry_interval_seconds INT NOT NULL DEFAULT 0 CHECK (retry_interval_seconds >= 0),
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'paused', 'completed', 'cancelled')),
+    occurrences INT NOT NULL DEFAULT 0,
+    failed_attempts INT NOT NULL DEFAULT 0,
+    current_run_at TIMESTAMPTZ,
+    next_run_at TIMESTAMPTZ,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (end_at IS NOT NULL OR max_occurrences IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_standing_orders_due ON standing_orders (next_run_at, id) WHERE status = 'active';
+
+-- Every execution attempt of a standing order, successful or not
+CREATE TABLE IF NOT EXISTS standing_order_runs (
+    id SERIAL PRIMARY KEY,
+    standing_order_id INT NOT NULL REFERENCES standing_orders(id),
+    scheduled_for TIMESTAMPTZ NOT NULL,
+    attempt INT NOT NULL CHECK (attempt > 0),
+    status TEXT NOT NULL CHECK (status IN ('succeeded', 'failed')),
+    transaction_id INT REFERENCES transactions(id),
+    failure_reason TEXT,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
+
+CREATE INDEX IF NOT EXISTS idx_standing_order_runs_order ON standing_order_runs (standing_order_id, id);
+
+-- Transactions executed for a standing order link back to it
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS standing_order_id INT REFERENCES standing_orders(id);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_standing_order_id ON transactions (standing_order_id) WHERE standing_order_id IS NOT NULL;
+
+-- Transfer limit tiers (Named sets of limits; NULL is unlimited)
+CREATE TABLE IF NOT EXISTS limit_tiers (
+    name TEXT PRIMARY KEY,
+    max_single_amount NUMERIC(38,18) CHECK (max_single_amount >= 0),
+    max_daily_amount NUMERIC(38,18) CHECK (max_daily_amount >= 0),
+    max_monthly_amount NUMERIC(38,18) CHECK (max_monthly_amount >= 0),
+    max_daily_count INT CHECK (max_daily_count >= 0),
+    max_monthly_count INT CHECK (max_monthly_count >= 0),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Per-account limits (Assigns a tier and overrides any of its limits)
+CREATE TABLE IF NOT EXISTS account_limits (
+    account_id INT PRIMARY KEY REFERENCES accounts(id),
+    tier TEXT REFERENCES limit_tiers(name),
+    max_single_amount NUMERIC(38,18) CHECK (max_single_amount >= 0),
+    max_daily_amount NUMERIC(38,18) CHECK (max_daily_amount >= 0),
+    max_monthly_amount NUMERIC(38,18) CHECK (max_monthly_amount >= 0),
+    max_daily_count INT CHECK (max_daily_count >= 0),
+    max_monthly_count INT CHECK (max_monthly_count >= 0),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Outgoing volume of an account in the current day and month
+CREATE INDEX IF NOT EXISTS idx_transactions_source_created_at ON transactions (source_account_id, created_at) WHERE kind = 'transfer';
+
+-- Fee schedules (The fee charged on transfers of an asset and the account it is credited to)
+CREATE TABLE IF NOT EXISTS fee_schedules (
+    asset_code TEXT PRIMARY KEY REFERENCES assets(code),
+    fee_type TEXT NOT NULL CHECK (fee_type IN ('flat', 'percent', 'tiered')),
+    flat_amount NUMERIC(38,18) CHECK (flat_amount >= 0),
+    percent NUMERIC(38,18) CHECK (percent >= 0),
+    tiers JSONB,
+    min_fee NUMERIC(38,18) CHECK (min_fee >= 0),
+    max_fee NUMERIC(38,18) CHECK (max_fee >= min_fee),
+    fee_account_id INT NOT NULL REFERENCES accounts(id),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Fees charged on a transfer, paid by the source on top of the amount
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fee NUMERIC(38,18) CHECK (fee > 0);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fee_account_id INT REFERENCES accounts(id);
+
+-- Server-allocated account identifiers. Accounts inserted without an id take
+-- the next value of the sequence; every account has an opaque public_id.
+CREATE SEQUENCE IF NOT EXISTS accounts_id_seq OWNED BY accounts.id;
+ALTER TABLE accounts ALTER COLUMN id SET DEFAULT nextval('accounts_id_seq');
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS public_id TEXT UNIQUE;
+UPDATE accounts SET public_id = 'acc_' || md5(random()::text || id::text) WHERE public_id IS NULL;
+ALTER TABLE accounts ALTER COLUMN public_id SET NOT NULL;
+
+-- Customers (The owners of accounts)
+CREATE TABLE IF NOT EXISTS customers (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL CHECK (name <> ''),
+    external_ref TEXT UNIQUE,
+    kyc_tier TEXT NOT NULL DEFAULT 'none' CHECK (kyc_tier IN ('none', 'basic', 'verified')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS customer_id INT REFERENCES customers(id);
+
+CREATE INDEX IF NOT EXISTS idx_accounts_customer_id ON accounts (customer_id) WHERE customer_id IS NOT NULL;
+
+-- Transaction history is paged newest first by (created_at, id)
+ALTER TABLE transactions ALTER COLUMN created_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_created_at_id ON transactions (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_transactions_source_history ON transactions (source_account_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_transactions_destination_history ON transactions (destination_account_id, created_at, id);
+
+-- Transaction history filters: counterparty pairs and amount ranges
+CREATE INDEX IF NOT EXISTS idx_transactions_source_destination ON transactions (source_account_id, destination_account_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_transactions_destination_source ON transactions (destination_account_id, source_account_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_transactions_amount ON transactions (amount);
+
+-- API keys of clients. Only the SHA-256 of each key is stored.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL CHECK (name <> ''),
+    key_prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    role TEXT NOT NULL CHECK (role IN ('customer', 'operator', 'admin')),
+    customer_id INT REFERENCES customers(id),
+    expires_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (role <> 'customer' OR customer_id IS NOT NULL)
+);
+
+-- Token buckets of the rate limiter, shared by every instance when
+-- RATE_LIMIT_STORE=postgres
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_updated_at ON rate_limit_buckets (updated_at);
@@ -0,0 +1,102 @@
+package service
+
+import (
+    "context"
+    "crypto/rand"
+    "database/sql"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "transfer-service/model"
+    "transfer-service/repository"
+)
+
+var ErrAccountRefMismatch = errors.New("account public ID does not match account ID")
+
+// newAccountPublicID returns a random, unguessable account identifier
+func newAccountPublicID() (string, error) {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return "acc_" + hex.EncodeToString(b), nil
+}
+
+// resolveAccountRef returns the internal ID of the account ref names. A ref that
+// is an integer is a legacy account ID and is returned as is.
+func resolveAccountRef(ctx context.Context, repo repository.AccountRepository, ref string) (int, error) {
+    if id, err := strconv.Atoi(ref); err == nil {
+        return id, nil
+    }
+    account, err := repo.GetByPublicID(ctx, ref)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return 0, ErrAccountNotFound
+        }
+        return 0, fmt.Errorf("get account %s: %w", ref, err)
+    }
+    return account.ID, nil
+}
+
+// resolveAccounts sets the source and destination account IDs of a request
+// that names its accounts by public ID, then clears the refs
+func resolveAccounts(ctx context.Context, repo repository.AccountRepository, source, destination *string, sourceID, destinationID *int) error {
+    resolve := func(ref string, id *int, notFound error) error {
+        if ref == "" {
+            return nil
+        }
+        resolved, err := resolveAccountRef(ctx, repo, ref)
+        if err != nil {
+            if errors.Is(err, ErrAccountNotFound) {
+                return notFound
+            }
+            return err
+        }
+        if *id != 0 && *id != resolved {
+            return fmt.Errorf("%w: %s", ErrAccountRefMismatch, ref)
+        }
+        *id = resolved
+        return nil
+    }
+
+    if err := resolve(*source, sourceID, ErrSourceAccountNotFound); err != nil {
+        return err
+    }
+    if err := resolve(*destination, destinationID, ErrDestinationAccountNotFound); err != nil {
+        return err
+    }
+    *source, *destination = "", ""
+    return nil
+}
+
+// resolveTransferAccounts is resolveAccounts for a transfer
+func resolveTransferAccounts(ctx context.Context, repo repository.AccountRepository, t *model.Transaction) error {
+    return resolveAccounts(ctx, repo, &t.SourceAccount, &t.DestinationAccount, &t.SourceAccountID, &t.DestinationAccountID)
+}
+
+// ResolveAccountID returns the internal ID of an account given its public ID
+// or, for legacy clients, its integer ID
+func (s *AccountService) ResolveAccountID(ctx context.Context, ref string) (int, *AccountResult) {
+    id, err := resolveAccountRef(ctx, s.repo, ref)
+    if err != nil {
+        if errors.Is(err, ErrAccountNotFound) {
+            return 0, &AccountResult{
+                Success: false,
+                Status:  http.StatusNotFound,
+                Message: "Account not found",
+                Error:   err.Error(),
+                Code:    errorCode(err),
+            }
+        }
+        return 0, &AccountResult{
+            Success: false,
+            Status:  http.StatusInternalServerError,
+            Message: "Failed to retrieve account",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+    return id, nil
+}
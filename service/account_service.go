@@ -2,6 +2,8 @@ package service
 
 import (
     "context"
+    "database/sql"
+    "fmt"
     "transfer-service/model"
     "transfer-service/repository"
     "errors"
@@ -12,29 +14,62 @@ import (
 )
 
 type AccountService struct {
-    repo repository.AccountRepository
+    repo            repository.AccountRepository
+    idempotencyRepo repository.IdempotencyRepository
+    uow             repository.UnitOfWork
 }
 
 var ErrAccountExists = errors.New("account already exists")
+var ErrOverdraftLimitTooLow = errors.New("balance is below the overdraft limit")
 
-func NewAccountService(repo repository.AccountRepository) *AccountService {
-    return &AccountService{repo: repo}
+func NewAccountService(repo repository.AccountRepository, idempotencyRepo repository.IdempotencyRepository, uow repository.UnitOfWork) *AccountService {
+    return &AccountService{
+        repo:            repo,
+        idempotencyRepo: idempotencyRepo,
+        uow:             uow,
+    }
 }
 
 // AccountResult represents the result of an account operation
-type AccountResult struct {
-    Success bool
-    Status  int
-    Message string
-    Error   string
-    Data    interface{}
-}
+type AccountResult = Result
 
 func (s *AccountService) CreateAccount(ctx context.Context, acc model.Account) *AccountResult {
+    return s.CreateAccountIdempotent(ctx, acc, nil)
+}
+
+// CreateAccountIdempotent creates an account once per idempotency key. A repeated
+// key with the same request replays the original response; a nil key disables the check.
+func (s *AccountService) CreateAccountIdempotent(ctx context.Context, acc model.Account, key *model.IdempotencyKey) *AccountResult {
     log := middleware.GetLogger()
     
-    // Validate balance precision (5 decimal places)
-    if !isValidPrecision(acc.Balance, 5) {
+    if err := authorize(ctx, PermManageAccounts); err != nil {
+        return forbidden(err)
+    }
+
+    // New accounts are always active; the status is changed by an admin later
+    acc.Status = model.AccountStatusActive
+
+    // Accounts without an asset code hold the default asset
+    if acc.AssetCode == "" {
+        acc.AssetCode = model.DefaultAssetCode
+    }
+    asset, ok := model.LookupAsset(acc.AssetCode)
+    if !ok {
+        log.Warn("Account creation failed - unknown asset",
+            zap.Int("account_id", acc.ID),
+            zap.String("asset_code", acc.AssetCode),
+        )
+        return &AccountResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Unknown asset code",
+            Error:   ErrUnknownAsset.Error(),
+            Code:    errorCode(ErrUnknownAsset),
+        }
+    }
+
+    // Validate balance precision against the asset's scale
+    if !isValidPrecision(acc.Balance, int(asset.Scale)) {
         log.Warn("Account creation failed - invalid balance precision",
             zap.Int("account_id", acc.ID),
             zap.String("balance", acc.Balance.String()),
@@ -42,19 +77,86 @@ func (s *AccountService) CreateAccount(ctx context.Context, acc model.Account) *
         return &AccountResult{
             Success: false,
             Status:  http.StatusBadRequest,
-            Message: "Balance must have at most 5 decimal places",
+            Message: fmt.Sprintf("Balance must have at most %d decimal places", asset.Scale),
             Error:   "invalid precision",
+            Code:    CodePrecisionExceeded,
         }
     }
     
+    if result := validateOverdraft(acc, int(asset.Scale)); result != nil {
+        log.Warn("Account creation failed - invalid overdraft limit",
+            zap.Int("account_id", acc.ID),
+            zap.String("overdraft_limit", acc.OverdraftLimit.String()),
+        )
+        return result
+    }
+    
+    // The service allocates the public ID, and the account ID unless a legacy client chose one
+    publicID, err := newAccountPublicID()
+    if err != nil {
+        log.Error("Account creation failed - public ID generation",
+            zap.Error(err),
+        )
+        return &AccountResult{
+            Success: false,
+            Status:  http.StatusInternalServerError,
+            Message: "Failed to create account",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+    acc.PublicID = publicID
+
     log.Info("Creating account",
         zap.Int("account_id", acc.ID),
-        zap.Float64("balance", formatDecimal(acc.Balance)),
+        zap.String("public_id", acc.PublicID),
+        zap.String("asset_code", acc.AssetCode),
+        zap.Intp("customer_id", acc.CustomerID),
+        zap.Float64("balance", formatDecimal(acc.Balance, acc.AssetCode)),
     )
     
-    err := s.repo.Create(ctx, acc)
+    var result *AccountResult
+    err = retryTx(ctx, func() error {
+        return s.uow.Do(ctx, nil, func(tx *sql.Tx) error {
+            if key != nil {
+                replayed, err := replayIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeAccount, key, &model.Account{})
+                if err != nil || replayed != nil {
+                    result = replayed
+                    return err
+                }
+            }
+
+            id, err := s.repo.CreateWithTx(ctx, tx, acc)
+            if err != nil {
+                // The constraint message names database internals; it is
+                // logged but not returned to the client
+                if middleware.IsUniqueViolation(err) {
+                    log.Debug("Account insert violated a unique constraint", zap.Error(err))
+                    return fmt.Errorf("%w: account %d", ErrAccountExists, acc.ID)
+                }
+                if middleware.IsForeignKeyViolation(err) {
+                    log.Debug("Account insert violated a foreign key", zap.Error(err))
+                    return ErrCustomerNotFound
+                }
+                return err
+            }
+            acc.ID = id
+            result = &AccountResult{
+                Success: true,
+                Status:  http.StatusCreated,
+                Message: "Account created successfully",
+                Data:    acc,
+            }
+
+            if key != nil {
+                return storeIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeAccount, key, result)
+            }
+            return nil
+        })
+    })
     if err != nil {
-        if middleware.IsUniqueViolation(err) {
+        switch {
+        case errors.Is(err, ErrAccountExists):
             log.Warn("Account creation failed - duplicate ID",
                 zap.Int("account_id", acc.ID),
                 zap.Error(err),
@@ -64,6 +166,29 @@ func (s *AccountService) CreateAccount(ctx context.Context, acc model.Account) *
                 Status:  http.StatusConflict,
                 Message: "Account already exists",
                 Error:   err.Error(),
+                Code:    errorCode(err),
+            }
+        case errors.Is(err, ErrCustomerNotFound):
+            log.Warn("Account creation failed - unknown customer",
+                zap.Intp("customer_id", acc.CustomerID),
+            )
+            return &AccountResult{
+                Success: false,
+                Status:  http.StatusBadRequest,
+                Message: "Customer not found",
+                Error:   err.Error(),
+                Code:    errorCode(err),
+            }
+        case errors.Is(err, ErrIdempotencyKeyReused):
+            log.Warn("Account creation failed - idempotency key reused",
+                zap.String("idempotency_key", key.Key),
+            )
+            return &AccountResult{
+                Success: false,
+                Status:  http.StatusUnprocessableEntity,
+                Message: "Idempotency key was already used with a different request",
+                Error:   err.Error(),
+                Code:    errorCode(err),
             }
         }
         log.Error("Account creation failed",
@@ -75,22 +200,142 @@ func (s *AccountService) CreateAccount(ctx context.Context, acc model.Account) *
             Status:  http.StatusInternalServerError,
             Message: "Failed to create account",
             Error:   err.Error(),
+            Code:    errorCode(err),
         }
     }
     
+    if result.Replayed {
+        log.Info("Replayed idempotent account creation",
+            zap.String("idempotency_key", key.Key),
+        )
+        return result
+    }
+
     log.Info("Account created successfully",
         zap.Int("account_id", acc.ID),
-        zap.Float64("balance", formatDecimal(acc.Balance)),
+        zap.String("public_id", acc.PublicID),
+        zap.Float64("balance", formatDecimal(acc.Balance, acc.AssetCode)),
     )
     
+    return result
+}
+
+// AccountUpdate is the body of an account update. Omitted fields are left unchanged.
+type AccountUpdate struct {
+    OverdraftLimit *decimal.Decimal `json:"overdraft_limit,omitempty"`
+}
+
+// UpdateAccount changes the settings of an account with its row locked, so a
+// new overdraft limit is checked against the balance it will apply to
+func (s *AccountService) UpdateAccount(ctx context.Context, id int, upd AccountUpdate) *AccountResult {
+    log := middleware.GetLogger()
+
+    if err := authorize(ctx, PermManageAccounts); err != nil {
+        return forbidden(err)
+    }
+
+    log.Info("Updating account",
+        zap.Int("account_id", id),
+        zap.Stringer("overdraft_limit", upd.OverdraftLimit),
+    )
+
+    var updated *model.Account
+    var invalid *AccountResult
+    err := retryTx(ctx, func() error {
+        return s.uow.Do(ctx, nil, func(tx *sql.Tx) error {
+            account, err := s.repo.GetByIDWithLock(ctx, tx, id)
+            if err != nil {
+                return err
+            }
+            acc := *account
+            if upd.OverdraftLimit != nil {
+                acc.OverdraftLimit = *upd.OverdraftLimit
+                if invalid = validateOverdraft(acc, int(model.AssetScale(acc.AssetCode))); invalid != nil {
+                    return nil
+                }
+                if err := s.repo.UpdateOverdraftLimitWithTx(ctx, tx, id, acc.OverdraftLimit); err != nil {
+                    return err
+                }
+            }
+            updated = &acc
+            return nil
+        })
+    })
+    if invalid != nil {
+        log.Warn("Account update failed - invalid overdraft limit",
+            zap.Int("account_id", id),
+            zap.String("error", invalid.Error),
+        )
+        return invalid
+    }
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return &AccountResult{
+                Success: false,
+                Status:  http.StatusNotFound,
+                Message: "Account not found",
+                Error:   ErrAccountNotFound.Error(),
+                Code:    errorCode(ErrAccountNotFound),
+            }
+        }
+        log.Error("Account update failed",
+            zap.Int("account_id", id),
+            zap.Error(err),
+        )
+        return &AccountResult{
+            Success: false,
+            Status:  http.StatusInternalServerError,
+            Message: "Failed to update account",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+
+    log.Info("Account updated successfully",
+        zap.Int("account_id", id),
+        zap.Float64("overdraft_limit", formatDecimal(updated.OverdraftLimit, updated.AssetCode)),
+    )
+
     return &AccountResult{
         Success: true,
-        Status:  http.StatusCreated,
-        Message: "Account created successfully",
-        Data:    acc,
+        Status:  http.StatusOK,
+        Message: "Account updated successfully",
+        Data:    updated,
     }
 }
 
+// validateOverdraft checks an account's overdraft limit against its asset's
+// scale and current balance, returning nil if it is valid
+func validateOverdraft(acc model.Account, scale int) *AccountResult {
+    switch {
+    case acc.OverdraftLimit.IsNegative():
+        return &AccountResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Overdraft limit cannot be negative",
+            Error:   "invalid overdraft limit",
+            Code:    CodeInvalidOverdraftLimit,
+        }
+    case !isValidPrecision(acc.OverdraftLimit, scale):
+        return &AccountResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: fmt.Sprintf("Overdraft limit must have at most %d decimal places", scale),
+            Error:   "invalid precision",
+            Code:    CodePrecisionExceeded,
+        }
+    case acc.Balance.Add(acc.OverdraftLimit).IsNegative():
+        return &AccountResult{
+            Success: false,
+            Status:  http.StatusConflict,
+            Message: "Balance is below the overdraft limit",
+            Error:   ErrOverdraftLimitTooLow.Error(),
+            Code:    errorCode(ErrOverdraftLimitTooLow),
+        }
+    }
+    return nil
+}
+
 func (s *AccountService) GetAccount(ctx context.Context, id int) *AccountResult {
     log := middleware.GetLogger()
     
@@ -99,22 +344,42 @@ func (s *AccountService) GetAccount(ctx context.Context, id int) *AccountResult
     )
     
     account, err := s.repo.GetByID(ctx, id)
-    if err != nil {
+    if err == nil {
+        if authErr := authorizeAccount(ctx, PermReadAccount, account); authErr != nil {
+            if !errors.Is(authErr, ErrAccountNotFound) {
+                return forbidden(authErr)
+            }
+            err = sql.ErrNoRows
+        }
+    }
+    if err == sql.ErrNoRows {
         log.Warn("Account not found",
             zap.Int("account_id", id),
-            zap.Error(err),
         )
         return &AccountResult{
             Success: false,
             Status:  http.StatusNotFound,
             Message: "Account not found",
+            Error:   ErrAccountNotFound.Error(),
+            Code:    errorCode(ErrAccountNotFound),
+        }
+    }
+    if err != nil {
+        log.Error("Account retrieval failed",
+            zap.Int("account_id", id),
+            zap.Error(err),
+        )
+        return &AccountResult{
+            Success: false,
+            Status:  http.StatusInternalServerError,
+            Message: "Failed to retrieve account",
             Error:   err.Error(),
+            Code:    errorCode(err),
         }
     }
-    
     log.Info("Account retrieved successfully",
         zap.Int("account_id", id),
-        zap.Float64("balance", formatDecimal(account.Balance)),
+        zap.Float64("balance", formatDecimal(account.Balance, account.AssetCode)),
     )
     
     return &AccountResult{
@@ -130,7 +395,7 @@ func isValidPrecision(d decimal.Decimal, maxDecimalPlaces int) bool {
     return d.Exponent() >= int32(-maxDecimalPlaces)
 }
 
-// formatDecimal formats a decimal to exactly 5 decimal places
-func formatDecimal(d decimal.Decimal) float64 {
-    return d.Round(5).InexactFloat64()
+// formatDecimal rounds a decimal to the scale of its asset for logging
+func formatDecimal(d decimal.Decimal, assetCode string) float64 {
+    return d.Round(model.AssetScale(assetCode)).InexactFloat64()
 }
@@ -0,0 +1,251 @@
+package service
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "net/http"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "go.uber.org/zap"
+    "github.com/shopspring/decimal"
+)
+
+var ErrAccountNotFound = errors.New("account not found")
+var ErrInvalidAccountStatus = errors.New("invalid account status")
+var ErrStatusTransition = errors.New("account status change not allowed")
+var ErrAccountHasBalance = errors.New("account has a balance and no sweep account")
+var ErrAccountHasHolds = errors.New("account has pending holds")
+var ErrAccountOverdrawn = errors.New("account is overdrawn")
+
+// AccountStatusRequest is the body of an account status change. Closing an
+// account with a balance moves it to SweepAccountID first.
+type AccountStatusRequest struct {
+    Status         string `json:"status"`
+    SweepAccountID *int   `json:"sweep_account_id,omitempty"`
+}
+
+// AccountStatusReceipt is the data returned for a status change
+type AccountStatusReceipt struct {
+    Message string             `json:"message"`
+    Account *model.Account     `json:"account"`
+    Sweep   *model.Transaction `json:"sweep,omitempty"`
+}
+
+func (s *TransactionService) ChangeAccountStatus(ctx context.Context, id int, req AccountStatusRequest) *TransferResult {
+    return s.ChangeAccountStatusIdempotent(ctx, id, req, nil)
+}
+
+// ChangeAccountStatusIdempotent moves an account to req.Status, once per
+// idempotency key. Allowed changes are active to frozen, dormant or closed,
+// frozen or dormant back to active, and dormant to closed.
+func (s *TransactionService) ChangeAccountStatusIdempotent(ctx context.Context, id int, req AccountStatusRequest, key *model.IdempotencyKey) *TransferResult {
+    log := middleware.GetLogger()
+
+    if err := authorize(ctx, PermManageAccounts); err != nil {
+        return forbidden(err)
+    }
+
+    log.Info("Changing account status",
+        zap.Int("account_id", id),
+        zap.String("status", req.Status),
+    )
+
+    if !model.IsAccountStatus(req.Status) {
+        return accountStatusFailure(fmt.Errorf("%w: %q", ErrInvalidAccountStatus, req.Status))
+    }
+    if req.SweepAccountID != nil && req.Status != model.AccountStatusClosed {
+        return accountStatusFailure(fmt.Errorf("%w: sweep_account_id is only used when closing", ErrInvalidAccountStatus))
+    }
+
+    var result *TransferResult
+    var receipt *AccountStatusReceipt
+    err := retryTx(ctx, func() error {
+        return s.uow.Do(ctx, &sql.TxOptions{
+            Isolation: sql.LevelSerializable,
+        }, func(tx *sql.Tx) error {
+            if key != nil {
+                replayed, err := replayIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeAccountStatus, key, &AccountStatusReceipt{})
+                if err != nil || replayed != nil {
+                    result = replayed
+                    return err
+                }
+            }
+
+            var err error
+            receipt, err = s.changeStatus(ctx, tx, id, req)
+            if err != nil {
+                return err
+            }
+            result = &TransferResult{
+                Success: true,
+                Status:  http.StatusOK,
+                Message: receipt.Message,
+                Data:    receipt,
+            }
+
+            if key != nil {
+                return storeIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeAccountStatus, key, result)
+            }
+            return nil
+        })
+    })
+    if err != nil {
+        return accountStatusFailure(err)
+    }
+
+    if result.Replayed {
+        log.Info("Replayed idempotent account status change",
+            zap.String("idempotency_key", key.Key),
+        )
+        return result
+    }
+
+    log.Info("Account status changed",
+        zap.Int("account_id", id),
+        zap.String("status", receipt.Account.Status),
+    )
+
+    return result
+}
+
+// changeStatus checks and applies a status change inside tx, sweeping the
+// balance of an account being closed
+func (s *TransactionService) changeStatus(ctx context.Context, tx *sql.Tx, id int, req AccountStatusRequest) (*AccountStatusReceipt, error) {
+    log := middleware.GetLogger()
+
+    ids := []int{id}
+    if req.SweepAccountID != nil {
+        ids = append(ids, *req.SweepAccountID)
+    }
+    accounts, err := s.lockAccounts(ctx, tx, ids...)
+    if err != nil {
+        return nil, err
+    }
+    account, ok := accounts[id]
+    if !ok {
+        return nil, ErrAccountNotFound
+    }
+
+    if !model.CanChangeAccountStatus(account.Status, req.Status) {
+        log.Warn("Account status change rejected",
+            zap.Int("account_id", id),
+            zap.String("from", account.Status),
+            zap.String("to", req.Status),
+        )
+        return nil, fmt.Errorf("%w: %s to %s", ErrStatusTransition, account.Status, req.Status)
+    }
+
+    updated := *account
+    updated.Status = req.Status
+    receipt := &AccountStatusReceipt{Message: "Account status changed successfully", Account: &updated}
+    if req.Status == model.AccountStatusClosed {
+        if account.HeldBalance.IsPositive() {
+            return nil, ErrAccountHasHolds
+        }
+        if !account.Balance.IsZero() {
+            receipt.Sweep, err = s.sweep(ctx, tx, accounts, account, req.SweepAccountID)
+            if err != nil {
+                return nil, err
+            }
+            receipt.Message = "Account balance swept and account closed successfully"
+            updated.Balance = decimal.Zero
+        }
+    }
+
+    if err := s.accountRepo.UpdateStatusWithTx(ctx, tx, id, req.Status); err != nil {
+        return nil, fmt.Errorf("update status of account %d: %w", id, err)
+    }
+    return receipt, nil
+}
+
+// sweep moves the whole balance of an account being closed to sweepID
+func (s *TransactionService) sweep(ctx context.Context, tx *sql.Tx, accounts map[int]*model.Account, account *model.Account, sweepID *int) (*model.Transaction, error) {
+    if account.Balance.IsNegative() {
+        return nil, ErrAccountOverdrawn
+    }
+    if sweepID == nil {
+        return nil, ErrAccountHasBalance
+    }
+    if *sweepID == account.ID {
+        return nil, ErrSameAccount
+    }
+    to, ok := accounts[*sweepID]
+    if !ok {
+        return nil, ErrDestinationAccountNotFound
+    }
+    if to.AssetCode != account.AssetCode {
+        return nil, ErrCrossAssetTransfer
+    }
+    if to.Status == model.AccountStatusClosed {
+        return nil, fmt.Errorf("%w: account %d", ErrAccountClosed, to.ID)
+    }
+
+    // The sweep is an admin action, so it is not subject to the source's status
+    postings := []model.Posting{
+        {AccountID: account.ID, AssetCode: account.AssetCode, Amount: account.Balance.Neg()},
+        {AccountID: to.ID, AssetCode: account.AssetCode, Amount: account.Balance},
+    }
+    return s.post(ctx, tx, model.Transaction{
+        SourceAccountID:      account.ID,
+        DestinationAccountID: to.ID,
+        AssetCode:            account.AssetCode,
+        Amount:               account.Balance,
+    }, postings)
+}
+
+// accountStatusFailure maps an account status error to a result
+func accountStatusFailure(err error) *TransferResult {
+    switch {
+    case errors.Is(err, ErrAccountNotFound):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusNotFound,
+            Message: "Account not found",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrInvalidAccountStatus):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Status must be active, frozen, dormant or closed",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrStatusTransition):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusConflict,
+            Message: "Account status change not allowed",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrAccountHasBalance):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusConflict,
+            Message: "Account has a balance; give a sweep_account_id to move it before closing",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrAccountOverdrawn):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusConflict,
+            Message: "Account is overdrawn; bring its balance to zero before closing",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrAccountHasHolds):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusConflict,
+            Message: "Account has pending holds; capture or void them before closing",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+    return transferFailure(err)
+}
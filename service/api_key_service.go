@@ -0,0 +1,253 @@
+package service
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "database/sql"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "transfer-service/repository"
+    "go.uber.org/zap"
+)
+
+var ErrAPIKeyNotFound = errors.New("API key not found")
+var ErrInvalidAPIKeyRequest = errors.New("invalid API key request")
+
+// apiKeyBytes is the entropy of an API key. Keys are random enough that an
+// unsalted SHA-256 is a safe way to store them.
+const apiKeyBytes = 32
+
+// apiKeyPrefixLength is how much of a key is stored in the clear
+const apiKeyPrefixLength = 12
+
+type APIKeyService struct {
+    repo repository.APIKeyRepository
+    now  func() time.Time
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository) *APIKeyService {
+    return &APIKeyService{repo: repo, now: time.Now}
+}
+
+// APIKeyRequest is the body of an API key issue request. Customer keys must
+// name the customer they act for.
+type APIKeyRequest struct {
+    Name       string     `json:"name"`
+    Role       string     `json:"role"`
+    CustomerID *int       `json:"customer_id,omitempty"`
+    ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// IssuedAPIKey is a newly issued key. Key is only ever returned here.
+type IssuedAPIKey struct {
+    *model.APIKey
+    Key string `json:"key"`
+}
+
+// IssueKey creates an API key. Only admins may issue keys.
+func (s *APIKeyService) IssueKey(ctx context.Context, req APIKeyRequest) *Result {
+    log := middleware.GetLogger()
+
+    if err := requireAdmin(ctx); err != nil {
+        return apiKeyFailure(err)
+    }
+    if err := s.validateRequest(req); err != nil {
+        return apiKeyFailure(err)
+    }
+
+    key, err := newAPIKey()
+    if err != nil {
+        return apiKeyFailure(err)
+    }
+    record := model.APIKey{
+        Name:       req.Name,
+        Prefix:     key[:apiKeyPrefixLength],
+        Role:       req.Role,
+        CustomerID: req.CustomerID,
+        Hash:       hashAPIKey(key),
+    }
+    if req.ExpiresAt != nil {
+        expiresAt := req.ExpiresAt.UTC()
+        record.ExpiresAt = &expiresAt
+    }
+
+    created, err := s.repo.Create(ctx, record)
+    if err != nil {
+        if middleware.IsForeignKeyViolation(err) {
+            return apiKeyFailure(fmt.Errorf("%w: %d", ErrCustomerNotFound, *req.CustomerID))
+        }
+        return apiKeyFailure(err)
+    }
+
+    log.Info("API key issued",
+        zap.Int("api_key_id", created.ID),
+        zap.String("prefix", created.Prefix),
+        zap.String("role", created.Role),
+        zap.String("issued_by", middleware.PrincipalFromContext(ctx).Subject),
+    )
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusCreated,
+        Message: "API key issued successfully; store the key now, it is not shown again",
+        Data:    &IssuedAPIKey{APIKey: created, Key: key},
+    }
+}
+
+func (s *APIKeyService) ListKeys(ctx context.Context) *Result {
+    if err := requireAdmin(ctx); err != nil {
+        return apiKeyFailure(err)
+    }
+
+    keys, err := s.repo.GetAll(ctx)
+    if err != nil {
+        return apiKeyFailure(err)
+    }
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "API keys retrieved successfully",
+        Data:    keys,
+    }
+}
+
+// RevokeKey stops a key from authenticating. Revoking a revoked key succeeds.
+func (s *APIKeyService) RevokeKey(ctx context.Context, id int) *Result {
+    if err := requireAdmin(ctx); err != nil {
+        return apiKeyFailure(err)
+    }
+
+    revoked, err := s.repo.Revoke(ctx, id, s.now())
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return apiKeyFailure(ErrAPIKeyNotFound)
+        }
+        return apiKeyFailure(err)
+    }
+
+    middleware.GetLogger().Info("API key revoked",
+        zap.Int("api_key_id", id),
+        zap.String("revoked_by", middleware.PrincipalFromContext(ctx).Subject),
+    )
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "API key revoked successfully",
+        Data:    revoked,
+    }
+}
+
+// AuthenticateAPIKey implements middleware.APIKeyAuthenticator
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, key string) (*model.Principal, error) {
+    record, err := s.repo.GetByHash(ctx, hashAPIKey(key))
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, middleware.ErrInvalidAPIKey
+        }
+        return nil, err
+    }
+    if !record.Active(s.now()) {
+        return nil, fmt.Errorf("%w: key %d is revoked or expired", middleware.ErrInvalidAPIKey, record.ID)
+    }
+
+    return &model.Principal{
+        Subject:    fmt.Sprintf("api_key:%d", record.ID),
+        Method:     model.AuthMethodAPIKey,
+        Role:       record.Role,
+        CustomerID: record.CustomerID,
+    }, nil
+}
+
+func (s *APIKeyService) validateRequest(req APIKeyRequest) error {
+    switch {
+    case strings.TrimSpace(req.Name) == "":
+        return fmt.Errorf("%w: name is required", ErrInvalidAPIKeyRequest)
+    case !model.IsRole(req.Role):
+        return fmt.Errorf("%w: unknown role %q", ErrInvalidAPIKeyRequest, req.Role)
+    case req.Role == model.RoleCustomer && req.CustomerID == nil:
+        return fmt.Errorf("%w: customer_id is required for the customer role", ErrInvalidAPIKeyRequest)
+    case req.Role != model.RoleCustomer && req.CustomerID != nil:
+        return fmt.Errorf("%w: only customer keys act for a customer", ErrInvalidAPIKeyRequest)
+    case req.ExpiresAt != nil && !req.ExpiresAt.After(s.now()):
+        return fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKeyRequest)
+    }
+    return nil
+}
+
+// requireAdmin rejects callers other than admins
+func requireAdmin(ctx context.Context) error {
+    principal := middleware.PrincipalFromContext(ctx)
+    if principal == nil || principal.Role != model.RoleAdmin {
+        return fmt.Errorf("%w: admin role required", ErrForbidden)
+    }
+    return nil
+}
+
+// newAPIKey generates a random key starting with middleware.APIKeyPrefix
+func newAPIKey() (string, error) {
+    b := make([]byte, apiKeyBytes)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return middleware.APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashAPIKey(key string) string {
+    sum := sha256.Sum256([]byte(key))
+    return hex.EncodeToString(sum[:])
+}
+
+// apiKeyFailure maps an API key error to a result
+func apiKeyFailure(err error) *Result {
+    switch {
+    case errors.Is(err, ErrForbidden):
+        return &Result{
+            Success: false,
+            Status:  http.StatusForbidden,
+            Message: "Not allowed to manage API keys",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrInvalidAPIKeyRequest):
+        return &Result{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Invalid API key request",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrCustomerNotFound):
+        return &Result{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Customer not found",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrAPIKeyNotFound):
+        return &Result{
+            Success: false,
+            Status:  http.StatusNotFound,
+            Message: "API key not found",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+    return &Result{
+        Success: false,
+        Status:  http.StatusInternalServerError,
+        Message: "Failed to process API key",
+        Error:   err.Error(),
+        Code:    errorCode(err),
+    }
+}
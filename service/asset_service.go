@@ -0,0 +1,76 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "transfer-service/repository"
+    "go.uber.org/zap"
+    "github.com/shopspring/decimal"
+)
+
+var ErrUnknownAsset = errors.New("unknown asset")
+var ErrInvalidAmount = errors.New("amount must be positive")
+
+// PrecisionError reports an amount with more decimal places than its asset allows
+type PrecisionError struct {
+    AssetCode string
+    Scale     int32
+}
+
+func (e *PrecisionError) Error() string {
+    return fmt.Sprintf("invalid precision: %s allows at most %d decimal places", e.AssetCode, e.Scale)
+}
+
+type AssetService struct {
+    repo repository.AssetRepository
+}
+
+func NewAssetService(repo repository.AssetRepository) *AssetService {
+    return &AssetService{repo: repo}
+}
+
+// LoadRegistry fills the model asset registry from the assets table
+func (s *AssetService) LoadRegistry(ctx context.Context) error {
+    log := middleware.GetLogger()
+
+    assets, err := s.repo.GetAll(ctx)
+    if err != nil {
+        return fmt.Errorf("load assets: %w", err)
+    }
+    for _, a := range assets {
+        model.RegisterAsset(a)
+    }
+
+    log.Info("Asset registry loaded",
+        zap.Int("assets", len(assets)),
+    )
+    return nil
+}
+
+func (s *AssetService) ListAssets(ctx context.Context) *Result {
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Assets retrieved successfully",
+        Data:    model.RegisteredAssets(),
+    }
+}
+
+// validateAmount checks that a positive amount fits the scale of its asset
+func validateAmount(amount decimal.Decimal, assetCode string) error {
+    asset, ok := model.LookupAsset(assetCode)
+    if !ok {
+        return fmt.Errorf("%w: %s", ErrUnknownAsset, assetCode)
+    }
+    if !amount.IsPositive() {
+        return ErrInvalidAmount
+    }
+    if !isValidPrecision(amount, int(asset.Scale)) {
+        return &PrecisionError{AssetCode: asset.Code, Scale: asset.Scale}
+    }
+    return nil
+}
@@ -0,0 +1,332 @@
+package service
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "net/http"
+    "sort"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "go.uber.org/zap"
+    "github.com/shopspring/decimal"
+)
+
+// MaxBatchLegs is the largest number of legs accepted in one batch
+const MaxBatchLegs = 100
+
+var ErrEmptyBatch = errors.New("batch has no legs")
+var ErrBatchTooLarge = fmt.Errorf("batch has more than %d legs", MaxBatchLegs)
+
+// BatchRequest is a list of same-asset transfers executed all-or-nothing
+type BatchRequest struct {
+    Legs []model.Transaction `json:"legs"`
+}
+
+// BatchReceipt is the data returned for a completed batch. Transactions and
+// their IDs are in leg order.
+type BatchReceipt struct {
+    Message        string               `json:"message"`
+    TransactionIDs []int                `json:"transaction_ids"`
+    Transactions   []*model.Transaction `json:"transactions"`
+}
+
+// BatchLegError reports the zero-based index of the leg that failed a batch
+type BatchLegError struct {
+    Leg int
+    Err error
+}
+
+func (e *BatchLegError) Error() string {
+    return fmt.Sprintf("leg %d: %v", e.Leg, e.Err)
+}
+
+func (e *BatchLegError) Unwrap() error {
+    return e.Err
+}
+
+func (s *TransactionService) TransferBatch(ctx context.Context, req BatchRequest) *TransferResult {
+    return s.TransferBatchIdempotent(ctx, req, nil)
+}
+
+// TransferBatchIdempotent executes every leg in one database transaction, once
+// per idempotency key. Any failing leg rolls back the whole batch.
+func (s *TransactionService) TransferBatchIdempotent(ctx context.Context, req BatchRequest, key *model.IdempotencyKey) *TransferResult {
+    log := middleware.GetLogger()
+
+    log.Info("Starting batch transfer",
+        zap.Int("legs", len(req.Legs)),
+    )
+
+    switch {
+    case len(req.Legs) == 0:
+        return batchFailure(ErrEmptyBatch)
+    case len(req.Legs) > MaxBatchLegs:
+        return batchFailure(ErrBatchTooLarge)
+    }
+    for i := range req.Legs {
+        if err := resolveTransferAccounts(ctx, s.accountRepo, &req.Legs[i]); err != nil {
+            return batchFailure(&BatchLegError{Leg: i, Err: err})
+        }
+        // Authorize before a stored response can be replayed
+        if err := authorizeAccountID(ctx, s.accountRepo, PermDebitAccount, req.Legs[i].SourceAccountID); err != nil {
+            return batchFailure(&BatchLegError{Leg: i, Err: err})
+        }
+    }
+
+    var result *TransferResult
+    var receipt *BatchReceipt
+    err := retryTx(ctx, func() error {
+        return s.uow.Do(ctx, &sql.TxOptions{
+            Isolation: sql.LevelSerializable,
+        }, func(tx *sql.Tx) error {
+            if key != nil {
+                replayed, err := replayIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeBatch, key, &BatchReceipt{})
+                if err != nil || replayed != nil {
+                    result = replayed
+                    return err
+                }
+            }
+
+            transactions, err := s.transferBatch(ctx, tx, req.Legs)
+            if err != nil {
+                return err
+            }
+            receipt = &BatchReceipt{
+                Message:      "Batch completed successfully",
+                Transactions: transactions,
+            }
+            for _, t := range transactions {
+                receipt.TransactionIDs = append(receipt.TransactionIDs, t.ID)
+            }
+            result = &TransferResult{
+                Success: true,
+                Status:  http.StatusOK,
+                Message: receipt.Message,
+                Data:    receipt,
+            }
+
+            if key != nil {
+                return storeIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeBatch, key, result)
+            }
+            return nil
+        })
+    })
+    if err != nil {
+        return batchFailure(err)
+    }
+
+    if result.Replayed {
+        log.Info("Replayed idempotent batch transfer",
+            zap.String("idempotency_key", key.Key),
+        )
+        return result
+    }
+
+    log.Info("Batch transfer completed successfully",
+        zap.Ints("transaction_ids", receipt.TransactionIDs),
+    )
+
+    return result
+}
+
+// transferBatch locks every account of the batch in one ordered pass, checks
+// the net effect of all legs, fees included, and then posts each leg as its
+// own transaction.
+// Because funds are checked on the net effect, money may pass through an
+// intermediate account that is credited by a later leg.
+func (s *TransactionService) transferBatch(ctx context.Context, tx *sql.Tx, legs []model.Transaction) ([]*model.Transaction, error) {
+    log := middleware.GetLogger()
+
+    // Every leg pays the fee of a transfer; fee accounts are locked with the rest
+    ids := make([]int, 0, 3*len(legs))
+    schedules := make([]*model.FeeSchedule, len(legs))
+    for i, leg := range legs {
+        ids = append(ids, leg.SourceAccountID, leg.DestinationAccountID)
+        schedule, err := s.prepareFee(ctx, tx, leg)
+        if err != nil {
+            return nil, err
+        }
+        if schedule != nil {
+            ids = append(ids, schedule.FeeAccountID)
+        }
+        schedules[i] = schedule
+    }
+    accounts, err := s.lockAccounts(ctx, tx, ids...)
+    if err != nil {
+        return nil, err
+    }
+
+    transfers := make([]model.Transaction, len(legs))
+    legPostings := make([][]model.Posting, len(legs))
+    var all []model.Posting
+    for i, leg := range legs {
+        clearDerivedFields(&leg)
+        postings, err := batchLeg(accounts, &leg)
+        if err == nil {
+            err = authorizeAccount(ctx, PermDebitAccount, accounts[leg.SourceAccountID])
+        }
+        if err == nil {
+            var feePostings []model.Posting
+            feePostings, err = chargeFee(accounts, schedules[i], &leg)
+            postings = append(postings, feePostings...)
+        }
+        if err == nil {
+            err = checkStatus(accounts, postings)
+        }
+        if err != nil {
+            log.Warn("Batch transfer failed - invalid leg",
+                zap.Int("leg", i),
+                zap.Error(err),
+            )
+            return nil, &BatchLegError{Leg: i, Err: err}
+        }
+        transfers[i] = leg
+        legPostings[i] = postings
+        all = append(all, postings...)
+    }
+
+    // Each source's legs count against its limits together
+    amounts := make(map[int][]decimal.Decimal)
+    firstLeg := make(map[int]int)
+    for i, t := range transfers {
+        if _, ok := amounts[t.SourceAccountID]; !ok {
+            firstLeg[t.SourceAccountID] = i
+        }
+        amounts[t.SourceAccountID] = append(amounts[t.SourceAccountID], t.Amount)
+    }
+    sources := make([]int, 0, len(amounts))
+    for id := range amounts {
+        sources = append(sources, id)
+    }
+    sort.Ints(sources)
+    for _, id := range sources {
+        if err := s.checkLimits(ctx, tx, accounts[id], amounts[id]...); err != nil {
+            return nil, &BatchLegError{Leg: firstLeg[id], Err: err}
+        }
+    }
+
+    if shortID, ok := checkFunds(accounts, all); !ok {
+        // Blame the first leg that debits the short account
+        leg := 0
+        for i, t := range transfers {
+            if t.SourceAccountID == shortID {
+                leg = i
+                break
+            }
+        }
+        short := accounts[shortID]
+        log.Warn("Batch transfer failed - insufficient balance",
+            zap.Int("leg", leg),
+            zap.Int("account_id", shortID),
+            zap.Float64("available_balance", formatDecimal(short.AvailableBalance(), short.AssetCode)),
+        )
+        return nil, &BatchLegError{Leg: leg, Err: ErrInsufficientBalance}
+    }
+
+    // Apply all credits before any debit so an intermediate account never
+    // dips below zero part way through the batch
+    var credits, debits []*model.Posting
+    for i := range legPostings {
+        for j := range legPostings[i] {
+            p := &legPostings[i][j]
+            if p.Amount.IsPositive() {
+                credits = append(credits, p)
+            } else {
+                debits = append(debits, p)
+            }
+        }
+    }
+    applied := append(credits, debits...)
+    if err := s.applyPostings(ctx, tx, applied); err != nil {
+        return nil, err
+    }
+
+    // The postings are recorded in leg order, so their running balances must
+    // follow leg order too: start from each account's balance before the
+    // batch and add its postings leg by leg
+    running := make(map[int]decimal.Decimal)
+    for _, p := range applied {
+        running[p.AccountID] = p.BalanceAfter
+    }
+    for _, p := range applied {
+        running[p.AccountID] = running[p.AccountID].Sub(p.Amount)
+    }
+    for i := range legPostings {
+        for j := range legPostings[i] {
+            p := &legPostings[i][j]
+            running[p.AccountID] = running[p.AccountID].Add(p.Amount)
+            p.BalanceAfter = running[p.AccountID]
+        }
+    }
+
+    transactions := make([]*model.Transaction, len(transfers))
+    for i := range transfers {
+        loggedTx, err := s.record(ctx, tx, transfers[i], legPostings[i])
+        if err != nil {
+            return nil, &BatchLegError{Leg: i, Err: err}
+        }
+        transactions[i] = loggedTx
+    }
+    return transactions, nil
+}
+
+// batchLeg validates one leg against the locked accounts and returns its postings.
+// Batches do not convert between assets.
+func batchLeg(accounts map[int]*model.Account, t *model.Transaction) ([]model.Posting, error) {
+    if t.SourceAccountID == t.DestinationAccountID {
+        return nil, ErrSameAccount
+    }
+    from, ok := accounts[t.SourceAccountID]
+    if !ok {
+        return nil, ErrSourceAccountNotFound
+    }
+    to, ok := accounts[t.DestinationAccountID]
+    if !ok {
+        return nil, ErrDestinationAccountNotFound
+    }
+
+    if t.AssetCode != "" && t.AssetCode != from.AssetCode {
+        return nil, ErrAssetMismatch
+    }
+    if from.AssetCode != to.AssetCode {
+        return nil, ErrCrossAssetTransfer
+    }
+    if t.QuoteID != "" {
+        return nil, ErrQuoteMismatch
+    }
+    t.AssetCode = from.AssetCode
+
+    if err := validateAmount(t.Amount, t.AssetCode); err != nil {
+        return nil, err
+    }
+
+    return []model.Posting{
+        {AccountID: from.ID, AssetCode: t.AssetCode, Amount: t.Amount.Neg()},
+        {AccountID: to.ID, AssetCode: t.AssetCode, Amount: t.Amount},
+    }, nil
+}
+
+// batchFailure maps a batch error to a result, naming the failed leg
+func batchFailure(err error) *TransferResult {
+    switch {
+    case errors.Is(err, ErrEmptyBatch), errors.Is(err, ErrBatchTooLarge):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: fmt.Sprintf("A batch needs between 1 and %d legs", MaxBatchLegs),
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+
+    var legErr *BatchLegError
+    if !errors.As(err, &legErr) {
+        return transferFailure(err)
+    }
+    result := transferFailure(legErr.Err)
+    result.Message = fmt.Sprintf("Leg %d: %s", legErr.Leg, result.Message)
+    result.Error = legErr.Error()
+    return result
+}
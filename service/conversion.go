@@ -0,0 +1,132 @@
+package service
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "time"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "go.uber.org/zap"
+    "github.com/shopspring/decimal"
+)
+
+// conversion holds the rate and position accounts of a cross-asset transfer
+type conversion struct {
+    sourceAsset           string
+    destinationAsset      string
+    rate                  decimal.Decimal
+    quoteID               string
+    sourcePositionID      int
+    destinationPositionID int
+}
+
+// prepareConversion resolves the rate and FX position accounts for a transfer
+// that requested a conversion. It returns nil when both accounts hold the same
+// asset. A referenced quote is locked for the rest of the unit of work.
+func (s *TransactionService) prepareConversion(ctx context.Context, tx *sql.Tx, t model.Transaction) (*conversion, error) {
+    log := middleware.GetLogger()
+
+    // Asset codes never change, so they can be read before the accounts are locked
+    from, err := s.accountRepo.GetByID(ctx, t.SourceAccountID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, ErrSourceAccountNotFound
+        }
+        return nil, fmt.Errorf("get source account: %w", err)
+    }
+    to, err := s.accountRepo.GetByID(ctx, t.DestinationAccountID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, ErrDestinationAccountNotFound
+        }
+        return nil, fmt.Errorf("get destination account: %w", err)
+    }
+    if from.AssetCode == to.AssetCode {
+        return nil, nil
+    }
+
+    conv := &conversion{
+        sourceAsset:      from.AssetCode,
+        destinationAsset: to.AssetCode,
+    }
+
+    if t.QuoteID != "" {
+        quote, err := s.fxRepo.GetQuoteWithLock(ctx, tx, t.QuoteID)
+        if err != nil {
+            if err == sql.ErrNoRows {
+                return nil, ErrQuoteNotFound
+            }
+            return nil, fmt.Errorf("get quote: %w", err)
+        }
+        switch {
+        case quote.TransactionID != nil:
+            return nil, ErrQuoteUsed
+        case time.Now().After(quote.ExpiresAt):
+            return nil, ErrQuoteExpired
+        case quote.BaseAsset != from.AssetCode || quote.QuoteAsset != to.AssetCode:
+            return nil, ErrQuoteMismatch
+        }
+        conv.rate = quote.Rate
+        conv.quoteID = quote.ID
+    } else {
+        rate, err := s.fxRepo.GetRateWithTx(ctx, tx, from.AssetCode, to.AssetCode)
+        if err != nil {
+            if err == sql.ErrNoRows {
+                return nil, ErrRateUnavailable
+            }
+            return nil, fmt.Errorf("get fx rate: %w", err)
+        }
+        conv.rate = rate.Rate
+    }
+
+    for _, leg := range []struct {
+        asset string
+        id    *int
+    }{
+        {from.AssetCode, &conv.sourcePositionID},
+        {to.AssetCode, &conv.destinationPositionID},
+    } {
+        position, err := s.fxRepo.GetPositionWithTx(ctx, tx, leg.asset)
+        if err != nil {
+            if err == sql.ErrNoRows {
+                log.Warn("No FX position account configured",
+                    zap.String("asset_code", leg.asset),
+                )
+                return nil, ErrConversionUnavailable
+            }
+            return nil, fmt.Errorf("get fx position: %w", err)
+        }
+        *leg.id = position.AccountID
+    }
+
+    return conv, nil
+}
+
+// postings converts t.Amount at the conversion rate, records the conversion on t
+// and returns the four legs: the source pays the source-asset position, and the
+// destination-asset position pays the destination. The converted amount is
+// rounded down to the destination asset's scale; the remainder is kept in
+// t.FXRounding.
+func (c *conversion) postings(t *model.Transaction) ([]model.Posting, error) {
+    converted := t.Amount.Mul(c.rate)
+    destinationAmount := converted.RoundDown(model.AssetScale(c.destinationAsset))
+    if !destinationAmount.IsPositive() {
+        return nil, ErrConvertedAmountTooSmall
+    }
+    rounding := converted.Sub(destinationAmount)
+    rate := c.rate
+
+    t.QuoteID = c.quoteID
+    t.DestinationAssetCode = c.destinationAsset
+    t.DestinationAmount = &destinationAmount
+    t.FXRate = &rate
+    t.FXRounding = &rounding
+
+    return []model.Posting{
+        {AccountID: t.SourceAccountID, AssetCode: c.sourceAsset, Amount: t.Amount.Neg()},
+        {AccountID: c.sourcePositionID, AssetCode: c.sourceAsset, Amount: t.Amount},
+        {AccountID: c.destinationPositionID, AssetCode: c.destinationAsset, Amount: destinationAmount.Neg()},
+        {AccountID: t.DestinationAccountID, AssetCode: c.destinationAsset, Amount: destinationAmount},
+    }, nil
+}
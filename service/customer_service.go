@@ -0,0 +1,370 @@
+package service
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "net/http"
+    "sort"
+    "strings"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "transfer-service/repository"
+    "go.uber.org/zap"
+)
+
+var (
+    ErrCustomerNotFound    = errors.New("customer not found")
+    ErrCustomerExists      = errors.New("customer external reference already in use")
+    ErrCustomerHasAccounts = errors.New("customer still owns accounts")
+    ErrInvalidCustomer     = errors.New("invalid customer")
+)
+
+type CustomerService struct {
+    repo            repository.CustomerRepository
+    accountRepo     repository.AccountRepository
+    idempotencyRepo repository.IdempotencyRepository
+    uow             repository.UnitOfWork
+}
+
+func NewCustomerService(repo repository.CustomerRepository, accountRepo repository.AccountRepository, idempotencyRepo repository.IdempotencyRepository, uow repository.UnitOfWork) *CustomerService {
+    return &CustomerService{
+        repo:            repo,
+        accountRepo:     accountRepo,
+        idempotencyRepo: idempotencyRepo,
+        uow:             uow,
+    }
+}
+
+// CustomerUpdate is the body of a customer update. Omitted fields are left unchanged.
+type CustomerUpdate struct {
+    Name        *string `json:"name,omitempty"`
+    ExternalRef *string `json:"external_ref,omitempty"`
+    KYCTier     *string `json:"kyc_tier,omitempty"`
+}
+
+// CustomerAccounts is a customer with the accounts it owns and their totals per asset
+type CustomerAccounts struct {
+    Customer *model.Customer         `json:"customer"`
+    Accounts []*model.Account        `json:"accounts"`
+    Balances []model.CustomerBalance `json:"balances"`
+}
+
+func (s *CustomerService) CreateCustomer(ctx context.Context, c model.Customer) *Result {
+    return s.CreateCustomerIdempotent(ctx, c, nil)
+}
+
+// CreateCustomerIdempotent creates a customer once per idempotency key; a nil
+// key disables the check. Customers without a KYC tier start at none.
+func (s *CustomerService) CreateCustomerIdempotent(ctx context.Context, c model.Customer, key *model.IdempotencyKey) *Result {
+    log := middleware.GetLogger()
+
+    if err := authorize(ctx, PermManageCustomers); err != nil {
+        return forbidden(err)
+    }
+
+    if c.KYCTier == "" {
+        c.KYCTier = model.KYCTierNone
+    }
+    if err := validateCustomer(c); err != nil {
+        return customerFailure(err)
+    }
+
+    var result *Result
+    err := retryTx(ctx, func() error {
+        return s.uow.Do(ctx, nil, func(tx *sql.Tx) error {
+            if key != nil {
+                replayed, err := replayIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeCustomer, key, &model.Customer{})
+                if err != nil || replayed != nil {
+                    result = replayed
+                    return err
+                }
+            }
+
+            created, err := s.repo.CreateWithTx(ctx, tx, c)
+            if err != nil {
+                if middleware.IsUniqueViolation(err) {
+                    log.Debug("Customer insert violated a unique constraint", zap.Error(err))
+                    return fmt.Errorf("%w: %q", ErrCustomerExists, c.ExternalRef)
+                }
+                return err
+            }
+            result = &Result{
+                Success: true,
+                Status:  http.StatusCreated,
+                Message: "Customer created successfully",
+                Data:    created,
+            }
+
+            if key != nil {
+                return storeIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeCustomer, key, result)
+            }
+            return nil
+        })
+    })
+    if err != nil {
+        log.Warn("Customer creation failed",
+            zap.String("external_ref", c.ExternalRef),
+            zap.Error(err),
+        )
+        return customerFailure(err)
+    }
+
+    if result.Replayed {
+        log.Info("Replayed idempotent customer creation",
+            zap.String("idempotency_key", key.Key),
+        )
+        return result
+    }
+
+    log.Info("Customer created successfully",
+        zap.Int("customer_id", result.Data.(*model.Customer).ID),
+        zap.String("kyc_tier", c.KYCTier),
+    )
+
+    return result
+}
+
+func (s *CustomerService) GetCustomer(ctx context.Context, id int) *Result {
+    if err := authorizeCustomer(ctx, id); err != nil {
+        return forbidden(err)
+    }
+
+    customer, err := s.repo.GetByID(ctx, id)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return customerFailure(ErrCustomerNotFound)
+        }
+        return customerFailure(err)
+    }
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Customer retrieved successfully",
+        Data:    customer,
+    }
+}
+
+func (s *CustomerService) ListCustomers(ctx context.Context) *Result {
+    if err := authorize(ctx, PermReadAll); err != nil {
+        return forbidden(err)
+    }
+
+    customers, err := s.repo.GetAll(ctx)
+    if err != nil {
+        return customerFailure(err)
+    }
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Customers retrieved successfully",
+        Data:    customers,
+    }
+}
+
+// UpdateCustomer changes a customer's name, external reference or KYC tier.
+// An empty external reference clears it.
+func (s *CustomerService) UpdateCustomer(ctx context.Context, id int, upd CustomerUpdate) *Result {
+    log := middleware.GetLogger()
+
+    if err := authorize(ctx, PermManageCustomers); err != nil {
+        return forbidden(err)
+    }
+
+    customer, err := s.repo.GetByID(ctx, id)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return customerFailure(ErrCustomerNotFound)
+        }
+        return customerFailure(err)
+    }
+    c := *customer
+    if upd.Name != nil {
+        c.Name = *upd.Name
+    }
+    if upd.ExternalRef != nil {
+        c.ExternalRef = *upd.ExternalRef
+    }
+    if upd.KYCTier != nil {
+        c.KYCTier = *upd.KYCTier
+    }
+    if err := validateCustomer(c); err != nil {
+        return customerFailure(err)
+    }
+
+    updated, err := s.repo.Update(ctx, c)
+    if err != nil {
+        switch {
+        case err == sql.ErrNoRows:
+            err = ErrCustomerNotFound
+        case middleware.IsUniqueViolation(err):
+            log.Debug("Customer update violated a unique constraint", zap.Error(err))
+            err = fmt.Errorf("%w: %q", ErrCustomerExists, c.ExternalRef)
+        }
+        return customerFailure(err)
+    }
+
+    log.Info("Customer updated successfully",
+        zap.Int("customer_id", id),
+        zap.String("kyc_tier", updated.KYCTier),
+    )
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Customer updated successfully",
+        Data:    updated,
+    }
+}
+
+// DeleteCustomer removes a customer that owns no accounts. The foreign key on
+// accounts also blocks an account created for the customer concurrently.
+func (s *CustomerService) DeleteCustomer(ctx context.Context, id int) *Result {
+    if err := authorize(ctx, PermManageCustomers); err != nil {
+        return forbidden(err)
+    }
+
+    accounts, err := s.accountRepo.GetByCustomerID(ctx, id)
+    if err != nil {
+        return customerFailure(err)
+    }
+    if len(accounts) > 0 {
+        return customerFailure(fmt.Errorf("%w: %d accounts", ErrCustomerHasAccounts, len(accounts)))
+    }
+
+    if err := s.repo.Delete(ctx, id); err != nil {
+        switch {
+        case err == sql.ErrNoRows:
+            err = ErrCustomerNotFound
+        case middleware.IsForeignKeyViolation(err):
+            middleware.GetLogger().Debug("Customer delete violated a foreign key", zap.Error(err))
+            err = ErrCustomerHasAccounts
+        }
+        return customerFailure(err)
+    }
+
+    middleware.GetLogger().Info("Customer deleted",
+        zap.Int("customer_id", id),
+    )
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Customer deleted successfully",
+    }
+}
+
+// GetCustomerAccounts returns the accounts a customer owns with their ledger
+// and available balances summed per asset
+func (s *CustomerService) GetCustomerAccounts(ctx context.Context, id int) *Result {
+    if err := authorizeCustomer(ctx, id); err != nil {
+        return forbidden(err)
+    }
+
+    customer, err := s.repo.GetByID(ctx, id)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return customerFailure(ErrCustomerNotFound)
+        }
+        return customerFailure(err)
+    }
+    accounts, err := s.accountRepo.GetByCustomerID(ctx, id)
+    if err != nil {
+        return customerFailure(err)
+    }
+
+    totals := make(map[string]*model.CustomerBalance)
+    for _, account := range accounts {
+        total, ok := totals[account.AssetCode]
+        if !ok {
+            total = &model.CustomerBalance{AssetCode: account.AssetCode}
+            totals[account.AssetCode] = total
+        }
+        total.Accounts++
+        total.LedgerBalance = total.LedgerBalance.Add(account.Balance)
+        total.AvailableBalance = total.AvailableBalance.Add(account.AvailableBalance())
+    }
+    balances := make([]model.CustomerBalance, 0, len(totals))
+    for _, total := range totals {
+        balances = append(balances, *total)
+    }
+    sort.Slice(balances, func(i, j int) bool { return balances[i].AssetCode < balances[j].AssetCode })
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Customer accounts retrieved successfully",
+        Data: &CustomerAccounts{
+            Customer: customer,
+            Accounts: accounts,
+            Balances: balances,
+        },
+    }
+}
+
+// validateCustomer checks the fields a customer must always have
+func validateCustomer(c model.Customer) error {
+    if strings.TrimSpace(c.Name) == "" {
+        return fmt.Errorf("%w: name is required", ErrInvalidCustomer)
+    }
+    if !model.IsKYCTier(c.KYCTier) {
+        return fmt.Errorf("%w: unknown KYC tier %q", ErrInvalidCustomer, c.KYCTier)
+    }
+    return nil
+}
+
+// customerFailure maps a customer error to a result
+func customerFailure(err error) *Result {
+    switch {
+    case errors.Is(err, ErrInvalidCustomer):
+        return &Result{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Invalid customer",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrCustomerNotFound):
+        return &Result{
+            Success: false,
+            Status:  http.StatusNotFound,
+            Message: "Customer not found",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrCustomerExists):
+        return &Result{
+            Success: false,
+            Status:  http.StatusConflict,
+            Message: "A customer with this external reference already exists",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrCustomerHasAccounts):
+        return &Result{
+            Success: false,
+            Status:  http.StatusConflict,
+            Message: "Customer still owns accounts",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrIdempotencyKeyReused):
+        return &Result{
+            Success: false,
+            Status:  http.StatusUnprocessableEntity,
+            Message: "Idempotency key was already used with a different request",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+    return &Result{
+        Success: false,
+        Status:  http.StatusInternalServerError,
+        Message: "Failed to process customer",
+        Error:   err.Error(),
+        Code:    errorCode(err),
+    }
+}
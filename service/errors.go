@@ -0,0 +1,224 @@
+package service
+
+import (
+    "errors"
+    "net/http"
+    "transfer-service/middleware"
+)
+
+// ErrorCode is the stable, machine-readable code of a failed request. Codes
+// never change once published; messages may.
+type ErrorCode string
+
+// Error codes of specific failures
+const (
+    CodeInsufficientFunds         ErrorCode = "INSUFFICIENT_FUNDS"
+    CodeAccountNotFound           ErrorCode = "ACCOUNT_NOT_FOUND"
+    CodeAccountExists             ErrorCode = "ACCOUNT_EXISTS"
+    CodeAccountRefMismatch        ErrorCode = "ACCOUNT_REF_MISMATCH"
+    CodeAccountFrozen             ErrorCode = "ACCOUNT_FROZEN"
+    CodeAccountClosed             ErrorCode = "ACCOUNT_CLOSED"
+    CodeInvalidAccountStatus      ErrorCode = "INVALID_ACCOUNT_STATUS"
+    CodeStatusTransition          ErrorCode = "STATUS_TRANSITION_NOT_ALLOWED"
+    CodeAccountHasBalance         ErrorCode = "ACCOUNT_HAS_BALANCE"
+    CodeAccountHasHolds           ErrorCode = "ACCOUNT_HAS_HOLDS"
+    CodeAccountOverdrawn          ErrorCode = "ACCOUNT_OVERDRAWN"
+    CodeInvalidOverdraftLimit     ErrorCode = "INVALID_OVERDRAFT_LIMIT"
+    CodeOverdraftLimitTooLow      ErrorCode = "OVERDRAFT_LIMIT_TOO_LOW"
+    CodePrecisionExceeded         ErrorCode = "PRECISION_EXCEEDED"
+    CodeInvalidAmount             ErrorCode = "INVALID_AMOUNT"
+    CodeUnknownAsset              ErrorCode = "UNKNOWN_ASSET"
+    CodeAssetMismatch             ErrorCode = "ASSET_MISMATCH"
+    CodeSameAccount               ErrorCode = "SAME_ACCOUNT"
+    CodeLimitExceeded             ErrorCode = "LIMIT_EXCEEDED"
+    CodeInvalidLimits             ErrorCode = "INVALID_LIMITS"
+    CodeFeeScheduleNotFound       ErrorCode = "FEE_SCHEDULE_NOT_FOUND"
+    CodeInvalidFeeSchedule        ErrorCode = "INVALID_FEE_SCHEDULE"
+    CodeFeeAccountUnavailable     ErrorCode = "FEE_ACCOUNT_UNAVAILABLE"
+    CodeConversionUnavailable     ErrorCode = "CONVERSION_UNAVAILABLE"
+    CodeQuoteNotFound             ErrorCode = "QUOTE_NOT_FOUND"
+    CodeQuoteExpired              ErrorCode = "QUOTE_EXPIRED"
+    CodeQuoteUsed                 ErrorCode = "QUOTE_USED"
+    CodeQuoteMismatch             ErrorCode = "QUOTE_MISMATCH"
+    CodeConvertedAmountTooSmall   ErrorCode = "CONVERTED_AMOUNT_TOO_SMALL"
+    CodeInsufficientLiquidity     ErrorCode = "INSUFFICIENT_LIQUIDITY"
+    CodeHoldNotFound              ErrorCode = "HOLD_NOT_FOUND"
+    CodeHoldNotPending            ErrorCode = "HOLD_NOT_PENDING"
+    CodeHoldExpired               ErrorCode = "HOLD_EXPIRED"
+    CodeCaptureExceedsHold        ErrorCode = "CAPTURE_EXCEEDS_HOLD"
+    CodeTransactionNotFound       ErrorCode = "TRANSACTION_NOT_FOUND"
+    CodeReversalNotAllowed        ErrorCode = "REVERSAL_NOT_ALLOWED"
+    CodeAlreadyReversed           ErrorCode = "ALREADY_REVERSED"
+    CodeRefundExceedsOriginal     ErrorCode = "REFUND_EXCEEDS_ORIGINAL"
+    CodeEmptyBatch                ErrorCode = "EMPTY_BATCH"
+    CodeScheduledTransferNotFound ErrorCode = "SCHEDULED_TRANSFER_NOT_FOUND"
+    CodeNotScheduled              ErrorCode = "NOT_SCHEDULED"
+    CodeInvalidExecuteAt          ErrorCode = "INVALID_EXECUTE_AT"
+    CodeStandingOrderNotFound     ErrorCode = "STANDING_ORDER_NOT_FOUND"
+    CodeStandingOrderNotActive    ErrorCode = "STANDING_ORDER_NOT_ACTIVE"
+    CodeStandingOrderNotPaused    ErrorCode = "STANDING_ORDER_NOT_PAUSED"
+    CodeStandingOrderFinished     ErrorCode = "STANDING_ORDER_FINISHED"
+    CodeInvalidSchedule           ErrorCode = "INVALID_SCHEDULE"
+    CodeCustomerNotFound          ErrorCode = "CUSTOMER_NOT_FOUND"
+    CodeCustomerExists            ErrorCode = "CUSTOMER_EXISTS"
+    CodeCustomerHasAccounts       ErrorCode = "CUSTOMER_HAS_ACCOUNTS"
+    CodeInvalidCustomer           ErrorCode = "INVALID_CUSTOMER"
+    CodeInvalidCursor             ErrorCode = "INVALID_CURSOR"
+    CodeInvalidPageLimit          ErrorCode = "INVALID_PAGE_LIMIT"
+    CodeInvalidStatementRange     ErrorCode = "INVALID_STATEMENT_RANGE"
+    CodeIdempotencyKeyReused      ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+    CodeRetryableConflict         ErrorCode = "RETRYABLE_CONFLICT"
+    CodeUnauthenticated           ErrorCode = "UNAUTHENTICATED"
+    CodeForbidden                 ErrorCode = "FORBIDDEN"
+    CodeAPIKeyNotFound            ErrorCode = "API_KEY_NOT_FOUND"
+    CodeInvalidAPIKeyRequest      ErrorCode = "INVALID_API_KEY_REQUEST"
+    CodeRateLimited               ErrorCode = "RATE_LIMITED"
+)
+
+// Error codes of failures without a specific code, by HTTP status
+const (
+    CodeInvalidRequest ErrorCode = "INVALID_REQUEST"
+    CodeNotFound       ErrorCode = "NOT_FOUND"
+    CodeConflict       ErrorCode = "CONFLICT"
+    CodeUnprocessable  ErrorCode = "UNPROCESSABLE"
+    CodeInternal       ErrorCode = "INTERNAL_ERROR"
+)
+
+// errorCatalogue maps the service's errors to their codes. errorCode matches
+// them with errors.Is, so wrapped errors keep their code.
+var errorCatalogue = []struct {
+    err  error
+    code ErrorCode
+}{
+    {ErrInsufficientBalance, CodeInsufficientFunds},
+    {ErrAccountNotFound, CodeAccountNotFound},
+    {ErrSourceAccountNotFound, CodeAccountNotFound},
+    {ErrDestinationAccountNotFound, CodeAccountNotFound},
+    {ErrAccountExists, CodeAccountExists},
+    {ErrAccountRefMismatch, CodeAccountRefMismatch},
+    {ErrAccountFrozen, CodeAccountFrozen},
+    {ErrAccountClosed, CodeAccountClosed},
+    {ErrInvalidAccountStatus, CodeInvalidAccountStatus},
+    {ErrStatusTransition, CodeStatusTransition},
+    {ErrAccountHasBalance, CodeAccountHasBalance},
+    {ErrAccountHasHolds, CodeAccountHasHolds},
+    {ErrAccountOverdrawn, CodeAccountOverdrawn},
+    {ErrOverdraftLimitTooLow, CodeOverdraftLimitTooLow},
+    {ErrInvalidAmount, CodeInvalidAmount},
+    {ErrUnknownAsset, CodeUnknownAsset},
+    {ErrAssetMismatch, CodeAssetMismatch},
+    {ErrCrossAssetTransfer, CodeAssetMismatch},
+    {ErrSameAccount, CodeSameAccount},
+    {ErrLimitExceeded, CodeLimitExceeded},
+    {ErrInvalidLimits, CodeInvalidLimits},
+    {ErrFeeScheduleNotFound, CodeFeeScheduleNotFound},
+    {ErrInvalidFeeSchedule, CodeInvalidFeeSchedule},
+    {ErrFeeAccountUnavailable, CodeFeeAccountUnavailable},
+    {ErrConversionUnavailable, CodeConversionUnavailable},
+    {ErrRateUnavailable, CodeConversionUnavailable},
+    {ErrQuoteNotFound, CodeQuoteNotFound},
+    {ErrQuoteExpired, CodeQuoteExpired},
+    {ErrQuoteUsed, CodeQuoteUsed},
+    {ErrQuoteMismatch, CodeQuoteMismatch},
+    {ErrConvertedAmountTooSmall, CodeConvertedAmountTooSmall},
+    {ErrInsufficientLiquidity, CodeInsufficientLiquidity},
+    {ErrHoldNotFound, CodeHoldNotFound},
+    {ErrHoldNotPending, CodeHoldNotPending},
+    {ErrHoldExpired, CodeHoldExpired},
+    {ErrCaptureExceedsHold, CodeCaptureExceedsHold},
+    {ErrTransactionNotFound, CodeTransactionNotFound},
+    {ErrNotReversible, CodeReversalNotAllowed},
+    {ErrAlreadyReversed, CodeAlreadyReversed},
+    {ErrRefundExceedsOriginal, CodeRefundExceedsOriginal},
+    {ErrEmptyBatch, CodeEmptyBatch},
+    {ErrScheduledTransferNotFound, CodeScheduledTransferNotFound},
+    {ErrNotScheduled, CodeNotScheduled},
+    {ErrExecuteAtRequired, CodeInvalidExecuteAt},
+    {ErrStandingOrderNotFound, CodeStandingOrderNotFound},
+    {ErrStandingOrderNotActive, CodeStandingOrderNotActive},
+    {ErrStandingOrderNotPaused, CodeStandingOrderNotPaused},
+    {ErrStandingOrderFinished, CodeStandingOrderFinished},
+    {ErrInvalidSchedule, CodeInvalidSchedule},
+    {ErrInvalidRetryPolicy, CodeInvalidSchedule},
+    {ErrCustomerNotFound, CodeCustomerNotFound},
+    {ErrCustomerExists, CodeCustomerExists},
+    {ErrCustomerHasAccounts, CodeCustomerHasAccounts},
+    {ErrInvalidCustomer, CodeInvalidCustomer},
+    {ErrInvalidCursor, CodeInvalidCursor},
+    {ErrInvalidPageLimit, CodeInvalidPageLimit},
+    {ErrInvalidStatementRange, CodeInvalidStatementRange},
+    {ErrIdempotencyKeyReused, CodeIdempotencyKeyReused},
+    {ErrRetryableConflict, CodeRetryableConflict},
+    {middleware.ErrMissingCredentials, CodeUnauthenticated},
+    {middleware.ErrInvalidAPIKey, CodeUnauthenticated},
+    {middleware.ErrInvalidToken, CodeUnauthenticated},
+    {ErrForbidden, CodeForbidden},
+    {ErrAPIKeyNotFound, CodeAPIKeyNotFound},
+    {ErrInvalidAPIKeyRequest, CodeInvalidAPIKeyRequest},
+    {middleware.ErrRateLimited, CodeRateLimited},
+}
+
+// errorCode returns the code of err, or "" when it has no specific code
+func errorCode(err error) ErrorCode {
+    var precisionErr *PrecisionError
+    var limitErr *LimitError
+    switch {
+    case errors.As(err, &precisionErr):
+        return CodePrecisionExceeded
+    case errors.As(err, &limitErr):
+        return CodeLimitExceeded
+    }
+    for _, entry := range errorCatalogue {
+        if errors.Is(err, entry.err) {
+            return entry.code
+        }
+    }
+    return ""
+}
+
+// ErrorCodeOf returns the code of err, falling back to the code of the HTTP
+// status it is reported with
+func ErrorCodeOf(err error, status int) ErrorCode {
+    if code := errorCode(err); code != "" {
+        return code
+    }
+    return StatusErrorCode(status)
+}
+
+// HasErrorCode reports whether err is one of the service's errors, whose text
+// is written for clients
+func HasErrorCode(err error) bool {
+    return errorCode(err) != ""
+}
+
+// StatusErrorCode is the code of a failure with the given HTTP status that
+// has no specific code
+func StatusErrorCode(status int) ErrorCode {
+    switch {
+    case status == http.StatusUnauthorized:
+        return CodeUnauthenticated
+    case status == http.StatusForbidden:
+        return CodeForbidden
+    case status == http.StatusNotFound:
+        return CodeNotFound
+    case status == http.StatusConflict:
+        return CodeConflict
+    case status == http.StatusUnprocessableEntity:
+        return CodeUnprocessable
+    case status == http.StatusTooManyRequests:
+        return CodeRateLimited
+    case status == http.StatusServiceUnavailable:
+        return CodeRetryableConflict
+    case status >= 500:
+        return CodeInternal
+    }
+    return CodeInvalidRequest
+}
+
+// ErrorCode returns the code of a failed result
+func (r *Result) ErrorCode() ErrorCode {
+    if r.Code != "" {
+        return r.Code
+    }
+    return StatusErrorCode(r.Status)
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "net/http"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "transfer-service/repository"
+    "go.uber.org/zap"
+)
+
+var ErrFeeScheduleNotFound = errors.New("fee schedule not found")
+
+type FeeService struct {
+    repo        repository.FeeRepository
+    accountRepo repository.AccountRepository
+}
+
+func NewFeeService(repo repository.FeeRepository, accountRepo repository.AccountRepository) *FeeService {
+    return &FeeService{repo: repo, accountRepo: accountRepo}
+}
+
+func (s *FeeService) ListSchedules(ctx context.Context) *Result {
+    schedules, err := s.repo.GetSchedules(ctx)
+    if err != nil {
+        return &Result{
+            Success: false,
+            Status:  http.StatusInternalServerError,
+            Message: "Failed to retrieve fee schedules",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Fee schedules retrieved successfully",
+        Data:    schedules,
+    }
+}
+
+// SetSchedule creates or replaces the fee schedule of an asset. The fee
+// account must hold the same asset and be open.
+func (s *FeeService) SetSchedule(ctx context.Context, schedule model.FeeSchedule) *Result {
+    log := middleware.GetLogger()
+
+    if err := authorize(ctx, PermConfigure); err != nil {
+        return forbidden(err)
+    }
+
+    if _, ok := model.LookupAsset(schedule.AssetCode); !ok {
+        return feeFailure(fmt.Errorf("%w: %s", ErrUnknownAsset, schedule.AssetCode))
+    }
+    if err := validateFeeSchedule(schedule); err != nil {
+        return feeFailure(err)
+    }
+    account, err := s.accountRepo.GetByID(ctx, schedule.FeeAccountID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return feeFailure(fmt.Errorf("%w: fee account %d not found", ErrInvalidFeeSchedule, schedule.FeeAccountID))
+        }
+        return feeFailure(err)
+    }
+    if account.AssetCode != schedule.AssetCode {
+        return feeFailure(fmt.Errorf("%w: fee account %d holds %s", ErrInvalidFeeSchedule, account.ID, account.AssetCode))
+    }
+    if account.Status == model.AccountStatusClosed {
+        return feeFailure(fmt.Errorf("%w: fee account %d is closed", ErrInvalidFeeSchedule, account.ID))
+    }
+
+    stored, err := s.repo.UpsertSchedule(ctx, schedule)
+    if err != nil {
+        log.Error("Failed to store fee schedule",
+            zap.String("asset_code", schedule.AssetCode),
+            zap.Error(err),
+        )
+        return feeFailure(err)
+    }
+
+    log.Info("Fee schedule stored",
+        zap.String("asset_code", stored.AssetCode),
+        zap.String("type", stored.Type),
+        zap.Int("fee_account_id", stored.FeeAccountID),
+    )
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Fee schedule stored successfully",
+        Data:    stored,
+    }
+}
+
+// DeleteSchedule makes transfers of an asset free
+func (s *FeeService) DeleteSchedule(ctx context.Context, assetCode string) *Result {
+    if err := authorize(ctx, PermConfigure); err != nil {
+        return forbidden(err)
+    }
+
+    if err := s.repo.DeleteSchedule(ctx, assetCode); err != nil {
+        if err == sql.ErrNoRows {
+            return feeFailure(ErrFeeScheduleNotFound)
+        }
+        return feeFailure(err)
+    }
+
+    middleware.GetLogger().Info("Fee schedule deleted",
+        zap.String("asset_code", assetCode),
+    )
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Fee schedule deleted successfully",
+    }
+}
+
+// Preview returns the fee a transfer of t.Amount from the source account would
+// be charged now and the total the source would pay
+func (s *FeeService) Preview(ctx context.Context, t model.Transaction) *Result {
+    if err := resolveTransferAccounts(ctx, s.accountRepo, &t); err != nil {
+        return transferFailure(err)
+    }
+    // Customers get the same answer for a missing account as for someone else's
+    if err := authorizeAccountID(ctx, s.accountRepo, PermReadAccount, t.SourceAccountID); err != nil {
+        return transferFailure(err)
+    }
+    from, err := s.accountRepo.GetByID(ctx, t.SourceAccountID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return transferFailure(ErrSourceAccountNotFound)
+        }
+        return feeFailure(err)
+    }
+    if t.AssetCode != "" && t.AssetCode != from.AssetCode {
+        return transferFailure(ErrAssetMismatch)
+    }
+    if err := validateAmount(t.Amount, from.AssetCode); err != nil {
+        return transferFailure(err)
+    }
+
+    preview := &model.FeePreview{
+        SourceAccountID: from.ID,
+        SourceAccount:   from.PublicID,
+        AssetCode:       from.AssetCode,
+        Amount:          t.Amount,
+    }
+    schedule, err := s.repo.GetScheduleWithTx(ctx, nil, from.AssetCode)
+    if err != nil && err != sql.ErrNoRows {
+        return feeFailure(err)
+    }
+    if schedule != nil && schedule.FeeAccountID != from.ID {
+        preview.Fee = computeFee(*schedule, t.Amount)
+    }
+    preview.Total = preview.Amount.Add(preview.Fee)
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Fee preview calculated successfully",
+        Data:    preview,
+    }
+}
+
+// feeFailure maps a fee schedule error to a result
+func feeFailure(err error) *Result {
+    switch {
+    case errors.Is(err, ErrInvalidFeeSchedule), errors.Is(err, ErrUnknownAsset):
+        return &Result{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Invalid fee schedule",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrFeeScheduleNotFound):
+        return &Result{
+            Success: false,
+            Status:  http.StatusNotFound,
+            Message: "Fee schedule not found",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+    return &Result{
+        Success: false,
+        Status:  http.StatusInternalServerError,
+        Message: "Failed to process fee schedule",
+        Error:   err.Error(),
+        Code:    errorCode(err),
+    }
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "transfer-service/model"
+    "github.com/shopspring/decimal"
+)
+
+var ErrInvalidFeeSchedule = errors.New("invalid fee schedule")
+var ErrFeeAccountUnavailable = errors.New("fee account not available")
+
+var hundred = decimal.NewFromInt(100)
+
+// computeFee returns the fee schedule charges on a transfer of amount,
+// rounded to the scale of the schedule's asset
+func computeFee(schedule model.FeeSchedule, amount decimal.Decimal) decimal.Decimal {
+    fee := decimal.Zero
+    switch schedule.Type {
+    case model.FeeTypeFlat:
+        fee = valueOrZero(schedule.FlatAmount)
+    case model.FeeTypePercent:
+        fee = amount.Mul(valueOrZero(schedule.Percent)).Div(hundred)
+    case model.FeeTypeTiered:
+        for _, tier := range schedule.Tiers {
+            if tier.UpTo == nil || amount.LessThanOrEqual(*tier.UpTo) {
+                fee = valueOrZero(tier.FlatAmount).Add(amount.Mul(valueOrZero(tier.Percent)).Div(hundred))
+                break
+            }
+        }
+    }
+
+    if schedule.MinFee != nil {
+        fee = decimal.Max(fee, *schedule.MinFee)
+    }
+    if schedule.MaxFee != nil {
+        fee = decimal.Min(fee, *schedule.MaxFee)
+    }
+    return fee.Round(model.AssetScale(schedule.AssetCode))
+}
+
+func valueOrZero(d *decimal.Decimal) decimal.Decimal {
+    if d == nil {
+        return decimal.Zero
+    }
+    return *d
+}
+
+// validateFeeSchedule checks that a schedule has the fields of its type and
+// no negative amounts
+func validateFeeSchedule(s model.FeeSchedule) error {
+    values := []*decimal.Decimal{s.FlatAmount, s.Percent, s.MinFee, s.MaxFee}
+    switch s.Type {
+    case model.FeeTypeFlat:
+        if s.FlatAmount == nil {
+            return fmt.Errorf("%w: flat fees need a flat_amount", ErrInvalidFeeSchedule)
+        }
+    case model.FeeTypePercent:
+        if s.Percent == nil {
+            return fmt.Errorf("%w: percent fees need a percent", ErrInvalidFeeSchedule)
+        }
+    case model.FeeTypeTiered:
+        if len(s.Tiers) == 0 {
+            return fmt.Errorf("%w: tiered fees need at least one tier", ErrInvalidFeeSchedule)
+        }
+        for i, tier := range s.Tiers {
+            if tier.UpTo == nil && i != len(s.Tiers)-1 {
+                return fmt.Errorf("%w: only the last tier may omit up_to", ErrInvalidFeeSchedule)
+            }
+            if i > 0 && tier.UpTo != nil && !tier.UpTo.GreaterThan(*s.Tiers[i-1].UpTo) {
+                return fmt.Errorf("%w: tiers must be in ascending up_to order", ErrInvalidFeeSchedule)
+            }
+            values = append(values, tier.UpTo, tier.FlatAmount, tier.Percent)
+        }
+    default:
+        return fmt.Errorf("%w: type must be flat, percent or tiered", ErrInvalidFeeSchedule)
+    }
+
+    for _, v := range values {
+        if v != nil && v.IsNegative() {
+            return fmt.Errorf("%w: fee amounts cannot be negative", ErrInvalidFeeSchedule)
+        }
+    }
+    if s.MinFee != nil && s.MaxFee != nil && s.MinFee.GreaterThan(*s.MaxFee) {
+        return fmt.Errorf("%w: min_fee is greater than max_fee", ErrInvalidFeeSchedule)
+    }
+    return nil
+}
+
+// prepareFee returns the fee schedule of the transfer's source asset, or nil
+// if its transfers are free. A missing source account is left to the caller.
+func (s *TransactionService) prepareFee(ctx context.Context, tx *sql.Tx, t model.Transaction) (*model.FeeSchedule, error) {
+    // Asset codes never change, so they can be read before the accounts are locked
+    from, err := s.accountRepo.GetByID(ctx, t.SourceAccountID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, nil
+        }
+        return nil, fmt.Errorf("get source account: %w", err)
+    }
+    schedule, err := s.feeRepo.GetScheduleWithTx(ctx, tx, from.AssetCode)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, nil
+        }
+        return nil, fmt.Errorf("get fee schedule: %w", err)
+    }
+    return schedule, nil
+}
+
+// chargeFee sets the fee of t and returns the legs that move it from the
+// source to the fee account. Transfers out of the fee account are free.
+func chargeFee(accounts map[int]*model.Account, schedule *model.FeeSchedule, t *model.Transaction) ([]model.Posting, error) {
+    if schedule == nil || schedule.FeeAccountID == t.SourceAccountID {
+        return nil, nil
+    }
+    fee := computeFee(*schedule, t.Amount)
+    if !fee.IsPositive() {
+        return nil, nil
+    }
+
+    // The schedule was valid when set, but its account may since have closed
+    feeAccount, ok := accounts[schedule.FeeAccountID]
+    if !ok || feeAccount.AssetCode != t.AssetCode || feeAccount.Status == model.AccountStatusClosed {
+        return nil, fmt.Errorf("%w: account %d", ErrFeeAccountUnavailable, schedule.FeeAccountID)
+    }
+
+    feeAccountID := feeAccount.ID
+    t.Fee = &fee
+    t.FeeAccountID = &feeAccountID
+    return []model.Posting{
+        {AccountID: t.SourceAccountID, AssetCode: t.AssetCode, Amount: fee.Neg()},
+        {AccountID: feeAccount.ID, AssetCode: t.AssetCode, Amount: fee},
+    }, nil
+}
+
+// withoutFeeLegs drops the two legs that charged a transfer's fee; fees are
+// not refunded by reversals
+func withoutFeeLegs(t *model.Transaction, postings []*model.Posting) []*model.Posting {
+    if t.Fee == nil || t.FeeAccountID == nil {
+        return postings
+    }
+    debitFound, creditFound := false, false
+    kept := make([]*model.Posting, 0, len(postings))
+    for _, p := range postings {
+        switch {
+        case !debitFound && p.AccountID == t.SourceAccountID && p.Amount.Equal(t.Fee.Neg()):
+            debitFound = true
+        case !creditFound && p.AccountID == *t.FeeAccountID && p.Amount.Equal(*t.Fee):
+            creditFound = true
+        default:
+            kept = append(kept, p)
+        }
+    }
+    return kept
+}
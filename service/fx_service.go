@@ -0,0 +1,321 @@
+package service
+
+import (
+    "context"
+    "crypto/rand"
+    "database/sql"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "os"
+    "time"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "transfer-service/repository"
+    "go.uber.org/zap"
+)
+
+var ErrConversionUnavailable = errors.New("asset conversion not available")
+var ErrRateUnavailable = errors.New("fx rate not available")
+var ErrQuoteNotFound = errors.New("quote not found")
+var ErrQuoteExpired = errors.New("quote expired")
+var ErrQuoteUsed = errors.New("quote already used")
+var ErrQuoteMismatch = errors.New("quote does not match transfer assets")
+var ErrConvertedAmountTooSmall = errors.New("converted amount rounds to zero")
+var ErrInsufficientLiquidity = errors.New("insufficient fx position balance")
+
+// DefaultQuoteTTL is how long a quote locks its rate unless configured otherwise
+const DefaultQuoteTTL = 30 * time.Second
+
+type FXService struct {
+    repo     repository.FXRepository
+    quoteTTL time.Duration
+}
+
+func NewFXService(repo repository.FXRepository, quoteTTL time.Duration) *FXService {
+    return &FXService{repo: repo, quoteTTL: quoteTTL}
+}
+
+// RatesFile is the format of the local file FX rates and positions are loaded from
+type RatesFile struct {
+    Rates     []model.FXRate     `json:"rates"`
+    Positions []model.FXPosition `json:"positions"`
+}
+
+// LoadRatesFile upserts the rates and position accounts listed in a local JSON file
+func (s *FXService) LoadRatesFile(ctx context.Context, path string) error {
+    log := middleware.GetLogger()
+
+    raw, err := os.ReadFile(path)
+    if err != nil {
+        return fmt.Errorf("read fx rates file: %w", err)
+    }
+    var file RatesFile
+    if err := json.Unmarshal(raw, &file); err != nil {
+        return fmt.Errorf("parse fx rates file: %w", err)
+    }
+
+    for _, rate := range file.Rates {
+        if err := validateRate(rate); err != nil {
+            return fmt.Errorf("rate %s/%s: %w", rate.BaseAsset, rate.QuoteAsset, err)
+        }
+        if _, err := s.repo.UpsertRate(ctx, rate); err != nil {
+            return fmt.Errorf("store rate %s/%s: %w", rate.BaseAsset, rate.QuoteAsset, err)
+        }
+    }
+    for _, position := range file.Positions {
+        if err := s.repo.UpsertPosition(ctx, position); err != nil {
+            return fmt.Errorf("store position %s: %w", position.AssetCode, err)
+        }
+    }
+
+    log.Info("FX rates loaded from file",
+        zap.String("path", path),
+        zap.Int("rates", len(file.Rates)),
+        zap.Int("positions", len(file.Positions)),
+    )
+    return nil
+}
+
+func (s *FXService) ListRates(ctx context.Context) *Result {
+    rates, err := s.repo.GetRates(ctx)
+    if err != nil {
+        return &Result{
+            Success: false,
+            Status:  http.StatusInternalServerError,
+            Message: "Failed to retrieve FX rates",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "FX rates retrieved successfully",
+        Data:    rates,
+    }
+}
+
+func (s *FXService) SetRate(ctx context.Context, rate model.FXRate) *Result {
+    log := middleware.GetLogger()
+
+    if err := authorize(ctx, PermConfigure); err != nil {
+        return forbidden(err)
+    }
+
+    if err := validateRate(rate); err != nil {
+        return &Result{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Invalid FX rate",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+
+    stored, err := s.repo.UpsertRate(ctx, rate)
+    if err != nil {
+        log.Error("Failed to store FX rate",
+            zap.String("base_asset", rate.BaseAsset),
+            zap.String("quote_asset", rate.QuoteAsset),
+            zap.Error(err),
+        )
+        return &Result{
+            Success: false,
+            Status:  http.StatusInternalServerError,
+            Message: "Failed to store FX rate",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+
+    log.Info("FX rate updated",
+        zap.String("base_asset", rate.BaseAsset),
+        zap.String("quote_asset", rate.QuoteAsset),
+        zap.String("rate", rate.Rate.String()),
+    )
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "FX rate updated successfully",
+        Data:    stored,
+    }
+}
+
+func (s *FXService) ListPositions(ctx context.Context) *Result {
+    if err := authorize(ctx, PermReadAll); err != nil {
+        return forbidden(err)
+    }
+
+    positions, err := s.repo.GetPositions(ctx)
+    if err != nil {
+        return &Result{
+            Success: false,
+            Status:  http.StatusInternalServerError,
+            Message: "Failed to retrieve FX positions",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "FX positions retrieved successfully",
+        Data:    positions,
+    }
+}
+
+func (s *FXService) SetPosition(ctx context.Context, position model.FXPosition) *Result {
+    log := middleware.GetLogger()
+
+    if err := authorize(ctx, PermConfigure); err != nil {
+        return forbidden(err)
+    }
+
+    if _, ok := model.LookupAsset(position.AssetCode); !ok {
+        return &Result{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Unknown asset code",
+            Error:   ErrUnknownAsset.Error(),
+            Code:    errorCode(ErrUnknownAsset),
+        }
+    }
+
+    if err := s.repo.UpsertPosition(ctx, position); err != nil {
+        if middleware.IsForeignKeyViolation(err) {
+            return &Result{
+                Success: false,
+                Status:  http.StatusNotFound,
+                Message: "Position account not found",
+                Error:   ErrAccountNotFound.Error(),
+                Code:    CodeAccountNotFound,
+            }
+        }
+        log.Error("Failed to store FX position",
+            zap.String("asset_code", position.AssetCode),
+            zap.Error(err),
+        )
+        return &Result{
+            Success: false,
+            Status:  http.StatusInternalServerError,
+            Message: "Failed to store FX position",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+
+    log.Info("FX position account updated",
+        zap.String("asset_code", position.AssetCode),
+        zap.Int("account_id", position.AccountID),
+    )
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "FX position updated successfully",
+        Data:    position,
+    }
+}
+
+// CreateQuote locks the current rate between two assets for the quote TTL
+func (s *FXService) CreateQuote(ctx context.Context, req model.FXQuote) *Result {
+    log := middleware.GetLogger()
+
+    rate, err := s.repo.GetRateWithTx(ctx, nil, req.BaseAsset, req.QuoteAsset)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return &Result{
+                Success: false,
+                Status:  http.StatusUnprocessableEntity,
+                Message: "No FX rate is available for these assets",
+                Error:   ErrRateUnavailable.Error(),
+                Code:    errorCode(ErrRateUnavailable),
+            }
+        }
+        return &Result{
+            Success: false,
+            Status:  http.StatusInternalServerError,
+            Message: "Failed to get FX rate",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+
+    id, err := newQuoteID()
+    if err != nil {
+        return &Result{
+            Success: false,
+            Status:  http.StatusInternalServerError,
+            Message: "Failed to create quote",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+
+    quote, err := s.repo.CreateQuote(ctx, model.FXQuote{
+        ID:         id,
+        BaseAsset:  rate.BaseAsset,
+        QuoteAsset: rate.QuoteAsset,
+        Rate:       rate.Rate,
+        ExpiresAt:  time.Now().UTC().Add(s.quoteTTL),
+    })
+    if err != nil {
+        log.Error("Failed to create quote",
+            zap.String("base_asset", req.BaseAsset),
+            zap.String("quote_asset", req.QuoteAsset),
+            zap.Error(err),
+        )
+        return &Result{
+            Success: false,
+            Status:  http.StatusInternalServerError,
+            Message: "Failed to create quote",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+
+    log.Info("Quote created",
+        zap.String("quote_id", quote.ID),
+        zap.String("rate", quote.Rate.String()),
+        zap.Time("expires_at", quote.ExpiresAt),
+    )
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusCreated,
+        Message: "Quote created successfully",
+        Data:    quote,
+    }
+}
+
+// validateRate checks that a rate converts between two different registered assets
+func validateRate(rate model.FXRate) error {
+    if _, ok := model.LookupAsset(rate.BaseAsset); !ok {
+        return fmt.Errorf("%w: %s", ErrUnknownAsset, rate.BaseAsset)
+    }
+    if _, ok := model.LookupAsset(rate.QuoteAsset); !ok {
+        return fmt.Errorf("%w: %s", ErrUnknownAsset, rate.QuoteAsset)
+    }
+    if rate.BaseAsset == rate.QuoteAsset {
+        return errors.New("base and quote asset are the same")
+    }
+    if !rate.Rate.IsPositive() {
+        return errors.New("rate must be positive")
+    }
+    return nil
+}
+
+// newQuoteID returns a random, unguessable quote identifier
+func newQuoteID() (string, error) {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return "q_" + hex.EncodeToString(b), nil
+}
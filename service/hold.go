@@ -0,0 +1,451 @@
+package service
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "net/http"
+    "time"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "go.uber.org/zap"
+    "github.com/shopspring/decimal"
+)
+
+// DefaultHoldTTL is how long a hold reserves funds when HOLD_TTL is not set
+const DefaultHoldTTL = 7 * 24 * time.Hour
+
+var ErrHoldNotFound = errors.New("hold not found")
+var ErrHoldNotPending = errors.New("hold is already captured or voided")
+var ErrHoldExpired = errors.New("hold has expired")
+var ErrCaptureExceedsHold = errors.New("capture exceeds the held amount")
+
+// CaptureRequest is the body of a capture. A nil Amount captures the full hold;
+// a smaller amount captures part of it and releases the rest.
+type CaptureRequest struct {
+    Amount *decimal.Decimal `json:"amount,omitempty"`
+}
+
+// HoldReceipt is the data returned for a captured hold
+type HoldReceipt struct {
+    Message     string             `json:"message"`
+    Hold        *model.Hold        `json:"hold"`
+    Transaction *model.Transaction `json:"transaction"`
+}
+
+// SetHoldTTL sets how long new holds reserve funds
+func (s *TransactionService) SetHoldTTL(ttl time.Duration) {
+    s.holdTTL = ttl
+}
+
+func (s *TransactionService) Authorize(ctx context.Context, h model.Hold) *TransferResult {
+    return s.AuthorizeIdempotent(ctx, h, nil)
+}
+
+// AuthorizeIdempotent places a hold on the source account's available balance
+// without moving money, once per idempotency key
+func (s *TransactionService) AuthorizeIdempotent(ctx context.Context, h model.Hold, key *model.IdempotencyKey) *TransferResult {
+    log := middleware.GetLogger()
+
+    log.Info("Starting authorization",
+        zap.Int("source_account_id", h.SourceAccountID),
+        zap.Int("destination_account_id", h.DestinationAccountID),
+        zap.String("amount", h.Amount.String()),
+    )
+
+    if err := resolveAccounts(ctx, s.accountRepo, &h.SourceAccount, &h.DestinationAccount, &h.SourceAccountID, &h.DestinationAccountID); err != nil {
+        return transferFailure(err)
+    }
+    if h.SourceAccountID == h.DestinationAccountID {
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Source and destination accounts are the same",
+            Error:   "same accounts",
+            Code:    CodeSameAccount,
+        }
+    }
+    // Authorize before a stored response can be replayed
+    if err := authorizeAccountID(ctx, s.accountRepo, PermDebitAccount, h.SourceAccountID); err != nil {
+        return transferFailure(err)
+    }
+
+    var result *TransferResult
+    var hold *model.Hold
+    err := retryTx(ctx, func() error {
+        return s.uow.Do(ctx, &sql.TxOptions{
+            Isolation: sql.LevelSerializable,
+        }, func(tx *sql.Tx) error {
+            if key != nil {
+                replayed, err := replayIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeHold, key, &model.Hold{})
+                if err != nil || replayed != nil {
+                    result = replayed
+                    return err
+                }
+            }
+
+            var err error
+            hold, err = s.authorize(ctx, tx, h)
+            if err != nil {
+                return err
+            }
+            result = &TransferResult{
+                Success: true,
+                Status:  http.StatusCreated,
+                Message: "Hold placed successfully",
+                Data:    hold,
+            }
+
+            if key != nil {
+                return storeIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeHold, key, result)
+            }
+            return nil
+        })
+    })
+    if err != nil {
+        return transferFailure(err)
+    }
+
+    if result.Replayed {
+        log.Info("Replayed idempotent authorization",
+            zap.String("idempotency_key", key.Key),
+        )
+        return result
+    }
+
+    log.Info("Hold placed successfully",
+        zap.Int("hold_id", hold.ID),
+        zap.Int("source_account_id", hold.SourceAccountID),
+        zap.Float64("amount", formatDecimal(hold.Amount, hold.AssetCode)),
+        zap.Time("expires_at", hold.ExpiresAt),
+    )
+
+    return result
+}
+
+// authorize checks the available balance with the accounts locked and records the hold
+func (s *TransactionService) authorize(ctx context.Context, tx *sql.Tx, h model.Hold) (*model.Hold, error) {
+    log := middleware.GetLogger()
+
+    accounts, err := s.lockAccounts(ctx, tx, h.SourceAccountID, h.DestinationAccountID)
+    if err != nil {
+        return nil, err
+    }
+    from, ok := accounts[h.SourceAccountID]
+    if !ok {
+        return nil, ErrSourceAccountNotFound
+    }
+    if err := authorizeAccount(ctx, PermDebitAccount, from); err != nil {
+        return nil, err
+    }
+    to, ok := accounts[h.DestinationAccountID]
+    if !ok {
+        return nil, ErrDestinationAccountNotFound
+    }
+
+    if h.AssetCode != "" && h.AssetCode != from.AssetCode {
+        return nil, ErrAssetMismatch
+    }
+    if from.AssetCode != to.AssetCode {
+        return nil, ErrCrossAssetTransfer
+    }
+    h.AssetCode = from.AssetCode
+
+    if err := validateAmount(h.Amount, h.AssetCode); err != nil {
+        return nil, err
+    }
+
+    // The hold is checked as if it were the transfer it will become
+    if err := checkStatus(accounts, []model.Posting{
+        {AccountID: from.ID, AssetCode: h.AssetCode, Amount: h.Amount.Neg()},
+        {AccountID: to.ID, AssetCode: h.AssetCode, Amount: h.Amount},
+    }); err != nil {
+        return nil, err
+    }
+    if err := s.checkLimits(ctx, tx, from, h.Amount); err != nil {
+        return nil, err
+    }
+
+    // The hold must fit in the available balance as if it were a debit
+    debit := []model.Posting{{AccountID: from.ID, AssetCode: h.AssetCode, Amount: h.Amount.Neg()}}
+    if _, ok := checkFunds(accounts, debit); !ok {
+        log.Warn("Authorization failed - insufficient available balance",
+            zap.Int("account_id", from.ID),
+            zap.Float64("available_balance", formatDecimal(from.AvailableBalance(), from.AssetCode)),
+            zap.Float64("requested_amount", formatDecimal(h.Amount, h.AssetCode)),
+        )
+        return nil, ErrInsufficientBalance
+    }
+
+    h.ExpiresAt = time.Now().Add(s.holdTTL)
+    hold, err := s.holdRepo.CreateWithTx(ctx, tx, h)
+    if err != nil {
+        return nil, fmt.Errorf("create hold: %w", err)
+    }
+    return hold, nil
+}
+
+func (s *TransactionService) Capture(ctx context.Context, holdID int, req CaptureRequest) *TransferResult {
+    return s.CaptureIdempotent(ctx, holdID, req, nil)
+}
+
+// CaptureIdempotent settles a hold with a transfer of the captured amount,
+// once per idempotency key
+func (s *TransactionService) CaptureIdempotent(ctx context.Context, holdID int, req CaptureRequest, key *model.IdempotencyKey) *TransferResult {
+    log := middleware.GetLogger()
+
+    log.Info("Starting capture",
+        zap.Int("hold_id", holdID),
+        zap.Stringer("amount", req.Amount),
+    )
+
+    // Authorize before a stored response can be replayed; the capture checks
+    // again on the locked hold
+    current, err := s.holdRepo.GetByID(ctx, holdID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return transferFailure(ErrHoldNotFound)
+        }
+        return transferFailure(fmt.Errorf("get hold %d: %w", holdID, err))
+    }
+    if err := authorizeRecord(ctx, s.accountRepo, PermDebitAccount, current.SourceAccountID, ErrHoldNotFound); err != nil {
+        return transferFailure(err)
+    }
+
+    var result *TransferResult
+    var receipt *HoldReceipt
+    err = retryTx(ctx, func() error {
+        return s.uow.Do(ctx, &sql.TxOptions{
+            Isolation: sql.LevelSerializable,
+        }, func(tx *sql.Tx) error {
+            if key != nil {
+                replayed, err := replayIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeCapture, key, &HoldReceipt{})
+                if err != nil || replayed != nil {
+                    result = replayed
+                    return err
+                }
+            }
+
+            var err error
+            receipt, err = s.capture(ctx, tx, holdID, req.Amount)
+            if err != nil {
+                return err
+            }
+            result = &TransferResult{
+                Success: true,
+                Status:  http.StatusOK,
+                Message: receipt.Message,
+                Data:    receipt,
+            }
+
+            if key != nil {
+                return storeIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeCapture, key, result)
+            }
+            return nil
+        })
+    })
+    if err != nil {
+        return transferFailure(err)
+    }
+
+    if result.Replayed {
+        log.Info("Replayed idempotent capture",
+            zap.String("idempotency_key", key.Key),
+        )
+        return result
+    }
+
+    log.Info("Hold captured successfully",
+        zap.Int("hold_id", holdID),
+        zap.Int("transaction_id", receipt.Transaction.ID),
+        zap.Float64("amount", formatDecimal(receipt.Transaction.Amount, receipt.Transaction.AssetCode)),
+    )
+
+    return result
+}
+
+// capture posts the transfer for a pending hold inside tx. The hold's own
+// reservation is released before the funds check so it covers the capture;
+// any fee is checked and charged on top, as for a transfer.
+func (s *TransactionService) capture(ctx context.Context, tx *sql.Tx, holdID int, amount *decimal.Decimal) (*HoldReceipt, error) {
+    log := middleware.GetLogger()
+
+    hold, err := s.lockPendingHold(ctx, tx, holdID)
+    if err != nil {
+        return nil, err
+    }
+
+    captured := hold.Amount
+    if amount != nil {
+        if err := validateAmount(*amount, hold.AssetCode); err != nil {
+            return nil, err
+        }
+        if amount.GreaterThan(hold.Amount) {
+            return nil, ErrCaptureExceedsHold
+        }
+        captured = *amount
+    }
+
+    t := model.Transaction{
+        SourceAccountID:      hold.SourceAccountID,
+        DestinationAccountID: hold.DestinationAccountID,
+        AssetCode:            hold.AssetCode,
+        Amount:               captured,
+    }
+    // The capture is the transfer, so it pays the fee of a transfer
+    schedule, err := s.prepareFee(ctx, tx, t)
+    if err != nil {
+        return nil, err
+    }
+    ids := []int{hold.SourceAccountID, hold.DestinationAccountID}
+    if schedule != nil {
+        ids = append(ids, schedule.FeeAccountID)
+    }
+
+    accounts, err := s.lockAccounts(ctx, tx, ids...)
+    if err != nil {
+        return nil, err
+    }
+    from, ok := accounts[hold.SourceAccountID]
+    if !ok {
+        return nil, ErrSourceAccountNotFound
+    }
+    to, ok := accounts[hold.DestinationAccountID]
+    if !ok {
+        return nil, ErrDestinationAccountNotFound
+    }
+    released := *from
+    released.HeldBalance = released.HeldBalance.Sub(hold.Amount)
+    accounts[from.ID] = &released
+    from = &released
+
+    postings := []model.Posting{
+        {AccountID: from.ID, AssetCode: hold.AssetCode, Amount: captured.Neg()},
+        {AccountID: to.ID, AssetCode: hold.AssetCode, Amount: captured},
+    }
+    feePostings, err := chargeFee(accounts, schedule, &t)
+    if err != nil {
+        return nil, err
+    }
+    postings = append(postings, feePostings...)
+    if err := checkStatus(accounts, postings); err != nil {
+        return nil, err
+    }
+    // Transfers made since the hold was placed count against the capture
+    if err := s.checkLimits(ctx, tx, from, captured); err != nil {
+        return nil, err
+    }
+    if _, ok := checkFunds(accounts, postings); !ok {
+        log.Warn("Capture failed - insufficient balance",
+            zap.Int("hold_id", hold.ID),
+            zap.Int("account_id", from.ID),
+            zap.Float64("available_balance", formatDecimal(from.AvailableBalance(), from.AssetCode)),
+        )
+        return nil, ErrInsufficientBalance
+    }
+
+    loggedTx, err := s.post(ctx, tx, t, postings)
+    if err != nil {
+        return nil, err
+    }
+
+    if err := s.holdRepo.CaptureWithTx(ctx, tx, hold.ID, captured, loggedTx.ID); err != nil {
+        return nil, fmt.Errorf("capture hold %d: %w", hold.ID, err)
+    }
+    hold.Status = model.HoldStatusCaptured
+    hold.CapturedAmount = &captured
+    hold.TransactionID = &loggedTx.ID
+
+    return &HoldReceipt{
+        Message:     "Hold captured successfully",
+        Hold:        hold,
+        Transaction: loggedTx,
+    }, nil
+}
+
+// Void releases a pending hold without moving money
+func (s *TransactionService) Void(ctx context.Context, holdID int) *TransferResult {
+    log := middleware.GetLogger()
+
+    var hold *model.Hold
+    err := retryTx(ctx, func() error {
+        return s.uow.Do(ctx, nil, func(tx *sql.Tx) error {
+            var err error
+            hold, err = s.lockPendingHold(ctx, tx, holdID)
+            if err != nil {
+                return err
+            }
+            if err := s.holdRepo.VoidWithTx(ctx, tx, hold.ID); err != nil {
+                return fmt.Errorf("void hold %d: %w", hold.ID, err)
+            }
+            hold.Status = model.HoldStatusVoided
+            return nil
+        })
+    })
+    if err != nil {
+        return transferFailure(err)
+    }
+
+    log.Info("Hold voided successfully",
+        zap.Int("hold_id", hold.ID),
+        zap.Int("source_account_id", hold.SourceAccountID),
+    )
+
+    return &TransferResult{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Hold voided successfully",
+        Data:    hold,
+    }
+}
+
+func (s *TransactionService) GetHold(ctx context.Context, holdID int) *TransferResult {
+    hold, err := s.holdRepo.GetByID(ctx, holdID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return transferFailure(ErrHoldNotFound)
+        }
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusInternalServerError,
+            Message: "Failed to retrieve hold",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+    if err := authorizeRecord(ctx, s.accountRepo, PermReadAccount, hold.SourceAccountID, ErrHoldNotFound); err != nil {
+        return transferFailure(err)
+    }
+
+    return &TransferResult{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Hold retrieved successfully",
+        Data:    hold,
+    }
+}
+
+// lockPendingHold locks a hold and checks that it can still be captured or voided
+func (s *TransactionService) lockPendingHold(ctx context.Context, tx *sql.Tx, holdID int) (*model.Hold, error) {
+    hold, err := s.holdRepo.GetByIDWithLock(ctx, tx, holdID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, ErrHoldNotFound
+        }
+        return nil, fmt.Errorf("lock hold %d: %w", holdID, err)
+    }
+    // Capturing and voiding act on the source account's funds
+    if err := authorizeRecord(ctx, s.accountRepo, PermDebitAccount, hold.SourceAccountID, ErrHoldNotFound); err != nil {
+        return nil, err
+    }
+
+    switch hold.Status {
+    case model.HoldStatusPending:
+        return hold, nil
+    case model.HoldStatusExpired:
+        return nil, ErrHoldExpired
+    default:
+        return nil, ErrHoldNotPending
+    }
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "transfer-service/repository"
+)
+
+// Idempotency keys are unique per caller and scope, so the same key can be
+// used for a transfer and an account without clashing, and one caller's key
+// never replays another caller's response
+const (
+    idempotencyScopeTransfer = "transfer"
+    idempotencyScopeAccount  = "account"
+    idempotencyScopeReversal = "reversal"
+    idempotencyScopeHold     = "hold"
+    idempotencyScopeCapture  = "capture"
+    idempotencyScopeBatch    = "batch"
+
+    idempotencyScopeAccountStatus     = "account_status"
+    idempotencyScopeScheduledTransfer = "scheduled_transfer"
+    idempotencyScopeStandingOrder     = "standing_order"
+    idempotencyScopeCustomer          = "customer"
+)
+
+var ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
+
+// errIdempotencyRace means a concurrent request with the same key committed
+// first; rerunning the unit of work replays its response
+var errIdempotencyRace = errors.New("idempotency key claimed by a concurrent request")
+
+// storedResponse is the persisted body of a successful idempotent request
+type storedResponse struct {
+    Message string          `json:"message"`
+    Data    json.RawMessage `json:"data"`
+}
+
+// replayIdempotent looks up key inside tx. It returns the stored result with its
+// data decoded into data, nil if the key is unused, or ErrIdempotencyKeyReused
+// if the key was used for a different request.
+func replayIdempotent(ctx context.Context, tx *sql.Tx, repo repository.IdempotencyRepository, scope string, key *model.IdempotencyKey, data interface{}) (*Result, error) {
+    record, err := repo.GetWithTx(ctx, tx, idempotencyPrincipal(ctx), scope, key.Key)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, nil
+        }
+        return nil, fmt.Errorf("get idempotency key: %w", err)
+    }
+
+    if record.Fingerprint != key.Fingerprint {
+        return nil, ErrIdempotencyKeyReused
+    }
+
+    var stored storedResponse
+    if err := json.Unmarshal(record.ResponseBody, &stored); err != nil {
+        return nil, fmt.Errorf("decode stored response: %w", err)
+    }
+    if err := json.Unmarshal(stored.Data, data); err != nil {
+        return nil, fmt.Errorf("decode stored response data: %w", err)
+    }
+
+    return &Result{
+        Success:  true,
+        Status:   record.ResponseStatus,
+        Message:  stored.Message,
+        Data:     data,
+        Replayed: true,
+    }, nil
+}
+
+// storeIdempotent saves a successful result under key inside tx. Amounts are
+// stored as exact decimal strings so a replay loses no precision in either
+// API version.
+func storeIdempotent(ctx context.Context, tx *sql.Tx, repo repository.IdempotencyRepository, scope string, key *model.IdempotencyKey, result *Result) error {
+    data, err := json.Marshal(model.ExactJSON(result.Data))
+    if err != nil {
+        return fmt.Errorf("encode response data: %w", err)
+    }
+    body, err := json.Marshal(storedResponse{Message: result.Message, Data: data})
+    if err != nil {
+        return fmt.Errorf("encode response: %w", err)
+    }
+
+    err = repo.CreateWithTx(ctx, tx, model.IdempotencyRecord{
+        Principal:      idempotencyPrincipal(ctx),
+        Scope:          scope,
+        Key:            key.Key,
+        Fingerprint:    key.Fingerprint,
+        ResponseStatus: result.Status,
+        ResponseBody:   body,
+    })
+    if err != nil {
+        if middleware.IsUniqueViolation(err) {
+            return errIdempotencyRace
+        }
+        return fmt.Errorf("store idempotency key: %w", err)
+    }
+    return nil
+}
+
+// idempotencyPrincipal names the caller on ctx that idempotency keys belong to
+func idempotencyPrincipal(ctx context.Context) string {
+    principal := middleware.PrincipalFromContext(ctx)
+    if principal == nil {
+        return ""
+    }
+    return principal.Method + ":" + principal.Subject
+}
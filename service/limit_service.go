@@ -0,0 +1,245 @@
+package service
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "net/http"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "transfer-service/repository"
+    "go.uber.org/zap"
+    "github.com/shopspring/decimal"
+)
+
+var ErrInvalidLimits = errors.New("invalid limits")
+
+type LimitService struct {
+    repo        repository.LimitRepository
+    accountRepo repository.AccountRepository
+}
+
+func NewLimitService(repo repository.LimitRepository, accountRepo repository.AccountRepository) *LimitService {
+    return &LimitService{repo: repo, accountRepo: accountRepo}
+}
+
+// LimitStatus is an account's effective limits, what it has used of them in
+// the current windows and what remains. Nil limits and remainders are unlimited.
+type LimitStatus struct {
+    AccountID  int               `json:"account_id"`
+    Configured *model.AccountLimits `json:"configured,omitempty"`
+    Limits     model.Limits      `json:"limits"`
+    Usage      model.LimitUsage  `json:"usage"`
+    Remaining  model.Limits      `json:"remaining"`
+}
+
+func (s *LimitService) ListTiers(ctx context.Context) *Result {
+    if err := authorize(ctx, PermReadAll); err != nil {
+        return forbidden(err)
+    }
+
+    tiers, err := s.repo.GetTiers(ctx)
+    if err != nil {
+        return &Result{
+            Success: false,
+            Status:  http.StatusInternalServerError,
+            Message: "Failed to retrieve limit tiers",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Limit tiers retrieved successfully",
+        Data:    tiers,
+    }
+}
+
+// SetTier creates or replaces a limit tier. Accounts assigned to it pick up
+// the new limits on their next transfer.
+func (s *LimitService) SetTier(ctx context.Context, tier model.LimitTier) *Result {
+    log := middleware.GetLogger()
+
+    if err := authorize(ctx, PermConfigure); err != nil {
+        return forbidden(err)
+    }
+
+    if tier.Name == "" {
+        return limitFailure(fmt.Errorf("%w: tier name is required", ErrInvalidLimits))
+    }
+    if err := validateLimits(tier.Limits); err != nil {
+        return limitFailure(err)
+    }
+
+    if err := s.repo.UpsertTier(ctx, tier); err != nil {
+        log.Error("Failed to store limit tier",
+            zap.String("tier", tier.Name),
+            zap.Error(err),
+        )
+        return limitFailure(err)
+    }
+
+    log.Info("Limit tier stored",
+        zap.String("tier", tier.Name),
+    )
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Limit tier stored successfully",
+        Data:    tier,
+    }
+}
+
+// SetAccountLimits assigns an account to a tier and sets the limits that
+// override it, replacing any set before
+func (s *LimitService) SetAccountLimits(ctx context.Context, limits model.AccountLimits) *Result {
+    log := middleware.GetLogger()
+
+    if err := authorize(ctx, PermManageAccounts); err != nil {
+        return forbidden(err)
+    }
+
+    if err := validateLimits(limits.Limits); err != nil {
+        return limitFailure(err)
+    }
+    if _, err := s.accountRepo.GetByID(ctx, limits.AccountID); err != nil {
+        if err == sql.ErrNoRows {
+            return limitFailure(ErrAccountNotFound)
+        }
+        return limitFailure(err)
+    }
+
+    if err := s.repo.UpsertAccountLimits(ctx, limits); err != nil {
+        if middleware.IsForeignKeyViolation(err) {
+            return limitFailure(fmt.Errorf("%w: unknown tier %q", ErrInvalidLimits, limits.Tier))
+        }
+        log.Error("Failed to store account limits",
+            zap.Int("account_id", limits.AccountID),
+            zap.Error(err),
+        )
+        return limitFailure(err)
+    }
+
+    log.Info("Account limits stored",
+        zap.Int("account_id", limits.AccountID),
+        zap.String("tier", limits.Tier),
+    )
+
+    return s.GetAccountLimits(ctx, limits.AccountID)
+}
+
+// GetAccountLimits returns an account's effective limits with its usage and
+// remaining allowance
+func (s *LimitService) GetAccountLimits(ctx context.Context, accountID int) *Result {
+    account, err := s.accountRepo.GetByID(ctx, accountID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return limitFailure(ErrAccountNotFound)
+        }
+        return limitFailure(err)
+    }
+    if err := authorizeAccount(ctx, PermReadAccount, account); err != nil {
+        if errors.Is(err, ErrAccountNotFound) {
+            return limitFailure(err)
+        }
+        return forbidden(err)
+    }
+
+    status := &LimitStatus{AccountID: accountID}
+    configured, err := s.repo.GetAccountLimits(ctx, accountID)
+    if err != nil && err != sql.ErrNoRows {
+        return limitFailure(err)
+    }
+    status.Configured = configured
+    limits, err := s.repo.GetEffectiveWithTx(ctx, nil, accountID)
+    if err != nil {
+        return limitFailure(err)
+    }
+    usage, err := s.repo.GetUsageWithTx(ctx, nil, accountID)
+    if err != nil {
+        return limitFailure(err)
+    }
+    status.Limits = *limits
+    status.Usage = *usage
+    status.Remaining = model.Limits{
+        MaxSingleAmount:  limits.MaxSingleAmount,
+        MaxDailyAmount:   remainingAmount(limits.MaxDailyAmount, usage.DailyAmount),
+        MaxMonthlyAmount: remainingAmount(limits.MaxMonthlyAmount, usage.MonthlyAmount),
+        MaxDailyCount:    remainingCount(limits.MaxDailyCount, usage.DailyCount),
+        MaxMonthlyCount:  remainingCount(limits.MaxMonthlyCount, usage.MonthlyCount),
+    }
+
+    return &Result{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Account limits retrieved successfully",
+        Data:    status,
+    }
+}
+
+// validateLimits rejects negative limits
+func validateLimits(l model.Limits) error {
+    for _, amount := range []*decimal.Decimal{l.MaxSingleAmount, l.MaxDailyAmount, l.MaxMonthlyAmount} {
+        if amount != nil && amount.IsNegative() {
+            return fmt.Errorf("%w: amount limits cannot be negative", ErrInvalidLimits)
+        }
+    }
+    for _, count := range []*int{l.MaxDailyCount, l.MaxMonthlyCount} {
+        if count != nil && *count < 0 {
+            return fmt.Errorf("%w: count limits cannot be negative", ErrInvalidLimits)
+        }
+    }
+    return nil
+}
+
+func remainingAmount(limit *decimal.Decimal, used decimal.Decimal) *decimal.Decimal {
+    if limit == nil {
+        return nil
+    }
+    remaining := decimal.Max(limit.Sub(used), decimal.Zero)
+    return &remaining
+}
+
+func remainingCount(limit *int, used int) *int {
+    if limit == nil {
+        return nil
+    }
+    remaining := *limit - used
+    if remaining < 0 {
+        remaining = 0
+    }
+    return &remaining
+}
+
+// limitFailure maps a limit configuration error to a result
+func limitFailure(err error) *Result {
+    switch {
+    case errors.Is(err, ErrInvalidLimits):
+        return &Result{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Invalid limits",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrAccountNotFound):
+        return &Result{
+            Success: false,
+            Status:  http.StatusNotFound,
+            Message: "Account not found",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+    return &Result{
+        Success: false,
+        Status:  http.StatusInternalServerError,
+        Message: "Failed to process limits",
+        Error:   err.Error(),
+        Code:    errorCode(err),
+    }
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "go.uber.org/zap"
+    "github.com/shopspring/decimal"
+)
+
+var ErrLimitExceeded = errors.New("transfer limit exceeded")
+
+// Limit codes name the limit a rejected transfer would have exceeded
+const (
+    LimitCodeSingleAmount  = "single_amount_limit_exceeded"
+    LimitCodeDailyAmount   = "daily_amount_limit_exceeded"
+    LimitCodeMonthlyAmount = "monthly_amount_limit_exceeded"
+    LimitCodeDailyCount    = "daily_count_limit_exceeded"
+    LimitCodeMonthlyCount  = "monthly_count_limit_exceeded"
+)
+
+// LimitError rejects a transfer that would exceed one of the source account's
+// limits. Remaining is what the account may still send (or, for count limits,
+// how many transfers it may still make) in the limit's window.
+type LimitError struct {
+    Code      string          `json:"code"`
+    AccountID int             `json:"account_id"`
+    Limit     decimal.Decimal `json:"limit"`
+    Remaining decimal.Decimal `json:"remaining"`
+}
+
+func (e *LimitError) Error() string {
+    return fmt.Sprintf("%s: account %d has %s remaining of %s", e.Code, e.AccountID, e.Remaining, e.Limit)
+}
+
+func (e *LimitError) Unwrap() error {
+    return ErrLimitExceeded
+}
+
+// checkLimits checks outgoing transfers of amounts from account against its
+// limits and the transfers it already made in the current windows. It must run
+// inside tx with the account locked, so concurrent transfers see each other.
+func (s *TransactionService) checkLimits(ctx context.Context, tx *sql.Tx, account *model.Account, amounts ...decimal.Decimal) error {
+    log := middleware.GetLogger()
+
+    limits, err := s.limitRepo.GetEffectiveWithTx(ctx, tx, account.ID)
+    if err != nil {
+        return fmt.Errorf("get limits of account %d: %w", account.ID, err)
+    }
+    if *limits == (model.Limits{}) {
+        return nil
+    }
+
+    usage, err := s.limitRepo.GetUsageWithTx(ctx, tx, account.ID)
+    if err != nil {
+        return fmt.Errorf("get limit usage of account %d: %w", account.ID, err)
+    }
+
+    total, largest := decimal.Zero, decimal.Zero
+    for _, amount := range amounts {
+        total = total.Add(amount)
+        largest = decimal.Max(largest, amount)
+    }
+    count := int64(len(amounts))
+
+    var limitErr *LimitError
+    switch {
+    case limits.MaxSingleAmount != nil && largest.GreaterThan(*limits.MaxSingleAmount):
+        limitErr = limitExceeded(account.ID, LimitCodeSingleAmount, *limits.MaxSingleAmount, decimal.Zero)
+    case limits.MaxDailyAmount != nil && usage.DailyAmount.Add(total).GreaterThan(*limits.MaxDailyAmount):
+        limitErr = limitExceeded(account.ID, LimitCodeDailyAmount, *limits.MaxDailyAmount, usage.DailyAmount)
+    case limits.MaxMonthlyAmount != nil && usage.MonthlyAmount.Add(total).GreaterThan(*limits.MaxMonthlyAmount):
+        limitErr = limitExceeded(account.ID, LimitCodeMonthlyAmount, *limits.MaxMonthlyAmount, usage.MonthlyAmount)
+    case limits.MaxDailyCount != nil && int64(usage.DailyCount)+count > int64(*limits.MaxDailyCount):
+        limitErr = limitExceeded(account.ID, LimitCodeDailyCount, decimal.NewFromInt(int64(*limits.MaxDailyCount)), decimal.NewFromInt(int64(usage.DailyCount)))
+    case limits.MaxMonthlyCount != nil && int64(usage.MonthlyCount)+count > int64(*limits.MaxMonthlyCount):
+        limitErr = limitExceeded(account.ID, LimitCodeMonthlyCount, decimal.NewFromInt(int64(*limits.MaxMonthlyCount)), decimal.NewFromInt(int64(usage.MonthlyCount)))
+    default:
+        return nil
+    }
+
+    log.Warn("Transfer failed - limit exceeded",
+        zap.Int("account_id", account.ID),
+        zap.String("code", limitErr.Code),
+        zap.String("remaining", limitErr.Remaining.String()),
+    )
+    return limitErr
+}
+
+// limitExceeded builds the error for a limit of which used is already taken
+func limitExceeded(accountID int, code string, limit, used decimal.Decimal) *LimitError {
+    remaining := limit.Sub(used)
+    if remaining.IsNegative() {
+        remaining = decimal.Zero
+    }
+    return &LimitError{Code: code, AccountID: accountID, Limit: limit, Remaining: remaining}
+}
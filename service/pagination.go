@@ -0,0 +1,100 @@
+package service
+
+import (
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+    "transfer-service/model"
+)
+
+// Page sizes of transaction history
+const (
+    DefaultPageLimit = 50
+    MaxPageLimit     = 200
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+var ErrInvalidPageLimit = errors.New("invalid page limit")
+
+// PageRequest selects a page of history: the cursor returned as next_cursor by
+// the previous page, empty for the first, and the page size, 0 for the default
+type PageRequest struct {
+    Cursor string
+    Limit  int
+}
+
+// parsePage decodes a page request into the position to continue after and
+// the page size
+func parsePage(page PageRequest) (*model.TransactionCursor, int, error) {
+    limit := page.Limit
+    if limit == 0 {
+        limit = DefaultPageLimit
+    }
+    if limit < 1 || limit > MaxPageLimit {
+        return nil, 0, fmt.Errorf("%w: must be between 1 and %d", ErrInvalidPageLimit, MaxPageLimit)
+    }
+    if page.Cursor == "" {
+        return nil, limit, nil
+    }
+    after, err := decodeCursor(page.Cursor)
+    if err != nil {
+        return nil, 0, err
+    }
+    return after, limit, nil
+}
+
+// newTransactionPage builds a page from up to limit+1 transactions; the extra
+// one only shows that another page follows
+func newTransactionPage(transactions []*model.Transaction, limit int) *model.TransactionPage {
+    page := &model.TransactionPage{Transactions: transactions}
+    if len(transactions) > limit {
+        page.Transactions = transactions[:limit]
+        page.NextCursor = encodeCursor(transactions[limit-1])
+    }
+    if page.Transactions == nil {
+        page.Transactions = []*model.Transaction{}
+    }
+    return page
+}
+
+// encodeCursor makes the opaque cursor of the page that follows t. It holds
+// t's created_at in Unix nanoseconds and its id.
+func encodeCursor(t *model.Transaction) string {
+    raw := strconv.FormatInt(t.CreatedAt.UnixNano(), 10) + ":" + strconv.Itoa(t.ID)
+    return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (*model.TransactionCursor, error) {
+    raw, err := base64.RawURLEncoding.DecodeString(cursor)
+    if err != nil {
+        return nil, ErrInvalidCursor
+    }
+    nanos, id, ok := strings.Cut(string(raw), ":")
+    if !ok {
+        return nil, ErrInvalidCursor
+    }
+    n, err := strconv.ParseInt(nanos, 10, 64)
+    if err != nil {
+        return nil, ErrInvalidCursor
+    }
+    after := &model.TransactionCursor{CreatedAt: time.Unix(0, n).UTC()}
+    if after.ID, err = strconv.Atoi(id); err != nil {
+        return nil, ErrInvalidCursor
+    }
+    return after, nil
+}
+
+// pageFailure maps a page request error to a result
+func pageFailure(err error) *Result {
+    return &Result{
+        Success: false,
+        Status:  http.StatusBadRequest,
+        Message: "Invalid page request",
+        Error:   err.Error(),
+        Code:    errorCode(err),
+    }
+}
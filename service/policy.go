@@ -0,0 +1,151 @@
+package service
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "net/http"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "transfer-service/repository"
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+// Permission is something a principal may be allowed to do
+type Permission string
+
+// Permissions checked by the policy
+const (
+    PermReadAccount     Permission = "accounts:read"    // an account, its history, limits, holds and planned transfers
+    PermDebitAccount    Permission = "accounts:debit"   // move funds out of an account
+    PermManageAccounts  Permission = "accounts:manage"  // create accounts, change status, overdraft and limits, reverse transfers
+    PermReadAll         Permission = "ledger:read"      // data across accounts: full history, customers, positions and tiers
+    PermManageCustomers Permission = "customers:manage" // create, update and delete customers
+    PermConfigure       Permission = "config:manage"    // fees, FX rates and positions, limit tiers
+)
+
+// rolePermissions lists what each role may do. Customers hold their
+// permissions only for the accounts and customer they own.
+var rolePermissions = map[string][]Permission{
+    model.RoleCustomer: {PermReadAccount, PermDebitAccount},
+    model.RoleOperator: {PermReadAccount, PermReadAll},
+    model.RoleAdmin:    {PermReadAccount, PermDebitAccount, PermManageAccounts, PermReadAll, PermManageCustomers, PermConfigure},
+    model.RoleSystem:   {PermReadAccount, PermDebitAccount, PermManageAccounts, PermReadAll, PermManageCustomers, PermConfigure},
+}
+
+// The policy decides for the principal on ctx. Every request carries one, as
+// the authenticator turns the others away, and the background workers carry
+// the system principal. A context without a principal is denied everything.
+
+// authorize checks a permission that is not scoped to an account, which
+// customers never hold
+func authorize(ctx context.Context, perm Permission) error {
+    principal, err := principalOf(ctx)
+    if err != nil {
+        return err
+    }
+    if principal.Role == model.RoleCustomer || !hasPermission(principal, perm) {
+        return fmt.Errorf("%w: role %s may not %s", ErrForbidden, principal.Role, perm)
+    }
+    return nil
+}
+
+// authorizeAccount checks a permission on an account. Customers may only act
+// on accounts they own.
+func authorizeAccount(ctx context.Context, perm Permission, account *model.Account) error {
+    principal, err := principalOf(ctx)
+    if err != nil {
+        return err
+    }
+    if !hasPermission(principal, perm) {
+        return fmt.Errorf("%w: role %s may not %s", ErrForbidden, principal.Role, perm)
+    }
+    if principal.Role == model.RoleCustomer && !ownsCustomer(principal, account.CustomerID) {
+        // Someone else's account looks like a missing one, so that customers
+        // cannot probe for IDs
+        return ErrAccountNotFound
+    }
+    return nil
+}
+
+// authorizeAccountID loads an account to check a permission on it. Customers
+// get ErrAccountNotFound for a missing account; for other roles it passes, so
+// that the caller reports it as not found.
+func authorizeAccountID(ctx context.Context, accounts repository.AccountRepository, perm Permission, id int) error {
+    principal, err := principalOf(ctx)
+    if err != nil {
+        return err
+    }
+    if principal.Role != model.RoleCustomer {
+        return authorize(ctx, perm)
+    }
+    account, err := accounts.GetByID(ctx, id)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return ErrAccountNotFound
+        }
+        return err
+    }
+    return authorizeAccount(ctx, perm, account)
+}
+
+// authorizeRecord checks a permission on the account a record belongs to. A
+// customer who may not see the account gets notFound, as for a missing record.
+func authorizeRecord(ctx context.Context, accounts repository.AccountRepository, perm Permission, accountID int, notFound error) error {
+    err := authorizeAccountID(ctx, accounts, perm, accountID)
+    if errors.Is(err, ErrAccountNotFound) {
+        return notFound
+    }
+    return err
+}
+
+// authorizeCustomer checks a read of a customer's data, which customers may
+// only do for themselves
+func authorizeCustomer(ctx context.Context, customerID int) error {
+    principal, err := principalOf(ctx)
+    if err != nil {
+        return err
+    }
+    if principal.Role == model.RoleCustomer {
+        if !ownsCustomer(principal, &customerID) {
+            return fmt.Errorf("%w: customer %d is not the caller", ErrForbidden, customerID)
+        }
+        return nil
+    }
+    return authorize(ctx, PermReadAll)
+}
+
+// principalOf returns the principal on ctx, or ErrForbidden if there is none
+func principalOf(ctx context.Context) (*model.Principal, error) {
+    principal := middleware.PrincipalFromContext(ctx)
+    if principal == nil {
+        return nil, fmt.Errorf("%w: no authenticated principal", ErrForbidden)
+    }
+    return principal, nil
+}
+
+func hasPermission(principal *model.Principal, perm Permission) bool {
+    for _, p := range rolePermissions[principal.Role] {
+        if p == perm {
+            return true
+        }
+    }
+    return false
+}
+
+func ownsCustomer(principal *model.Principal, customerID *int) bool {
+    return principal.CustomerID != nil && customerID != nil && *principal.CustomerID == *customerID
+}
+
+// forbidden maps a policy error to a 403 result
+func forbidden(err error) *Result {
+    return &Result{
+        Success: false,
+        Status:  http.StatusForbidden,
+        Message: "The caller is not allowed to do this",
+        Error:   err.Error(),
+        Code:    errorCode(err),
+    }
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+    "transfer-service/model"
+)
+
+var ErrInvalidSchedule = errors.New("invalid standing order schedule")
+
+// cronSearchLimit bounds how far ahead a cron expression is searched, so
+// expressions that never match (e.g. 30 February) end the order instead of looping
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// recurrence yields the occurrence times of a standing order. All times are UTC.
+type recurrence interface {
+    // next returns the first occurrence strictly after after, or the zero time
+    // if there is none
+    next(after time.Time) time.Time
+}
+
+// newRecurrence builds the recurrence of an order's frequency
+func newRecurrence(o model.StandingOrder) (recurrence, error) {
+    start := o.StartAt.UTC()
+    switch o.Frequency {
+    case model.FrequencyDaily:
+        return &intervalRecurrence{start: start, step: 24 * time.Hour}, nil
+    case model.FrequencyWeekly:
+        return &intervalRecurrence{start: start, step: 7 * 24 * time.Hour}, nil
+    case model.FrequencyMonthly:
+        if o.DayOfMonth < 1 || o.DayOfMonth > 31 {
+            return nil, fmt.Errorf("%w: day_of_month must be between 1 and 31", ErrInvalidSchedule)
+        }
+        return &monthlyRecurrence{start: start, day: o.DayOfMonth}, nil
+    case model.FrequencyCron:
+        return parseCron(o.Cron, start)
+    }
+    return nil, fmt.Errorf("%w: frequency must be daily, weekly, monthly or cron", ErrInvalidSchedule)
+}
+
+// intervalRecurrence occurs at start and every step after it
+type intervalRecurrence struct {
+    start time.Time
+    step  time.Duration
+}
+
+func (r *intervalRecurrence) next(after time.Time) time.Time {
+    if after.Before(r.start) {
+        return r.start
+    }
+    n := after.Sub(r.start)/r.step + 1
+    return r.start.Add(n * r.step)
+}
+
+// monthlyRecurrence occurs on day of every month at start's time of day,
+// moved to the last day of months that are too short
+type monthlyRecurrence struct {
+    start time.Time
+    day   int
+}
+
+func (r *monthlyRecurrence) next(after time.Time) time.Time {
+    from := after.UTC()
+    if from.Before(r.start) {
+        from = r.start.Add(-time.Nanosecond)
+    }
+    year, month, _ := from.Date()
+    if t := r.in(year, month); t.After(from) {
+        return t
+    }
+    return r.in(year, month+1)
+}
+
+// in returns the occurrence in the given month; month may overflow into the next year
+func (r *monthlyRecurrence) in(year int, month time.Month) time.Time {
+    first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+    lastDay := first.AddDate(0, 1, -1).Day()
+    day := r.day
+    if day > lastDay {
+        day = lastDay
+    }
+    hour, minute, second := r.start.Clock()
+    return time.Date(first.Year(), first.Month(), day, hour, minute, second, 0, time.UTC)
+}
+
+// cronRecurrence matches a five-field cron expression: minute, hour, day of
+// month, month and day of week (0 or 7 is Sunday). Fields accept *, lists,
+// ranges and steps. As in cron, when both day fields are restricted a day
+// matching either one matches.
+type cronRecurrence struct {
+    start                                  time.Time
+    minutes, hours, days, months, weekdays map[int]bool
+    anyDay, anyWeekday                     bool
+}
+
+func parseCron(expr string, start time.Time) (*cronRecurrence, error) {
+    fields := strings.Fields(expr)
+    if len(fields) != 5 {
+        return nil, fmt.Errorf("%w: cron needs 5 fields, got %d", ErrInvalidSchedule, len(fields))
+    }
+
+    bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+    var sets [5]map[int]bool
+    for i, field := range fields {
+        set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+        if err != nil {
+            return nil, fmt.Errorf("%w: cron field %q: %v", ErrInvalidSchedule, field, err)
+        }
+        sets[i] = set
+    }
+    if sets[4][7] {
+        sets[4][0] = true
+    }
+
+    return &cronRecurrence{
+        start:      start,
+        minutes:    sets[0],
+        hours:      sets[1],
+        days:       sets[2],
+        months:     sets[3],
+        weekdays:   sets[4],
+        anyDay:     fields[2] == "*",
+        anyWeekday: fields[4] == "*",
+    }, nil
+}
+
+// parseCronField expands one comma-separated cron field into the values it matches
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+    set := make(map[int]bool)
+    for _, part := range strings.Split(field, ",") {
+        step := 1
+        if i := strings.Index(part, "/"); i >= 0 {
+            n, err := strconv.Atoi(part[i+1:])
+            if err != nil || n < 1 {
+                return nil, fmt.Errorf("invalid step %q", part[i+1:])
+            }
+            step = n
+            part = part[:i]
+        }
+
+        lo, hi := min, max
+        if part != "*" {
+            bounds := strings.SplitN(part, "-", 2)
+            var err error
+            if lo, err = strconv.Atoi(bounds[0]); err != nil {
+                return nil, fmt.Errorf("invalid value %q", bounds[0])
+            }
+            hi = lo
+            if len(bounds) == 2 {
+                if hi, err = strconv.Atoi(bounds[1]); err != nil {
+                    return nil, fmt.Errorf("invalid value %q", bounds[1])
+                }
+            } else if step > 1 {
+                hi = max
+            }
+        }
+        if lo < min || hi > max || lo > hi {
+            return nil, fmt.Errorf("values must be between %d and %d", min, max)
+        }
+
+        for v := lo; v <= hi; v += step {
+            set[v] = true
+        }
+    }
+    return set, nil
+}
+
+func (r *cronRecurrence) next(after time.Time) time.Time {
+    if after.Before(r.start) {
+        after = r.start.Add(-time.Nanosecond)
+    }
+    t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+    limit := t.Add(cronSearchLimit)
+
+    // Skip whole months, days and hours that cannot match
+    for t.Before(limit) {
+        switch {
+        case !r.months[int(t.Month())]:
+            t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+        case !r.dayMatches(t):
+            t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+        case !r.hours[t.Hour()]:
+            t = t.Truncate(time.Hour).Add(time.Hour)
+        case !r.minutes[t.Minute()]:
+            t = t.Add(time.Minute)
+        default:
+            return t
+        }
+    }
+    return time.Time{}
+}
+
+func (r *cronRecurrence) dayMatches(t time.Time) bool {
+    day := r.days[t.Day()]
+    weekday := r.weekdays[int(t.Weekday())]
+    switch {
+    case r.anyDay && r.anyWeekday:
+        return true
+    case r.anyDay:
+        return weekday
+    case r.anyWeekday:
+        return day
+    }
+    return day || weekday
+}
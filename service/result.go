@@ -0,0 +1,13 @@
+package service
+
+// Result represents the outcome of a service operation. Handlers pass it
+// through as the HTTP response.
+type Result struct {
+    Success  bool
+    Status   int
+    Message  string
+    Error    string
+    Code     ErrorCode // set when the failure has a specific code
+    Data     interface{}
+    Replayed bool // true when the response was replayed for a repeated idempotency key
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "math/rand"
+    "time"
+    "transfer-service/middleware"
+    "go.uber.org/zap"
+)
+
+const (
+    // maxTxAttempts bounds how often a conflicting transaction is run in total
+    maxTxAttempts = 4
+    // retryBaseDelay is the backoff before the first retry; it doubles per attempt
+    retryBaseDelay = 10 * time.Millisecond
+)
+
+var ErrRetryableConflict = errors.New("retryable conflict")
+
+// isRetryable reports whether rerunning a failed unit of work may succeed
+func isRetryable(err error) bool {
+    return middleware.IsRetryable(err) || errors.Is(err, errIdempotencyRace)
+}
+
+// retryTx runs fn, which must execute a complete unit of work, and runs it
+// again with jittered exponential backoff when it fails with a serialization
+// failure or deadlock. Once the attempts are used up it returns an error
+// wrapping ErrRetryableConflict.
+func retryTx(ctx context.Context, fn func() error) error {
+    log := middleware.GetLogger()
+
+    var err error
+    for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+        err = fn()
+        if err == nil || !isRetryable(err) {
+            return err
+        }
+        if attempt == maxTxAttempts {
+            break
+        }
+
+        // Full backoff is base * 2^(attempt-1); pick a random point in its upper half
+        backoff := retryBaseDelay << (attempt - 1)
+        delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+        log.Warn("Retrying transaction after conflict",
+            zap.Int("attempt", attempt),
+            zap.Duration("delay", delay),
+            zap.Error(err),
+        )
+
+        timer := time.NewTimer(delay)
+        select {
+        case <-ctx.Done():
+            timer.Stop()
+            return ctx.Err()
+        case <-timer.C:
+        }
+    }
+
+    return fmt.Errorf("%w after %d attempts: %v", ErrRetryableConflict, maxTxAttempts, err)
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "net/http"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "go.uber.org/zap"
+    "github.com/shopspring/decimal"
+)
+
+var ErrTransactionNotFound = errors.New("transaction not found")
+var ErrNotReversible = errors.New("only transfers can be reversed")
+var ErrAlreadyReversed = errors.New("transaction is already fully reversed")
+var ErrRefundExceedsOriginal = errors.New("refund exceeds the amount not yet refunded")
+
+// ReversalRequest is the body of a reversal. A nil Amount refunds everything
+// not yet refunded; otherwise it is a partial refund in the original asset.
+type ReversalRequest struct {
+    Amount *decimal.Decimal `json:"amount,omitempty"`
+}
+
+func (s *TransactionService) Reverse(ctx context.Context, originalID int, req ReversalRequest) *TransferResult {
+    return s.ReverseIdempotent(ctx, originalID, req, nil)
+}
+
+// ReverseIdempotent refunds all or part of a transfer with a compensating
+// transaction linked to it, once per idempotency key
+func (s *TransactionService) ReverseIdempotent(ctx context.Context, originalID int, req ReversalRequest, key *model.IdempotencyKey) *TransferResult {
+    log := middleware.GetLogger()
+
+    if err := authorize(ctx, PermManageAccounts); err != nil {
+        return forbidden(err)
+    }
+
+    log.Info("Starting reversal",
+        zap.Int("transaction_id", originalID),
+        zap.Stringer("amount", req.Amount),
+    )
+
+    var result *TransferResult
+    var reversal *model.Transaction
+    err := retryTx(ctx, func() error {
+        return s.uow.Do(ctx, &sql.TxOptions{
+            Isolation: sql.LevelSerializable,
+        }, func(tx *sql.Tx) error {
+            if key != nil {
+                replayed, err := replayIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeReversal, key, &TransferReceipt{})
+                if err != nil || replayed != nil {
+                    result = replayed
+                    return err
+                }
+            }
+
+            var err error
+            reversal, err = s.reverse(ctx, tx, originalID, req.Amount)
+            if err != nil {
+                return err
+            }
+            result = &TransferResult{
+                Success: true,
+                Status:  http.StatusCreated,
+                Message: "Reversal completed successfully",
+                Data: &TransferReceipt{
+                    Message:     "Reversal completed successfully",
+                    Transaction: reversal,
+                },
+            }
+
+            if key != nil {
+                return storeIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeReversal, key, result)
+            }
+            return nil
+        })
+    })
+    if err != nil {
+        return transferFailure(err)
+    }
+
+    if result.Replayed {
+        log.Info("Replayed idempotent reversal",
+            zap.String("idempotency_key", key.Key),
+        )
+        return result
+    }
+
+    log.Info("Reversal completed successfully",
+        zap.Int("transaction_id", reversal.ID),
+        zap.Int("reversal_of", originalID),
+        zap.String("asset_code", reversal.AssetCode),
+        zap.Float64("amount", formatDecimal(reversal.Amount, reversal.AssetCode)),
+    )
+
+    return result
+}
+
+// reverse posts a compensating transaction for amount of the original inside
+// tx. The original row stays locked until commit, so concurrent refunds of the
+// same transfer are checked against each other.
+func (s *TransactionService) reverse(ctx context.Context, tx *sql.Tx, originalID int, amount *decimal.Decimal) (*model.Transaction, error) {
+    log := middleware.GetLogger()
+
+    original, err := s.transactionRepo.GetByIDWithLock(ctx, tx, originalID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, ErrTransactionNotFound
+        }
+        return nil, fmt.Errorf("lock transaction %d: %w", originalID, err)
+    }
+    // Reversals and any other kind of transaction are final
+    if original.Kind != model.TransactionKindTransfer {
+        return nil, fmt.Errorf("%w: transaction %d is a %s", ErrNotReversible, original.ID, original.Kind)
+    }
+
+    reversals, err := s.transactionRepo.GetReversalsWithTx(ctx, tx, original.ID)
+    if err != nil {
+        return nil, fmt.Errorf("get reversals of transaction %d: %w", original.ID, err)
+    }
+    refunded, refundedDestination := decimal.Zero, decimal.Zero
+    for _, r := range reversals {
+        refunded = refunded.Add(r.Amount)
+        if r.DestinationAmount != nil {
+            refundedDestination = refundedDestination.Add(*r.DestinationAmount)
+        }
+    }
+
+    remaining := original.Amount.Sub(refunded)
+    if !remaining.IsPositive() {
+        return nil, ErrAlreadyReversed
+    }
+    refund := remaining
+    if amount != nil {
+        if err := validateAmount(*amount, original.AssetCode); err != nil {
+            return nil, err
+        }
+        if amount.GreaterThan(remaining) {
+            log.Warn("Reversal rejected - refund exceeds original",
+                zap.Int("transaction_id", original.ID),
+                zap.String("remaining", remaining.String()),
+                zap.String("amount", amount.String()),
+            )
+            return nil, ErrRefundExceedsOriginal
+        }
+        refund = *amount
+    }
+
+    // The reversal pays the original destination back to the original source
+    originalID = original.ID
+    reversal := model.Transaction{
+        Kind:                 model.TransactionKindReversal,
+        ReversalOf:           &originalID,
+        SourceAccountID:      original.DestinationAccountID,
+        DestinationAccountID: original.SourceAccountID,
+        AssetCode:            original.AssetCode,
+        Amount:               refund,
+    }
+
+    // A converted transfer is refunded at its original rate. The last refund
+    // takes whatever destination amount is left so rounding cannot strand it.
+    destinationRefund := decimal.Zero
+    if original.DestinationAmount != nil {
+        if refund.Equal(remaining) {
+            destinationRefund = original.DestinationAmount.Sub(refundedDestination)
+        } else {
+            destinationRefund = original.DestinationAmount.Mul(refund).Div(original.Amount).
+                RoundDown(model.AssetScale(original.DestinationAssetCode))
+        }
+        if !destinationRefund.IsPositive() {
+            return nil, ErrConvertedAmountTooSmall
+        }
+        reversal.DestinationAssetCode = original.DestinationAssetCode
+        reversal.DestinationAmount = &destinationRefund
+        reversal.FXRate = original.FXRate
+    }
+
+    // Mirror every leg of the original but its fee with the refunded share
+    originalPostings, err := s.transactionRepo.GetPostingsByTransactionID(ctx, original.ID)
+    if err != nil {
+        return nil, fmt.Errorf("get postings of transaction %d: %w", original.ID, err)
+    }
+    originalPostings = withoutFeeLegs(original, originalPostings)
+    postings := make([]model.Posting, 0, len(originalPostings))
+    ids := make([]int, 0, len(originalPostings))
+    for _, p := range originalPostings {
+        share := refund
+        if p.AssetCode != original.AssetCode {
+            share = destinationRefund
+        }
+        if p.Amount.IsPositive() {
+            share = share.Neg()
+        }
+        postings = append(postings, model.Posting{AccountID: p.AccountID, AssetCode: p.AssetCode, Amount: share})
+        ids = append(ids, p.AccountID)
+    }
+
+    accounts, err := s.lockAccounts(ctx, tx, ids...)
+    if err != nil {
+        return nil, err
+    }
+    for _, id := range ids {
+        if _, ok := accounts[id]; !ok {
+            if id == reversal.SourceAccountID {
+                return nil, ErrSourceAccountNotFound
+            }
+            return nil, ErrDestinationAccountNotFound
+        }
+    }
+
+    if err := checkStatus(accounts, postings); err != nil {
+        return nil, err
+    }
+    if shortID, ok := checkFunds(accounts, postings); !ok {
+        short := accounts[shortID]
+        log.Warn("Reversal failed - insufficient balance",
+            zap.Int("account_id", shortID),
+            zap.Float64("available_balance", formatDecimal(short.AvailableBalance(), short.AssetCode)),
+            zap.Float64("requested_amount", formatDecimal(refund, reversal.AssetCode)),
+        )
+        if shortID != reversal.SourceAccountID {
+            return nil, ErrInsufficientLiquidity
+        }
+        return nil, ErrInsufficientBalance
+    }
+
+    return s.post(ctx, tx, reversal, postings)
+}
@@ -0,0 +1,393 @@
+package service
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "net/http"
+    "time"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "transfer-service/repository"
+    "go.uber.org/zap"
+)
+
+// DefaultSchedulerInterval is how often the worker looks for due transfers
+// when SCHEDULER_INTERVAL is not set
+const DefaultSchedulerInterval = 5 * time.Second
+
+// schedulerBatchSize bounds how many due transfers one worker tick executes
+const schedulerBatchSize = 100
+
+// A scheduled transfer that hits a database error is retried after
+// scheduledRetryDelay, doubling each time, and failed after
+// scheduledMaxAttempts attempts
+const (
+    scheduledMaxAttempts = 5
+    scheduledRetryDelay  = time.Minute
+)
+
+var ErrScheduledTransferNotFound = errors.New("scheduled transfer not found")
+var ErrNotScheduled = errors.New("transfer is no longer scheduled")
+var ErrExecuteAtRequired = errors.New("execute_at must be in the future")
+
+type ScheduledTransferService struct {
+    repo            repository.ScheduledTransferRepository
+    accountRepo     repository.AccountRepository
+    idempotencyRepo repository.IdempotencyRepository
+    transactions    *TransactionService
+    uow             repository.UnitOfWork
+}
+
+func NewScheduledTransferService(repo repository.ScheduledTransferRepository, accountRepo repository.AccountRepository, idempotencyRepo repository.IdempotencyRepository, transactions *TransactionService, uow repository.UnitOfWork) *ScheduledTransferService {
+    return &ScheduledTransferService{
+        repo:            repo,
+        accountRepo:     accountRepo,
+        idempotencyRepo: idempotencyRepo,
+        transactions:    transactions,
+        uow:             uow,
+    }
+}
+
+func (s *ScheduledTransferService) Schedule(ctx context.Context, st model.ScheduledTransfer) *TransferResult {
+    return s.ScheduleIdempotent(ctx, st, nil)
+}
+
+// ScheduleIdempotent stores a transfer to execute at st.ExecuteAt, once per
+// idempotency key. Accounts and amount are checked now; funds are checked when
+// the transfer runs.
+func (s *ScheduledTransferService) ScheduleIdempotent(ctx context.Context, st model.ScheduledTransfer, key *model.IdempotencyKey) *TransferResult {
+    log := middleware.GetLogger()
+
+    log.Info("Scheduling transfer",
+        zap.Int("source_account_id", st.SourceAccountID),
+        zap.Int("destination_account_id", st.DestinationAccountID),
+        zap.String("amount", st.Amount.String()),
+        zap.Time("execute_at", st.ExecuteAt),
+    )
+
+    if err := resolveAccounts(ctx, s.accountRepo, &st.SourceAccount, &st.DestinationAccount, &st.SourceAccountID, &st.DestinationAccountID); err != nil {
+        return scheduledFailure(err)
+    }
+    if st.SourceAccountID == st.DestinationAccountID {
+        return scheduledFailure(ErrSameAccount)
+    }
+    if !st.ExecuteAt.After(time.Now()) {
+        return scheduledFailure(ErrExecuteAtRequired)
+    }
+    if err := authorizeAccountID(ctx, s.accountRepo, PermDebitAccount, st.SourceAccountID); err != nil {
+        return scheduledFailure(err)
+    }
+
+    var result *TransferResult
+    var scheduled *model.ScheduledTransfer
+    err := retryTx(ctx, func() error {
+        return s.uow.Do(ctx, nil, func(tx *sql.Tx) error {
+            if key != nil {
+                replayed, err := replayIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeScheduledTransfer, key, &model.ScheduledTransfer{})
+                if err != nil || replayed != nil {
+                    result = replayed
+                    return err
+                }
+            }
+
+            if err := s.validate(ctx, &st); err != nil {
+                return err
+            }
+            var err error
+            scheduled, err = s.repo.CreateWithTx(ctx, tx, st)
+            if err != nil {
+                return fmt.Errorf("create scheduled transfer: %w", err)
+            }
+            result = &TransferResult{
+                Success: true,
+                Status:  http.StatusCreated,
+                Message: "Transfer scheduled successfully",
+                Data:    scheduled,
+            }
+
+            if key != nil {
+                return storeIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeScheduledTransfer, key, result)
+            }
+            return nil
+        })
+    })
+    if err != nil {
+        return scheduledFailure(err)
+    }
+
+    if result.Replayed {
+        log.Info("Replayed idempotent scheduled transfer",
+            zap.String("idempotency_key", key.Key),
+        )
+        return result
+    }
+
+    log.Info("Transfer scheduled successfully",
+        zap.Int("scheduled_transfer_id", scheduled.ID),
+        zap.Time("execute_at", scheduled.ExecuteAt),
+    )
+
+    return result
+}
+
+// validate checks the accounts and amount of a transfer to schedule and fills
+// in the source account's asset
+func (s *ScheduledTransferService) validate(ctx context.Context, st *model.ScheduledTransfer) error {
+    t := st.Transfer()
+    if err := validateTransfer(ctx, s.accountRepo, &t); err != nil {
+        return err
+    }
+    st.AssetCode = t.AssetCode
+    return nil
+}
+
+// validateTransfer checks the accounts and amount of a transfer that will run
+// later and fills in the source account's asset. Funds are checked when it runs.
+func validateTransfer(ctx context.Context, accountRepo repository.AccountRepository, t *model.Transaction) error {
+    from, err := accountRepo.GetByID(ctx, t.SourceAccountID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return ErrSourceAccountNotFound
+        }
+        return fmt.Errorf("get source account: %w", err)
+    }
+    to, err := accountRepo.GetByID(ctx, t.DestinationAccountID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return ErrDestinationAccountNotFound
+        }
+        return fmt.Errorf("get destination account: %w", err)
+    }
+
+    if from.Status == model.AccountStatusClosed || to.Status == model.AccountStatusClosed {
+        return ErrAccountClosed
+    }
+    if t.AssetCode != "" && t.AssetCode != from.AssetCode {
+        return ErrAssetMismatch
+    }
+    if from.AssetCode != to.AssetCode && !t.Convert {
+        return ErrCrossAssetTransfer
+    }
+    t.AssetCode = from.AssetCode
+
+    return validateAmount(t.Amount, t.AssetCode)
+}
+
+func (s *ScheduledTransferService) GetScheduledTransfer(ctx context.Context, id int) *TransferResult {
+    st, err := s.repo.GetByID(ctx, id)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return scheduledFailure(ErrScheduledTransferNotFound)
+        }
+        return scheduledFailure(fmt.Errorf("get scheduled transfer: %w", err))
+    }
+    if err := authorizeRecord(ctx, s.accountRepo, PermReadAccount, st.SourceAccountID, ErrScheduledTransferNotFound); err != nil {
+        return scheduledFailure(err)
+    }
+
+    return &TransferResult{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Scheduled transfer retrieved successfully",
+        Data:    st,
+    }
+}
+
+// Cancel stops a scheduled transfer from running. Executed, failed and
+// cancelled transfers cannot be cancelled.
+func (s *ScheduledTransferService) Cancel(ctx context.Context, id int) *TransferResult {
+    log := middleware.GetLogger()
+
+    current, err := s.repo.GetByID(ctx, id)
+    if err == sql.ErrNoRows {
+        return scheduledFailure(ErrScheduledTransferNotFound)
+    }
+    if err != nil {
+        return scheduledFailure(err)
+    }
+    if err := authorizeRecord(ctx, s.accountRepo, PermDebitAccount, current.SourceAccountID, ErrScheduledTransferNotFound); err != nil {
+        return scheduledFailure(err)
+    }
+
+    st, err := s.repo.Cancel(ctx, id)
+    if err == sql.ErrNoRows {
+        // Tell a missing transfer apart from one that already left the scheduled state
+        if _, getErr := s.repo.GetByID(ctx, id); getErr == sql.ErrNoRows {
+            err = ErrScheduledTransferNotFound
+        } else if getErr == nil {
+            err = ErrNotScheduled
+        }
+    }
+    if err != nil {
+        return scheduledFailure(err)
+    }
+
+    log.Info("Scheduled transfer cancelled",
+        zap.Int("scheduled_transfer_id", st.ID),
+    )
+
+    return &TransferResult{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Scheduled transfer cancelled successfully",
+        Data:    st,
+    }
+}
+
+// RunWorker executes due transfers every interval until ctx is cancelled. Any
+// number of service instances can run a worker against the same database.
+func (s *ScheduledTransferService) RunWorker(ctx context.Context, interval time.Duration) {
+    runWorker(ctx, "Scheduled transfer", interval, s.ExecuteDue)
+}
+
+// runWorker calls executeDue every interval until ctx is cancelled, logging
+// failures under name
+func runWorker(ctx context.Context, name string, interval time.Duration, executeDue func(context.Context) (int, error)) {
+    log := middleware.GetLogger()
+
+    log.Info(name+" worker started",
+        zap.Duration("interval", interval),
+    )
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            log.Info(name + " worker stopped")
+            return
+        case <-ticker.C:
+            if _, err := executeDue(ctx); err != nil && ctx.Err() == nil {
+                log.Error(name+" worker failed",
+                    zap.Error(err),
+                )
+            }
+        }
+    }
+}
+
+// ExecuteDue executes transfers whose execute_at has passed, one unit of work
+// each, and returns how many it processed
+func (s *ScheduledTransferService) ExecuteDue(ctx context.Context) (int, error) {
+    processed := 0
+    for processed < schedulerBatchSize {
+        found, err := s.executeNext(ctx)
+        if err != nil || !found {
+            return processed, err
+        }
+        processed++
+    }
+    return processed, nil
+}
+
+// executeNext claims the oldest due transfer and executes it with the same
+// unit of work, checks and retries as TransactionService.Transfer. The claim
+// and the executed status commit together with the transfer. A transfer
+// rejected by the checks is marked failed; one that hit a database problem
+// stays scheduled and is retried later, until it has used up its attempts. It
+// reports false when nothing is due.
+func (s *ScheduledTransferService) executeNext(ctx context.Context) (bool, error) {
+    log := middleware.GetLogger()
+
+    var claimed *model.ScheduledTransfer
+    var loggedTx *model.Transaction
+    err := retryTx(ctx, func() error {
+        return s.uow.Do(ctx, &sql.TxOptions{
+            Isolation: sql.LevelSerializable,
+        }, func(tx *sql.Tx) error {
+            due, err := s.repo.ClaimDueWithTx(ctx, tx, time.Now(), 1)
+            if err != nil {
+                return fmt.Errorf("claim due transfers: %w", err)
+            }
+            if len(due) == 0 {
+                claimed = nil
+                return nil
+            }
+            claimed = due[0]
+
+            loggedTx, err = s.transactions.transfer(ctx, tx, claimed.Transfer())
+            if err != nil {
+                return err
+            }
+            return s.repo.MarkExecutedWithTx(ctx, tx, claimed.ID, loggedTx.ID)
+        })
+    })
+    if err == nil {
+        if claimed == nil {
+            return false, nil
+        }
+        log.Info("Scheduled transfer executed",
+            zap.Int("scheduled_transfer_id", claimed.ID),
+            zap.Int("transaction_id", loggedTx.ID),
+        )
+        return true, nil
+    }
+    if claimed == nil {
+        return false, err
+    }
+
+    result := transferFailure(err)
+    if attempt := claimed.Attempts + 1; result.Status >= http.StatusInternalServerError && attempt < scheduledMaxAttempts {
+        // Back off so that later due transfers run in the meantime
+        next := time.Now().Add(scheduledRetryDelay << (attempt - 1))
+        log.Error("Scheduled transfer hit an error; will retry",
+            zap.Int("scheduled_transfer_id", claimed.ID),
+            zap.Int("attempt", attempt),
+            zap.Time("next_attempt_at", next),
+            zap.Error(err),
+        )
+        err = s.uow.Do(ctx, nil, func(tx *sql.Tx) error {
+            return s.repo.MarkRetryWithTx(ctx, tx, claimed.ID, result.Message, next)
+        })
+        if err != nil {
+            return false, fmt.Errorf("mark scheduled transfer %d for retry: %w", claimed.ID, err)
+        }
+        return true, nil
+    }
+
+    log.Warn("Scheduled transfer failed",
+        zap.Int("scheduled_transfer_id", claimed.ID),
+        zap.String("reason", result.Message),
+        zap.Error(err),
+    )
+    err = s.uow.Do(ctx, nil, func(tx *sql.Tx) error {
+        return s.repo.MarkFailedWithTx(ctx, tx, claimed.ID, result.Message)
+    })
+    if err != nil {
+        return false, fmt.Errorf("mark scheduled transfer %d failed: %w", claimed.ID, err)
+    }
+    return true, nil
+}
+
+// scheduledFailure maps a scheduled transfer error to a result
+func scheduledFailure(err error) *TransferResult {
+    switch {
+    case errors.Is(err, ErrScheduledTransferNotFound):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusNotFound,
+            Message: "Scheduled transfer not found",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrNotScheduled):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusConflict,
+            Message: "Transfer has already been executed, failed or been cancelled",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrExecuteAtRequired):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "execute_at must be a future time",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+    return transferFailure(err)
+}
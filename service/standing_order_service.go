@@ -0,0 +1,520 @@
+package service
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "net/http"
+    "time"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "transfer-service/repository"
+    "go.uber.org/zap"
+)
+
+// MaxStandingOrderRetries bounds how often one occurrence is retried
+const MaxStandingOrderRetries = 10
+
+// DefaultRetryInterval is used when an order allows retries without saying how far apart
+const DefaultRetryInterval = time.Hour
+
+var ErrStandingOrderNotFound = errors.New("standing order not found")
+var ErrStandingOrderNotActive = errors.New("standing order is not active")
+var ErrStandingOrderNotPaused = errors.New("standing order is not paused")
+var ErrStandingOrderFinished = errors.New("standing order is already completed or cancelled")
+var ErrInvalidRetryPolicy = errors.New("invalid standing order retry policy")
+
+type StandingOrderService struct {
+    repo            repository.StandingOrderRepository
+    accountRepo     repository.AccountRepository
+    idempotencyRepo repository.IdempotencyRepository
+    transactions    *TransactionService
+    uow             repository.UnitOfWork
+}
+
+func NewStandingOrderService(repo repository.StandingOrderRepository, accountRepo repository.AccountRepository, idempotencyRepo repository.IdempotencyRepository, transactions *TransactionService, uow repository.UnitOfWork) *StandingOrderService {
+    return &StandingOrderService{
+        repo:            repo,
+        accountRepo:     accountRepo,
+        idempotencyRepo: idempotencyRepo,
+        transactions:    transactions,
+        uow:             uow,
+    }
+}
+
+func (s *StandingOrderService) Create(ctx context.Context, o model.StandingOrder) *TransferResult {
+    return s.CreateIdempotent(ctx, o, nil)
+}
+
+// CreateIdempotent stores a recurring transfer, once per idempotency key. The
+// schedule, accounts and amount are checked now; funds are checked on each run.
+func (s *StandingOrderService) CreateIdempotent(ctx context.Context, o model.StandingOrder, key *model.IdempotencyKey) *TransferResult {
+    log := middleware.GetLogger()
+
+    log.Info("Creating standing order",
+        zap.Int("source_account_id", o.SourceAccountID),
+        zap.Int("destination_account_id", o.DestinationAccountID),
+        zap.String("amount", o.Amount.String()),
+        zap.String("frequency", o.Frequency),
+    )
+
+    if err := resolveAccounts(ctx, s.accountRepo, &o.SourceAccount, &o.DestinationAccount, &o.SourceAccountID, &o.DestinationAccountID); err != nil {
+        return standingOrderFailure(err)
+    }
+    if o.SourceAccountID == o.DestinationAccountID {
+        return standingOrderFailure(ErrSameAccount)
+    }
+    if err := prepareSchedule(&o, time.Now()); err != nil {
+        return standingOrderFailure(err)
+    }
+    if err := authorizeAccountID(ctx, s.accountRepo, PermDebitAccount, o.SourceAccountID); err != nil {
+        return standingOrderFailure(err)
+    }
+
+    var result *TransferResult
+    var created *model.StandingOrder
+    err := retryTx(ctx, func() error {
+        return s.uow.Do(ctx, nil, func(tx *sql.Tx) error {
+            if key != nil {
+                replayed, err := replayIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeStandingOrder, key, &model.StandingOrder{})
+                if err != nil || replayed != nil {
+                    result = replayed
+                    return err
+                }
+            }
+
+            t := o.Transfer()
+            if err := validateTransfer(ctx, s.accountRepo, &t); err != nil {
+                return err
+            }
+            o.AssetCode = t.AssetCode
+
+            var err error
+            created, err = s.repo.CreateWithTx(ctx, tx, o)
+            if err != nil {
+                return fmt.Errorf("create standing order: %w", err)
+            }
+            result = &TransferResult{
+                Success: true,
+                Status:  http.StatusCreated,
+                Message: "Standing order created successfully",
+                Data:    created,
+            }
+
+            if key != nil {
+                return storeIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeStandingOrder, key, result)
+            }
+            return nil
+        })
+    })
+    if err != nil {
+        return standingOrderFailure(err)
+    }
+
+    if result.Replayed {
+        log.Info("Replayed idempotent standing order",
+            zap.String("idempotency_key", key.Key),
+        )
+        return result
+    }
+
+    log.Info("Standing order created successfully",
+        zap.Int("standing_order_id", created.ID),
+        zap.Timep("next_run_at", created.NextRunAt),
+    )
+
+    return result
+}
+
+// prepareSchedule checks the schedule and retry policy of a new order and
+// sets its first occurrence. Orders start now unless start_at is given.
+func prepareSchedule(o *model.StandingOrder, now time.Time) error {
+    if o.StartAt.IsZero() {
+        o.StartAt = now
+    }
+    if o.EndAt == nil && o.MaxOccurrences == nil {
+        return fmt.Errorf("%w: end_at or max_occurrences is required", ErrInvalidSchedule)
+    }
+    if o.MaxOccurrences != nil && *o.MaxOccurrences < 1 {
+        return fmt.Errorf("%w: max_occurrences must be positive", ErrInvalidSchedule)
+    }
+    if o.Frequency != model.FrequencyMonthly {
+        o.DayOfMonth = 0
+    }
+    if o.Frequency != model.FrequencyCron {
+        o.Cron = ""
+    }
+
+    if o.MaxRetries < 0 || o.MaxRetries > MaxStandingOrderRetries {
+        return fmt.Errorf("%w: max_retries must be between 0 and %d", ErrInvalidRetryPolicy, MaxStandingOrderRetries)
+    }
+    if o.RetryIntervalSeconds < 0 {
+        return fmt.Errorf("%w: retry_interval_seconds cannot be negative", ErrInvalidRetryPolicy)
+    }
+    if o.MaxRetries > 0 && o.RetryIntervalSeconds == 0 {
+        o.RetryIntervalSeconds = int(DefaultRetryInterval / time.Second)
+    }
+
+    rec, err := newRecurrence(*o)
+    if err != nil {
+        return err
+    }
+    o.Status = model.StandingOrderActive
+    o.Occurrences = 0
+    o.FailedAttempts = 0
+    scheduleAfter(o, rec, o.StartAt.Add(-time.Nanosecond))
+    if o.Status != model.StandingOrderActive {
+        return fmt.Errorf("%w: the schedule has no occurrences", ErrInvalidSchedule)
+    }
+    return nil
+}
+
+// scheduleAfter moves o to its first occurrence after after, or completes it
+// when its end date or occurrence count is reached
+func scheduleAfter(o *model.StandingOrder, rec recurrence, after time.Time) {
+    next := rec.next(after)
+    if next.IsZero() || (o.EndAt != nil && next.After(*o.EndAt)) ||
+        (o.MaxOccurrences != nil && o.Occurrences >= *o.MaxOccurrences) {
+        o.Status = model.StandingOrderCompleted
+        o.CurrentRunAt = nil
+        o.NextRunAt = nil
+        return
+    }
+    o.CurrentRunAt = &next
+    o.NextRunAt = &next
+}
+
+// advance counts the pending occurrence of o and moves it to the next one
+func advance(o *model.StandingOrder) error {
+    rec, err := newRecurrence(*o)
+    if err != nil {
+        return err
+    }
+    o.Occurrences++
+    o.FailedAttempts = 0
+    scheduleAfter(o, rec, *o.CurrentRunAt)
+    return nil
+}
+
+func (s *StandingOrderService) GetStandingOrder(ctx context.Context, id int) *TransferResult {
+    o, err := s.repo.GetByID(ctx, id)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return standingOrderFailure(ErrStandingOrderNotFound)
+        }
+        return standingOrderFailure(fmt.Errorf("get standing order: %w", err))
+    }
+    if err := authorizeRecord(ctx, s.accountRepo, PermReadAccount, o.SourceAccountID, ErrStandingOrderNotFound); err != nil {
+        return standingOrderFailure(err)
+    }
+
+    return &TransferResult{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Standing order retrieved successfully",
+        Data:    o,
+    }
+}
+
+// GetRuns lists every execution attempt of an order, failed ones included
+func (s *StandingOrderService) GetRuns(ctx context.Context, id int) *TransferResult {
+    o, err := s.repo.GetByID(ctx, id)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return standingOrderFailure(ErrStandingOrderNotFound)
+        }
+        return standingOrderFailure(fmt.Errorf("get standing order: %w", err))
+    }
+    if err := authorizeRecord(ctx, s.accountRepo, PermReadAccount, o.SourceAccountID, ErrStandingOrderNotFound); err != nil {
+        return standingOrderFailure(err)
+    }
+    runs, err := s.repo.GetRuns(ctx, id)
+    if err != nil {
+        return standingOrderFailure(fmt.Errorf("get standing order runs: %w", err))
+    }
+
+    return &TransferResult{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Standing order runs retrieved successfully",
+        Data:    runs,
+    }
+}
+
+// Pause stops an active order from running until it is resumed
+func (s *StandingOrderService) Pause(ctx context.Context, id int) *TransferResult {
+    return s.update(ctx, id, "Standing order paused successfully", func(o *model.StandingOrder) error {
+        if o.Status != model.StandingOrderActive {
+            return ErrStandingOrderNotActive
+        }
+        o.Status = model.StandingOrderPaused
+        return nil
+    })
+}
+
+// Resume reactivates a paused order. Occurrences missed while paused are
+// skipped, not counted, and a pending retry starts over.
+func (s *StandingOrderService) Resume(ctx context.Context, id int) *TransferResult {
+    return s.update(ctx, id, "Standing order resumed successfully", func(o *model.StandingOrder) error {
+        if o.Status != model.StandingOrderPaused {
+            return ErrStandingOrderNotPaused
+        }
+        rec, err := newRecurrence(*o)
+        if err != nil {
+            return err
+        }
+        o.Status = model.StandingOrderActive
+        o.FailedAttempts = 0
+        scheduleAfter(o, rec, time.Now().Add(-time.Nanosecond))
+        return nil
+    })
+}
+
+// Cancel ends an active or paused order for good
+func (s *StandingOrderService) Cancel(ctx context.Context, id int) *TransferResult {
+    return s.update(ctx, id, "Standing order cancelled successfully", func(o *model.StandingOrder) error {
+        if o.Status != model.StandingOrderActive && o.Status != model.StandingOrderPaused {
+            return ErrStandingOrderFinished
+        }
+        o.Status = model.StandingOrderCancelled
+        o.CurrentRunAt = nil
+        o.NextRunAt = nil
+        return nil
+    })
+}
+
+// update applies change to a locked order. An order being executed stays
+// locked until the worker commits, so a change never interleaves with a run.
+func (s *StandingOrderService) update(ctx context.Context, id int, message string, change func(o *model.StandingOrder) error) *TransferResult {
+    log := middleware.GetLogger()
+
+    var updated *model.StandingOrder
+    err := s.uow.Do(ctx, nil, func(tx *sql.Tx) error {
+        o, err := s.repo.GetByIDWithLock(ctx, tx, id)
+        if err != nil {
+            if err == sql.ErrNoRows {
+                return ErrStandingOrderNotFound
+            }
+            return fmt.Errorf("lock standing order %d: %w", id, err)
+        }
+        if err := authorizeRecord(ctx, s.accountRepo, PermDebitAccount, o.SourceAccountID, ErrStandingOrderNotFound); err != nil {
+            return err
+        }
+        if err := change(o); err != nil {
+            return err
+        }
+        updated = o
+        return s.repo.UpdateWithTx(ctx, tx, o)
+    })
+    if err != nil {
+        return standingOrderFailure(err)
+    }
+
+    log.Info(message,
+        zap.Int("standing_order_id", updated.ID),
+        zap.String("status", updated.Status),
+    )
+
+    return &TransferResult{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: message,
+        Data:    updated,
+    }
+}
+
+// RunWorker executes due occurrences every interval until ctx is cancelled.
+// Any number of service instances can run a worker against the same database.
+func (s *StandingOrderService) RunWorker(ctx context.Context, interval time.Duration) {
+    runWorker(ctx, "Standing order", interval, s.ExecuteDue)
+}
+
+// ExecuteDue executes orders whose next run has passed, one unit of work each,
+// and returns how many it processed
+func (s *StandingOrderService) ExecuteDue(ctx context.Context) (int, error) {
+    processed := 0
+    for processed < schedulerBatchSize {
+        found, err := s.executeNext(ctx)
+        if err != nil || !found {
+            return processed, err
+        }
+        processed++
+    }
+    return processed, nil
+}
+
+// executeNext claims the most overdue order and runs its pending occurrence as
+// a normal transfer. The claim, the run record and the move to the next
+// occurrence commit together with the transfer. A transfer rejected by the
+// checks is recorded as a failed run and retried or skipped per the order's
+// policy; one that hit a database problem is recorded too and retried with
+// backoff, until it has used up its attempts. It reports false when nothing
+// is due.
+func (s *StandingOrderService) executeNext(ctx context.Context) (bool, error) {
+    log := middleware.GetLogger()
+
+    var claimed *model.StandingOrder
+    var loggedTx *model.Transaction
+    err := retryTx(ctx, func() error {
+        return s.uow.Do(ctx, &sql.TxOptions{
+            Isolation: sql.LevelSerializable,
+        }, func(tx *sql.Tx) error {
+            due, err := s.repo.ClaimDueWithTx(ctx, tx, time.Now(), 1)
+            if err != nil {
+                return fmt.Errorf("claim due standing orders: %w", err)
+            }
+            if len(due) == 0 {
+                claimed = nil
+                return nil
+            }
+            claimed = due[0]
+
+            loggedTx, err = s.transactions.transfer(ctx, tx, claimed.Transfer())
+            if err != nil {
+                return err
+            }
+            order := *claimed
+            transactionID := loggedTx.ID
+            err = s.repo.CreateRunWithTx(ctx, tx, model.StandingOrderRun{
+                StandingOrderID: order.ID,
+                ScheduledFor:    *order.CurrentRunAt,
+                Attempt:         order.FailedAttempts + 1,
+                Status:          model.RunStatusSucceeded,
+                TransactionID:   &transactionID,
+            })
+            if err != nil {
+                return fmt.Errorf("record standing order run: %w", err)
+            }
+            if err := advance(&order); err != nil {
+                return err
+            }
+            return s.repo.UpdateWithTx(ctx, tx, &order)
+        })
+    })
+    if err == nil {
+        if claimed == nil {
+            return false, nil
+        }
+        log.Info("Standing order executed",
+            zap.Int("standing_order_id", claimed.ID),
+            zap.Int("transaction_id", loggedTx.ID),
+        )
+        return true, nil
+    }
+    if claimed == nil {
+        return false, err
+    }
+
+    result := transferFailure(err)
+    transient := result.Status >= http.StatusInternalServerError
+    if transient {
+        log.Error("Standing order run hit an error",
+            zap.Int("standing_order_id", claimed.ID),
+            zap.Int("attempt", claimed.FailedAttempts+1),
+            zap.Error(err),
+        )
+    } else {
+        log.Warn("Standing order run failed",
+            zap.Int("standing_order_id", claimed.ID),
+            zap.Int("attempt", claimed.FailedAttempts+1),
+            zap.String("reason", result.Message),
+        )
+    }
+    err = s.uow.Do(ctx, nil, func(tx *sql.Tx) error {
+        return s.recordFailure(ctx, tx, claimed, result.Message, transient)
+    })
+    if err != nil {
+        return false, fmt.Errorf("record failed run of standing order %d: %w", claimed.ID, err)
+    }
+    return true, nil
+}
+
+// recordFailure stores a failed run of claimed and schedules a retry, or skips
+// the occurrence once its retries are used up. A rejected transfer is retried
+// per the order's policy; one that hit a database problem is retried like a
+// scheduled transfer, with backoff, so that later due orders run meanwhile.
+// Orders paused, cancelled or run by another worker since the claim are left
+// alone.
+func (s *StandingOrderService) recordFailure(ctx context.Context, tx *sql.Tx, claimed *model.StandingOrder, reason string, transient bool) error {
+    o, err := s.repo.GetByIDWithLock(ctx, tx, claimed.ID)
+    if err != nil {
+        return err
+    }
+    if o.Status != model.StandingOrderActive || o.NextRunAt == nil || !o.NextRunAt.Equal(*claimed.NextRunAt) {
+        return nil
+    }
+
+    err = s.repo.CreateRunWithTx(ctx, tx, model.StandingOrderRun{
+        StandingOrderID: o.ID,
+        ScheduledFor:    *o.CurrentRunAt,
+        Attempt:         o.FailedAttempts + 1,
+        Status:          model.RunStatusFailed,
+        FailureReason:   reason,
+    })
+    if err != nil {
+        return err
+    }
+
+    o.FailedAttempts++
+    switch {
+    case transient && o.FailedAttempts < scheduledMaxAttempts:
+        retryAt := time.Now().Add(scheduledRetryDelay << (o.FailedAttempts - 1))
+        o.NextRunAt = &retryAt
+    case !transient && o.FailedAttempts <= o.MaxRetries:
+        retryAt := time.Now().Add(time.Duration(o.RetryIntervalSeconds) * time.Second)
+        o.NextRunAt = &retryAt
+    default:
+        if err := advance(o); err != nil {
+            return err
+        }
+    }
+    return s.repo.UpdateWithTx(ctx, tx, o)
+}
+
+// standingOrderFailure maps a standing order error to a result
+func standingOrderFailure(err error) *TransferResult {
+    switch {
+    case errors.Is(err, ErrStandingOrderNotFound):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusNotFound,
+            Message: "Standing order not found",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrStandingOrderNotActive):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusConflict,
+            Message: "Only an active standing order can be paused",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrStandingOrderNotPaused):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusConflict,
+            Message: "Only a paused standing order can be resumed",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrStandingOrderFinished):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusConflict,
+            Message: "Standing order has already completed or been cancelled",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrInvalidSchedule), errors.Is(err, ErrInvalidRetryPolicy):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Invalid standing order",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    }
+    return transferFailure(err)
+}
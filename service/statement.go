@@ -0,0 +1,184 @@
+package service
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "net/http"
+    "time"
+    "transfer-service/middleware"
+    "transfer-service/model"
+    "go.uber.org/zap"
+)
+
+// MaxStatementRange is the longest period one statement may cover
+const MaxStatementRange = 366 * 24 * time.Hour
+
+var ErrInvalidStatementRange = errors.New("invalid statement range")
+
+// withEntries returns copies of an account's transactions with the entry of
+// each set from the account's postings. Transactions without postings for
+// the account are returned without an entry.
+func (s *TransactionService) withEntries(ctx context.Context, accountID int, transactions []*model.Transaction) ([]*model.Transaction, error) {
+    if len(transactions) == 0 {
+        return transactions, nil
+    }
+    ids := make([]int, len(transactions))
+    for i, t := range transactions {
+        ids[i] = t.ID
+    }
+    postings, err := s.transactionRepo.GetAccountPostings(ctx, accountID, ids)
+    if err != nil {
+        return nil, err
+    }
+    entries := accountEntries(accountID, postings)
+
+    result := make([]*model.Transaction, len(transactions))
+    for i, t := range transactions {
+        withEntry := *t
+        if entry, ok := entries[t.ID]; ok {
+            entry.CounterpartyAccountID = counterparty(t, accountID)
+            withEntry.Entry = entry
+        }
+        result[i] = &withEntry
+    }
+    return result, nil
+}
+
+// accountEntries nets an account's postings, in ID order, into one entry per
+// transaction. A transfer that charged a fee debits its source twice.
+func accountEntries(accountID int, postings []*model.Posting) map[int]*model.StatementEntry {
+    entries := make(map[int]*model.StatementEntry)
+    for _, p := range postings {
+        entry, ok := entries[p.TransactionID]
+        if !ok {
+            entry = &model.StatementEntry{AccountID: accountID, AssetCode: p.AssetCode}
+            entries[p.TransactionID] = entry
+        }
+        entry.Amount = entry.Amount.Add(p.Amount)
+        entry.BalanceAfter = p.BalanceAfter
+    }
+    return entries
+}
+
+// counterparty is the other account of a transaction seen from accountID; the
+// fee account's counterparty is the source that paid the fee
+func counterparty(t *model.Transaction, accountID int) int {
+    if t.SourceAccountID == accountID {
+        return t.DestinationAccountID
+    }
+    return t.SourceAccountID
+}
+
+// GetAccountStatement returns the transactions that changed an account from
+// from up to to, oldest first, with its opening and closing balances. The
+// entries are built from the account's postings, so they include fees the
+// account received and always sum to the closing less the opening balance.
+func (s *TransactionService) GetAccountStatement(ctx context.Context, accountID int, from, to time.Time) *TransferResult {
+    log := middleware.GetLogger()
+
+    if !from.Before(to) || to.Sub(from) > MaxStatementRange {
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: fmt.Sprintf("Statement range must be positive and at most %d days", int(MaxStatementRange.Hours()/24)),
+            Error:   ErrInvalidStatementRange.Error(),
+            Code:    errorCode(ErrInvalidStatementRange),
+        }
+    }
+
+    account, err := s.accountRepo.GetByID(ctx, accountID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return &TransferResult{
+                Success: false,
+                Status:  http.StatusNotFound,
+                Message: "Account not found",
+                Error:   ErrAccountNotFound.Error(),
+                Code:    errorCode(ErrAccountNotFound),
+            }
+        }
+        return statementFailure(accountID, err)
+    }
+    if err := authorizeAccount(ctx, PermReadAccount, account); err != nil {
+        return transferFailure(err)
+    }
+
+    statement := &model.Statement{
+        AccountID: account.ID,
+        AssetCode: account.AssetCode,
+        From:      from,
+        To:        to,
+        Entries:   []*model.Transaction{},
+    }
+    if statement.OpeningBalance, err = s.transactionRepo.GetBalanceBefore(ctx, accountID, from); err != nil {
+        return statementFailure(accountID, err)
+    }
+    postings, err := s.transactionRepo.GetAccountPostingsBetween(ctx, accountID, from, to)
+    if err != nil {
+        return statementFailure(accountID, err)
+    }
+
+    statement.ClosingBalance = statement.OpeningBalance
+    if len(postings) > 0 {
+        statement.ClosingBalance = postings[len(postings)-1].BalanceAfter
+
+        var ids []int
+        seen := make(map[int]bool)
+        for _, p := range postings {
+            if !seen[p.TransactionID] {
+                seen[p.TransactionID] = true
+                ids = append(ids, p.TransactionID)
+            }
+        }
+        transactions, err := s.transactionRepo.GetByIDs(ctx, ids)
+        if err != nil {
+            return statementFailure(accountID, err)
+        }
+        byID := make(map[int]*model.Transaction, len(transactions))
+        for _, t := range transactions {
+            byID[t.ID] = t
+        }
+        entries := accountEntries(accountID, postings)
+        for _, id := range ids {
+            t, ok := byID[id]
+            if !ok {
+                continue
+            }
+            entry := *t
+            entry.Entry = entries[id]
+            entry.Entry.CounterpartyAccountID = counterparty(t, accountID)
+            statement.Entries = append(statement.Entries, &entry)
+        }
+    }
+
+    log.Info("Account statement generated",
+        zap.Int("account_id", accountID),
+        zap.Time("from", from),
+        zap.Time("to", to),
+        zap.Int("entries", len(statement.Entries)),
+    )
+
+    return &TransferResult{
+        Success: true,
+        Status:  http.StatusOK,
+        Message: "Account statement retrieved successfully",
+        Data:    statement,
+    }
+}
+
+// statementFailure is the result of a database error building a statement
+func statementFailure(accountID int, err error) *TransferResult {
+    middleware.GetLogger().Error("Account statement failed",
+        zap.Int("account_id", accountID),
+        zap.Error(err),
+    )
+    return &TransferResult{
+        Success: false,
+        Status:  http.StatusInternalServerError,
+        Message: "Failed to retrieve account statement",
+        Error:   err.Error(),
+        Code:    errorCode(err),
+    }
+}
@@ -3,61 +3,83 @@ package service
 import (
     "context"
     "errors"
+    "fmt"
     "transfer-service/model"
     "transfer-service/repository"
     "database/sql"
     "net/http"
+    "sort"
+    "time"
     "transfer-service/middleware"
     "go.uber.org/zap"
+    "github.com/shopspring/decimal"
 )
 
 type TransactionService struct {
     accountRepo     repository.AccountRepository
     transactionRepo repository.TransactionRepository
+    idempotencyRepo repository.IdempotencyRepository
+    fxRepo          repository.FXRepository
+    holdRepo        repository.HoldRepository
+    limitRepo       repository.LimitRepository
+    feeRepo         repository.FeeRepository
+    uow             repository.UnitOfWork
+    holdTTL         time.Duration
 }
 
 var ErrInsufficientBalance = errors.New("insufficient balance")
 var ErrSourceAccountNotFound = errors.New("source account not found")
 var ErrDestinationAccountNotFound = errors.New("destination account not found")
+var ErrUnbalancedPostings = errors.New("unbalanced postings")
+var ErrAssetMismatch = errors.New("asset does not match source account")
+var ErrCrossAssetTransfer = errors.New("cross-asset transfer requires conversion")
+var ErrSameAccount = errors.New("same accounts")
+var ErrAccountFrozen = errors.New("account cannot send funds")
+var ErrAccountClosed = errors.New("account is closed")
 
-func NewTransactionService(accountRepo repository.AccountRepository, transactionRepo repository.TransactionRepository) *TransactionService {
+func NewTransactionService(accountRepo repository.AccountRepository, transactionRepo repository.TransactionRepository, idempotencyRepo repository.IdempotencyRepository, fxRepo repository.FXRepository, holdRepo repository.HoldRepository, limitRepo repository.LimitRepository, feeRepo repository.FeeRepository, uow repository.UnitOfWork) *TransactionService {
     return &TransactionService{
         accountRepo:     accountRepo,
         transactionRepo: transactionRepo,
+        idempotencyRepo: idempotencyRepo,
+        fxRepo:          fxRepo,
+        holdRepo:        holdRepo,
+        limitRepo:       limitRepo,
+        feeRepo:         feeRepo,
+        uow:             uow,
+        holdTTL:         DefaultHoldTTL,
     }
 }
 
 // TransferResult represents the result of a transfer operation
-type TransferResult struct {
-    Success bool
-    Status  int
-    Message string
-    Error   string
-    Data    interface{}
+type TransferResult = Result
+
+// TransferReceipt is the data returned for a completed transfer
+type TransferReceipt struct {
+    Message     string             `json:"message"`
+    Transaction *model.Transaction `json:"transaction"`
 }
 
 func (s *TransactionService) Transfer(ctx context.Context, t model.Transaction) *TransferResult {
+    return s.TransferIdempotent(ctx, t, nil)
+}
+
+// TransferIdempotent performs a transfer once per idempotency key. A repeated key
+// with the same request replays the original response; a nil key disables the check.
+func (s *TransactionService) TransferIdempotent(ctx context.Context, t model.Transaction, key *model.IdempotencyKey) *TransferResult {
     log := middleware.GetLogger()
     
-    // Validate amount precision (5 decimal places)
-    if !isValidPrecision(t.Amount, 5) {
-        log.Warn("Transfer failed - invalid amount precision",
-            zap.String("amount", t.Amount.String()),
-        )
-        return &TransferResult{
-            Success: false,
-            Status:  http.StatusBadRequest,
-            Message: "Amount must have at most 5 decimal places",
-            Error:   "invalid precision",
-        }
-    }
-    
     log.Info("Starting transfer",
         zap.Int("source_account_id", t.SourceAccountID),
         zap.Int("destination_account_id", t.DestinationAccountID),
-        zap.Float64("amount", t.Amount.Round(5).InexactFloat64()),
+        zap.String("amount", t.Amount.String()),
     )
     
+    clearDerivedFields(&t)
+    if err := resolveTransferAccounts(ctx, s.accountRepo, &t); err != nil {
+        return transferFailure(err)
+    }
+
     // Check if source and destination accounts are the same
     if t.SourceAccountID == t.DestinationAccountID {
         log.Warn("Transfer rejected - same source and destination accounts",
@@ -68,190 +90,715 @@ func (s *TransactionService) Transfer(ctx context.Context, t model.Transaction)
             Status:  http.StatusBadRequest,
             Message: "Source and destination accounts are the same",
             Error:   "same accounts",
+            Code:    CodeSameAccount,
         }
     }
 
-    // Start a database transaction
-    tx, err := s.accountRepo.GetDB().BeginTx(ctx, &sql.TxOptions{
-        Isolation: sql.LevelSerializable, // Highest isolation level for financial transactions
+    // Authorize before a stored response can be replayed; the transfer
+    // checks again on the locked account
+    if err := authorizeAccountID(ctx, s.accountRepo, PermDebitAccount, t.SourceAccountID); err != nil {
+        return transferFailure(err)
+    }
+
+    // Balance updates, the transaction log and its postings commit or roll back as one unit
+    // Serialization failures and deadlocks rerun the whole unit of work
+    var result *TransferResult
+    var loggedTx *model.Transaction
+    err := retryTx(ctx, func() error {
+        return s.uow.Do(ctx, &sql.TxOptions{
+            Isolation: sql.LevelSerializable, // Highest isolation level for financial transactions
+        }, func(tx *sql.Tx) error {
+            if key != nil {
+                replayed, err := replayIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeTransfer, key, &TransferReceipt{})
+                if err != nil || replayed != nil {
+                    result = replayed
+                    return err
+                }
+            }
+
+            var err error
+            loggedTx, err = s.transfer(ctx, tx, t)
+            if err != nil {
+                return err
+            }
+            result = &TransferResult{
+                Success: true,
+                Status:  http.StatusOK,
+                Message: "Transfer completed successfully",
+                Data: &TransferReceipt{
+                    Message:     "Transfer completed successfully",
+                    Transaction: loggedTx,
+                },
+            }
+
+            // The key and its response commit together with the transfer
+            if key != nil {
+                return storeIdempotent(ctx, tx, s.idempotencyRepo, idempotencyScopeTransfer, key, result)
+            }
+            return nil
+        })
     })
     if err != nil {
-        log.Error("Failed to start database transaction",
-            zap.Error(err),
+        return transferFailure(err)
+    }
+
+    if result.Replayed {
+        log.Info("Replayed idempotent transfer",
+            zap.String("idempotency_key", key.Key),
         )
-        return &TransferResult{
-            Success: false,
-            Status:  http.StatusInternalServerError,
-            Message: "Failed to start transaction",
-            Error:   err.Error(),
-        }
+        return result
     }
-    
-    // Ensure transaction is rolled back on error
-    defer func() {
+
+    log.Info("Transfer completed successfully",
+        zap.Int("transaction_id", loggedTx.ID),
+        zap.Int("source_account_id", t.SourceAccountID),
+        zap.Int("destination_account_id", t.DestinationAccountID),
+        zap.String("asset_code", loggedTx.AssetCode),
+        zap.Float64("amount", formatDecimal(loggedTx.Amount, loggedTx.AssetCode)),
+    )
+
+    return result
+}
+
+// transfer moves t.Amount from the source to the destination account inside tx
+// and logs the transaction with its postings. It must run inside a unit of work;
+// fields derived by the service must already be cleared or set by the caller.
+func (s *TransactionService) transfer(ctx context.Context, tx *sql.Tx, t model.Transaction) (*model.Transaction, error) {
+    log := middleware.GetLogger()
+
+    // A conversion also moves money through the FX position accounts, which must
+    // be known up front so every account is locked in a single ordered pass
+    ids := []int{t.SourceAccountID, t.DestinationAccountID}
+    var conv *conversion
+    if t.Convert {
+        var err error
+        conv, err = s.prepareConversion(ctx, tx, t)
         if err != nil {
-            tx.Rollback()
+            return nil, err
+        }
+        if conv != nil {
+            ids = append(ids, conv.sourcePositionID, conv.destinationPositionID)
         }
-    }()
+    }
+    schedule, err := s.prepareFee(ctx, tx, t)
+    if err != nil {
+        return nil, err
+    }
+    if schedule != nil {
+        ids = append(ids, schedule.FeeAccountID)
+    }
 
-    // Lock and get source account with FOR UPDATE
-    from, err := s.accountRepo.GetByIDWithLock(ctx, tx, t.SourceAccountID)
+    // Lock all accounts in ascending ID order so opposite transfers cannot deadlock
+    accounts, err := s.lockAccounts(ctx, tx, ids...)
     if err != nil {
-        if err == sql.ErrNoRows {
-            log.Warn("Transfer failed - source account not found",
-                zap.Int("source_account_id", t.SourceAccountID),
+        return nil, err
+    }
+
+    from, ok := accounts[t.SourceAccountID]
+    if !ok {
+        log.Warn("Transfer failed - source account not found",
+            zap.Int("source_account_id", t.SourceAccountID),
+        )
+        return nil, ErrSourceAccountNotFound
+    }
+    // Only the owner of the source account, or an admin, may debit it
+    if err := authorizeAccount(ctx, PermDebitAccount, from); err != nil {
+        log.Warn("Transfer rejected - caller may not debit the source account",
+            zap.Int("source_account_id", from.ID),
+            zap.Error(err),
+        )
+        return nil, err
+    }
+    to, ok := accounts[t.DestinationAccountID]
+    if !ok {
+        log.Warn("Transfer failed - destination account not found",
+            zap.Int("destination_account_id", t.DestinationAccountID),
+        )
+        return nil, ErrDestinationAccountNotFound
+    }
+
+    // The transfer moves the source account's asset; a requested asset must match it
+    if t.AssetCode != "" && t.AssetCode != from.AssetCode {
+        log.Warn("Transfer failed - asset does not match source account",
+            zap.Int("source_account_id", from.ID),
+            zap.String("source_asset_code", from.AssetCode),
+            zap.String("asset_code", t.AssetCode),
+        )
+        return nil, ErrAssetMismatch
+    }
+    t.AssetCode = from.AssetCode
+
+    if err := validateAmount(t.Amount, t.AssetCode); err != nil {
+        log.Warn("Transfer failed - invalid amount",
+            zap.String("asset_code", t.AssetCode),
+            zap.String("amount", t.Amount.String()),
+            zap.Error(err),
+        )
+        return nil, err
+    }
+
+    // Build the double-entry legs: debit the source, credit the destination
+    var postings []model.Posting
+    switch {
+    case from.AssetCode == to.AssetCode:
+        if t.QuoteID != "" {
+            return nil, ErrQuoteMismatch
+        }
+        postings = []model.Posting{
+            {AccountID: from.ID, AssetCode: t.AssetCode, Amount: t.Amount.Neg()},
+            {AccountID: to.ID, AssetCode: t.AssetCode, Amount: t.Amount},
+        }
+    case !t.Convert:
+        log.Warn("Transfer failed - cross-asset transfer without conversion",
+            zap.String("source_asset_code", from.AssetCode),
+            zap.String("destination_asset_code", to.AssetCode),
+        )
+        return nil, ErrCrossAssetTransfer
+    default:
+        postings, err = conv.postings(&t)
+        if err != nil {
+            log.Warn("Transfer failed - conversion rejected",
+                zap.String("amount", t.Amount.String()),
+                zap.Error(err),
             )
-            return &TransferResult{
-                Success: false,
-                Status:  http.StatusNotFound,
-                Message: "Source account not found",
-                Error:   err.Error(),
-            }
+            return nil, err
         }
-        log.Error("Failed to get source account",
-            zap.Int("source_account_id", t.SourceAccountID),
+    }
+
+    // The source pays any fee on top of the amount
+    feePostings, err := chargeFee(accounts, schedule, &t)
+    if err != nil {
+        log.Error("Transfer failed - fee account unavailable",
+            zap.String("asset_code", t.AssetCode),
             zap.Error(err),
         )
-        return &TransferResult{
-            Success: false,
-            Status:  http.StatusInternalServerError,
-            Message: "Failed to get source account",
-            Error:   err.Error(),
+        return nil, err
+    }
+    postings = append(postings, feePostings...)
+
+    if err := checkStatus(accounts, postings); err != nil {
+        log.Warn("Transfer failed - account status",
+            zap.Error(err),
+        )
+        return nil, err
+    }
+    if err := s.checkLimits(ctx, tx, from, t.Amount); err != nil {
+        return nil, err
+    }
+
+    // Check funds of every debited account with locked data
+    if shortID, ok := checkFunds(accounts, postings); !ok {
+        short := accounts[shortID]
+        log.Warn("Transfer failed - insufficient balance",
+            zap.Int("account_id", shortID),
+            zap.Float64("available_balance", formatDecimal(short.AvailableBalance(), short.AssetCode)),
+            zap.Float64("requested_amount", formatDecimal(t.Amount, t.AssetCode)),
+        )
+        if shortID != from.ID {
+            return nil, ErrInsufficientLiquidity
         }
+        return nil, ErrInsufficientBalance
     }
 
-    // Lock and get destination account with FOR UPDATE
-    to, err := s.accountRepo.GetByIDWithLock(ctx, tx, t.DestinationAccountID)
+    loggedTx, err := s.post(ctx, tx, t, postings)
     if err != nil {
-        if err == sql.ErrNoRows {
-            log.Warn("Transfer failed - destination account not found",
-                zap.Int("destination_account_id", t.DestinationAccountID),
+        return nil, err
+    }
+
+    // A locked quote can only be used once
+    if conv != nil && conv.quoteID != "" {
+        if err := s.fxRepo.MarkQuoteUsedWithTx(ctx, tx, conv.quoteID, loggedTx.ID); err != nil {
+            log.Error("Failed to mark quote as used",
+                zap.String("quote_id", conv.quoteID),
+                zap.Error(err),
             )
-            return &TransferResult{
-                Success: false,
-                Status:  http.StatusNotFound,
-                Message: "Destination account not found",
-                Error:   err.Error(),
-            }
+            return nil, fmt.Errorf("mark quote used: %w", err)
         }
-        log.Error("Failed to get destination account",
-            zap.Int("destination_account_id", t.DestinationAccountID),
-            zap.Error(err),
-        )
-        return &TransferResult{
-            Success: false,
-            Status:  http.StatusInternalServerError,
-            Message: "Failed to get destination account",
-            Error:   err.Error(),
+    }
+
+    return loggedTx, nil
+}
+
+// clearDerivedFields resets the linkage and conversion results of a requested
+// transfer; they are set by the service, never by the request
+func clearDerivedFields(t *model.Transaction) {
+    t.Kind = model.TransactionKindTransfer
+    t.ReversalOf = nil
+    t.StandingOrderID = nil
+    t.DestinationAssetCode = ""
+    t.DestinationAmount = nil
+    t.FXRate = nil
+    t.FXRounding = nil
+    t.Fee = nil
+    t.FeeAccountID = nil
+    t.Entry = nil
+}
+
+// checkStatus reports the first account of the postings that is closed, or
+// that is debited while frozen or dormant
+func checkStatus(accounts map[int]*model.Account, postings []model.Posting) error {
+    for _, p := range postings {
+        account := accounts[p.AccountID]
+        if account.Status == model.AccountStatusClosed {
+            return fmt.Errorf("%w: account %d", ErrAccountClosed, account.ID)
+        }
+        if p.Amount.IsNegative() && !account.CanSend() {
+            return fmt.Errorf("%w: account %d is %s", ErrAccountFrozen, account.ID, account.Status)
         }
     }
+    return nil
+}
 
-    // Check balance with locked data
-    if from.Balance.LessThan(t.Amount) {
-        log.Warn("Transfer failed - insufficient balance",
+// checkFunds applies the postings to the locked spendable balances and reports
+// the first account that would go below its overdraft limit
+func checkFunds(accounts map[int]*model.Account, postings []model.Posting) (int, bool) {
+    net := make(map[int]decimal.Decimal)
+    for _, p := range postings {
+        net[p.AccountID] = net[p.AccountID].Add(p.Amount)
+    }
+
+    ids := make([]int, 0, len(net))
+    for id := range net {
+        ids = append(ids, id)
+    }
+    sort.Ints(ids)
+
+    for _, id := range ids {
+        if net[id].IsNegative() && accounts[id].SpendableBalance().Add(net[id]).IsNegative() {
+            return id, false
+        }
+    }
+    return 0, true
+}
+
+// post applies the postings to the account balances, logs t and records the
+// postings against it, all inside tx
+func (s *TransactionService) post(ctx context.Context, tx *sql.Tx, t model.Transaction, postings []model.Posting) (*model.Transaction, error) {
+    log := middleware.GetLogger()
+
+    if !model.PostingsBalanced(postings) {
+        log.Error("Transfer failed - postings do not balance",
             zap.Int("source_account_id", t.SourceAccountID),
-            zap.Float64("current_balance", from.Balance.Round(5).InexactFloat64()),
-            zap.Float64("requested_amount", t.Amount.Round(5).InexactFloat64()),
+            zap.Int("destination_account_id", t.DestinationAccountID),
         )
-        return &TransferResult{
-            Success: false,
-            Status:  http.StatusBadRequest,
-            Message: "Insufficient balance",
-            Error:   "insufficient balance",
+        return nil, ErrUnbalancedPostings
+    }
+
+    // Derive the balances by applying the postings within the transaction
+    applied := make([]*model.Posting, len(postings))
+    for i := range postings {
+        applied[i] = &postings[i]
+    }
+    if err := s.applyPostings(ctx, tx, applied); err != nil {
+        return nil, err
+    }
+
+    return s.record(ctx, tx, t, postings)
+}
+
+// applyPostings adds each posting to its account balance in the given order and
+// stores the resulting balance on the posting
+func (s *TransactionService) applyPostings(ctx context.Context, tx *sql.Tx, postings []*model.Posting) error {
+    log := middleware.GetLogger()
+
+    for _, p := range postings {
+        balance, err := s.accountRepo.ApplyPostingWithTx(ctx, tx, p.AccountID, p.Amount)
+        if err != nil {
+            log.Error("Failed to apply posting",
+                zap.Int("account_id", p.AccountID),
+                zap.Error(err),
+            )
+            return fmt.Errorf("apply posting to account %d: %w", p.AccountID, err)
         }
+        p.BalanceAfter = balance
+
+        log.Info("Applied posting",
+            zap.Int("account_id", p.AccountID),
+            zap.String("asset_code", p.AssetCode),
+            zap.Float64("amount", formatDecimal(p.Amount, p.AssetCode)),
+            zap.Float64("new_balance", formatDecimal(balance, p.AssetCode)),
+        )
     }
+    return nil
+}
 
-    // Calculate new balances
-    from.Balance = from.Balance.Sub(t.Amount)
-    to.Balance = to.Balance.Add(t.Amount)
+// record logs t and its already applied postings inside tx
+func (s *TransactionService) record(ctx context.Context, tx *sql.Tx, t model.Transaction, postings []model.Posting) (*model.Transaction, error) {
+    log := middleware.GetLogger()
 
-    log.Info("Updating account balances",
-        zap.Int("source_account_id", from.ID),
-        zap.Float64("source_new_balance", from.Balance.Round(5).InexactFloat64()),
-        zap.Int("destination_account_id", to.ID),
-        zap.Float64("destination_new_balance", to.Balance.Round(5).InexactFloat64()),
-    )
+    // Log the transaction within the same database transaction
+    loggedTx, err := s.transactionRepo.CreateWithTx(ctx, tx, t)
+    if err != nil {
+        log.Error("Failed to log transaction",
+            zap.Error(err),
+        )
+        return nil, fmt.Errorf("log transaction: %w", err)
+    }
 
-    // Update both accounts within the transaction
-    if err := s.accountRepo.UpdateBalanceWithTx(ctx, tx, from.ID, from.Balance); err != nil {
-        log.Error("Failed to update source account balance",
-            zap.Int("source_account_id", from.ID),
+    // Record the postings against the logged transaction
+    for i := range postings {
+        postings[i].TransactionID = loggedTx.ID
+    }
+    if err := s.transactionRepo.CreatePostingsWithTx(ctx, tx, postings); err != nil {
+        log.Error("Failed to record postings",
+            zap.Int("transaction_id", loggedTx.ID),
             zap.Error(err),
         )
+        return nil, fmt.Errorf("record postings: %w", err)
+    }
+
+    return loggedTx, nil
+}
+
+// lockAccounts locks the given accounts with FOR UPDATE in ascending ID order.
+// Accounts that do not exist are left out of the returned map.
+func (s *TransactionService) lockAccounts(ctx context.Context, tx *sql.Tx, ids ...int) (map[int]*model.Account, error) {
+    log := middleware.GetLogger()
+
+    ordered := make([]int, 0, len(ids))
+    seen := make(map[int]bool, len(ids))
+    for _, id := range ids {
+        if !seen[id] {
+            seen[id] = true
+            ordered = append(ordered, id)
+        }
+    }
+    sort.Ints(ordered)
+
+    accounts := make(map[int]*model.Account, len(ordered))
+    for _, id := range ordered {
+        account, err := s.accountRepo.GetByIDWithLock(ctx, tx, id)
+        if err != nil {
+            if err == sql.ErrNoRows {
+                continue
+            }
+            log.Error("Failed to lock account",
+                zap.Int("account_id", id),
+                zap.Error(err),
+            )
+            return nil, fmt.Errorf("lock account %d: %w", id, err)
+        }
+        accounts[id] = account
+    }
+    return accounts, nil
+}
+
+// transferFailure maps an error from a transfer unit of work to a result
+func transferFailure(err error) *TransferResult {
+    var precisionErr *PrecisionError
+    var limitErr *LimitError
+    switch {
+    case errors.Is(err, ErrForbidden):
+        return forbidden(err)
+    case errors.Is(err, ErrSameAccount):
         return &TransferResult{
             Success: false,
-            Status:  http.StatusInternalServerError,
-            Message: "Failed to update source account",
+            Status:  http.StatusBadRequest,
+            Message: "Source and destination accounts are the same",
             Error:   err.Error(),
+            Code:    errorCode(err),
         }
-    }
-
-    if err := s.accountRepo.UpdateBalanceWithTx(ctx, tx, to.ID, to.Balance); err != nil {
-        log.Error("Failed to update destination account balance",
-            zap.Int("destination_account_id", to.ID),
-            zap.Error(err),
-        )
+    case errors.Is(err, ErrSourceAccountNotFound):
         return &TransferResult{
             Success: false,
-            Status:  http.StatusInternalServerError,
-            Message: "Failed to update destination account",
+            Status:  http.StatusNotFound,
+            Message: "Source account not found",
             Error:   err.Error(),
+            Code:    errorCode(err),
         }
-    }
-
-    // Log the transaction (within the same database transaction)
-    loggedTx, err := s.transactionRepo.Create(ctx, t)
-    if err != nil {
-        log.Error("Failed to log transaction",
-            zap.Error(err),
-        )
+    case errors.Is(err, ErrDestinationAccountNotFound):
         return &TransferResult{
             Success: false,
-            Status:  http.StatusInternalServerError,
-            Message: "Failed to log transaction",
+            Status:  http.StatusNotFound,
+            Message: "Destination account not found",
             Error:   err.Error(),
+            Code:    errorCode(err),
         }
-    }
-
-    // Commit the transaction
-    if err := tx.Commit(); err != nil {
-        log.Error("Failed to commit database transaction",
+    case errors.Is(err, ErrAccountNotFound):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusNotFound,
+            Message: "Account not found",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrFeeAccountUnavailable):
+        // A misconfigured fee schedule is ours to fix, not the caller's
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusServiceUnavailable,
+            Message: "Fees cannot be charged right now",
+            Error:   ErrFeeAccountUnavailable.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrAccountRefMismatch):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Account public ID and account ID name different accounts",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrTransactionNotFound):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusNotFound,
+            Message: "Transaction not found",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrNotReversible):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusUnprocessableEntity,
+            Message: "Only transfers can be reversed",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrAlreadyReversed):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusConflict,
+            Message: "Transaction is already fully reversed",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrRefundExceedsOriginal):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusUnprocessableEntity,
+            Message: "Refund exceeds the amount not yet refunded",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrHoldNotFound):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusNotFound,
+            Message: "Hold not found",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrHoldNotPending):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusConflict,
+            Message: "Hold is already captured or voided",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrHoldExpired):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusConflict,
+            Message: "Hold has expired",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrCaptureExceedsHold):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusUnprocessableEntity,
+            Message: "Capture exceeds the held amount",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.As(err, &limitErr):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusUnprocessableEntity,
+            Message: "Transfer exceeds a limit of the source account",
+            Error:   limitErr.Code,
+            Code:    CodeLimitExceeded,
+            Data:    limitErr,
+        }
+    case errors.Is(err, ErrAccountFrozen):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusUnprocessableEntity,
+            Message: "Account is frozen or dormant and cannot send funds",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrAccountClosed):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusUnprocessableEntity,
+            Message: "Account is closed",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrUnknownAsset):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Unknown asset code",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrInvalidAmount):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Amount must be positive",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.As(err, &precisionErr):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: fmt.Sprintf("Amount must have at most %d decimal places", precisionErr.Scale),
+            Error:   "invalid precision",
+            Code:    CodePrecisionExceeded,
+        }
+    case errors.Is(err, ErrAssetMismatch):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Asset code does not match the source account",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrCrossAssetTransfer):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Source and destination accounts hold different assets; set convert to request a conversion",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrConversionUnavailable):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusUnprocessableEntity,
+            Message: "Asset conversion is not available for these assets",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrRateUnavailable):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusUnprocessableEntity,
+            Message: "No FX rate is available for these assets",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrQuoteNotFound):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusNotFound,
+            Message: "Quote not found",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrQuoteExpired):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusUnprocessableEntity,
+            Message: "Quote has expired, request a new quote",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrQuoteUsed):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusConflict,
+            Message: "Quote has already been used",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrQuoteMismatch):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Quote does not match the assets of the transfer",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrConvertedAmountTooSmall):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Converted amount rounds down to zero",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrInsufficientLiquidity):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusUnprocessableEntity,
+            Message: "Insufficient liquidity to convert this amount",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrInsufficientBalance):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusBadRequest,
+            Message: "Insufficient balance",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrIdempotencyKeyReused):
+        return &TransferResult{
+            Success: false,
+            Status:  http.StatusUnprocessableEntity,
+            Message: "Idempotency key was already used with a different request",
+            Error:   err.Error(),
+            Code:    errorCode(err),
+        }
+    case errors.Is(err, ErrRetryableConflict):
+        middleware.GetLogger().Warn("Transfer gave up after repeated conflicts",
             zap.Error(err),
         )
         return &TransferResult{
             Success: false,
-            Status:  http.StatusInternalServerError,
-            Message: "Failed to commit transaction",
-            Error:   err.Error(),
+            Status:  http.StatusServiceUnavailable,
+            Message: "Transfer conflicted with concurrent transfers, please retry",
+            Error:   ErrRetryableConflict.Error(),
+            Code:    errorCode(ErrRetryableConflict),
         }
     }
 
-    log.Info("Transfer completed successfully",
-        zap.Int("transaction_id", loggedTx.ID),
-        zap.Int("source_account_id", t.SourceAccountID),
-        zap.Int("destination_account_id", t.DestinationAccountID),
-        zap.Float64("amount", t.Amount.Round(5).InexactFloat64()),
+    middleware.GetLogger().Error("Transfer rolled back",
+        zap.Error(err),
     )
-
     return &TransferResult{
-        Success: true,
-        Status:  http.StatusOK,
-        Message: "Transfer completed successfully",
-        Data: map[string]interface{}{
-            "message": "Transfer completed successfully",
-            "transaction": loggedTx,
-        },
+        Success: false,
+        Status:  http.StatusInternalServerError,
+        Message: "Failed to complete transfer",
+        Error:   err.Error(),
+        Code:    errorCode(err),
     }
 }
 
-func (s *TransactionService) GetTransactionHistory(ctx context.Context) *TransferResult {
-    transactions, err := s.transactionRepo.GetAll(ctx)
+// GetTransactionHistory returns a page of the transactions matching the filter, newest first
+func (s *TransactionService) GetTransactionHistory(ctx context.Context, filter model.TransactionFilter, page PageRequest) *TransferResult {
+    // History across accounts is for staff; customers must name their account
+    var err error
+    if filter.AccountID != nil {
+        err = authorizeAccountID(ctx, s.accountRepo, PermReadAccount, *filter.AccountID)
+    } else {
+        err = authorize(ctx, PermReadAll)
+    }
+    if err != nil {
+        return transferFailure(err)
+    }
+
+    after, limit, err := parsePage(page)
+    if err != nil {
+        return pageFailure(err)
+    }
+
+    transactions, err := s.transactionRepo.GetAllPage(ctx, filter, after, limit+1)
     if err != nil {
         return &TransferResult{
             Success: false,
             Status:  http.StatusInternalServerError,
             Message: "Failed to retrieve transaction history",
             Error:   err.Error(),
+            Code:    errorCode(err),
         }
     }
     
@@ -259,18 +806,33 @@ func (s *TransactionService) GetTransactionHistory(ctx context.Context) *Transfe
         Success: true,
         Status:  http.StatusOK,
         Message: "Transaction history retrieved successfully",
-        Data:    transactions,
+        Data:    newTransactionPage(transactions, limit),
     }
 }
 
-func (s *TransactionService) GetAccountTransactionHistory(ctx context.Context, accountID int) *TransferResult {
-    transactions, err := s.transactionRepo.GetByAccountID(ctx, accountID)
+// GetAccountTransactionHistory returns a page of an account's transactions
+// matching the filter, newest first
+func (s *TransactionService) GetAccountTransactionHistory(ctx context.Context, accountID int, filter model.TransactionFilter, page PageRequest) *TransferResult {
+    if err := authorizeAccountID(ctx, s.accountRepo, PermReadAccount, accountID); err != nil {
+        return transferFailure(err)
+    }
+
+    after, limit, err := parsePage(page)
+    if err != nil {
+        return pageFailure(err)
+    }
+
+    transactions, err := s.transactionRepo.GetByAccountIDPage(ctx, accountID, filter, after, limit+1)
+    if err == nil {
+        transactions, err = s.withEntries(ctx, accountID, transactions)
+    }
     if err != nil {
         return &TransferResult{
             Success: false,
             Status:  http.StatusInternalServerError,
             Message: "Failed to retrieve account transaction history",
             Error:   err.Error(),
+            Code:    errorCode(err),
         }
     }
     
@@ -278,6 +840,6 @@ func (s *TransactionService) GetAccountTransactionHistory(ctx context.Context, a
         Success: true,
         Status:  http.StatusOK,
         Message: "Account transaction history retrieved successfully",
-        Data:    transactions,
+        Data:    newTransactionPage(transactions, limit),
     }
 }
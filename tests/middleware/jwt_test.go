@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+	"transfer-service/middleware"
+	"transfer-service/model"
+)
+
+var hmacSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// signToken builds a JWT with the given algorithm and claims
+func signToken(t *testing.T, alg string, claims map[string]interface{}, key interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(signed))
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":         "user-42",
+		"iss":         "issuer",
+		"aud":         []string{"transfer-service"},
+		"exp":         time.Now().Add(time.Hour).Unix(),
+		"role":        model.RoleCustomer,
+		"customer_id": 3,
+	}
+}
+
+func TestJWTVerifier_AcceptsHS256AndEdDSA(t *testing.T) {
+	// Arrange
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := middleware.NewJWTVerifier(hmacSecret, public, "issuer", "transfer-service")
+
+	for _, token := range []string{
+		signToken(t, "HS256", validClaims(), hmacSecret),
+		signToken(t, "EdDSA", validClaims(), private),
+	} {
+		// Act
+		principal, err := verifier.Verify(token)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected the token to verify, got %v", err)
+		}
+		if principal.Subject != "user-42" || principal.Method != model.AuthMethodJWT || principal.Role != model.RoleCustomer {
+			t.Errorf("Unexpected principal %+v", principal)
+		}
+		if principal.CustomerID == nil || *principal.CustomerID != 3 {
+			t.Errorf("Expected customer 3, got %v", principal.CustomerID)
+		}
+	}
+}
+
+func TestJWTVerifier_RejectsInvalidTokens(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	withClaim := func(name string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name     string
+		verifier *middleware.JWTVerifier
+		token    string
+	}{
+		{"wrong secret", middleware.NewJWTVerifier(hmacSecret, nil, "", ""), signToken(t, "HS256", validClaims(), []byte("another secret of thirty-two bytes"))},
+		{"algorithm none", middleware.NewJWTVerifier(hmacSecret, nil, "", ""), signToken(t, "none", validClaims(), nil)},
+		{"EdDSA without a public key", middleware.NewJWTVerifier(hmacSecret, nil, "", ""), signToken(t, "EdDSA", validClaims(), private)},
+		{"HS256 signed with the public key", middleware.NewJWTVerifier(nil, public, "", ""), signToken(t, "HS256", validClaims(), []byte(public))},
+		{"expired", middleware.NewJWTVerifier(hmacSecret, nil, "", ""), signToken(t, "HS256", withClaim("exp", time.Now().Add(-time.Hour).Unix()), hmacSecret)},
+		{"no expiry", middleware.NewJWTVerifier(hmacSecret, nil, "", ""), signToken(t, "HS256", withClaim("exp", nil), hmacSecret)},
+		{"wrong issuer", middleware.NewJWTVerifier(hmacSecret, nil, "other", ""), signToken(t, "HS256", validClaims(), hmacSecret)},
+		{"wrong audience", middleware.NewJWTVerifier(hmacSecret, nil, "", "other"), signToken(t, "HS256", validClaims(), hmacSecret)},
+		{"unknown role", middleware.NewJWTVerifier(hmacSecret, nil, "", ""), signToken(t, "HS256", withClaim("role", "root"), hmacSecret)},
+		{"customer without customer", middleware.NewJWTVerifier(hmacSecret, nil, "", ""), signToken(t, "HS256", withClaim("customer_id", nil), hmacSecret)},
+		{"malformed", middleware.NewJWTVerifier(hmacSecret, nil, "", ""), "not-a-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			_, err := tt.verifier.Verify(tt.token)
+
+			// Assert
+			if !errors.Is(err, middleware.ErrInvalidToken) {
+				t.Errorf("Expected ErrInvalidToken, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"transfer-service/middleware"
+	"transfer-service/model"
+)
+
+// newTestRateLimiter limits money movement to 2 requests a minute and reads
+// to 5, on a memory store whose clock the test moves
+func newTestRateLimiter() (*middleware.RateLimiter, *time.Time) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := middleware.NewMemoryRateLimitStore()
+	store.SetClock(func() time.Time { return now })
+	limits := map[middleware.RouteClass]model.RateLimit{
+		middleware.RouteClassRead:  {Burst: 5, Per: time.Minute},
+		middleware.RouteClassMoney: {Burst: 2, Per: time.Minute},
+	}
+	reject := func(w http.ResponseWriter, r *http.Request, err error) {
+		if !errors.Is(err, middleware.ErrRateLimited) {
+			panic(err)
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+	return middleware.NewRateLimiter(store, limits, reject), &now
+}
+
+func serve(h http.Handler, method, path, remoteAddr string, principal *model.Principal) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = remoteAddr
+	if principal != nil {
+		req = req.WithContext(middleware.WithPrincipal(req.Context(), principal))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimiter_LimitsMoneyMovementPerClient(t *testing.T) {
+	// Arrange
+	limiter, now := newTestRateLimiter()
+	h := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	alice := &model.Principal{Subject: "api_key:1", Method: model.AuthMethodAPIKey}
+	bob := &model.Principal{Subject: "api_key:2", Method: model.AuthMethodAPIKey}
+
+	// Act
+	first := serve(h, "POST", "/v2/transactions", "10.0.0.1:5000", alice)
+	second := serve(h, "POST", "/transactions/batch", "10.0.0.1:5000", alice)
+	limited := serve(h, "POST", "/holds", "10.0.0.1:5000", alice)
+	read := serve(h, "GET", "/accounts/1", "10.0.0.1:5000", alice)
+	other := serve(h, "POST", "/transactions", "10.0.0.1:5000", bob)
+	*now = now.Add(30 * time.Second)
+	refilled := serve(h, "POST", "/transactions", "10.0.0.1:5000", alice)
+
+	// Assert
+	if first.Code != http.StatusOK || second.Code != http.StatusOK {
+		t.Fatalf("Expected the first two transfers to pass, got %d and %d", first.Code, second.Code)
+	}
+	if got := second.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("Expected RateLimit-Remaining 0, got %q", got)
+	}
+	if limited.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected the third money movement to be limited, got %d", limited.Code)
+	}
+	if got := limited.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Expected Retry-After 30, got %q", got)
+	}
+	if got := limited.Header().Get("RateLimit-Limit"); got != "2" {
+		t.Errorf("Expected RateLimit-Limit 2, got %q", got)
+	}
+	if read.Code != http.StatusOK || read.Header().Get("RateLimit-Limit") != "5" {
+		t.Errorf("Expected reads to have their own limit of 5, got %d with limit %q", read.Code, read.Header().Get("RateLimit-Limit"))
+	}
+	if other.Code != http.StatusOK {
+		t.Errorf("Expected another client to have its own bucket, got %d", other.Code)
+	}
+	if refilled.Code != http.StatusOK {
+		t.Errorf("Expected a token after 30 seconds, got %d", refilled.Code)
+	}
+}
+
+func TestRateLimiter_LimitsUnauthenticatedByIP(t *testing.T) {
+	// Arrange
+	limiter, _ := newTestRateLimiter()
+	reject := limiter.LimitRejected(func(w http.ResponseWriter, r *http.Request, err error) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reject(w, r, middleware.ErrMissingCredentials)
+	})
+
+	// Act
+	var codes []int
+	for i := 0; i < 3; i++ {
+		codes = append(codes, serve(h, "POST", "/transactions", "192.0.2.7:4000", nil).Code)
+	}
+	otherIP := serve(h, "POST", "/transactions", "192.0.2.8:4000", nil)
+
+	// Assert
+	want := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Errorf("Request %d: expected %d, got %d", i+1, want[i], codes[i])
+		}
+	}
+	if otherIP.Code != http.StatusUnauthorized {
+		t.Errorf("Expected another IP address to have its own bucket, got %d", otherIP.Code)
+	}
+}
+
+// failingStore fails every take
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit model.RateLimit) (model.RateLimitDecision, error) {
+	return model.RateLimitDecision{}, errors.New("connection refused")
+}
+
+func TestRateLimiter_LetsRequestsThroughWhenStoreFails(t *testing.T) {
+	// Arrange
+	limiter := middleware.NewRateLimiter(failingStore{}, map[middleware.RouteClass]model.RateLimit{
+		middleware.RouteClassMoney: {Burst: 1, Per: time.Minute},
+	}, func(w http.ResponseWriter, r *http.Request, err error) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	h := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Act
+	rec := serve(h, "POST", "/transactions", "10.0.0.1:5000", nil)
+
+	// Assert
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the request to pass, got %d", rec.Code)
+	}
+}
+
+func TestClassifyRoute(t *testing.T) {
+	tests := []struct {
+		method, path string
+		want         middleware.RouteClass
+	}{
+		{"GET", "/transactions", middleware.RouteClassRead},
+		{"POST", "/transactions", middleware.RouteClassMoney},
+		{"POST", "/v1/holds/3/capture", middleware.RouteClassMoney},
+		{"POST", "/v2/standing-orders", middleware.RouteClassMoney},
+		{"POST", "/scheduled-transfers/4/cancel", middleware.RouteClassMoney},
+		{"POST", "/accounts", middleware.RouteClassWrite},
+		{"PUT", "/v2/fees/USD", middleware.RouteClassWrite},
+	}
+
+	for _, tt := range tests {
+		// Act
+		got := middleware.ClassifyRoute(httptest.NewRequest(tt.method, tt.path, nil))
+
+		// Assert
+		if got != tt.want {
+			t.Errorf("%s %s: expected %s, got %s", tt.method, tt.path, tt.want, got)
+		}
+	}
+}
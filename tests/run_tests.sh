@@ -11,5 +11,26 @@ echo ""
 echo "Running Transaction Service Validation Tests..."
 go test ./tests/service -v -run "Test.*Validation.*"
 
+echo ""
+echo "Running Transaction Service Transfer Tests..."
+go test ./tests/service -v -run "TestTransfer_.*"
+
+echo ""
+echo "Running Transaction Service Reversal Tests..."
+go test ./tests/service -v -run "TestReverse_.*"
+
+echo ""
+echo "Running Standing Order Tests..."
+go test ./tests/service -v -run "Test.*StandingOrder.*|TestExecuteDue_.*"
+
+echo ""
+echo "Running Authentication Tests..."
+go test ./tests/service -v -run "Test.*APIKey.*"
+go test ./tests/middleware -v -run "TestJWTVerifier_.*"
+
+echo ""
+echo "Running Rate Limit Tests..."
+go test ./tests/middleware -v -run "TestRateLimiter_.*|TestClassifyRoute"
+
 echo ""
 echo "All tests completed!" 
\ No newline at end of file
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"net/http"
+	"sort"
+	"strings"
 	"testing"
 	"transfer-service/model"
 	svc "transfer-service/service"
@@ -25,17 +27,24 @@ func NewMockAccountRepository() *MockAccountRepository {
 	}
 }
 
-func (m *MockAccountRepository) Create(ctx context.Context, account model.Account) error {
+func (m *MockAccountRepository) Create(ctx context.Context, account model.Account) (int, error) {
+	return m.CreateWithTx(ctx, nil, account)
+}
+
+func (m *MockAccountRepository) CreateWithTx(ctx context.Context, tx *sql.Tx, account model.Account) (int, error) {
 	if m.createError != nil {
-		return m.createError
+		return 0, m.createError
+	}
+	if account.ID == 0 {
+		account.ID = nextAccountID(m.accounts)
 	}
 	if _, exists := m.accounts[account.ID]; exists {
-		return &pq.Error{
+		return 0, &pq.Error{
 			Code: "23505", // PostgreSQL unique violation error code
 		}
 	}
 	m.accounts[account.ID] = &account
-	return nil
+	return account.ID, nil
 }
 
 func (m *MockAccountRepository) GetByID(ctx context.Context, id int) (*model.Account, error) {
@@ -48,6 +57,26 @@ func (m *MockAccountRepository) GetByID(ctx context.Context, id int) (*model.Acc
 	return nil, sql.ErrNoRows
 }
 
+func (m *MockAccountRepository) GetByPublicID(ctx context.Context, publicID string) (*model.Account, error) {
+	for _, account := range m.accounts {
+		if account.PublicID == publicID {
+			return account, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockAccountRepository) GetByCustomerID(ctx context.Context, customerID int) ([]*model.Account, error) {
+	accounts := []*model.Account{}
+	for _, account := range m.accounts {
+		if account.CustomerID != nil && *account.CustomerID == customerID {
+			accounts = append(accounts, account)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	return accounts, nil
+}
+
 func (m *MockAccountRepository) GetByIDWithLock(ctx context.Context, tx *sql.Tx, id int) (*model.Account, error) {
 	return m.GetByID(ctx, id)
 }
@@ -60,10 +89,30 @@ func (m *MockAccountRepository) UpdateBalanceWithTx(ctx context.Context, tx *sql
 	return nil
 }
 
-func (m *MockAccountRepository) DeleteByID(ctx context.Context, id int) error {
+func (m *MockAccountRepository) ApplyPostingWithTx(ctx context.Context, tx *sql.Tx, id int, amount decimal.Decimal) (decimal.Decimal, error) {
+	return decimal.Zero, nil
+}
+
+func (m *MockAccountRepository) GetPostingsByAccountID(ctx context.Context, accountID int) ([]*model.Posting, error) {
+	return nil, nil
+}
+
+func (m *MockAccountRepository) GetLedgerBalance(ctx context.Context, id int) (decimal.Decimal, error) {
+	return decimal.Zero, nil
+}
+
+func (m *MockAccountRepository) UpdateStatusWithTx(ctx context.Context, tx *sql.Tx, id int, status string) error {
 	return nil
 }
 
+func (m *MockAccountRepository) UpdateOverdraftLimitWithTx(ctx context.Context, tx *sql.Tx, id int, limit decimal.Decimal) error {
+	if account, exists := m.accounts[id]; exists {
+		account.OverdraftLimit = limit
+		return nil
+	}
+	return sql.ErrNoRows
+}
+
 func (m *MockAccountRepository) GetDB() *sql.DB {
 	return nil
 }
@@ -71,8 +120,8 @@ func (m *MockAccountRepository) GetDB() *sql.DB {
 func TestCreateAccount_Success(t *testing.T) {
 	// Arrange
 	mockRepo := NewMockAccountRepository()
-	service := svc.NewAccountService(mockRepo)
-	ctx := context.Background()
+	service := svc.NewAccountService(mockRepo, NewMockIdempotencyRepository(), &MockUnitOfWork{})
+	ctx := testContext()
 	account := model.Account{ID: 1, Balance: decimal.NewFromFloat(1000.0)}
 
 	// Act
@@ -90,11 +139,43 @@ func TestCreateAccount_Success(t *testing.T) {
 	}
 }
 
+func TestCreateAccount_GeneratesIDs(t *testing.T) {
+	// Arrange
+	mockRepo := NewMockAccountRepository()
+	service := svc.NewAccountService(mockRepo, NewMockIdempotencyRepository(), &MockUnitOfWork{})
+	ctx := testContext()
+	service.CreateAccount(ctx, model.Account{ID: 7, Balance: decimal.NewFromFloat(100.0)})
+
+	// Act
+	result := service.CreateAccount(ctx, model.Account{Balance: decimal.NewFromFloat(1000.0), PublicID: "acc_chosen"})
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	created := result.Data.(model.Account)
+	if created.ID != 8 {
+		t.Errorf("Expected the next free account ID 8, got %d", created.ID)
+	}
+	if !strings.HasPrefix(created.PublicID, "acc_") || created.PublicID == "acc_chosen" {
+		t.Errorf("Expected a generated public ID, got %q", created.PublicID)
+	}
+	if id, failure := service.ResolveAccountID(ctx, created.PublicID); failure != nil || id != created.ID {
+		t.Errorf("Expected public ID to resolve to %d, got %d", created.ID, id)
+	}
+	if id, failure := service.ResolveAccountID(ctx, "7"); failure != nil || id != 7 {
+		t.Errorf("Expected legacy ID 7 to resolve to itself, got %d", id)
+	}
+	if _, failure := service.ResolveAccountID(ctx, "acc_missing"); failure == nil || failure.Status != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown public ID, got %+v", failure)
+	}
+}
+
 func TestCreateAccount_DuplicateID(t *testing.T) {
 	// Arrange
 	mockRepo := NewMockAccountRepository()
-	service := svc.NewAccountService(mockRepo)
-	ctx := context.Background()
+	service := svc.NewAccountService(mockRepo, NewMockIdempotencyRepository(), &MockUnitOfWork{})
+	ctx := testContext()
 	
 	// Create first account
 	account1 := model.Account{ID: 1, Balance: decimal.NewFromFloat(1000.0)}
@@ -122,8 +203,8 @@ func TestCreateAccount_InvalidRequest(t *testing.T) {
 	// Arrange
 	mockRepo := NewMockAccountRepository()
 	mockRepo.createError = errors.New("invalid input syntax for integer")
-	service := svc.NewAccountService(mockRepo)
-	ctx := context.Background()
+	service := svc.NewAccountService(mockRepo, NewMockIdempotencyRepository(), &MockUnitOfWork{})
+	ctx := testContext()
 	account := model.Account{ID: 1, Balance: decimal.NewFromFloat(1000.0)}
 
 	// Act
@@ -144,8 +225,8 @@ func TestCreateAccount_InvalidRequest(t *testing.T) {
 func TestGetAccount_Success(t *testing.T) {
 	// Arrange
 	mockRepo := NewMockAccountRepository()
-	service := svc.NewAccountService(mockRepo)
-	ctx := context.Background()
+	service := svc.NewAccountService(mockRepo, NewMockIdempotencyRepository(), &MockUnitOfWork{})
+	ctx := testContext()
 	
 	// Create account first
 	account := model.Account{ID: 1, Balance: decimal.NewFromFloat(1000.0)}
@@ -174,8 +255,8 @@ func TestGetAccount_Success(t *testing.T) {
 func TestGetAccount_NotFound(t *testing.T) {
 	// Arrange
 	mockRepo := NewMockAccountRepository()
-	service := svc.NewAccountService(mockRepo)
-	ctx := context.Background()
+	service := svc.NewAccountService(mockRepo, NewMockIdempotencyRepository(), &MockUnitOfWork{})
+	ctx := testContext()
 
 	// Act
 	result := service.GetAccount(ctx, 999)
@@ -190,4 +271,92 @@ func TestGetAccount_NotFound(t *testing.T) {
 	if result.Message != "Account not found" {
 		t.Errorf("Expected message 'Account not found', got '%s'", result.Message)
 	}
-} 
\ No newline at end of file
+	if result.Error != svc.ErrAccountNotFound.Error() {
+		t.Errorf("Expected error %q, got %q", svc.ErrAccountNotFound, result.Error)
+	}
+}
+
+func TestGetAccount_DatabaseErrorIsInternal(t *testing.T) {
+	// Arrange
+	mockRepo := NewMockAccountRepository()
+	mockRepo.getError = errors.New("connection refused")
+	service := svc.NewAccountService(mockRepo, NewMockIdempotencyRepository(), &MockUnitOfWork{})
+
+	// Act
+	result := service.GetAccount(testContext(), 1)
+
+	// Assert
+	if result.Status != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, result.Status)
+	}
+	if result.ErrorCode() != svc.CodeInternal {
+		t.Errorf("Expected code %s, got %s", svc.CodeInternal, result.ErrorCode())
+	}
+}
+
+func TestCreateAccount_IdempotentReplay(t *testing.T) {
+	// Arrange
+	mockRepo := NewMockAccountRepository()
+	service := svc.NewAccountService(mockRepo, NewMockIdempotencyRepository(), &MockUnitOfWork{})
+	ctx := testContext()
+	account := model.Account{ID: 1, Balance: decimal.NewFromFloat(1000.0)}
+	key := &model.IdempotencyKey{Key: "create-1", Fingerprint: "fp-1"}
+
+	// Act
+	first := service.CreateAccountIdempotent(ctx, account, key)
+	second := service.CreateAccountIdempotent(ctx, account, key)
+
+	// Assert
+	if !first.Success || !second.Success {
+		t.Fatalf("Expected both requests to succeed, got %q and %q", first.Message, second.Message)
+	}
+	if !second.Replayed {
+		t.Error("Expected the second request to be replayed")
+	}
+	if second.Status != http.StatusCreated {
+		t.Errorf("Expected replayed status %d, got %d", http.StatusCreated, second.Status)
+	}
+}
+
+func TestCreateAccount_NegativeOverdraftLimit(t *testing.T) {
+	// Arrange
+	mockRepo := NewMockAccountRepository()
+	service := svc.NewAccountService(mockRepo, NewMockIdempotencyRepository(), &MockUnitOfWork{})
+	account := model.Account{ID: 1, Balance: decimal.NewFromFloat(100.0), OverdraftLimit: decimal.NewFromFloat(-50.0)}
+
+	// Act
+	result := service.CreateAccount(testContext(), account)
+
+	// Assert
+	if result.Status != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, result.Status)
+	}
+	if _, exists := mockRepo.accounts[1]; exists {
+		t.Error("Expected the account not to be created")
+	}
+}
+
+func TestUpdateAccount_OverdraftLimitCoversBalance(t *testing.T) {
+	// Arrange
+	mockRepo := NewMockAccountRepository()
+	mockRepo.accounts[1] = &model.Account{ID: 1, AssetCode: model.DefaultAssetCode, Balance: decimal.NewFromFloat(-50.0), OverdraftLimit: decimal.NewFromFloat(100.0)}
+	service := svc.NewAccountService(mockRepo, NewMockIdempotencyRepository(), &MockUnitOfWork{})
+	tooLow := decimal.NewFromFloat(20.0)
+	enough := decimal.NewFromFloat(60.0)
+
+	// Act
+	rejected := service.UpdateAccount(testContext(), 1, svc.AccountUpdate{OverdraftLimit: &tooLow})
+	updated := service.UpdateAccount(testContext(), 1, svc.AccountUpdate{OverdraftLimit: &enough})
+	missing := service.UpdateAccount(testContext(), 2, svc.AccountUpdate{OverdraftLimit: &enough})
+
+	// Assert
+	if rejected.Status != http.StatusConflict {
+		t.Errorf("Expected status %d for a limit below the balance, got %d", http.StatusConflict, rejected.Status)
+	}
+	if !updated.Success || !mockRepo.accounts[1].OverdraftLimit.Equal(enough) {
+		t.Errorf("Expected overdraft limit 60, got %s (%s)", mockRepo.accounts[1].OverdraftLimit, updated.Message)
+	}
+	if missing.Status != http.StatusNotFound {
+		t.Errorf("Expected status %d for a missing account, got %d", http.StatusNotFound, missing.Status)
+	}
+}
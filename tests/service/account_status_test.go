@@ -0,0 +1,91 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"transfer-service/model"
+	svc "transfer-service/service"
+	"github.com/shopspring/decimal"
+)
+
+func newTestAccountStatusService() (*svc.TransactionService, *SimpleMockAccountRepository) {
+	service, accountRepo, _, _ := newTestTransactionService()
+	accountRepo.accounts[1].Status = model.AccountStatusActive
+	accountRepo.accounts[2].Status = model.AccountStatusActive
+	return service, accountRepo
+}
+
+func TestTransfer_FrozenAccountCanOnlyReceive(t *testing.T) {
+	// Arrange
+	service, accountRepo := newTestAccountStatusService()
+	frozen := service.ChangeAccountStatus(testContext(), 2, svc.AccountStatusRequest{Status: model.AccountStatusFrozen})
+
+	// Act
+	debit := service.Transfer(testContext(), model.Transaction{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromFloat(10.0)})
+	credit := service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(10.0)})
+
+	// Assert
+	if !frozen.Success {
+		t.Fatalf("Expected the account to be frozen, got %s", frozen.Message)
+	}
+	if debit.Status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d debiting a frozen account, got %d", http.StatusUnprocessableEntity, debit.Status)
+	}
+	if !credit.Success {
+		t.Errorf("Expected a frozen account to receive funds, got %s", credit.Message)
+	}
+	if !accountRepo.accounts[2].Balance.Equal(decimal.NewFromFloat(510.0)) {
+		t.Errorf("Expected balance 510, got %s", accountRepo.accounts[2].Balance)
+	}
+}
+
+func TestChangeAccountStatus_RejectsInvalidTransitions(t *testing.T) {
+	// Arrange
+	service, _ := newTestAccountStatusService()
+	service.ChangeAccountStatus(testContext(), 2, svc.AccountStatusRequest{Status: model.AccountStatusFrozen})
+
+	// Act
+	closeFrozen := service.ChangeAccountStatus(testContext(), 2, svc.AccountStatusRequest{Status: model.AccountStatusClosed})
+	unknown := service.ChangeAccountStatus(testContext(), 2, svc.AccountStatusRequest{Status: "deleted"})
+	reactivate := service.ChangeAccountStatus(testContext(), 2, svc.AccountStatusRequest{Status: model.AccountStatusActive})
+
+	// Assert
+	if closeFrozen.Status != http.StatusConflict {
+		t.Errorf("Expected status %d closing a frozen account, got %d", http.StatusConflict, closeFrozen.Status)
+	}
+	if unknown.Status != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown status, got %d", http.StatusBadRequest, unknown.Status)
+	}
+	if !reactivate.Success || reactivate.Data.(*svc.AccountStatusReceipt).Account.Status != model.AccountStatusActive {
+		t.Errorf("Expected the account to be active again, got %+v", reactivate)
+	}
+}
+
+func TestChangeAccountStatus_CloseSweepsBalance(t *testing.T) {
+	// Arrange
+	service, accountRepo := newTestAccountStatusService()
+	sweepID := 1
+
+	// Act
+	withoutSweep := service.ChangeAccountStatus(testContext(), 2, svc.AccountStatusRequest{Status: model.AccountStatusClosed})
+	closed := service.ChangeAccountStatus(testContext(), 2, svc.AccountStatusRequest{Status: model.AccountStatusClosed, SweepAccountID: &sweepID})
+	toClosed := service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(10.0)})
+
+	// Assert
+	if withoutSweep.Status != http.StatusConflict {
+		t.Errorf("Expected status %d closing an account with a balance, got %d", http.StatusConflict, withoutSweep.Status)
+	}
+	if !closed.Success {
+		t.Fatalf("Expected the account to be closed, got %s", closed.Message)
+	}
+	receipt := closed.Data.(*svc.AccountStatusReceipt)
+	if receipt.Sweep == nil || !receipt.Sweep.Amount.Equal(decimal.NewFromFloat(500.0)) || !receipt.Account.Balance.IsZero() {
+		t.Errorf("Expected the 500 balance to be swept, got %+v", receipt)
+	}
+	if !accountRepo.accounts[1].Balance.Equal(decimal.NewFromFloat(1500.0)) || !accountRepo.accounts[2].Balance.IsZero() {
+		t.Errorf("Expected balances 1500 and 0, got %s and %s", accountRepo.accounts[1].Balance, accountRepo.accounts[2].Balance)
+	}
+	if toClosed.Status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d paying a closed account, got %d", http.StatusUnprocessableEntity, toClosed.Status)
+	}
+}
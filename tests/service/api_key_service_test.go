@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"transfer-service/middleware"
+	"transfer-service/model"
+	svc "transfer-service/service"
+)
+
+// MockAPIKeyRepository keeps API keys in memory
+type MockAPIKeyRepository struct {
+	keys   map[int]model.APIKey
+	nextID int
+}
+
+func NewMockAPIKeyRepository() *MockAPIKeyRepository {
+	return &MockAPIKeyRepository{keys: make(map[int]model.APIKey), nextID: 1}
+}
+
+func (m *MockAPIKeyRepository) Create(ctx context.Context, key model.APIKey) (*model.APIKey, error) {
+	key.ID = m.nextID
+	key.CreatedAt = time.Now()
+	m.nextID++
+	m.keys[key.ID] = key
+	return &key, nil
+}
+
+func (m *MockAPIKeyRepository) GetByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	for _, key := range m.keys {
+		if key.Hash == hash {
+			return &key, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockAPIKeyRepository) GetAll(ctx context.Context) ([]*model.APIKey, error) {
+	keys := []*model.APIKey{}
+	for id := 1; id < m.nextID; id++ {
+		if key, exists := m.keys[id]; exists {
+			keys = append(keys, &key)
+		}
+	}
+	return keys, nil
+}
+
+func (m *MockAPIKeyRepository) Revoke(ctx context.Context, id int, at time.Time) (*model.APIKey, error) {
+	key, exists := m.keys[id]
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+	if key.RevokedAt == nil {
+		key.RevokedAt = &at
+	}
+	m.keys[id] = key
+	return &key, nil
+}
+
+// adminContext is the context of a request authenticated as an admin
+func adminContext() context.Context {
+	return middleware.WithPrincipal(context.Background(), &model.Principal{Subject: "admin", Role: model.RoleAdmin})
+}
+
+func TestIssueAPIKey_AuthenticatesUntilRevoked(t *testing.T) {
+	// Arrange
+	repo := NewMockAPIKeyRepository()
+	service := svc.NewAPIKeyService(repo)
+	customerID := 7
+
+	// Act
+	result := service.IssueKey(adminContext(), svc.APIKeyRequest{Name: "mobile app", Role: model.RoleCustomer, CustomerID: &customerID})
+
+	// Assert
+	if result.Status != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, result.Status, result.Error)
+	}
+	issued := result.Data.(*svc.IssuedAPIKey)
+	if !strings.HasPrefix(issued.Key, middleware.APIKeyPrefix) || !strings.HasPrefix(issued.Key, issued.Prefix) {
+		t.Errorf("Expected a key starting with %q and its prefix %q, got %q", middleware.APIKeyPrefix, issued.Prefix, issued.Key)
+	}
+	stored := repo.keys[issued.ID]
+	if stored.Hash == "" || strings.Contains(stored.Hash, issued.Key) {
+		t.Errorf("Expected only a hash of the key to be stored, got %q", stored.Hash)
+	}
+
+	principal, err := service.AuthenticateAPIKey(context.Background(), issued.Key)
+	if err != nil {
+		t.Fatalf("Expected the issued key to authenticate, got %v", err)
+	}
+	if principal.Role != model.RoleCustomer || principal.CustomerID == nil || *principal.CustomerID != customerID {
+		t.Errorf("Expected a customer principal for customer %d, got %+v", customerID, principal)
+	}
+	if _, err := service.AuthenticateAPIKey(context.Background(), issued.Key+"x"); !errors.Is(err, middleware.ErrInvalidAPIKey) {
+		t.Errorf("Expected ErrInvalidAPIKey for an unknown key, got %v", err)
+	}
+
+	if result := service.RevokeKey(adminContext(), issued.ID); !result.Success {
+		t.Fatalf("Expected revoke to succeed, got %d: %s", result.Status, result.Error)
+	}
+	if _, err := service.AuthenticateAPIKey(context.Background(), issued.Key); !errors.Is(err, middleware.ErrInvalidAPIKey) {
+		t.Errorf("Expected ErrInvalidAPIKey for a revoked key, got %v", err)
+	}
+}
+
+func TestIssueAPIKey_Validation(t *testing.T) {
+	customerID := 7
+	past := time.Now().Add(-time.Hour)
+	operator := middleware.WithPrincipal(context.Background(), &model.Principal{Subject: "ops", Role: model.RoleOperator})
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		req    svc.APIKeyRequest
+		status int
+	}{
+		{"unauthenticated", context.Background(), svc.APIKeyRequest{Name: "k", Role: model.RoleAdmin}, http.StatusForbidden},
+		{"operator", operator, svc.APIKeyRequest{Name: "k", Role: model.RoleAdmin}, http.StatusForbidden},
+		{"missing name", adminContext(), svc.APIKeyRequest{Role: model.RoleOperator}, http.StatusBadRequest},
+		{"unknown role", adminContext(), svc.APIKeyRequest{Name: "k", Role: "root"}, http.StatusBadRequest},
+		{"customer without customer", adminContext(), svc.APIKeyRequest{Name: "k", Role: model.RoleCustomer}, http.StatusBadRequest},
+		{"operator with customer", adminContext(), svc.APIKeyRequest{Name: "k", Role: model.RoleOperator, CustomerID: &customerID}, http.StatusBadRequest},
+		{"already expired", adminContext(), svc.APIKeyRequest{Name: "k", Role: model.RoleOperator, ExpiresAt: &past}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			repo := NewMockAPIKeyRepository()
+			service := svc.NewAPIKeyService(repo)
+
+			// Act
+			result := service.IssueKey(tt.ctx, tt.req)
+
+			// Assert
+			if result.Status != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, result.Status)
+			}
+			if len(repo.keys) != 0 {
+				t.Errorf("Expected no stored keys, got %d", len(repo.keys))
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+	"transfer-service/model"
+	svc "transfer-service/service"
+	"github.com/shopspring/decimal"
+)
+
+// MockCustomerRepository keeps customers in memory
+type MockCustomerRepository struct {
+	customers map[int]model.Customer
+	nextID    int
+}
+
+func NewMockCustomerRepository() *MockCustomerRepository {
+	return &MockCustomerRepository{customers: make(map[int]model.Customer), nextID: 1}
+}
+
+func (m *MockCustomerRepository) CreateWithTx(ctx context.Context, tx *sql.Tx, customer model.Customer) (*model.Customer, error) {
+	customer.ID = m.nextID
+	customer.CreatedAt = time.Now()
+	customer.UpdatedAt = customer.CreatedAt
+	m.nextID++
+	m.customers[customer.ID] = customer
+	return &customer, nil
+}
+
+func (m *MockCustomerRepository) GetByID(ctx context.Context, id int) (*model.Customer, error) {
+	if customer, exists := m.customers[id]; exists {
+		return &customer, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockCustomerRepository) GetAll(ctx context.Context) ([]*model.Customer, error) {
+	customers := []*model.Customer{}
+	for id := 1; id < m.nextID; id++ {
+		if customer, exists := m.customers[id]; exists {
+			customers = append(customers, &customer)
+		}
+	}
+	return customers, nil
+}
+
+func (m *MockCustomerRepository) Update(ctx context.Context, customer model.Customer) (*model.Customer, error) {
+	if _, exists := m.customers[customer.ID]; !exists {
+		return nil, sql.ErrNoRows
+	}
+	customer.UpdatedAt = time.Now()
+	m.customers[customer.ID] = customer
+	return &customer, nil
+}
+
+func (m *MockCustomerRepository) Delete(ctx context.Context, id int) error {
+	if _, exists := m.customers[id]; !exists {
+		return sql.ErrNoRows
+	}
+	delete(m.customers, id)
+	return nil
+}
+
+func newTestCustomerService() (*svc.CustomerService, *SimpleMockAccountRepository) {
+	accountRepo := NewSimpleMockAccountRepository()
+	customers := svc.NewCustomerService(NewMockCustomerRepository(), accountRepo, NewMockIdempotencyRepository(), &MockUnitOfWork{})
+	return customers, accountRepo
+}
+
+func TestCreateCustomer_Validation(t *testing.T) {
+	// Arrange
+	customers, _ := newTestCustomerService()
+
+	// Act
+	created := customers.CreateCustomer(testContext(), model.Customer{Name: "Ada Lovelace", ExternalRef: "crm-42"})
+	noName := customers.CreateCustomer(testContext(), model.Customer{Name: "  "})
+	badTier := customers.CreateCustomer(testContext(), model.Customer{Name: "Grace Hopper", KYCTier: "gold"})
+
+	// Assert
+	if !created.Success || created.Status != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", created.Status, created.Message)
+	}
+	if customer := created.Data.(*model.Customer); customer.ID == 0 || customer.KYCTier != model.KYCTierNone {
+		t.Errorf("Expected an allocated ID and KYC tier %s, got %+v", model.KYCTierNone, customer)
+	}
+	if noName.Success || noName.Status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a blank name, got %d", noName.Status)
+	}
+	if badTier.Success || badTier.Status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown KYC tier, got %d", badTier.Status)
+	}
+}
+
+func TestGetCustomerAccounts_AggregatesBalancesPerAsset(t *testing.T) {
+	// Arrange
+	customers, accountRepo := newTestCustomerService()
+	customer := customers.CreateCustomer(testContext(), model.Customer{Name: "Ada Lovelace"}).Data.(*model.Customer)
+	owner := customer.ID
+	accountRepo.accounts[1] = &model.Account{ID: 1, AssetCode: "USD", Balance: decimal.NewFromInt(100), CustomerID: &owner}
+	accountRepo.accounts[2] = &model.Account{ID: 2, AssetCode: "USD", Balance: decimal.NewFromInt(50), CustomerID: &owner, HeldBalance: decimal.NewFromInt(20)}
+	accountRepo.accounts[3] = &model.Account{ID: 3, AssetCode: "EUR", Balance: decimal.NewFromInt(10), CustomerID: &owner}
+	accountRepo.accounts[4] = &model.Account{ID: 4, AssetCode: "USD", Balance: decimal.NewFromInt(999)}
+
+	// Act
+	result := customers.GetCustomerAccounts(testContext(), owner)
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	owned := result.Data.(*svc.CustomerAccounts)
+	if len(owned.Accounts) != 3 {
+		t.Errorf("Expected 3 owned accounts, got %d", len(owned.Accounts))
+	}
+	if len(owned.Balances) != 2 || owned.Balances[0].AssetCode != "EUR" || owned.Balances[1].AssetCode != "USD" {
+		t.Fatalf("Expected EUR and USD totals, got %+v", owned.Balances)
+	}
+	usd := owned.Balances[1]
+	if usd.Accounts != 2 || !usd.LedgerBalance.Equal(decimal.NewFromInt(150)) || !usd.AvailableBalance.Equal(decimal.NewFromInt(130)) {
+		t.Errorf("Expected 2 USD accounts with 150 ledger and 130 available, got %+v", usd)
+	}
+}
+
+func TestDeleteCustomer_BlockedWhileOwningAccounts(t *testing.T) {
+	// Arrange
+	customers, accountRepo := newTestCustomerService()
+	customer := customers.CreateCustomer(testContext(), model.Customer{Name: "Ada Lovelace"}).Data.(*model.Customer)
+	owner := customer.ID
+	accountRepo.accounts[1] = &model.Account{ID: 1, AssetCode: model.DefaultAssetCode, CustomerID: &owner}
+
+	// Act
+	blocked := customers.DeleteCustomer(testContext(), owner)
+	delete(accountRepo.accounts, 1)
+	deleted := customers.DeleteCustomer(testContext(), owner)
+
+	// Assert
+	if blocked.Success || blocked.Status != http.StatusConflict {
+		t.Errorf("Expected 409 while the customer owns an account, got %d", blocked.Status)
+	}
+	if !deleted.Success {
+		t.Errorf("Expected delete to succeed once no accounts remain, got %s", deleted.Message)
+	}
+	if lookup := customers.GetCustomer(testContext(), owner); lookup.Status != http.StatusNotFound {
+		t.Errorf("Expected 404 after delete, got %d", lookup.Status)
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"transfer-service/model"
+	svc "transfer-service/service"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+func TestErrorCodes_TransferFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		transfer model.Transaction
+		failures int
+		code     svc.ErrorCode
+	}{
+		{"insufficient funds", model.Transaction{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromFloat(600.0)}, 0, svc.CodeInsufficientFunds},
+		{"unknown account", model.Transaction{SourceAccountID: 1, DestinationAccountID: 99, Amount: decimal.NewFromFloat(10.0)}, 0, svc.CodeAccountNotFound},
+		{"precision", model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString("10.123456")}, 0, svc.CodePrecisionExceeded},
+		{"same account", model.Transaction{SourceAccountID: 1, DestinationAccountID: 1, Amount: decimal.NewFromFloat(10.0)}, 0, svc.CodeSameAccount},
+		{"retries exhausted", model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(10.0)}, 10, svc.CodeRetryableConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			service, _, _, uow := newTestTransactionService()
+			for i := 0; i < tt.failures; i++ {
+				uow.failures = append(uow.failures, &pq.Error{Code: "40001"})
+			}
+
+			// Act
+			result := service.Transfer(testContext(), tt.transfer)
+
+			// Assert
+			if result.Success {
+				t.Fatal("Expected failure, got success")
+			}
+			if result.ErrorCode() != tt.code {
+				t.Errorf("Expected code %s, got %s", tt.code, result.ErrorCode())
+			}
+		})
+	}
+}
+
+func TestErrorCodes_FallBackToStatus(t *testing.T) {
+	// Arrange
+	wrapped := fmt.Errorf("%w: bad base64", svc.ErrInvalidCursor)
+
+	// Act & Assert
+	if code := svc.ErrorCodeOf(wrapped, http.StatusBadRequest); code != svc.CodeInvalidCursor {
+		t.Errorf("Expected code %s for a wrapped error, got %s", svc.CodeInvalidCursor, code)
+	}
+	if code := svc.ErrorCodeOf(errors.New("connection refused"), http.StatusInternalServerError); code != svc.CodeInternal {
+		t.Errorf("Expected code %s for an unknown error, got %s", svc.CodeInternal, code)
+	}
+	result := &svc.Result{Status: http.StatusNotFound}
+	if result.ErrorCode() != svc.CodeNotFound {
+		t.Errorf("Expected code %s for a 404 without a code, got %s", svc.CodeNotFound, result.ErrorCode())
+	}
+}
+
+func TestCreateAccount_DuplicateIDHidesDatabaseError(t *testing.T) {
+	// Arrange
+	mockRepo := NewMockAccountRepository()
+	mockRepo.createError = &pq.Error{
+		Code:       "23505",
+		Message:    `duplicate key value violates unique constraint "accounts_pkey"`,
+		Constraint: "accounts_pkey",
+	}
+	service := svc.NewAccountService(mockRepo, NewMockIdempotencyRepository(), &MockUnitOfWork{})
+
+	// Act
+	result := service.CreateAccount(testContext(), model.Account{ID: 1, Balance: decimal.NewFromFloat(100.0)})
+
+	// Assert
+	if result.Status != http.StatusConflict {
+		t.Fatalf("Expected status %d, got %d", http.StatusConflict, result.Status)
+	}
+	if result.ErrorCode() != svc.CodeAccountExists {
+		t.Errorf("Expected code %s, got %s", svc.CodeAccountExists, result.ErrorCode())
+	}
+	if strings.Contains(result.Error, "accounts_pkey") || strings.Contains(result.Error, "pq:") {
+		t.Errorf("Expected the database error to be hidden, got %q", result.Error)
+	}
+}
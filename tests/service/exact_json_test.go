@@ -0,0 +1,80 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"transfer-service/model"
+	svc "transfer-service/service"
+	"github.com/shopspring/decimal"
+)
+
+func TestExactJSON_TransferReceipt(t *testing.T) {
+	// Arrange
+	service, fees, _, _ := newTestFeeService()
+	fees.SetSchedule(testContext(), model.FeeSchedule{
+		AssetCode:    model.DefaultAssetCode,
+		Type:         model.FeeTypePercent,
+		Percent:      decimalRef("1"),
+		FeeAccountID: 9,
+	})
+	result := service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString("0.1")})
+
+	// Act
+	v1, err1 := json.Marshal(result.Data)
+	v2, err2 := json.Marshal(model.ExactJSON(result.Data))
+
+	// Assert
+	if err1 != nil || err2 != nil {
+		t.Fatalf("Expected both versions to marshal, got %v and %v", err1, err2)
+	}
+	receipt := result.Data.(*svc.TransferReceipt)
+	if !strings.Contains(string(v1), `"amount":0.1,`) || !strings.Contains(string(v1), `"fee":0.001`) {
+		t.Errorf("Expected v1 to keep float amounts, got %s", v1)
+	}
+	var body struct {
+		Message     string                 `json:"message"`
+		Transaction map[string]interface{} `json:"transaction"`
+	}
+	if err := json.Unmarshal(v2, &body); err != nil {
+		t.Fatalf("Expected v2 JSON to decode, got %v", err)
+	}
+	if body.Message != receipt.Message || body.Transaction["id"] != float64(receipt.Transaction.ID) {
+		t.Errorf("Expected v2 to keep the other fields, got %s", v2)
+	}
+	if body.Transaction["amount"] != "0.10000" || body.Transaction["fee"] != "0.00100" {
+		t.Errorf("Expected exact strings fixed to 5 places, got amount %v and fee %v", body.Transaction["amount"], body.Transaction["fee"])
+	}
+	if _, ok := body.Transaction["fx_rate"]; ok {
+		t.Errorf("Expected omitempty fields to stay omitted, got %s", v2)
+	}
+}
+
+func TestExactJSON_AccountBalances(t *testing.T) {
+	// Arrange
+	account := &model.Account{
+		ID:          1,
+		AssetCode:   model.DefaultAssetCode,
+		Balance:     decimal.RequireFromString("12345678901.23456"),
+		HeldBalance: decimal.RequireFromString("0.00001"),
+	}
+
+	// Act
+	v2, err := json.Marshal(model.ExactJSON([]*model.Account{account}))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	var accounts []map[string]interface{}
+	json.Unmarshal(v2, &accounts)
+	if len(accounts) != 1 {
+		t.Fatalf("Expected one account, got %s", v2)
+	}
+	if accounts[0]["balance"] != "12345678901.23456" || accounts[0]["available_balance"] != "12345678901.23455" {
+		t.Errorf("Expected exact balances, got %s", v2)
+	}
+	if _, ok := accounts[0]["HeldBalance"]; ok {
+		t.Errorf("Expected fields tagged - to stay hidden, got %s", v2)
+	}
+}
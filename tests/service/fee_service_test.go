@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"transfer-service/model"
+	svc "transfer-service/service"
+	"github.com/shopspring/decimal"
+)
+
+// MockFeeRepository keeps fee schedules in memory
+type MockFeeRepository struct {
+	schedules map[string]model.FeeSchedule
+}
+
+func NewMockFeeRepository() *MockFeeRepository {
+	return &MockFeeRepository{schedules: make(map[string]model.FeeSchedule)}
+}
+
+func (m *MockFeeRepository) GetSchedules(ctx context.Context) ([]*model.FeeSchedule, error) {
+	schedules := []*model.FeeSchedule{}
+	for _, schedule := range m.schedules {
+		s := schedule
+		schedules = append(schedules, &s)
+	}
+	return schedules, nil
+}
+
+func (m *MockFeeRepository) GetScheduleWithTx(ctx context.Context, tx *sql.Tx, assetCode string) (*model.FeeSchedule, error) {
+	if schedule, exists := m.schedules[assetCode]; exists {
+		return &schedule, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockFeeRepository) UpsertSchedule(ctx context.Context, schedule model.FeeSchedule) (*model.FeeSchedule, error) {
+	m.schedules[schedule.AssetCode] = schedule
+	return &schedule, nil
+}
+
+func (m *MockFeeRepository) DeleteSchedule(ctx context.Context, assetCode string) error {
+	if _, exists := m.schedules[assetCode]; !exists {
+		return sql.ErrNoRows
+	}
+	delete(m.schedules, assetCode)
+	return nil
+}
+
+// newTestFeeService wires a TransactionService with two funded accounts and
+// fee account 9, and a FeeService sharing its fee schedules
+func newTestFeeService() (*svc.TransactionService, *svc.FeeService, *SimpleMockAccountRepository, *SimpleMockTransactionRepository) {
+	accountRepo := NewSimpleMockAccountRepository()
+	accountRepo.accounts[1] = &model.Account{ID: 1, AssetCode: model.DefaultAssetCode, Balance: decimal.NewFromFloat(1000.0)}
+	accountRepo.accounts[2] = &model.Account{ID: 2, AssetCode: model.DefaultAssetCode, Balance: decimal.NewFromFloat(500.0)}
+	accountRepo.accounts[9] = &model.Account{ID: 9, AssetCode: model.DefaultAssetCode, Balance: decimal.Zero}
+	transactionRepo := NewSimpleMockTransactionRepository()
+	feeRepo := NewMockFeeRepository()
+	transactionSvc := svc.NewTransactionService(accountRepo, transactionRepo, NewMockIdempotencyRepository(), NewMockFXRepository(), NewMockHoldRepository(accountRepo), NewMockLimitRepository(), feeRepo, &MockUnitOfWork{})
+	return transactionSvc, svc.NewFeeService(feeRepo, accountRepo), accountRepo, transactionRepo
+}
+
+func decimalRef(s string) *decimal.Decimal {
+	d := decimal.RequireFromString(s)
+	return &d
+}
+
+func TestTransfer_ChargesPercentFeeWithMinimum(t *testing.T) {
+	// Arrange
+	service, fees, accountRepo, transactionRepo := newTestFeeService()
+	fees.SetSchedule(testContext(), model.FeeSchedule{
+		AssetCode:    model.DefaultAssetCode,
+		Type:         model.FeeTypePercent,
+		Percent:      decimalRef("1.5"),
+		MinFee:       decimalRef("2"),
+		FeeAccountID: 9,
+	})
+
+	// Act
+	large := service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(400)})
+	small := service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10)})
+
+	// Assert
+	if !large.Success || !small.Success {
+		t.Fatalf("Expected both transfers to succeed, got %s and %s", large.Message, small.Message)
+	}
+	tx := large.Data.(*svc.TransferReceipt).Transaction
+	if tx.Fee == nil || !tx.Fee.Equal(decimal.NewFromInt(6)) || tx.FeeAccountID == nil || *tx.FeeAccountID != 9 {
+		t.Errorf("Expected a fee of 6 to account 9 recorded on the transaction, got %v", tx.Fee)
+	}
+	if fee := small.Data.(*svc.TransferReceipt).Transaction.Fee; fee == nil || !fee.Equal(decimal.NewFromInt(2)) {
+		t.Errorf("Expected the minimum fee of 2, got %v", fee)
+	}
+	if !accountRepo.accounts[1].Balance.Equal(decimal.NewFromInt(582)) {
+		t.Errorf("Expected source balance 582, got %s", accountRepo.accounts[1].Balance)
+	}
+	if !accountRepo.accounts[2].Balance.Equal(decimal.NewFromInt(910)) {
+		t.Errorf("Expected destination balance 910, got %s", accountRepo.accounts[2].Balance)
+	}
+	if !accountRepo.accounts[9].Balance.Equal(decimal.NewFromInt(8)) {
+		t.Errorf("Expected fee account balance 8, got %s", accountRepo.accounts[9].Balance)
+	}
+	if len(transactionRepo.postings) != 8 {
+		t.Errorf("Expected 4 postings per transfer, got %d", len(transactionRepo.postings))
+	}
+}
+
+func TestTransfer_FeeCountsTowardsFunds(t *testing.T) {
+	// Arrange
+	service, fees, accountRepo, _ := newTestFeeService()
+	fees.SetSchedule(testContext(), model.FeeSchedule{
+		AssetCode:    model.DefaultAssetCode,
+		Type:         model.FeeTypeFlat,
+		FlatAmount:   decimalRef("5"),
+		FeeAccountID: 9,
+	})
+
+	// Act
+	result := service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1000)})
+
+	// Assert
+	if result.Success || result.Error != svc.ErrInsufficientBalance.Error() {
+		t.Fatalf("Expected insufficient balance for a transfer the fee overdraws, got %d: %s", result.Status, result.Message)
+	}
+	if !accountRepo.accounts[1].Balance.Equal(decimal.NewFromInt(1000)) || !accountRepo.accounts[9].Balance.IsZero() {
+		t.Errorf("Expected balances unchanged, got source %s and fee account %s", accountRepo.accounts[1].Balance, accountRepo.accounts[9].Balance)
+	}
+}
+
+func TestTransferBatchAndCapture_ChargeFees(t *testing.T) {
+	// Arrange
+	service, fees, accountRepo, _ := newTestFeeService()
+	fees.SetSchedule(testContext(), model.FeeSchedule{
+		AssetCode:    model.DefaultAssetCode,
+		Type:         model.FeeTypeFlat,
+		FlatAmount:   decimalRef("5"),
+		FeeAccountID: 9,
+	})
+	placed := service.Authorize(testContext(), model.Hold{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(100)})
+
+	// Act
+	batch := service.TransferBatch(testContext(), svc.BatchRequest{Legs: []model.Transaction{
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(50)},
+		{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(20)},
+	}})
+	capture := service.Capture(testContext(), placed.Data.(*model.Hold).ID, svc.CaptureRequest{})
+
+	// Assert
+	if !batch.Success || !capture.Success {
+		t.Fatalf("Expected the batch and capture to succeed, got %s and %s", batch.Message, capture.Message)
+	}
+	for i, tx := range batch.Data.(*svc.BatchReceipt).Transactions {
+		if tx.Fee == nil || !tx.Fee.Equal(decimal.NewFromInt(5)) {
+			t.Errorf("Expected leg %d to be charged a fee of 5, got %v", i, tx.Fee)
+		}
+	}
+	if fee := capture.Data.(*svc.HoldReceipt).Transaction.Fee; fee == nil || !fee.Equal(decimal.NewFromInt(5)) {
+		t.Errorf("Expected the capture to be charged a fee of 5, got %v", fee)
+	}
+	if !accountRepo.accounts[9].Balance.Equal(decimal.NewFromInt(15)) {
+		t.Errorf("Expected fee account balance 15, got %s", accountRepo.accounts[9].Balance)
+	}
+	if !accountRepo.accounts[1].Balance.Equal(decimal.NewFromInt(860)) {
+		t.Errorf("Expected source balance 860, got %s", accountRepo.accounts[1].Balance)
+	}
+}
+
+func TestReverse_KeepsFee(t *testing.T) {
+	// Arrange
+	service, fees, accountRepo, _ := newTestFeeService()
+	fees.SetSchedule(testContext(), model.FeeSchedule{
+		AssetCode:    model.DefaultAssetCode,
+		Type:         model.FeeTypeFlat,
+		FlatAmount:   decimalRef("5"),
+		FeeAccountID: 9,
+	})
+	transfer := service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(100)})
+	original := transfer.Data.(*svc.TransferReceipt).Transaction
+
+	// Act
+	result := service.Reverse(testContext(), original.ID, svc.ReversalRequest{})
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	if !accountRepo.accounts[1].Balance.Equal(decimal.NewFromInt(995)) {
+		t.Errorf("Expected the amount but not the fee refunded, got source balance %s", accountRepo.accounts[1].Balance)
+	}
+	if !accountRepo.accounts[9].Balance.Equal(decimal.NewFromInt(5)) {
+		t.Errorf("Expected fee account to keep 5, got %s", accountRepo.accounts[9].Balance)
+	}
+}
+
+func TestPreviewFee_Tiered(t *testing.T) {
+	// Arrange
+	_, fees, _, _ := newTestFeeService()
+	stored := fees.SetSchedule(testContext(), model.FeeSchedule{
+		AssetCode: model.DefaultAssetCode,
+		Type:      model.FeeTypeTiered,
+		Tiers: []model.FeeTier{
+			{UpTo: decimalRef("100"), FlatAmount: decimalRef("1")},
+			{UpTo: decimalRef("1000"), FlatAmount: decimalRef("2"), Percent: decimalRef("0.5")},
+			{Percent: decimalRef("0.25")},
+		},
+		MaxFee:       decimalRef("20"),
+		FeeAccountID: 9,
+	})
+	unordered := fees.SetSchedule(testContext(), model.FeeSchedule{
+		AssetCode:    model.DefaultAssetCode,
+		Type:         model.FeeTypeTiered,
+		Tiers:        []model.FeeTier{{Percent: decimalRef("1")}, {UpTo: decimalRef("100")}},
+		FeeAccountID: 9,
+	})
+
+	// Act
+	cases := map[string]string{"50": "1", "200": "3", "2000": "5", "100000": "20"}
+	previews := make(map[string]*svc.Result)
+	for amount := range cases {
+		previews[amount] = fees.Preview(testContext(), model.Transaction{SourceAccountID: 1, Amount: decimal.RequireFromString(amount)})
+	}
+
+	// Assert
+	if !stored.Success {
+		t.Fatalf("Expected schedule to be stored, got %s", stored.Error)
+	}
+	if unordered.Success || unordered.Status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a schedule with an unbounded tier first, got %d", unordered.Status)
+	}
+	for amount, want := range cases {
+		preview := previews[amount].Data.(*model.FeePreview)
+		if !preview.Fee.Equal(decimal.RequireFromString(want)) {
+			t.Errorf("Expected fee %s on %s, got %s", want, amount, preview.Fee)
+		}
+		if !preview.Total.Equal(preview.Amount.Add(preview.Fee)) {
+			t.Errorf("Expected total %s, got %s", preview.Amount.Add(preview.Fee), preview.Total)
+		}
+	}
+}
+
+func TestTransfer_ClosedFeeAccountIsUnavailable(t *testing.T) {
+	// Arrange
+	service, fees, accountRepo, transactionRepo := newTestFeeService()
+	schedule := model.FeeSchedule{
+		AssetCode:    model.DefaultAssetCode,
+		Type:         model.FeeTypeFlat,
+		FlatAmount:   decimalRef("5"),
+		FeeAccountID: 9,
+	}
+	stored := fees.SetSchedule(testContext(), schedule)
+	accountRepo.accounts[9].Status = model.AccountStatusClosed
+
+	// Act
+	rejected := fees.SetSchedule(testContext(), schedule)
+	result := service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10)})
+
+	// Assert
+	if !stored.Success {
+		t.Fatalf("Expected schedule to be stored, got %s", stored.Error)
+	}
+	if rejected.Status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a schedule naming a closed fee account, got %d", rejected.Status)
+	}
+	if result.Status != http.StatusServiceUnavailable || result.ErrorCode() != svc.CodeFeeAccountUnavailable {
+		t.Fatalf("Expected 503 %s, got %d %s: %s", svc.CodeFeeAccountUnavailable, result.Status, result.ErrorCode(), result.Error)
+	}
+	if result.Error != svc.ErrFeeAccountUnavailable.Error() {
+		t.Errorf("Expected the bare error without account details, got %q", result.Error)
+	}
+	if len(transactionRepo.transactions) != 0 || !accountRepo.accounts[1].Balance.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("Expected nothing to move, got %d transactions and source balance %s", len(transactionRepo.transactions), accountRepo.accounts[1].Balance)
+	}
+}
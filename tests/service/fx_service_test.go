@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+	"transfer-service/model"
+	svc "transfer-service/service"
+	"github.com/shopspring/decimal"
+)
+
+// MockFXRepository keeps rates, quotes and positions in memory
+type MockFXRepository struct {
+	rates     map[string]*model.FXRate
+	quotes    map[string]*model.FXQuote
+	positions map[string]*model.FXPosition
+}
+
+func NewMockFXRepository() *MockFXRepository {
+	return &MockFXRepository{
+		rates:     make(map[string]*model.FXRate),
+		quotes:    make(map[string]*model.FXQuote),
+		positions: make(map[string]*model.FXPosition),
+	}
+}
+
+func (m *MockFXRepository) GetRates(ctx context.Context) ([]*model.FXRate, error) {
+	var result []*model.FXRate
+	for _, rate := range m.rates {
+		result = append(result, rate)
+	}
+	return result, nil
+}
+
+func (m *MockFXRepository) GetRateWithTx(ctx context.Context, tx *sql.Tx, baseAsset, quoteAsset string) (*model.FXRate, error) {
+	if rate, exists := m.rates[baseAsset+"/"+quoteAsset]; exists {
+		return rate, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockFXRepository) UpsertRate(ctx context.Context, rate model.FXRate) (*model.FXRate, error) {
+	m.rates[rate.BaseAsset+"/"+rate.QuoteAsset] = &rate
+	return &rate, nil
+}
+
+func (m *MockFXRepository) CreateQuote(ctx context.Context, quote model.FXQuote) (*model.FXQuote, error) {
+	m.quotes[quote.ID] = &quote
+	return &quote, nil
+}
+
+func (m *MockFXRepository) GetQuoteWithLock(ctx context.Context, tx *sql.Tx, id string) (*model.FXQuote, error) {
+	if quote, exists := m.quotes[id]; exists {
+		return quote, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockFXRepository) MarkQuoteUsedWithTx(ctx context.Context, tx *sql.Tx, id string, transactionID int) error {
+	m.quotes[id].TransactionID = &transactionID
+	return nil
+}
+
+func (m *MockFXRepository) GetPositions(ctx context.Context) ([]*model.FXPosition, error) {
+	var result []*model.FXPosition
+	for _, position := range m.positions {
+		result = append(result, position)
+	}
+	return result, nil
+}
+
+func (m *MockFXRepository) GetPositionWithTx(ctx context.Context, tx *sql.Tx, assetCode string) (*model.FXPosition, error) {
+	if position, exists := m.positions[assetCode]; exists {
+		return position, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockFXRepository) UpsertPosition(ctx context.Context, position model.FXPosition) error {
+	m.positions[position.AssetCode] = &position
+	return nil
+}
+
+// newTestFXTransactionService wires a USD account 1, a EUR account 3 and funded
+// position accounts for both assets
+func newTestFXTransactionService() (*svc.TransactionService, *SimpleMockAccountRepository, *SimpleMockTransactionRepository, *MockFXRepository) {
+	model.RegisterAsset(model.Asset{Code: "EUR", Scale: 2})
+
+	accountRepo := NewSimpleMockAccountRepository()
+	accountRepo.accounts[1] = &model.Account{ID: 1, AssetCode: "USD", Balance: decimal.NewFromFloat(1000.0)}
+	accountRepo.accounts[3] = &model.Account{ID: 3, AssetCode: "EUR", Balance: decimal.Zero}
+	accountRepo.accounts[901] = &model.Account{ID: 901, AssetCode: "USD", Balance: decimal.Zero}
+	accountRepo.accounts[902] = &model.Account{ID: 902, AssetCode: "EUR", Balance: decimal.NewFromFloat(10000.0)}
+
+	fxRepo := NewMockFXRepository()
+	fxRepo.UpsertPosition(testContext(), model.FXPosition{AssetCode: "USD", AccountID: 901})
+	fxRepo.UpsertPosition(testContext(), model.FXPosition{AssetCode: "EUR", AccountID: 902})
+	fxRepo.UpsertRate(testContext(), model.FXRate{BaseAsset: "USD", QuoteAsset: "EUR", Rate: decimal.RequireFromString("0.9234")})
+
+	transactionRepo := NewSimpleMockTransactionRepository()
+	service := svc.NewTransactionService(accountRepo, transactionRepo, NewMockIdempotencyRepository(), fxRepo, NewMockHoldRepository(accountRepo), NewMockLimitRepository(), NewMockFeeRepository(), &MockUnitOfWork{})
+	return service, accountRepo, transactionRepo, fxRepo
+}
+
+func TestTransfer_ConvertsAtStoredRate(t *testing.T) {
+	// Arrange
+	service, accountRepo, transactionRepo, _ := newTestFXTransactionService()
+	transfer := model.Transaction{SourceAccountID: 1, DestinationAccountID: 3, Amount: decimal.NewFromFloat(10.01), Convert: true}
+
+	// Act
+	result := service.Transfer(testContext(), transfer)
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	// 10.01 * 0.9234 = 9.243234, rounded down to 2 places
+	if !accountRepo.accounts[3].Balance.Equal(decimal.RequireFromString("9.24")) {
+		t.Errorf("Expected destination balance 9.24, got %s", accountRepo.accounts[3].Balance)
+	}
+	if !accountRepo.accounts[901].Balance.Equal(decimal.NewFromFloat(10.01)) {
+		t.Errorf("Expected USD position balance 10.01, got %s", accountRepo.accounts[901].Balance)
+	}
+	logged := result.Data.(*svc.TransferReceipt).Transaction
+	if logged.FXRounding == nil || !logged.FXRounding.Equal(decimal.RequireFromString("0.003234")) {
+		t.Errorf("Expected fx rounding 0.003234, got %v", logged.FXRounding)
+	}
+	if len(transactionRepo.postings) != 4 {
+		t.Errorf("Expected 4 postings, got %d", len(transactionRepo.postings))
+	}
+}
+
+func TestTransfer_UsesQuoteOnce(t *testing.T) {
+	// Arrange
+	service, accountRepo, _, fxRepo := newTestFXTransactionService()
+	fxRepo.CreateQuote(testContext(), model.FXQuote{
+		ID: "q_test", BaseAsset: "USD", QuoteAsset: "EUR",
+		Rate: decimal.RequireFromString("0.5"), ExpiresAt: time.Now().Add(time.Minute),
+	})
+	transfer := model.Transaction{SourceAccountID: 1, DestinationAccountID: 3, Amount: decimal.NewFromFloat(10.0), Convert: true, QuoteID: "q_test"}
+
+	// Act
+	first := service.Transfer(testContext(), transfer)
+	second := service.Transfer(testContext(), transfer)
+
+	// Assert
+	if !first.Success {
+		t.Fatalf("Expected success, got failure: %s", first.Message)
+	}
+	if !accountRepo.accounts[3].Balance.Equal(decimal.NewFromFloat(5.0)) {
+		t.Errorf("Expected destination balance 5 at the quoted rate, got %s", accountRepo.accounts[3].Balance)
+	}
+	if second.Status != http.StatusConflict {
+		t.Errorf("Expected status %d for a reused quote, got %d", http.StatusConflict, second.Status)
+	}
+}
+
+func TestTransfer_QuoteExpired(t *testing.T) {
+	// Arrange
+	service, _, _, fxRepo := newTestFXTransactionService()
+	fxRepo.CreateQuote(testContext(), model.FXQuote{
+		ID: "q_old", BaseAsset: "USD", QuoteAsset: "EUR",
+		Rate: decimal.RequireFromString("0.5"), ExpiresAt: time.Now().Add(-time.Second),
+	})
+	transfer := model.Transaction{SourceAccountID: 1, DestinationAccountID: 3, Amount: decimal.NewFromFloat(10.0), Convert: true, QuoteID: "q_old"}
+
+	// Act
+	result := service.Transfer(testContext(), transfer)
+
+	// Assert
+	if result.Success {
+		t.Fatal("Expected failure for an expired quote, got success")
+	}
+	if result.Status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, result.Status)
+	}
+}
+
+func TestCreateQuote_LocksCurrentRate(t *testing.T) {
+	// Arrange
+	model.RegisterAsset(model.Asset{Code: "EUR", Scale: 2})
+	fxRepo := NewMockFXRepository()
+	fxRepo.UpsertRate(testContext(), model.FXRate{BaseAsset: "USD", QuoteAsset: "EUR", Rate: decimal.RequireFromString("0.9")})
+	service := svc.NewFXService(fxRepo, time.Minute)
+
+	// Act
+	result := service.CreateQuote(testContext(), model.FXQuote{BaseAsset: "USD", QuoteAsset: "EUR"})
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	quote := result.Data.(*model.FXQuote)
+	if !quote.Rate.Equal(decimal.RequireFromString("0.9")) {
+		t.Errorf("Expected quoted rate 0.9, got %s", quote.Rate)
+	}
+	if !quote.ExpiresAt.After(time.Now()) {
+		t.Error("Expected the quote to expire in the future")
+	}
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+	"transfer-service/model"
+	svc "transfer-service/service"
+	"github.com/shopspring/decimal"
+)
+
+// MockHoldRepository keeps holds in memory and mirrors pending holds into the
+// held balance of the mock accounts, like the held_balance column does
+type MockHoldRepository struct {
+	holds    map[int]*model.Hold
+	accounts *SimpleMockAccountRepository
+	nextID   int
+}
+
+func NewMockHoldRepository(accounts *SimpleMockAccountRepository) *MockHoldRepository {
+	return &MockHoldRepository{
+		holds:    make(map[int]*model.Hold),
+		accounts: accounts,
+		nextID:   1,
+	}
+}
+
+func (m *MockHoldRepository) CreateWithTx(ctx context.Context, tx *sql.Tx, hold model.Hold) (*model.Hold, error) {
+	hold.ID = m.nextID
+	hold.Status = model.HoldStatusPending
+	m.holds[hold.ID] = &hold
+	m.nextID++
+	m.release(&hold, hold.Amount.Neg())
+	created := hold
+	return &created, nil
+}
+
+func (m *MockHoldRepository) GetByID(ctx context.Context, id int) (*model.Hold, error) {
+	hold, exists := m.holds[id]
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+	found := *hold
+	if found.Status == model.HoldStatusPending && !found.ExpiresAt.After(time.Now()) {
+		found.Status = model.HoldStatusExpired
+	}
+	return &found, nil
+}
+
+func (m *MockHoldRepository) GetByIDWithLock(ctx context.Context, tx *sql.Tx, id int) (*model.Hold, error) {
+	return m.GetByID(ctx, id)
+}
+
+func (m *MockHoldRepository) CaptureWithTx(ctx context.Context, tx *sql.Tx, id int, amount decimal.Decimal, transactionID int) error {
+	hold := m.holds[id]
+	hold.Status = model.HoldStatusCaptured
+	hold.CapturedAmount = &amount
+	hold.TransactionID = &transactionID
+	m.release(hold, hold.Amount)
+	return nil
+}
+
+func (m *MockHoldRepository) VoidWithTx(ctx context.Context, tx *sql.Tx, id int) error {
+	hold := m.holds[id]
+	hold.Status = model.HoldStatusVoided
+	m.release(hold, hold.Amount)
+	return nil
+}
+
+// release adds amount back to the held balance of the hold's source account
+func (m *MockHoldRepository) release(hold *model.Hold, amount decimal.Decimal) {
+	if account, exists := m.accounts.accounts[hold.SourceAccountID]; exists {
+		account.HeldBalance = account.HeldBalance.Sub(amount)
+	}
+}
+
+func newTestHoldService() (*svc.TransactionService, *SimpleMockAccountRepository, *MockHoldRepository) {
+	accountRepo := NewSimpleMockAccountRepository()
+	accountRepo.accounts[1] = &model.Account{ID: 1, AssetCode: model.DefaultAssetCode, Balance: decimal.NewFromFloat(1000.0)}
+	accountRepo.accounts[2] = &model.Account{ID: 2, AssetCode: model.DefaultAssetCode, Balance: decimal.NewFromFloat(500.0)}
+	holdRepo := NewMockHoldRepository(accountRepo)
+	service := svc.NewTransactionService(accountRepo, NewSimpleMockTransactionRepository(), NewMockIdempotencyRepository(), NewMockFXRepository(), holdRepo, NewMockLimitRepository(), NewMockFeeRepository(), &MockUnitOfWork{})
+	return service, accountRepo, holdRepo
+}
+
+func TestAuthorize_ReducesAvailableBalance(t *testing.T) {
+	// Arrange
+	service, accountRepo, _ := newTestHoldService()
+	hold := model.Hold{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(800.0)}
+
+	// Act
+	result := service.Authorize(testContext(), hold)
+	transfer := service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(300.0)})
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	if !accountRepo.accounts[1].Balance.Equal(decimal.NewFromFloat(1000.0)) {
+		t.Errorf("Expected ledger balance to stay 1000, got %s", accountRepo.accounts[1].Balance)
+	}
+	if !accountRepo.accounts[1].AvailableBalance().Equal(decimal.NewFromFloat(200.0)) {
+		t.Errorf("Expected available balance 200, got %s", accountRepo.accounts[1].AvailableBalance())
+	}
+	if transfer.Success {
+		t.Error("Expected a transfer over the available balance to fail, got success")
+	}
+}
+
+func TestAuthorize_ByPublicID(t *testing.T) {
+	// Arrange
+	service, accountRepo, _ := newTestHoldService()
+	accountRepo.accounts[1].PublicID = "acc_source"
+	accountRepo.accounts[2].PublicID = "acc_destination"
+
+	// Act
+	result := service.Authorize(testContext(), model.Hold{SourceAccount: "acc_source", DestinationAccount: "acc_destination", Amount: decimal.NewFromFloat(100.0)})
+	unknown := service.Authorize(testContext(), model.Hold{SourceAccount: "acc_missing", DestinationAccountID: 2, Amount: decimal.NewFromFloat(1.0)})
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	hold := result.Data.(*model.Hold)
+	if hold.SourceAccountID != 1 || hold.DestinationAccountID != 2 {
+		t.Errorf("Expected hold from 1 to 2, got %d to %d", hold.SourceAccountID, hold.DestinationAccountID)
+	}
+	if unknown.Status != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown source public ID, got %d", unknown.Status)
+	}
+}
+
+func TestCapture_PartialReleasesRest(t *testing.T) {
+	// Arrange
+	service, accountRepo, _ := newTestHoldService()
+	placed := service.Authorize(testContext(), model.Hold{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(100.0)})
+	hold := placed.Data.(*model.Hold)
+	partial := decimal.NewFromFloat(60.0)
+
+	// Act
+	result := service.Capture(testContext(), hold.ID, svc.CaptureRequest{Amount: &partial})
+	again := service.Capture(testContext(), hold.ID, svc.CaptureRequest{})
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	if !accountRepo.accounts[1].Balance.Equal(decimal.NewFromFloat(940.0)) {
+		t.Errorf("Expected source balance 940, got %s", accountRepo.accounts[1].Balance)
+	}
+	if !accountRepo.accounts[1].AvailableBalance().Equal(decimal.NewFromFloat(940.0)) {
+		t.Errorf("Expected the uncaptured rest to be released, got available %s", accountRepo.accounts[1].AvailableBalance())
+	}
+	if !accountRepo.accounts[2].Balance.Equal(decimal.NewFromFloat(560.0)) {
+		t.Errorf("Expected destination balance 560, got %s", accountRepo.accounts[2].Balance)
+	}
+	if again.Status != http.StatusConflict {
+		t.Errorf("Expected status %d for a second capture, got %d", http.StatusConflict, again.Status)
+	}
+}
+
+func TestVoid_ReleasesHold(t *testing.T) {
+	// Arrange
+	service, accountRepo, _ := newTestHoldService()
+	placed := service.Authorize(testContext(), model.Hold{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(100.0)})
+	hold := placed.Data.(*model.Hold)
+
+	// Act
+	result := service.Void(testContext(), hold.ID)
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	if !accountRepo.accounts[1].AvailableBalance().Equal(decimal.NewFromFloat(1000.0)) {
+		t.Errorf("Expected available balance 1000, got %s", accountRepo.accounts[1].AvailableBalance())
+	}
+	if !accountRepo.accounts[2].Balance.Equal(decimal.NewFromFloat(500.0)) {
+		t.Errorf("Expected no money to move, got destination balance %s", accountRepo.accounts[2].Balance)
+	}
+}
+
+func TestCapture_ExpiredHold(t *testing.T) {
+	// Arrange
+	service, _, holdRepo := newTestHoldService()
+	service.SetHoldTTL(-time.Second)
+	placed := service.Authorize(testContext(), model.Hold{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(100.0)})
+	hold := placed.Data.(*model.Hold)
+
+	// Act
+	result := service.Capture(testContext(), hold.ID, svc.CaptureRequest{})
+
+	// Assert
+	if result.Status != http.StatusConflict {
+		t.Errorf("Expected status %d for an expired hold, got %d", http.StatusConflict, result.Status)
+	}
+	if holdRepo.holds[hold.ID].TransactionID != nil {
+		t.Error("Expected the expired hold not to be captured")
+	}
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+	"testing"
+	"transfer-service/model"
+	svc "transfer-service/service"
+	"github.com/shopspring/decimal"
+)
+
+// MockLimitRepository keeps tiers and account limits in memory. Usage is set
+// directly by tests rather than derived from transactions.
+type MockLimitRepository struct {
+	tiers    map[string]model.LimitTier
+	accounts map[int]model.AccountLimits
+	usage    map[int]model.LimitUsage
+}
+
+func NewMockLimitRepository() *MockLimitRepository {
+	return &MockLimitRepository{
+		tiers:    make(map[string]model.LimitTier),
+		accounts: make(map[int]model.AccountLimits),
+		usage:    make(map[int]model.LimitUsage),
+	}
+}
+
+func (m *MockLimitRepository) GetTiers(ctx context.Context) ([]*model.LimitTier, error) {
+	tiers := []*model.LimitTier{}
+	for _, tier := range m.tiers {
+		t := tier
+		tiers = append(tiers, &t)
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].Name < tiers[j].Name })
+	return tiers, nil
+}
+
+func (m *MockLimitRepository) UpsertTier(ctx context.Context, tier model.LimitTier) error {
+	m.tiers[tier.Name] = tier
+	return nil
+}
+
+func (m *MockLimitRepository) GetAccountLimits(ctx context.Context, accountID int) (*model.AccountLimits, error) {
+	if limits, exists := m.accounts[accountID]; exists {
+		return &limits, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockLimitRepository) UpsertAccountLimits(ctx context.Context, limits model.AccountLimits) error {
+	if _, exists := m.tiers[limits.Tier]; limits.Tier != "" && !exists {
+		return errors.New("unknown tier")
+	}
+	m.accounts[limits.AccountID] = limits
+	return nil
+}
+
+// GetEffectiveWithTx overlays an account's own limits on its tier's
+func (m *MockLimitRepository) GetEffectiveWithTx(ctx context.Context, tx *sql.Tx, accountID int) (*model.Limits, error) {
+	account, exists := m.accounts[accountID]
+	if !exists {
+		return &model.Limits{}, nil
+	}
+	effective := m.tiers[account.Tier].Limits
+	if account.MaxSingleAmount != nil {
+		effective.MaxSingleAmount = account.MaxSingleAmount
+	}
+	if account.MaxDailyAmount != nil {
+		effective.MaxDailyAmount = account.MaxDailyAmount
+	}
+	if account.MaxMonthlyAmount != nil {
+		effective.MaxMonthlyAmount = account.MaxMonthlyAmount
+	}
+	if account.MaxDailyCount != nil {
+		effective.MaxDailyCount = account.MaxDailyCount
+	}
+	if account.MaxMonthlyCount != nil {
+		effective.MaxMonthlyCount = account.MaxMonthlyCount
+	}
+	return &effective, nil
+}
+
+func (m *MockLimitRepository) GetUsageWithTx(ctx context.Context, tx *sql.Tx, accountID int) (*model.LimitUsage, error) {
+	usage := m.usage[accountID]
+	return &usage, nil
+}
+
+func newTestLimitService() (*svc.TransactionService, *svc.LimitService, *MockLimitRepository) {
+	accountRepo := NewSimpleMockAccountRepository()
+	accountRepo.accounts[1] = &model.Account{ID: 1, AssetCode: model.DefaultAssetCode, Balance: decimal.NewFromFloat(1000.0)}
+	accountRepo.accounts[2] = &model.Account{ID: 2, AssetCode: model.DefaultAssetCode, Balance: decimal.NewFromFloat(500.0)}
+	limitRepo := NewMockLimitRepository()
+	transactionSvc := svc.NewTransactionService(accountRepo, NewSimpleMockTransactionRepository(), NewMockIdempotencyRepository(), NewMockFXRepository(), NewMockHoldRepository(accountRepo), limitRepo, NewMockFeeRepository(), &MockUnitOfWork{})
+	return transactionSvc, svc.NewLimitService(limitRepo, accountRepo), limitRepo
+}
+
+func TestTransfer_ExceedsSingleAmountLimit(t *testing.T) {
+	// Arrange
+	service, limits, _ := newTestLimitService()
+	max := decimal.NewFromInt(100)
+	limits.SetAccountLimits(testContext(), model.AccountLimits{AccountID: 1, Limits: model.Limits{MaxSingleAmount: &max}})
+	transfer := model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(150)}
+
+	// Act
+	result := service.Transfer(testContext(), transfer)
+
+	// Assert
+	if result.Success || result.Status != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422, got %d: %s", result.Status, result.Message)
+	}
+	limitErr, ok := result.Data.(*svc.LimitError)
+	if !ok || limitErr.Code != svc.LimitCodeSingleAmount || !limitErr.Limit.Equal(max) {
+		t.Errorf("Expected %s with limit %s, got %+v", svc.LimitCodeSingleAmount, max, result.Data)
+	}
+}
+
+func TestTransfer_DailyLimitsCountTodaysTransfers(t *testing.T) {
+	// Arrange
+	service, limits, limitRepo := newTestLimitService()
+	maxDaily := decimal.NewFromInt(500)
+	maxCount := 3
+	limits.SetAccountLimits(testContext(), model.AccountLimits{AccountID: 1, Limits: model.Limits{MaxDailyAmount: &maxDaily, MaxDailyCount: &maxCount}})
+	limitRepo.usage[1] = model.LimitUsage{DailyAmount: decimal.NewFromInt(400), DailyCount: 2}
+
+	// Act
+	overAmount := service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(150)})
+	withinAmount := service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(100)})
+	limitRepo.usage[1] = model.LimitUsage{DailyAmount: decimal.NewFromInt(100), DailyCount: 3}
+	overCount := service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10)})
+
+	// Assert
+	limitErr, ok := overAmount.Data.(*svc.LimitError)
+	if overAmount.Success || !ok || limitErr.Code != svc.LimitCodeDailyAmount {
+		t.Fatalf("Expected %s, got %+v", svc.LimitCodeDailyAmount, overAmount)
+	}
+	if !limitErr.Remaining.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("Expected 100 remaining, got %s", limitErr.Remaining)
+	}
+	if !withinAmount.Success {
+		t.Errorf("Expected transfer within the daily limit to succeed, got %s", withinAmount.Message)
+	}
+	limitErr, ok = overCount.Data.(*svc.LimitError)
+	if overCount.Success || !ok || limitErr.Code != svc.LimitCodeDailyCount {
+		t.Errorf("Expected %s, got %+v", svc.LimitCodeDailyCount, overCount)
+	}
+}
+
+func TestHold_ChecksLimitsOnAuthorizeAndCapture(t *testing.T) {
+	// Arrange
+	service, limits, limitRepo := newTestLimitService()
+	maxSingle := decimal.NewFromInt(100)
+	maxDaily := decimal.NewFromInt(500)
+	limits.SetAccountLimits(testContext(), model.AccountLimits{AccountID: 1, Limits: model.Limits{MaxSingleAmount: &maxSingle, MaxDailyAmount: &maxDaily}})
+
+	// Act
+	overSingle := service.Authorize(testContext(), model.Hold{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(150)})
+	placed := service.Authorize(testContext(), model.Hold{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(80)})
+	// Transfers made after the hold use up the daily limit
+	limitRepo.usage[1] = model.LimitUsage{DailyAmount: decimal.NewFromInt(450), DailyCount: 3}
+	capture := service.Capture(testContext(), placed.Data.(*model.Hold).ID, svc.CaptureRequest{})
+
+	// Assert
+	limitErr, ok := overSingle.Data.(*svc.LimitError)
+	if overSingle.Success || !ok || limitErr.Code != svc.LimitCodeSingleAmount {
+		t.Fatalf("Expected a hold over the single amount limit to fail with %s, got %+v", svc.LimitCodeSingleAmount, overSingle)
+	}
+	if !placed.Success {
+		t.Fatalf("Expected a hold within the limits to succeed, got %s", placed.Message)
+	}
+	limitErr, ok = capture.Data.(*svc.LimitError)
+	if capture.Success || !ok || limitErr.Code != svc.LimitCodeDailyAmount {
+		t.Errorf("Expected the capture to fail with %s, got %+v", svc.LimitCodeDailyAmount, capture)
+	}
+}
+
+func TestGetAccountLimits_OverrideOnTier(t *testing.T) {
+	// Arrange
+	_, limits, limitRepo := newTestLimitService()
+	tierSingle, tierDaily, ownDaily := decimal.NewFromInt(1000), decimal.NewFromInt(5000), decimal.NewFromInt(2000)
+	limits.SetTier(testContext(), model.LimitTier{Name: "retail", Limits: model.Limits{MaxSingleAmount: &tierSingle, MaxDailyAmount: &tierDaily}})
+	limits.SetAccountLimits(testContext(), model.AccountLimits{AccountID: 1, Tier: "retail", Limits: model.Limits{MaxDailyAmount: &ownDaily}})
+	limitRepo.usage[1] = model.LimitUsage{DailyAmount: decimal.NewFromInt(1500)}
+
+	// Act
+	result := limits.GetAccountLimits(testContext(), 1)
+	unknownTier := limits.SetAccountLimits(testContext(), model.AccountLimits{AccountID: 2, Tier: "missing"})
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	status := result.Data.(*svc.LimitStatus)
+	if !status.Limits.MaxSingleAmount.Equal(tierSingle) {
+		t.Errorf("Expected single limit %s from the tier, got %s", tierSingle, status.Limits.MaxSingleAmount)
+	}
+	if !status.Limits.MaxDailyAmount.Equal(ownDaily) {
+		t.Errorf("Expected daily limit %s from the override, got %s", ownDaily, status.Limits.MaxDailyAmount)
+	}
+	if !status.Remaining.MaxDailyAmount.Equal(decimal.NewFromInt(500)) {
+		t.Errorf("Expected 500 remaining today, got %s", status.Remaining.MaxDailyAmount)
+	}
+	if unknownTier.Success {
+		t.Errorf("Expected an unknown tier to be rejected")
+	}
+}
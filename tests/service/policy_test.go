@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+	"transfer-service/middleware"
+	"transfer-service/model"
+	svc "transfer-service/service"
+	"github.com/shopspring/decimal"
+)
+
+// principalContext is the context of a request authenticated with the given
+// role, acting for customerID when it is not nil
+func principalContext(role string, customerID *int) context.Context {
+	return middleware.WithPrincipal(context.Background(), &model.Principal{Subject: role, Role: role, CustomerID: customerID})
+}
+
+func TestTransfer_CustomerMayOnlyDebitOwnedAccounts(t *testing.T) {
+	// Arrange
+	service, accountRepo, transactionRepo, _ := newTestTransactionService()
+	owner, other := 7, 8
+	accountRepo.accounts[1].CustomerID = &owner
+	accountRepo.accounts[2].CustomerID = &other
+	ctx := principalContext(model.RoleCustomer, &owner)
+
+	// Act
+	own := service.Transfer(ctx, model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(10.0)})
+	foreign := service.Transfer(ctx, model.Transaction{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromFloat(10.0)})
+
+	// Assert
+	if !own.Success {
+		t.Fatalf("Expected a debit of the customer's own account to succeed, got %d: %s", own.Status, own.Error)
+	}
+	if foreign.Status != http.StatusNotFound {
+		t.Fatalf("Expected status %d for a debit of another customer's account, got %d", http.StatusNotFound, foreign.Status)
+	}
+	if foreign.ErrorCode() != svc.CodeAccountNotFound {
+		t.Errorf("Expected code %s, got %s", svc.CodeAccountNotFound, foreign.ErrorCode())
+	}
+	if len(transactionRepo.transactions) != 1 {
+		t.Errorf("Expected only the permitted transfer to be logged, got %d", len(transactionRepo.transactions))
+	}
+	if !accountRepo.accounts[2].Balance.Equal(decimal.NewFromFloat(510.0)) {
+		t.Errorf("Expected the foreign account to keep 510.00, got %s", accountRepo.accounts[2].Balance)
+	}
+}
+
+func TestPolicy_CustomerSeesForeignAccountsAsMissing(t *testing.T) {
+	// Arrange
+	service, accountRepo, _, _ := newTestTransactionService()
+	owner, other := 7, 8
+	accountRepo.accounts[1].CustomerID = &owner
+	accountRepo.accounts[2].CustomerID = &other
+	ctx := principalContext(model.RoleCustomer, &owner)
+
+	tests := []struct {
+		name    string
+		foreign *svc.TransferResult
+		missing *svc.TransferResult
+	}{
+		{
+			"transfer",
+			service.Transfer(ctx, model.Transaction{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromFloat(10.0)}),
+			service.Transfer(ctx, model.Transaction{SourceAccountID: 9, DestinationAccountID: 1, Amount: decimal.NewFromFloat(10.0)}),
+		},
+		{
+			"account history",
+			service.GetAccountTransactionHistory(ctx, 2, model.TransactionFilter{}, svc.PageRequest{}),
+			service.GetAccountTransactionHistory(ctx, 9, model.TransactionFilter{}, svc.PageRequest{}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Assert
+			if tt.foreign.Status != http.StatusNotFound || tt.foreign.ErrorCode() != svc.CodeAccountNotFound {
+				t.Errorf("Expected 404 %s for another customer's account, got %d %s", svc.CodeAccountNotFound, tt.foreign.Status, tt.foreign.ErrorCode())
+			}
+			if tt.foreign.Status != tt.missing.Status || tt.foreign.Error != tt.missing.Error {
+				t.Errorf("Expected the same answer as for a missing account, got %d %q and %d %q", tt.foreign.Status, tt.foreign.Error, tt.missing.Status, tt.missing.Error)
+			}
+		})
+	}
+}
+
+func TestTransfer_RolesThatMayDebit(t *testing.T) {
+	tests := []struct {
+		role   string
+		status int
+	}{
+		{model.RoleAdmin, http.StatusOK},
+		{model.RoleOperator, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			// Arrange
+			service, _, _, _ := newTestTransactionService()
+
+			// Act
+			result := service.Transfer(principalContext(tt.role, nil), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(10.0)})
+
+			// Assert
+			if result.Status != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, result.Status, result.Error)
+			}
+		})
+	}
+}
+
+func TestAccountPolicy_ReadsAndAdminActions(t *testing.T) {
+	// Arrange
+	accountRepo := NewMockAccountRepository()
+	service := svc.NewAccountService(accountRepo, NewMockIdempotencyRepository(), &MockUnitOfWork{})
+	owner, other := 7, 8
+	accountRepo.accounts[1] = &model.Account{ID: 1, AssetCode: model.DefaultAssetCode, CustomerID: &owner}
+	accountRepo.accounts[2] = &model.Account{ID: 2, AssetCode: model.DefaultAssetCode, CustomerID: &other}
+	customer := principalContext(model.RoleCustomer, &owner)
+	operator := principalContext(model.RoleOperator, nil)
+	admin := principalContext(model.RoleAdmin, nil)
+
+	tests := []struct {
+		name   string
+		result *svc.AccountResult
+		status int
+	}{
+		{"customer reads own account", service.GetAccount(customer, 1), http.StatusOK},
+		{"customer reads another account", service.GetAccount(customer, 2), http.StatusNotFound},
+		{"customer reads a missing account", service.GetAccount(customer, 9), http.StatusNotFound},
+		{"operator reads any account", service.GetAccount(operator, 2), http.StatusOK},
+		{"customer creates an account", service.CreateAccount(customer, model.Account{ID: 3}), http.StatusForbidden},
+		{"operator creates an account", service.CreateAccount(operator, model.Account{ID: 3}), http.StatusForbidden},
+		{"admin creates an account", service.CreateAccount(admin, model.Account{ID: 3}), http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Assert
+			if tt.result.Status != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, tt.result.Status, tt.result.Error)
+			}
+		})
+	}
+}
+
+func TestPolicy_DeniesContextWithoutPrincipal(t *testing.T) {
+	// Arrange
+	service, _, transactionRepo, _ := newTestTransactionService()
+	transfer := model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(10.0)}
+
+	// Act
+	anonymous := service.Transfer(context.Background(), transfer)
+	system := service.Transfer(testContext(), transfer)
+
+	// Assert
+	if anonymous.Status != http.StatusForbidden {
+		t.Errorf("Expected status %d without a principal, got %d", http.StatusForbidden, anonymous.Status)
+	}
+	if !system.Success {
+		t.Errorf("Expected the system principal to transfer, got failure: %s", system.Message)
+	}
+	if len(transactionRepo.transactions) != 1 {
+		t.Errorf("Expected 1 logged transaction, got %d", len(transactionRepo.transactions))
+	}
+}
+
+func TestCancelScheduled_OtherCustomersTransferIsNotFound(t *testing.T) {
+	// Arrange
+	service, accountRepo, _ := newTestScheduledTransferService()
+	owner, other := 7, 8
+	accountRepo.accounts[1].CustomerID = &owner
+	accountRepo.accounts[2].CustomerID = &other
+	scheduled := service.Schedule(testContext(), model.ScheduledTransfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(10.0), ExecuteAt: time.Now().Add(time.Hour)})
+	id := scheduled.Data.(*model.ScheduledTransfer).ID
+
+	// Act
+	foreign := service.Cancel(principalContext(model.RoleCustomer, &other), id)
+	missing := service.Cancel(principalContext(model.RoleCustomer, &other), 999)
+	operator := service.Cancel(principalContext(model.RoleOperator, nil), id)
+	own := service.Cancel(principalContext(model.RoleCustomer, &owner), id)
+
+	// Assert
+	if foreign.Status != http.StatusNotFound || foreign.Error != missing.Error {
+		t.Errorf("Expected another customer's transfer to look missing, got %d: %s", foreign.Status, foreign.Error)
+	}
+	if operator.Status != http.StatusForbidden {
+		t.Errorf("Expected status %d for an operator, who may read all, got %d", http.StatusForbidden, operator.Status)
+	}
+	if !own.Success {
+		t.Errorf("Expected the owner to cancel, got %d: %s", own.Status, own.Error)
+	}
+}
+
+func TestTransfer_IdempotencyKeyBelongsToCaller(t *testing.T) {
+	// Arrange
+	service, accountRepo, transactionRepo, _ := newTestTransactionService()
+	owner, other := 7, 8
+	accountRepo.accounts[1].CustomerID = &owner
+	transfer := model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(10.0)}
+	key := &model.IdempotencyKey{Key: "retry-1", Fingerprint: "fp-1"}
+	admin := func(subject string) context.Context {
+		return middleware.WithPrincipal(context.Background(), &model.Principal{Subject: subject, Method: model.AuthMethodAPIKey, Role: model.RoleAdmin})
+	}
+
+	// Act
+	first := service.TransferIdempotent(principalContext(model.RoleCustomer, &owner), transfer, key)
+	stranger := service.TransferIdempotent(principalContext(model.RoleCustomer, &other), transfer, key)
+	adminOne := service.TransferIdempotent(admin("api_key:1"), transfer, key)
+	adminTwo := service.TransferIdempotent(admin("api_key:2"), transfer, key)
+
+	// Assert
+	if !first.Success {
+		t.Fatalf("Expected the owner's transfer to succeed, got %d: %s", first.Status, first.Error)
+	}
+	if stranger.Success || stranger.Replayed {
+		t.Errorf("Expected another customer to be refused rather than replayed, got %d", stranger.Status)
+	}
+	if adminOne.Replayed || adminTwo.Replayed {
+		t.Error("Expected each caller's key to be its own")
+	}
+	if len(transactionRepo.transactions) != 3 {
+		t.Errorf("Expected 3 transfers, got %d", len(transactionRepo.transactions))
+	}
+}
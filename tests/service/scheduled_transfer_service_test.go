@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+	"transfer-service/model"
+	svc "transfer-service/service"
+	"github.com/shopspring/decimal"
+)
+
+// MockScheduledTransferRepository keeps scheduled transfers in memory
+type MockScheduledTransferRepository struct {
+	transfers map[int]*model.ScheduledTransfer
+	nextID    int
+}
+
+func NewMockScheduledTransferRepository() *MockScheduledTransferRepository {
+	return &MockScheduledTransferRepository{
+		transfers: make(map[int]*model.ScheduledTransfer),
+		nextID:    1,
+	}
+}
+
+func (m *MockScheduledTransferRepository) CreateWithTx(ctx context.Context, tx *sql.Tx, s model.ScheduledTransfer) (*model.ScheduledTransfer, error) {
+	s.ID = m.nextID
+	s.Status = model.ScheduledStatusScheduled
+	m.transfers[s.ID] = &s
+	m.nextID++
+	created := s
+	return &created, nil
+}
+
+func (m *MockScheduledTransferRepository) GetByID(ctx context.Context, id int) (*model.ScheduledTransfer, error) {
+	if s, exists := m.transfers[id]; exists {
+		found := *s
+		return &found, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockScheduledTransferRepository) ClaimDueWithTx(ctx context.Context, tx *sql.Tx, now time.Time, limit int) ([]*model.ScheduledTransfer, error) {
+	var due []*model.ScheduledTransfer
+	for _, s := range m.transfers {
+		waiting := s.NextAttemptAt != nil && s.NextAttemptAt.After(now)
+		if s.Status == model.ScheduledStatusScheduled && !s.ExecuteAt.After(now) && !waiting {
+			claimed := *s
+			due = append(due, &claimed)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (m *MockScheduledTransferRepository) MarkExecutedWithTx(ctx context.Context, tx *sql.Tx, id int, transactionID int) error {
+	m.transfers[id].Status = model.ScheduledStatusExecuted
+	m.transfers[id].TransactionID = &transactionID
+	return nil
+}
+
+func (m *MockScheduledTransferRepository) MarkFailedWithTx(ctx context.Context, tx *sql.Tx, id int, reason string) error {
+	if m.transfers[id].Status == model.ScheduledStatusScheduled {
+		m.transfers[id].Status = model.ScheduledStatusFailed
+		m.transfers[id].FailureReason = reason
+	}
+	return nil
+}
+
+func (m *MockScheduledTransferRepository) MarkRetryWithTx(ctx context.Context, tx *sql.Tx, id int, reason string, nextAttemptAt time.Time) error {
+	if m.transfers[id].Status == model.ScheduledStatusScheduled {
+		m.transfers[id].Attempts++
+		m.transfers[id].FailureReason = reason
+		m.transfers[id].NextAttemptAt = &nextAttemptAt
+	}
+	return nil
+}
+
+func (m *MockScheduledTransferRepository) Cancel(ctx context.Context, id int) (*model.ScheduledTransfer, error) {
+	s, exists := m.transfers[id]
+	if !exists || s.Status != model.ScheduledStatusScheduled {
+		return nil, sql.ErrNoRows
+	}
+	s.Status = model.ScheduledStatusCancelled
+	cancelled := *s
+	return &cancelled, nil
+}
+
+func newTestScheduledTransferService() (*svc.ScheduledTransferService, *SimpleMockAccountRepository, *MockScheduledTransferRepository) {
+	transactions, accountRepo, _, _ := newTestTransactionService()
+	repo := NewMockScheduledTransferRepository()
+	service := svc.NewScheduledTransferService(repo, accountRepo, NewMockIdempotencyRepository(), transactions, &MockUnitOfWork{})
+	return service, accountRepo, repo
+}
+
+func TestSchedule_RejectsPastExecuteAt(t *testing.T) {
+	// Arrange
+	service, _, _ := newTestScheduledTransferService()
+	st := model.ScheduledTransfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(10.0), ExecuteAt: time.Now().Add(-time.Minute)}
+
+	// Act
+	result := service.Schedule(testContext(), st)
+
+	// Assert
+	if result.Status != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, result.Status)
+	}
+}
+
+func TestSchedule_ByPublicID(t *testing.T) {
+	// Arrange
+	service, accountRepo, _ := newTestScheduledTransferService()
+	accountRepo.accounts[1].PublicID = "acc_source"
+	accountRepo.accounts[2].PublicID = "acc_destination"
+	executeAt := time.Now().Add(time.Hour)
+
+	// Act
+	result := service.Schedule(testContext(), model.ScheduledTransfer{SourceAccount: "acc_source", DestinationAccount: "acc_destination", Amount: decimal.NewFromFloat(10.0), ExecuteAt: executeAt})
+	mismatch := service.Schedule(testContext(), model.ScheduledTransfer{SourceAccount: "acc_source", SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromFloat(10.0), ExecuteAt: executeAt})
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	scheduled := result.Data.(*model.ScheduledTransfer)
+	if scheduled.SourceAccountID != 1 || scheduled.DestinationAccountID != 2 {
+		t.Errorf("Expected transfer from 1 to 2, got %d to %d", scheduled.SourceAccountID, scheduled.DestinationAccountID)
+	}
+	if mismatch.Status != http.StatusBadRequest {
+		t.Errorf("Expected 400 when public ID and account ID disagree, got %d", mismatch.Status)
+	}
+}
+
+func TestExecuteDue_ExecutesAndMarksTransfers(t *testing.T) {
+	// Arrange
+	service, accountRepo, repo := newTestScheduledTransferService()
+	due := service.Schedule(testContext(), model.ScheduledTransfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(100.0), ExecuteAt: time.Now().Add(time.Hour)})
+	tooBig := service.Schedule(testContext(), model.ScheduledTransfer{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromFloat(5000.0), ExecuteAt: time.Now().Add(time.Hour)})
+	later := service.Schedule(testContext(), model.ScheduledTransfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(1.0), ExecuteAt: time.Now().Add(2 * time.Hour)})
+	// Make the first two due
+	repo.transfers[due.Data.(*model.ScheduledTransfer).ID].ExecuteAt = time.Now().Add(-time.Second)
+	repo.transfers[tooBig.Data.(*model.ScheduledTransfer).ID].ExecuteAt = time.Now().Add(-time.Second)
+
+	// Act
+	processed, err := service.ExecuteDue(testContext())
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if processed != 2 {
+		t.Errorf("Expected 2 processed transfers, got %d", processed)
+	}
+	executed := repo.transfers[due.Data.(*model.ScheduledTransfer).ID]
+	if executed.Status != model.ScheduledStatusExecuted || executed.TransactionID == nil {
+		t.Errorf("Expected the due transfer to be executed, got %+v", executed)
+	}
+	if failed := repo.transfers[tooBig.Data.(*model.ScheduledTransfer).ID]; failed.Status != model.ScheduledStatusFailed || failed.FailureReason != "Insufficient balance" {
+		t.Errorf("Expected the oversized transfer to fail with a reason, got %+v", failed)
+	}
+	if pending := repo.transfers[later.Data.(*model.ScheduledTransfer).ID]; pending.Status != model.ScheduledStatusScheduled {
+		t.Errorf("Expected the future transfer to stay scheduled, got %s", pending.Status)
+	}
+	if !accountRepo.accounts[2].Balance.Equal(decimal.NewFromFloat(600.0)) {
+		t.Errorf("Expected destination balance 600, got %s", accountRepo.accounts[2].Balance)
+	}
+}
+
+func TestExecuteDue_RetriesDatabaseErrorsWithBackoff(t *testing.T) {
+	// Arrange
+	transactions, accountRepo, transactionRepo, _ := newTestTransactionService()
+	repo := NewMockScheduledTransferRepository()
+	service := svc.NewScheduledTransferService(repo, accountRepo, NewMockIdempotencyRepository(), transactions, &MockUnitOfWork{})
+	first := service.Schedule(testContext(), model.ScheduledTransfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(10.0), ExecuteAt: time.Now().Add(time.Hour)})
+	second := service.Schedule(testContext(), model.ScheduledTransfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(20.0), ExecuteAt: time.Now().Add(time.Hour)})
+	a := repo.transfers[first.Data.(*model.ScheduledTransfer).ID]
+	b := repo.transfers[second.Data.(*model.ScheduledTransfer).ID]
+	a.ExecuteAt = time.Now().Add(-time.Second)
+	b.ExecuteAt = time.Now().Add(-time.Second)
+	transactionRepo.createError = errors.New("connection reset")
+
+	// Act
+	failing, err := service.ExecuteDue(testContext())
+	waiting, _ := service.ExecuteDue(testContext())
+
+	// Assert
+	if err != nil || failing != 2 {
+		t.Fatalf("Expected both transfers to be processed past the failure, got %d: %v", failing, err)
+	}
+	if a.Status != model.ScheduledStatusScheduled || a.Attempts != 1 || a.NextAttemptAt == nil || !a.NextAttemptAt.After(time.Now()) {
+		t.Errorf("Expected the transfer to stay scheduled with a later attempt, got %+v", a)
+	}
+	if waiting != 0 {
+		t.Errorf("Expected transfers waiting to retry to be skipped, got %d processed", waiting)
+	}
+
+	// Act: the first transfer uses its last attempt; the second succeeds
+	past := time.Now().Add(-time.Second)
+	a.NextAttemptAt, a.Attempts = &past, 4
+	service.ExecuteDue(testContext())
+	transactionRepo.createError = nil
+	b.NextAttemptAt = &past
+	service.ExecuteDue(testContext())
+
+	// Assert
+	if a.Status != model.ScheduledStatusFailed {
+		t.Errorf("Expected the transfer to fail after its last attempt, got %s", a.Status)
+	}
+	if b.Status != model.ScheduledStatusExecuted {
+		t.Errorf("Expected the retried transfer to execute, got %s", b.Status)
+	}
+}
+
+func TestCancel_OnlyScheduledTransfers(t *testing.T) {
+	// Arrange
+	service, _, _ := newTestScheduledTransferService()
+	scheduled := service.Schedule(testContext(), model.ScheduledTransfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(10.0), ExecuteAt: time.Now().Add(time.Hour)})
+	id := scheduled.Data.(*model.ScheduledTransfer).ID
+
+	// Act
+	first := service.Cancel(testContext(), id)
+	second := service.Cancel(testContext(), id)
+	missing := service.Cancel(testContext(), 999)
+
+	// Assert
+	if !first.Success || first.Data.(*model.ScheduledTransfer).Status != model.ScheduledStatusCancelled {
+		t.Errorf("Expected the transfer to be cancelled, got %+v", first)
+	}
+	if second.Status != http.StatusConflict {
+		t.Errorf("Expected status %d for a cancelled transfer, got %d", http.StatusConflict, second.Status)
+	}
+	if missing.Status != http.StatusNotFound {
+		t.Errorf("Expected status %d for a missing transfer, got %d", http.StatusNotFound, missing.Status)
+	}
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+	"transfer-service/model"
+	svc "transfer-service/service"
+	"github.com/shopspring/decimal"
+)
+
+// MockStandingOrderRepository keeps standing orders and their runs in memory
+type MockStandingOrderRepository struct {
+	orders map[int]*model.StandingOrder
+	runs   []*model.StandingOrderRun
+	nextID int
+}
+
+func NewMockStandingOrderRepository() *MockStandingOrderRepository {
+	return &MockStandingOrderRepository{
+		orders: make(map[int]*model.StandingOrder),
+		nextID: 1,
+	}
+}
+
+func (m *MockStandingOrderRepository) CreateWithTx(ctx context.Context, tx *sql.Tx, o model.StandingOrder) (*model.StandingOrder, error) {
+	o.ID = m.nextID
+	m.orders[o.ID] = &o
+	m.nextID++
+	created := o
+	return &created, nil
+}
+
+func (m *MockStandingOrderRepository) GetByID(ctx context.Context, id int) (*model.StandingOrder, error) {
+	if o, exists := m.orders[id]; exists {
+		found := *o
+		return &found, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockStandingOrderRepository) GetByIDWithLock(ctx context.Context, tx *sql.Tx, id int) (*model.StandingOrder, error) {
+	return m.GetByID(ctx, id)
+}
+
+func (m *MockStandingOrderRepository) ClaimDueWithTx(ctx context.Context, tx *sql.Tx, now time.Time, limit int) ([]*model.StandingOrder, error) {
+	var due []*model.StandingOrder
+	for _, o := range m.orders {
+		if o.Status == model.StandingOrderActive && o.NextRunAt != nil && !o.NextRunAt.After(now) {
+			claimed := *o
+			due = append(due, &claimed)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (m *MockStandingOrderRepository) UpdateWithTx(ctx context.Context, tx *sql.Tx, o *model.StandingOrder) error {
+	updated := *o
+	m.orders[o.ID] = &updated
+	return nil
+}
+
+func (m *MockStandingOrderRepository) CreateRunWithTx(ctx context.Context, tx *sql.Tx, run model.StandingOrderRun) error {
+	run.ID = len(m.runs) + 1
+	m.runs = append(m.runs, &run)
+	return nil
+}
+
+func (m *MockStandingOrderRepository) GetRuns(ctx context.Context, orderID int) ([]*model.StandingOrderRun, error) {
+	var runs []*model.StandingOrderRun
+	for _, run := range m.runs {
+		if run.StandingOrderID == orderID {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+// makeDue moves an order's next run into the past without changing its occurrence
+func (m *MockStandingOrderRepository) makeDue(id int) {
+	past := time.Now().Add(-time.Second)
+	m.orders[id].NextRunAt = &past
+}
+
+func newTestStandingOrderService() (*svc.StandingOrderService, *SimpleMockAccountRepository, *SimpleMockTransactionRepository, *MockStandingOrderRepository) {
+	transactions, accountRepo, transactionRepo, _ := newTestTransactionService()
+	repo := NewMockStandingOrderRepository()
+	service := svc.NewStandingOrderService(repo, accountRepo, NewMockIdempotencyRepository(), transactions, &MockUnitOfWork{})
+	return service, accountRepo, transactionRepo, repo
+}
+
+func TestCreateStandingOrder_RequiresEnd(t *testing.T) {
+	// Arrange
+	service, _, _, _ := newTestStandingOrderService()
+	o := model.StandingOrder{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(10.0), Frequency: model.FrequencyDaily}
+	badCron := o
+	occurrences := 3
+	badCron.Frequency = model.FrequencyCron
+	badCron.Cron = "0 9 * *"
+	badCron.MaxOccurrences = &occurrences
+
+	// Act
+	noEnd := service.Create(testContext(), o)
+	invalidCron := service.Create(testContext(), badCron)
+
+	// Assert
+	if noEnd.Status != http.StatusBadRequest {
+		t.Errorf("Expected status %d without end_at or max_occurrences, got %d", http.StatusBadRequest, noEnd.Status)
+	}
+	if invalidCron.Status != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a four-field cron, got %d", http.StatusBadRequest, invalidCron.Status)
+	}
+}
+
+func TestExecuteDue_RunsOccurrenceAndLinksTransaction(t *testing.T) {
+	// Arrange
+	service, accountRepo, transactionRepo, repo := newTestStandingOrderService()
+	occurrences := 2
+	start := time.Date(2031, time.January, 31, 9, 0, 0, 0, time.UTC)
+	created := service.Create(testContext(), model.StandingOrder{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(100.0),
+		Frequency: model.FrequencyMonthly, DayOfMonth: 31, StartAt: start, MaxOccurrences: &occurrences})
+	if !created.Success {
+		t.Fatalf("Expected success, got failure: %s", created.Message)
+	}
+	id := created.Data.(*model.StandingOrder).ID
+	repo.makeDue(id)
+
+	// Act
+	processed, err := service.ExecuteDue(testContext())
+
+	// Assert
+	if err != nil || processed != 1 {
+		t.Fatalf("Expected 1 processed order, got %d (%v)", processed, err)
+	}
+	if !accountRepo.accounts[2].Balance.Equal(decimal.NewFromFloat(600.0)) {
+		t.Errorf("Expected destination balance 600, got %s", accountRepo.accounts[2].Balance)
+	}
+	executed := transactionRepo.transactions[1]
+	if executed == nil || executed.StandingOrderID == nil || *executed.StandingOrderID != id {
+		t.Errorf("Expected the transaction to link to standing order %d, got %+v", id, executed)
+	}
+	order := repo.orders[id]
+	if order.Occurrences != 1 {
+		t.Errorf("Expected 1 occurrence, got %d", order.Occurrences)
+	}
+	// February is too short, so the second occurrence moves to its last day
+	if expected := time.Date(2031, time.February, 28, 9, 0, 0, 0, time.UTC); !order.NextRunAt.Equal(expected) {
+		t.Errorf("Expected next run at %s, got %s", expected, order.NextRunAt)
+	}
+	if len(repo.runs) != 1 || repo.runs[0].Status != model.RunStatusSucceeded || *repo.runs[0].TransactionID != executed.ID {
+		t.Errorf("Expected one succeeded run linked to the transaction, got %+v", repo.runs)
+	}
+}
+
+func TestExecuteDue_RetriesThenSkipsOccurrence(t *testing.T) {
+	// Arrange
+	service, _, _, repo := newTestStandingOrderService()
+	occurrences := 3
+	start := time.Now().Add(time.Hour)
+	created := service.Create(testContext(), model.StandingOrder{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromFloat(5000.0),
+		Frequency: model.FrequencyDaily, StartAt: start, MaxOccurrences: &occurrences, MaxRetries: 1, RetryIntervalSeconds: 600})
+	id := created.Data.(*model.StandingOrder).ID
+
+	// Act
+	repo.makeDue(id)
+	_, firstErr := service.ExecuteDue(testContext())
+	afterRetry := *repo.orders[id]
+	repo.makeDue(id)
+	_, secondErr := service.ExecuteDue(testContext())
+
+	// Assert
+	if firstErr != nil || secondErr != nil {
+		t.Fatalf("Expected no errors, got %v and %v", firstErr, secondErr)
+	}
+	if afterRetry.FailedAttempts != 1 || !afterRetry.CurrentRunAt.Equal(start.UTC()) || afterRetry.NextRunAt.Before(time.Now().Add(9*time.Minute)) {
+		t.Errorf("Expected the occurrence to be retried in 10 minutes, got %+v", afterRetry)
+	}
+	order := repo.orders[id]
+	if order.Occurrences != 1 || order.FailedAttempts != 0 || !order.CurrentRunAt.Equal(start.UTC().Add(24*time.Hour)) {
+		t.Errorf("Expected the occurrence to be skipped after its retry, got %+v", order)
+	}
+	if len(repo.runs) != 2 || repo.runs[1].Attempt != 2 || repo.runs[1].FailureReason != "Insufficient balance" {
+		t.Errorf("Expected two failed runs, got %+v", repo.runs)
+	}
+}
+
+func TestExecuteDue_BacksOffDatabaseErrors(t *testing.T) {
+	// Arrange
+	service, _, transactionRepo, repo := newTestStandingOrderService()
+	occurrences := 3
+	start := time.Now().Add(time.Hour)
+	order := model.StandingOrder{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(10.0),
+		Frequency: model.FrequencyDaily, StartAt: start, MaxOccurrences: &occurrences}
+	a := service.Create(testContext(), order).Data.(*model.StandingOrder).ID
+	b := service.Create(testContext(), order).Data.(*model.StandingOrder).ID
+	repo.makeDue(a)
+	repo.makeDue(b)
+	transactionRepo.createError = errors.New("connection reset")
+
+	// Act
+	failing, err := service.ExecuteDue(testContext())
+	waiting, _ := service.ExecuteDue(testContext())
+
+	// Assert
+	if err != nil || failing != 2 {
+		t.Fatalf("Expected both orders to be processed past the failure, got %d: %v", failing, err)
+	}
+	if waiting != 0 {
+		t.Errorf("Expected orders waiting to retry to be skipped, got %d processed", waiting)
+	}
+	backedOff := repo.orders[a]
+	if backedOff.FailedAttempts != 1 || !backedOff.NextRunAt.After(time.Now()) || len(repo.runs) != 2 || repo.runs[0].Status != model.RunStatusFailed {
+		t.Errorf("Expected a failed run and a later attempt, got %+v and %d runs", backedOff, len(repo.runs))
+	}
+
+	// Act: the order uses its last attempt
+	repo.orders[a].FailedAttempts = 4
+	repo.makeDue(a)
+	service.ExecuteDue(testContext())
+
+	// Assert
+	if skipped := repo.orders[a]; skipped.FailedAttempts != 0 || !skipped.CurrentRunAt.Equal(start.UTC().Add(24*time.Hour)) {
+		t.Errorf("Expected the occurrence to be skipped after its last attempt, got %+v", skipped)
+	}
+}
+
+func TestPauseResumeCancel_StandingOrder(t *testing.T) {
+	// Arrange
+	service, _, _, _ := newTestStandingOrderService()
+	end := time.Now().Add(30 * 24 * time.Hour)
+	created := service.Create(testContext(), model.StandingOrder{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(10.0),
+		Frequency: model.FrequencyWeekly, EndAt: &end})
+	id := created.Data.(*model.StandingOrder).ID
+
+	// Act
+	paused := service.Pause(testContext(), id)
+	pausedAgain := service.Pause(testContext(), id)
+	resumed := service.Resume(testContext(), id)
+	cancelled := service.Cancel(testContext(), id)
+	resumeCancelled := service.Resume(testContext(), id)
+
+	// Assert
+	if paused.Data.(*model.StandingOrder).Status != model.StandingOrderPaused {
+		t.Errorf("Expected the order to be paused, got %+v", paused)
+	}
+	if pausedAgain.Status != http.StatusConflict {
+		t.Errorf("Expected status %d pausing a paused order, got %d", http.StatusConflict, pausedAgain.Status)
+	}
+	if order := resumed.Data.(*model.StandingOrder); order.Status != model.StandingOrderActive || order.NextRunAt.Before(time.Now().Add(-time.Minute)) {
+		t.Errorf("Expected the order to be active with a current next run, got %+v", order)
+	}
+	if order := cancelled.Data.(*model.StandingOrder); order.Status != model.StandingOrderCancelled || order.NextRunAt != nil {
+		t.Errorf("Expected the order to be cancelled, got %+v", order)
+	}
+	if resumeCancelled.Status != http.StatusConflict {
+		t.Errorf("Expected status %d resuming a cancelled order, got %d", http.StatusConflict, resumeCancelled.Status)
+	}
+}
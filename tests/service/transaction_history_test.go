@@ -0,0 +1,262 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+	"transfer-service/model"
+	svc "transfer-service/service"
+	"github.com/shopspring/decimal"
+)
+
+// newTestHistoryService returns a TransactionService over the given transactions
+func newTestHistoryService(transactions ...model.Transaction) *svc.TransactionService {
+	accountRepo := NewSimpleMockAccountRepository()
+	transactionRepo := NewSimpleMockTransactionRepository()
+	for _, t := range transactions {
+		tx := t
+		transactionRepo.transactions[tx.ID] = &tx
+	}
+	return svc.NewTransactionService(accountRepo, transactionRepo, NewMockIdempotencyRepository(), NewMockFXRepository(), NewMockHoldRepository(accountRepo), NewMockLimitRepository(), NewMockFeeRepository(), &MockUnitOfWork{})
+}
+
+func TestGetTransactionHistory_PagesWithCursor(t *testing.T) {
+	// Arrange
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	service := newTestHistoryService(
+		model.Transaction{ID: 1, SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10), CreatedAt: base},
+		model.Transaction{ID: 2, SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10), CreatedAt: base.Add(time.Minute)},
+		model.Transaction{ID: 3, SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(10), CreatedAt: base.Add(time.Minute)},
+		model.Transaction{ID: 4, SourceAccountID: 1, DestinationAccountID: 3, Amount: decimal.NewFromInt(10), CreatedAt: base.Add(time.Minute)},
+		model.Transaction{ID: 5, SourceAccountID: 3, DestinationAccountID: 2, Amount: decimal.NewFromInt(10), CreatedAt: base.Add(time.Hour)},
+	)
+
+	// Act
+	var ids []int
+	var pages int
+	page := svc.PageRequest{Limit: 2}
+	for {
+		result := service.GetTransactionHistory(testContext(), model.TransactionFilter{}, page)
+		if !result.Success {
+			t.Fatalf("Expected success, got failure: %s", result.Message)
+		}
+		history := result.Data.(*model.TransactionPage)
+		pages++
+		for _, tx := range history.Transactions {
+			ids = append(ids, tx.ID)
+		}
+		if history.NextCursor == "" || pages > 5 {
+			break
+		}
+		page.Cursor = history.NextCursor
+	}
+
+	// Assert
+	want := []int{5, 4, 3, 2, 1}
+	if len(ids) != len(want) || pages != 3 {
+		t.Fatalf("Expected %v over 3 pages, got %v over %d", want, ids, pages)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Errorf("Expected newest first by created_at then id %v, got %v", want, ids)
+			break
+		}
+	}
+}
+
+func TestGetAccountTransactionHistory_Pages(t *testing.T) {
+	// Arrange
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	service := newTestHistoryService(
+		model.Transaction{ID: 1, SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10), CreatedAt: base},
+		model.Transaction{ID: 2, SourceAccountID: 2, DestinationAccountID: 3, Amount: decimal.NewFromInt(10), CreatedAt: base.Add(time.Minute)},
+		model.Transaction{ID: 3, SourceAccountID: 3, DestinationAccountID: 1, Amount: decimal.NewFromInt(10), CreatedAt: base.Add(time.Hour)},
+	)
+
+	// Act
+	first := service.GetAccountTransactionHistory(testContext(), 1, model.TransactionFilter{}, svc.PageRequest{Limit: 1})
+	second := service.GetAccountTransactionHistory(testContext(), 1, model.TransactionFilter{}, svc.PageRequest{Limit: 1, Cursor: first.Data.(*model.TransactionPage).NextCursor})
+	badCursor := service.GetAccountTransactionHistory(testContext(), 1, model.TransactionFilter{}, svc.PageRequest{Cursor: "not-a-cursor"})
+	badLimit := service.GetAccountTransactionHistory(testContext(), 1, model.TransactionFilter{}, svc.PageRequest{Limit: svc.MaxPageLimit + 1})
+
+	// Assert
+	if page := first.Data.(*model.TransactionPage); len(page.Transactions) != 1 || page.Transactions[0].ID != 3 || page.NextCursor == "" {
+		t.Errorf("Expected transaction 3 and a next cursor, got %+v", page)
+	}
+	if page := second.Data.(*model.TransactionPage); len(page.Transactions) != 1 || page.Transactions[0].ID != 1 || page.NextCursor != "" {
+		t.Errorf("Expected transaction 1 on the last page, got %+v", page)
+	}
+	if badCursor.Success || badCursor.Status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a malformed cursor, got %d", badCursor.Status)
+	}
+	if badLimit.Success || badLimit.Status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a limit over %d, got %d", svc.MaxPageLimit, badLimit.Status)
+	}
+}
+
+func TestGetAccountTransactionHistory_Filters(t *testing.T) {
+	// Arrange
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	service := newTestHistoryService(
+		model.Transaction{ID: 1, SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10), CreatedAt: base},
+		model.Transaction{ID: 2, SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(200), CreatedAt: base.Add(time.Hour)},
+		model.Transaction{ID: 3, SourceAccountID: 1, DestinationAccountID: 3, Amount: decimal.NewFromInt(50), CreatedAt: base.AddDate(0, 0, 1)},
+		model.Transaction{ID: 4, SourceAccountID: 3, DestinationAccountID: 1, Amount: decimal.NewFromInt(75), CreatedAt: base.AddDate(0, 0, 2)},
+		model.Transaction{ID: 5, SourceAccountID: 2, DestinationAccountID: 3, Amount: decimal.NewFromInt(60), CreatedAt: base.AddDate(0, 0, 2)},
+	)
+	counterparty := 3
+	from, to := base.Add(time.Minute), base.AddDate(0, 0, 2)
+	min, max := decimal.NewFromInt(50), decimal.NewFromInt(100)
+	ids := func(result *svc.Result) []int {
+		var ids []int
+		for _, tx := range result.Data.(*model.TransactionPage).Transactions {
+			ids = append(ids, tx.ID)
+		}
+		return ids
+	}
+
+	// Act
+	outgoing := service.GetAccountTransactionHistory(testContext(), 1, model.TransactionFilter{Direction: model.DirectionOutgoing}, svc.PageRequest{})
+	withCounterparty := service.GetAccountTransactionHistory(testContext(), 1, model.TransactionFilter{CounterpartyID: &counterparty}, svc.PageRequest{})
+	dateRange := service.GetAccountTransactionHistory(testContext(), 1, model.TransactionFilter{From: &from, To: &to}, svc.PageRequest{})
+	amountRange := service.GetTransactionHistory(testContext(), model.TransactionFilter{MinAmount: &min, MaxAmount: &max}, svc.PageRequest{})
+
+	// Assert
+	cases := []struct {
+		name string
+		got  []int
+		want []int
+	}{
+		{"outgoing", ids(outgoing), []int{3, 1}},
+		{"counterparty", ids(withCounterparty), []int{4, 3}},
+		{"date range", ids(dateRange), []int{3, 2}},
+		{"amount range", ids(amountRange), []int{5, 4, 3}},
+	}
+	for _, c := range cases {
+		if len(c.got) != len(c.want) {
+			t.Errorf("Expected %s to return %v, got %v", c.name, c.want, c.got)
+			continue
+		}
+		for i := range c.want {
+			if c.got[i] != c.want[i] {
+				t.Errorf("Expected %s to return %v, got %v", c.name, c.want, c.got)
+				break
+			}
+		}
+	}
+}
+
+func TestGetAccountTransactionHistory_SignedEntries(t *testing.T) {
+	// Arrange
+	service, fees, _, _ := newTestFeeService()
+	fees.SetSchedule(testContext(), model.FeeSchedule{
+		AssetCode:    model.DefaultAssetCode,
+		Type:         model.FeeTypeFlat,
+		FlatAmount:   decimalRef("5"),
+		FeeAccountID: 9,
+	})
+	service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(100)})
+	service.Transfer(testContext(), model.Transaction{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(50)})
+
+	// Act
+	result := service.GetAccountTransactionHistory(testContext(), 1, model.TransactionFilter{}, svc.PageRequest{})
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	history := result.Data.(*model.TransactionPage).Transactions
+	if len(history) != 2 || history[0].Entry == nil || history[1].Entry == nil {
+		t.Fatalf("Expected 2 transactions with entries, got %+v", history)
+	}
+	incoming, outgoing := history[0].Entry, history[1].Entry
+	if !incoming.Amount.Equal(decimal.NewFromInt(50)) || !incoming.BalanceAfter.Equal(decimal.NewFromInt(945)) || incoming.CounterpartyAccountID != 2 {
+		t.Errorf("Expected +50 from account 2 leaving 945, got %+v", incoming)
+	}
+	if !outgoing.Amount.Equal(decimal.NewFromInt(-105)) || !outgoing.BalanceAfter.Equal(decimal.NewFromInt(895)) || outgoing.CounterpartyAccountID != 2 {
+		t.Errorf("Expected -105 including the fee to account 2 leaving 895, got %+v", outgoing)
+	}
+}
+
+func TestGetAccountStatement_BatchThroughIntermediateAccount(t *testing.T) {
+	// Arrange
+	service, accountRepo, _, _ := newTestTransactionService()
+	accountRepo.accounts[3] = &model.Account{ID: 3, AssetCode: model.DefaultAssetCode, Balance: decimal.Zero}
+	start := time.Now()
+	batch := service.TransferBatch(testContext(), svc.BatchRequest{Legs: []model.Transaction{
+		{SourceAccountID: 3, DestinationAccountID: 2, Amount: decimal.NewFromInt(10)},
+		{SourceAccountID: 1, DestinationAccountID: 3, Amount: decimal.NewFromInt(10)},
+	}})
+	end := time.Now().Add(time.Minute)
+
+	// Act
+	result := service.GetAccountStatement(testContext(), 3, start, end)
+	next := service.GetAccountStatement(testContext(), 3, end, end.Add(time.Minute))
+
+	// Assert
+	if !batch.Success {
+		t.Fatalf("Expected the batch to succeed, got %d: %s", batch.Status, batch.Error)
+	}
+	statement := result.Data.(*model.Statement)
+	if !statement.ClosingBalance.Equal(accountRepo.accounts[3].Balance) {
+		t.Errorf("Expected the closing balance %s, got %s", accountRepo.accounts[3].Balance, statement.ClosingBalance)
+	}
+	sum := statement.OpeningBalance
+	for _, entry := range statement.Entries {
+		sum = sum.Add(entry.Entry.Amount)
+	}
+	if !sum.Equal(statement.ClosingBalance) {
+		t.Errorf("Expected the entries to reconcile to the closing balance, got %s", sum)
+	}
+	if opening := next.Data.(*model.Statement).OpeningBalance; !opening.IsZero() {
+		t.Errorf("Expected the next statement to open at 0, got %s", opening)
+	}
+}
+
+func TestGetAccountStatement_OpeningAndClosingBalances(t *testing.T) {
+	// Arrange
+	service, fees, _, transactionRepo := newTestFeeService()
+	transactionRepo.openingBalances[1] = decimal.NewFromInt(1000)
+	fees.SetSchedule(testContext(), model.FeeSchedule{
+		AssetCode:    model.DefaultAssetCode,
+		Type:         model.FeeTypeFlat,
+		FlatAmount:   decimalRef("5"),
+		FeeAccountID: 9,
+	})
+	service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(100)})
+	start := time.Now()
+	service.Transfer(testContext(), model.Transaction{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(50)})
+	service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(20)})
+	end := time.Now().Add(time.Minute)
+
+	// Act
+	result := service.GetAccountStatement(testContext(), 1, start, end)
+	feeAccount := service.GetAccountStatement(testContext(), 9, start.Add(-time.Hour), end)
+	invalid := service.GetAccountStatement(testContext(), 1, end, start)
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	statement := result.Data.(*model.Statement)
+	if !statement.OpeningBalance.Equal(decimal.NewFromInt(895)) || !statement.ClosingBalance.Equal(decimal.NewFromInt(920)) {
+		t.Errorf("Expected opening 895 and closing 920, got %s and %s", statement.OpeningBalance, statement.ClosingBalance)
+	}
+	sum := statement.OpeningBalance
+	for _, entry := range statement.Entries {
+		sum = sum.Add(entry.Entry.Amount)
+	}
+	if len(statement.Entries) != 2 || !sum.Equal(statement.ClosingBalance) {
+		t.Errorf("Expected 2 entries reconciling to the closing balance, got %d summing to %s", len(statement.Entries), sum)
+	}
+	fee := feeAccount.Data.(*model.Statement)
+	if len(fee.Entries) != 3 || !fee.ClosingBalance.Equal(decimal.NewFromInt(15)) {
+		t.Fatalf("Expected the fee account's statement to list all 3 fees, got %+v", fee)
+	}
+	if fee.Entries[0].Entry.CounterpartyAccountID != 1 || fee.Entries[1].Entry.CounterpartyAccountID != 2 {
+		t.Errorf("Expected each fee's payer as its counterparty, got %d and %d", fee.Entries[0].Entry.CounterpartyAccountID, fee.Entries[1].Entry.CounterpartyAccountID)
+	}
+	if invalid.Success || invalid.Status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a range that ends before it starts, got %d", invalid.Status)
+	}
+}
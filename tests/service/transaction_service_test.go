@@ -3,9 +3,15 @@ package service
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"net/http"
+	"sort"
 	"testing"
+	"time"
+	"transfer-service/middleware"
 	"transfer-service/model"
+	svc "transfer-service/service"
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 )
 
@@ -15,15 +21,40 @@ type SimpleMockAccountRepository struct {
 	getError error
 }
 
+// testContext carries the system principal, which the policy allows
+// everything; the policy tests set their own principals
+func testContext() context.Context {
+	return middleware.WithPrincipal(context.Background(), model.SystemPrincipal("tests"))
+}
+
 func NewSimpleMockAccountRepository() *SimpleMockAccountRepository {
 	return &SimpleMockAccountRepository{
 		accounts: make(map[int]*model.Account),
 	}
 }
 
-func (m *SimpleMockAccountRepository) Create(ctx context.Context, account model.Account) error {
+func (m *SimpleMockAccountRepository) Create(ctx context.Context, account model.Account) (int, error) {
+	return m.CreateWithTx(ctx, nil, account)
+}
+
+func (m *SimpleMockAccountRepository) CreateWithTx(ctx context.Context, tx *sql.Tx, account model.Account) (int, error) {
+	if account.ID == 0 {
+		account.ID = nextAccountID(m.accounts)
+	}
 	m.accounts[account.ID] = &account
-	return nil
+	return account.ID, nil
+}
+
+// nextAccountID allocates an account ID as the sequence does once it has
+// skipped the IDs taken by legacy accounts
+func nextAccountID(accounts map[int]*model.Account) int {
+	next := 1
+	for id := range accounts {
+		if id >= next {
+			next = id + 1
+		}
+	}
+	return next
 }
 
 func (m *SimpleMockAccountRepository) GetByID(ctx context.Context, id int) (*model.Account, error) {
@@ -36,6 +67,26 @@ func (m *SimpleMockAccountRepository) GetByID(ctx context.Context, id int) (*mod
 	return nil, sql.ErrNoRows
 }
 
+func (m *SimpleMockAccountRepository) GetByPublicID(ctx context.Context, publicID string) (*model.Account, error) {
+	for _, account := range m.accounts {
+		if account.PublicID == publicID {
+			return account, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *SimpleMockAccountRepository) GetByCustomerID(ctx context.Context, customerID int) ([]*model.Account, error) {
+	accounts := []*model.Account{}
+	for _, account := range m.accounts {
+		if account.CustomerID != nil && *account.CustomerID == customerID {
+			accounts = append(accounts, account)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	return accounts, nil
+}
+
 func (m *SimpleMockAccountRepository) GetByIDWithLock(ctx context.Context, tx *sql.Tx, id int) (*model.Account, error) {
 	return m.GetByID(ctx, id)
 }
@@ -52,9 +103,39 @@ func (m *SimpleMockAccountRepository) UpdateBalanceWithTx(ctx context.Context, t
 	return m.UpdateBalance(ctx, id, newBalance)
 }
 
-func (m *SimpleMockAccountRepository) DeleteByID(ctx context.Context, id int) error {
-	delete(m.accounts, id)
-	return nil
+func (m *SimpleMockAccountRepository) ApplyPostingWithTx(ctx context.Context, tx *sql.Tx, id int, amount decimal.Decimal) (decimal.Decimal, error) {
+	if account, exists := m.accounts[id]; exists {
+		account.Balance = account.Balance.Add(amount)
+		return account.Balance, nil
+	}
+	return decimal.Zero, sql.ErrNoRows
+}
+
+func (m *SimpleMockAccountRepository) GetPostingsByAccountID(ctx context.Context, accountID int) ([]*model.Posting, error) {
+	return nil, nil
+}
+
+func (m *SimpleMockAccountRepository) GetLedgerBalance(ctx context.Context, id int) (decimal.Decimal, error) {
+	if account, exists := m.accounts[id]; exists {
+		return account.Balance, nil
+	}
+	return decimal.Zero, sql.ErrNoRows
+}
+
+func (m *SimpleMockAccountRepository) UpdateStatusWithTx(ctx context.Context, tx *sql.Tx, id int, status string) error {
+	if account, exists := m.accounts[id]; exists {
+		account.Status = status
+		return nil
+	}
+	return sql.ErrNoRows
+}
+
+func (m *SimpleMockAccountRepository) UpdateOverdraftLimitWithTx(ctx context.Context, tx *sql.Tx, id int, limit decimal.Decimal) error {
+	if account, exists := m.accounts[id]; exists {
+		account.OverdraftLimit = limit
+		return nil
+	}
+	return sql.ErrNoRows
 }
 
 func (m *SimpleMockAccountRepository) GetDB() *sql.DB {
@@ -63,32 +144,37 @@ func (m *SimpleMockAccountRepository) GetDB() *sql.DB {
 
 // SimpleMockTransactionRepository for testing
 type SimpleMockTransactionRepository struct {
-	transactions map[int]*model.Transaction
-	nextID       int
-	createError  error
+	transactions    map[int]*model.Transaction
+	postings        []*model.Posting
+	openingBalances map[int]decimal.Decimal
+	nextID          int
+	createError     error
+	postingsError   error
 }
 
 func NewSimpleMockTransactionRepository() *SimpleMockTransactionRepository {
 	return &SimpleMockTransactionRepository{
-		transactions: make(map[int]*model.Transaction),
-		nextID:       1,
+		transactions:    make(map[int]*model.Transaction),
+		openingBalances: make(map[int]decimal.Decimal),
+		nextID:          1,
 	}
 }
 
 func (m *SimpleMockTransactionRepository) Create(ctx context.Context, tx model.Transaction) (*model.Transaction, error) {
+	return m.CreateWithTx(ctx, nil, tx)
+}
+
+func (m *SimpleMockTransactionRepository) CreateWithTx(ctx context.Context, sqlTx *sql.Tx, tx model.Transaction) (*model.Transaction, error) {
 	if m.createError != nil {
 		return nil, m.createError
 	}
 	
-	createdTx := &model.Transaction{
-		ID:                   m.nextID,
-		SourceAccountID:      tx.SourceAccountID,
-		DestinationAccountID: tx.DestinationAccountID,
-		Amount:               tx.Amount,
-	}
-	m.transactions[m.nextID] = createdTx
+	createdTx := tx
+	createdTx.ID = m.nextID
+	createdTx.CreatedAt = time.Now()
+	m.transactions[m.nextID] = &createdTx
 	m.nextID++
-	return createdTx, nil
+	return &createdTx, nil
 }
 
 func (m *SimpleMockTransactionRepository) GetByID(ctx context.Context, id int) (*model.Transaction, error) {
@@ -98,6 +184,20 @@ func (m *SimpleMockTransactionRepository) GetByID(ctx context.Context, id int) (
 	return nil, sql.ErrNoRows
 }
 
+func (m *SimpleMockTransactionRepository) GetByIDWithLock(ctx context.Context, sqlTx *sql.Tx, id int) (*model.Transaction, error) {
+	return m.GetByID(ctx, id)
+}
+
+func (m *SimpleMockTransactionRepository) GetReversalsWithTx(ctx context.Context, sqlTx *sql.Tx, originalID int) ([]*model.Transaction, error) {
+	var result []*model.Transaction
+	for _, tx := range m.transactions {
+		if tx.ReversalOf != nil && *tx.ReversalOf == originalID {
+			result = append(result, tx)
+		}
+	}
+	return result, nil
+}
+
 func (m *SimpleMockTransactionRepository) GetByAccountID(ctx context.Context, accountID int) ([]*model.Transaction, error) {
 	var result []*model.Transaction
 	for _, tx := range m.transactions {
@@ -116,7 +216,655 @@ func (m *SimpleMockTransactionRepository) GetAll(ctx context.Context) ([]*model.
 	return result, nil
 }
 
+func (m *SimpleMockTransactionRepository) GetAllPage(ctx context.Context, filter model.TransactionFilter, after *model.TransactionCursor, limit int) ([]*model.Transaction, error) {
+	return m.page(filter, after, limit), nil
+}
+
+func (m *SimpleMockTransactionRepository) GetByAccountIDPage(ctx context.Context, accountID int, filter model.TransactionFilter, after *model.TransactionCursor, limit int) ([]*model.Transaction, error) {
+	filter.AccountID = &accountID
+	return m.page(filter, after, limit), nil
+}
+
+// page returns up to limit transactions matching the filter after the cursor,
+// newest first by created_at and then id
+func (m *SimpleMockTransactionRepository) page(filter model.TransactionFilter, after *model.TransactionCursor, limit int) []*model.Transaction {
+	var result []*model.Transaction
+	for _, tx := range m.transactions {
+		if !matchesFilter(tx, filter) {
+			continue
+		}
+		if after != nil && !tx.CreatedAt.Before(after.CreatedAt) && !(tx.CreatedAt.Equal(after.CreatedAt) && tx.ID < after.ID) {
+			continue
+		}
+		result = append(result, tx)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.After(result[j].CreatedAt)
+		}
+		return result[i].ID > result[j].ID
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// matchesFilter applies a history filter the way the repository's SQL does
+func matchesFilter(tx *model.Transaction, f model.TransactionFilter) bool {
+	if f.AccountID != nil {
+		outgoing := tx.SourceAccountID == *f.AccountID && (f.CounterpartyID == nil || tx.DestinationAccountID == *f.CounterpartyID)
+		incoming := tx.DestinationAccountID == *f.AccountID && (f.CounterpartyID == nil || tx.SourceAccountID == *f.CounterpartyID)
+		switch f.Direction {
+		case model.DirectionOutgoing:
+			incoming = false
+		case model.DirectionIncoming:
+			outgoing = false
+		}
+		if !outgoing && !incoming {
+			return false
+		}
+	}
+	if f.From != nil && tx.CreatedAt.Before(*f.From) {
+		return false
+	}
+	if f.To != nil && !tx.CreatedAt.Before(*f.To) {
+		return false
+	}
+	if f.MinAmount != nil && tx.Amount.LessThan(*f.MinAmount) {
+		return false
+	}
+	if f.MaxAmount != nil && tx.Amount.GreaterThan(*f.MaxAmount) {
+		return false
+	}
+	return true
+}
+
+func (m *SimpleMockTransactionRepository) CreatePostingsWithTx(ctx context.Context, tx *sql.Tx, postings []model.Posting) error {
+	if m.postingsError != nil {
+		return m.postingsError
+	}
+	for _, p := range postings {
+		posting := p
+		posting.ID = len(m.postings) + 1
+		posting.CreatedAt = time.Now()
+		m.postings = append(m.postings, &posting)
+	}
+	return nil
+}
+
+func (m *SimpleMockTransactionRepository) GetPostingsByTransactionID(ctx context.Context, transactionID int) ([]*model.Posting, error) {
+	var result []*model.Posting
+	for _, p := range m.postings {
+		if p.TransactionID == transactionID {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+func (m *SimpleMockTransactionRepository) GetByIDs(ctx context.Context, ids []int) ([]*model.Transaction, error) {
+	var result []*model.Transaction
+	for _, id := range ids {
+		if tx, exists := m.transactions[id]; exists {
+			result = append(result, tx)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (m *SimpleMockTransactionRepository) GetAccountPostings(ctx context.Context, accountID int, transactionIDs []int) ([]*model.Posting, error) {
+	var result []*model.Posting
+	for _, p := range m.postings {
+		for _, id := range transactionIDs {
+			if p.AccountID == accountID && p.TransactionID == id {
+				result = append(result, p)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (m *SimpleMockTransactionRepository) GetAccountPostingsBetween(ctx context.Context, accountID int, from, to time.Time) ([]*model.Posting, error) {
+	var result []*model.Posting
+	for _, p := range m.postings {
+		if p.AccountID == accountID && !p.CreatedAt.Before(from) && p.CreatedAt.Before(to) {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+// GetBalanceBefore returns the balance after the account's last posting before
+// the given time, or its opening balance
+func (m *SimpleMockTransactionRepository) GetBalanceBefore(ctx context.Context, accountID int, before time.Time) (decimal.Decimal, error) {
+	balance := m.openingBalances[accountID]
+	for _, p := range m.postings {
+		if p.AccountID == accountID && p.CreatedAt.Before(before) {
+			balance = p.BalanceAfter
+		}
+	}
+	return balance, nil
+}
+
+// MockIdempotencyRepository stores idempotency records in memory
+type MockIdempotencyRepository struct {
+	records map[string]*model.IdempotencyRecord
+}
+
+func NewMockIdempotencyRepository() *MockIdempotencyRepository {
+	return &MockIdempotencyRepository{
+		records: make(map[string]*model.IdempotencyRecord),
+	}
+}
+
+func (m *MockIdempotencyRepository) GetWithTx(ctx context.Context, tx *sql.Tx, principal, scope, key string) (*model.IdempotencyRecord, error) {
+	if record, exists := m.records[principal+"/"+scope+"/"+key]; exists {
+		return record, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockIdempotencyRepository) CreateWithTx(ctx context.Context, tx *sql.Tx, record model.IdempotencyRecord) error {
+	id := record.Principal + "/" + record.Scope + "/" + record.Key
+	if _, exists := m.records[id]; exists {
+		return &pq.Error{Code: "23505"}
+	}
+	m.records[id] = &record
+	return nil
+}
+
+// MockUnitOfWork runs the unit of work without a database and records its outcome
+type MockUnitOfWork struct {
+	commits   int
+	rollbacks int
+	attempts  int
+	failures  []error // returned by successive calls before fn runs
+}
+
+func (m *MockUnitOfWork) Do(ctx context.Context, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	m.attempts++
+	if len(m.failures) > 0 {
+		err := m.failures[0]
+		m.failures = m.failures[1:]
+		m.rollbacks++
+		return err
+	}
+	if err := fn(nil); err != nil {
+		m.rollbacks++
+		return err
+	}
+	m.commits++
+	return nil
+}
+
+// newTestTransactionService wires a TransactionService with two funded accounts
+func newTestTransactionService() (*svc.TransactionService, *SimpleMockAccountRepository, *SimpleMockTransactionRepository, *MockUnitOfWork) {
+	accountRepo := NewSimpleMockAccountRepository()
+	accountRepo.accounts[1] = &model.Account{ID: 1, AssetCode: model.DefaultAssetCode, Balance: decimal.NewFromFloat(1000.0)}
+	accountRepo.accounts[2] = &model.Account{ID: 2, AssetCode: model.DefaultAssetCode, Balance: decimal.NewFromFloat(500.0)}
+	transactionRepo := NewSimpleMockTransactionRepository()
+	uow := &MockUnitOfWork{}
+	return svc.NewTransactionService(accountRepo, transactionRepo, NewMockIdempotencyRepository(), NewMockFXRepository(), NewMockHoldRepository(accountRepo), NewMockLimitRepository(), NewMockFeeRepository(), uow), accountRepo, transactionRepo, uow
+}
+
+func TestTransfer_Success(t *testing.T) {
+	// Arrange
+	service, accountRepo, transactionRepo, uow := newTestTransactionService()
+	transfer := model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(300.0)}
+
+	// Act
+	result := service.Transfer(testContext(), transfer)
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	if !accountRepo.accounts[1].Balance.Equal(decimal.NewFromFloat(700.0)) {
+		t.Errorf("Expected source balance 700, got %s", accountRepo.accounts[1].Balance)
+	}
+	if !accountRepo.accounts[2].Balance.Equal(decimal.NewFromFloat(800.0)) {
+		t.Errorf("Expected destination balance 800, got %s", accountRepo.accounts[2].Balance)
+	}
+	if len(transactionRepo.postings) != 2 {
+		t.Fatalf("Expected 2 postings, got %d", len(transactionRepo.postings))
+	}
+	if !transactionRepo.postings[0].Amount.Add(transactionRepo.postings[1].Amount).IsZero() {
+		t.Error("Expected postings to sum to zero")
+	}
+	if uow.commits != 1 || uow.rollbacks != 0 {
+		t.Errorf("Expected 1 commit and 0 rollbacks, got %d and %d", uow.commits, uow.rollbacks)
+	}
+}
+
+func TestTransfer_ByPublicID(t *testing.T) {
+	// Arrange
+	service, accountRepo, _, _ := newTestTransactionService()
+	accountRepo.accounts[1].PublicID = "acc_source"
+	accountRepo.accounts[2].PublicID = "acc_destination"
+
+	// Act
+	result := service.Transfer(testContext(), model.Transaction{SourceAccount: "acc_source", DestinationAccount: "acc_destination", Amount: decimal.NewFromFloat(100.0)})
+	mismatch := service.Transfer(testContext(), model.Transaction{SourceAccount: "acc_source", SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromFloat(1.0)})
+	unknown := service.Transfer(testContext(), model.Transaction{SourceAccount: "acc_source", DestinationAccount: "acc_missing", Amount: decimal.NewFromFloat(1.0)})
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	tx := result.Data.(*svc.TransferReceipt).Transaction
+	if tx.SourceAccountID != 1 || tx.DestinationAccountID != 2 {
+		t.Errorf("Expected transfer from 1 to 2, got %d to %d", tx.SourceAccountID, tx.DestinationAccountID)
+	}
+	if mismatch.Status != http.StatusBadRequest {
+		t.Errorf("Expected 400 when public ID and account ID disagree, got %d", mismatch.Status)
+	}
+	if unknown.Status != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown destination public ID, got %d", unknown.Status)
+	}
+}
+
+func TestTransfer_InsufficientBalance(t *testing.T) {
+	// Arrange
+	service, _, transactionRepo, uow := newTestTransactionService()
+	transfer := model.Transaction{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromFloat(600.0)}
+
+	// Act
+	result := service.Transfer(testContext(), transfer)
+
+	// Assert
+	if result.Success {
+		t.Fatal("Expected failure for insufficient balance, got success")
+	}
+	if result.Status != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, result.Status)
+	}
+	if len(transactionRepo.transactions) != 0 {
+		t.Errorf("Expected no logged transactions, got %d", len(transactionRepo.transactions))
+	}
+	if uow.rollbacks != 1 {
+		t.Errorf("Expected 1 rollback, got %d", uow.rollbacks)
+	}
+}
+
+func TestTransfer_WithinOverdraftLimit(t *testing.T) {
+	// Arrange
+	service, accountRepo, _, _ := newTestTransactionService()
+	accountRepo.accounts[2].OverdraftLimit = decimal.NewFromFloat(200.0)
+
+	// Act
+	overdrawn := service.Transfer(testContext(), model.Transaction{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromFloat(600.0)})
+	pastLimit := service.Transfer(testContext(), model.Transaction{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromFloat(150.0)})
+
+	// Assert
+	if !overdrawn.Success {
+		t.Fatalf("Expected success within the overdraft limit, got failure: %s", overdrawn.Message)
+	}
+	if !accountRepo.accounts[2].Balance.Equal(decimal.NewFromFloat(-100.0)) {
+		t.Errorf("Expected balance -100, got %s", accountRepo.accounts[2].Balance)
+	}
+	if pastLimit.Status != http.StatusBadRequest {
+		t.Errorf("Expected status %d past the overdraft limit, got %d", http.StatusBadRequest, pastLimit.Status)
+	}
+}
+
+func TestTransfer_LogFailureRollsBack(t *testing.T) {
+	// Arrange
+	service, _, transactionRepo, uow := newTestTransactionService()
+	transactionRepo.createError = errors.New("insert failed")
+	transfer := model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(100.0)}
+
+	// Act
+	result := service.Transfer(testContext(), transfer)
+
+	// Assert
+	if result.Success {
+		t.Fatal("Expected failure when logging the transaction fails, got success")
+	}
+	if result.Status != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, result.Status)
+	}
+	if uow.commits != 0 || uow.rollbacks != 1 {
+		t.Errorf("Expected the unit of work to roll back, got %d commits and %d rollbacks", uow.commits, uow.rollbacks)
+	}
+}
+
+func TestTransfer_PostingsFailureRollsBackLogRow(t *testing.T) {
+	// Arrange
+	service, _, transactionRepo, uow := newTestTransactionService()
+	transactionRepo.postingsError = errors.New("insert failed")
+	transfer := model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(100.0)}
+
+	// Act
+	result := service.Transfer(testContext(), transfer)
+
+	// Assert
+	if result.Success {
+		t.Fatal("Expected failure when recording the postings fails, got success")
+	}
+	if uow.commits != 0 || uow.rollbacks != 1 {
+		t.Errorf("Expected the transaction log row and postings to roll back together, got %d commits and %d rollbacks", uow.commits, uow.rollbacks)
+	}
+}
+
+func TestTransfer_RetriesSerializationFailure(t *testing.T) {
+	// Arrange
+	service, accountRepo, _, uow := newTestTransactionService()
+	uow.failures = []error{&pq.Error{Code: "40001"}, &pq.Error{Code: "40P01"}}
+	transfer := model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(100.0)}
+
+	// Act
+	result := service.Transfer(testContext(), transfer)
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success after retries, got failure: %s", result.Message)
+	}
+	if uow.attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", uow.attempts)
+	}
+	if !accountRepo.accounts[1].Balance.Equal(decimal.NewFromFloat(900.0)) {
+		t.Errorf("Expected source balance 900, got %s", accountRepo.accounts[1].Balance)
+	}
+}
+
+func TestTransfer_RetriesExhausted(t *testing.T) {
+	// Arrange
+	service, _, _, uow := newTestTransactionService()
+	for i := 0; i < 10; i++ {
+		uow.failures = append(uow.failures, &pq.Error{Code: "40001"})
+	}
+	transfer := model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(100.0)}
+
+	// Act
+	result := service.Transfer(testContext(), transfer)
+
+	// Assert
+	if result.Success {
+		t.Fatal("Expected failure after exhausting retries, got success")
+	}
+	if result.Status != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, result.Status)
+	}
+	if uow.attempts >= 10 {
+		t.Errorf("Expected retries to be bounded, got %d attempts", uow.attempts)
+	}
+}
+
+func TestTransfer_IdempotentReplay(t *testing.T) {
+	// Arrange
+	service, accountRepo, transactionRepo, _ := newTestTransactionService()
+	transfer := model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(100.0)}
+	key := &model.IdempotencyKey{Key: "retry-1", Fingerprint: "fp-1"}
+
+	// Act
+	first := service.TransferIdempotent(testContext(), transfer, key)
+	second := service.TransferIdempotent(testContext(), transfer, key)
+
+	// Assert
+	if !first.Success || !second.Success {
+		t.Fatalf("Expected both requests to succeed, got %q and %q", first.Message, second.Message)
+	}
+	if !second.Replayed {
+		t.Error("Expected the second request to be replayed")
+	}
+	if len(transactionRepo.transactions) != 1 {
+		t.Errorf("Expected money to move once, got %d transactions", len(transactionRepo.transactions))
+	}
+	if !accountRepo.accounts[1].Balance.Equal(decimal.NewFromFloat(900.0)) {
+		t.Errorf("Expected source balance 900, got %s", accountRepo.accounts[1].Balance)
+	}
+}
+
+func TestTransfer_IdempotencyKeyReused(t *testing.T) {
+	// Arrange
+	service, _, transactionRepo, _ := newTestTransactionService()
+	first := model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(100.0)}
+	second := model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(200.0)}
+
+	// Act
+	service.TransferIdempotent(testContext(), first, &model.IdempotencyKey{Key: "retry-1", Fingerprint: "fp-1"})
+	result := service.TransferIdempotent(testContext(), second, &model.IdempotencyKey{Key: "retry-1", Fingerprint: "fp-2"})
+
+	// Assert
+	if result.Success {
+		t.Fatal("Expected failure for a reused idempotency key, got success")
+	}
+	if result.Status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, result.Status)
+	}
+	if len(transactionRepo.transactions) != 1 {
+		t.Errorf("Expected 1 transaction, got %d", len(transactionRepo.transactions))
+	}
+}
+
+func TestTransfer_CrossAssetRequiresConversion(t *testing.T) {
+	// Arrange
+	service, accountRepo, _, _ := newTestTransactionService()
+	model.RegisterAsset(model.Asset{Code: "EUR", Scale: 2})
+	accountRepo.accounts[3] = &model.Account{ID: 3, AssetCode: "EUR", Balance: decimal.NewFromFloat(100.0)}
+	transfer := model.Transaction{SourceAccountID: 1, DestinationAccountID: 3, Amount: decimal.NewFromFloat(10.0)}
+
+	// Act
+	result := service.Transfer(testContext(), transfer)
+
+	// Assert
+	if result.Success {
+		t.Fatal("Expected failure for a cross-asset transfer without conversion, got success")
+	}
+	if result.Status != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, result.Status)
+	}
+	if !accountRepo.accounts[1].Balance.Equal(decimal.NewFromFloat(1000.0)) {
+		t.Errorf("Expected source balance to stay 1000, got %s", accountRepo.accounts[1].Balance)
+	}
+}
+
+func TestTransfer_PrecisionExceedsAssetScale(t *testing.T) {
+	// Arrange
+	service, _, _, _ := newTestTransactionService()
+	transfer := model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString("10.123456")}
+
+	// Act
+	result := service.Transfer(testContext(), transfer)
+
+	// Assert
+	if result.Success {
+		t.Fatal("Expected failure for an amount beyond the asset scale, got success")
+	}
+	if result.Status != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, result.Status)
+	}
+	if result.Message != "Amount must have at most 5 decimal places" {
+		t.Errorf("Expected message 'Amount must have at most 5 decimal places', got '%s'", result.Message)
+	}
+}
+
 // Test the business logic validation that happens before database transactions
+func TestReverse_FullRefund(t *testing.T) {
+	// Arrange
+	service, accountRepo, transactionRepo, _ := newTestTransactionService()
+	transfer := service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(100.0)})
+	original := transfer.Data.(*svc.TransferReceipt).Transaction
+
+	// Act
+	result := service.Reverse(testContext(), original.ID, svc.ReversalRequest{})
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	reversal := result.Data.(*svc.TransferReceipt).Transaction
+	if reversal.Kind != model.TransactionKindReversal || reversal.ReversalOf == nil || *reversal.ReversalOf != original.ID {
+		t.Errorf("Expected a reversal linked to transaction %d, got %+v", original.ID, reversal)
+	}
+	if !accountRepo.accounts[1].Balance.Equal(decimal.NewFromFloat(1000.0)) {
+		t.Errorf("Expected source balance restored to 1000, got %s", accountRepo.accounts[1].Balance)
+	}
+	if !accountRepo.accounts[2].Balance.Equal(decimal.NewFromFloat(500.0)) {
+		t.Errorf("Expected destination balance restored to 500, got %s", accountRepo.accounts[2].Balance)
+	}
+	if len(transactionRepo.postings) != 4 {
+		t.Errorf("Expected 4 postings, got %d", len(transactionRepo.postings))
+	}
+}
+
+func TestReverse_PartialRefundsCannotExceedOriginal(t *testing.T) {
+	// Arrange
+	service, accountRepo, _, _ := newTestTransactionService()
+	transfer := service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(100.0)})
+	original := transfer.Data.(*svc.TransferReceipt).Transaction
+	sixty := decimal.NewFromFloat(60.0)
+
+	// Act
+	first := service.Reverse(testContext(), original.ID, svc.ReversalRequest{Amount: &sixty})
+	second := service.Reverse(testContext(), original.ID, svc.ReversalRequest{Amount: &sixty})
+	rest := service.Reverse(testContext(), original.ID, svc.ReversalRequest{})
+	again := service.Reverse(testContext(), original.ID, svc.ReversalRequest{})
+
+	// Assert
+	if !first.Success {
+		t.Fatalf("Expected first refund to succeed, got failure: %s", first.Message)
+	}
+	if second.Status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d for a refund over the remaining amount, got %d", http.StatusUnprocessableEntity, second.Status)
+	}
+	if !rest.Success || !rest.Data.(*svc.TransferReceipt).Transaction.Amount.Equal(decimal.NewFromFloat(40.0)) {
+		t.Errorf("Expected the remaining 40 to be refunded, got %+v", rest)
+	}
+	if again.Status != http.StatusConflict {
+		t.Errorf("Expected status %d once fully reversed, got %d", http.StatusConflict, again.Status)
+	}
+	if !accountRepo.accounts[1].Balance.Equal(decimal.NewFromFloat(1000.0)) {
+		t.Errorf("Expected source balance restored to 1000, got %s", accountRepo.accounts[1].Balance)
+	}
+}
+
+func TestReverse_OnlyTransfers(t *testing.T) {
+	// Arrange
+	service, _, transactionRepo, _ := newTestTransactionService()
+	transfer := service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(100.0)})
+	original := transfer.Data.(*svc.TransferReceipt).Transaction
+	ten := decimal.NewFromFloat(10.0)
+	refund := service.Reverse(testContext(), original.ID, svc.ReversalRequest{Amount: &ten})
+	reversal := refund.Data.(*svc.TransferReceipt).Transaction
+
+	// Act
+	result := service.Reverse(testContext(), reversal.ID, svc.ReversalRequest{})
+
+	// Assert
+	if result.Status != http.StatusUnprocessableEntity || result.ErrorCode() != svc.CodeReversalNotAllowed {
+		t.Fatalf("Expected 422 %s for reversing a reversal, got %d %s", svc.CodeReversalNotAllowed, result.Status, result.ErrorCode())
+	}
+	if len(transactionRepo.transactions) != 2 {
+		t.Errorf("Expected only the transfer and its refund to be logged, got %d", len(transactionRepo.transactions))
+	}
+}
+
+func TestReverse_InsufficientBalance(t *testing.T) {
+	// Arrange
+	service, accountRepo, _, uow := newTestTransactionService()
+	transfer := service.Transfer(testContext(), model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(100.0)})
+	original := transfer.Data.(*svc.TransferReceipt).Transaction
+	accountRepo.accounts[2].Balance = decimal.NewFromFloat(10.0)
+
+	// Act
+	result := service.Reverse(testContext(), original.ID, svc.ReversalRequest{})
+
+	// Assert
+	if result.Success {
+		t.Fatal("Expected failure when the original destination cannot pay the refund, got success")
+	}
+	if result.Status != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, result.Status)
+	}
+	if uow.rollbacks != 1 {
+		t.Errorf("Expected 1 rollback, got %d", uow.rollbacks)
+	}
+}
+
+func TestTransferBatch_PassesThroughIntermediateAccount(t *testing.T) {
+	// Arrange
+	service, accountRepo, transactionRepo, uow := newTestTransactionService()
+	accountRepo.accounts[3] = &model.Account{ID: 3, AssetCode: model.DefaultAssetCode, Balance: decimal.Zero}
+	batch := svc.BatchRequest{Legs: []model.Transaction{
+		// Account 3 is empty until the second leg credits it
+		{SourceAccountID: 3, DestinationAccountID: 2, Amount: decimal.NewFromFloat(40.0)},
+		{SourceAccountID: 1, DestinationAccountID: 3, Amount: decimal.NewFromFloat(100.0)},
+	}}
+
+	// Act
+	result := service.TransferBatch(testContext(), batch)
+
+	// Assert
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %s", result.Message)
+	}
+	receipt := result.Data.(*svc.BatchReceipt)
+	if len(receipt.TransactionIDs) != 2 || receipt.TransactionIDs[0] == receipt.TransactionIDs[1] {
+		t.Errorf("Expected one transaction ID per leg, got %v", receipt.TransactionIDs)
+	}
+	if !accountRepo.accounts[3].Balance.Equal(decimal.NewFromFloat(60.0)) {
+		t.Errorf("Expected intermediate balance 60, got %s", accountRepo.accounts[3].Balance)
+	}
+	if len(transactionRepo.postings) != 4 {
+		t.Errorf("Expected 4 postings, got %d", len(transactionRepo.postings))
+	}
+	if uow.commits != 1 {
+		t.Errorf("Expected 1 commit, got %d", uow.commits)
+	}
+}
+
+func TestTransferBatch_FailingLegRollsBackBatch(t *testing.T) {
+	// Arrange
+	service, accountRepo, transactionRepo, uow := newTestTransactionService()
+	batch := svc.BatchRequest{Legs: []model.Transaction{
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(100.0)},
+		{SourceAccountID: 2, DestinationAccountID: 999, Amount: decimal.NewFromFloat(10.0)},
+	}}
+
+	// Act
+	result := service.TransferBatch(testContext(), batch)
+
+	// Assert
+	if result.Success {
+		t.Fatal("Expected failure, got success")
+	}
+	if result.Status != http.StatusNotFound || result.Message != "Leg 1: Destination account not found" {
+		t.Errorf("Expected a leg-indexed 404, got %d %q", result.Status, result.Message)
+	}
+	if !accountRepo.accounts[1].Balance.Equal(decimal.NewFromFloat(1000.0)) {
+		t.Errorf("Expected source balance unchanged, got %s", accountRepo.accounts[1].Balance)
+	}
+	if len(transactionRepo.transactions) != 0 {
+		t.Errorf("Expected no transactions, got %d", len(transactionRepo.transactions))
+	}
+	if uow.rollbacks != 1 {
+		t.Errorf("Expected 1 rollback, got %d", uow.rollbacks)
+	}
+}
+
+func TestTransferBatch_NetInsufficientBalance(t *testing.T) {
+	// Arrange
+	service, _, _, _ := newTestTransactionService()
+	batch := svc.BatchRequest{Legs: []model.Transaction{
+		{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromFloat(300.0)},
+		{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromFloat(300.0)},
+	}}
+
+	// Act
+	result := service.TransferBatch(testContext(), batch)
+
+	// Assert
+	if result.Success {
+		t.Fatal("Expected failure, got success")
+	}
+	if result.Message != "Leg 0: Insufficient balance" {
+		t.Errorf("Expected a leg-indexed insufficient balance error, got %q", result.Message)
+	}
+}
+
 func TestTransferValidation_SameAccounts(t *testing.T) {
 	// This test focuses on the validation logic that happens at the beginning of the Transfer method
 	// We'll test the business rule: source and destination accounts cannot be the same